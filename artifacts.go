@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeArtifact writes something extracted from the input (a GIF frame, a
+// bit plane, trailing data) to the -artifacts directory, returning its
+// path. A name used twice in a run gets a numeric suffix, so one input's
+// artifacts never overwrite another's.
+func writeArtifact(opts *Options, name string, data []byte) (string, error) {
+	if opts.artifacts == nil {
+		opts.artifacts = make(map[string]int)
+	}
+	opts.artifacts[name]++
+	if n := opts.artifacts[name]; n > 1 {
+		ext := filepath.Ext(name)
+		name = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(name, ext), n, ext)
+	}
+	path := filepath.Join(opts.ArtifactDir, name)
+	if err := os.MkdirAll(opts.ArtifactDir, 0o755); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, data, 0o644)
+}
+
+// saveArtifact writes an artifact, when -artifacts is set, and reports where
+func saveArtifact(opts *Options, name string, data []byte) {
+	if opts.ArtifactDir == "" {
+		return
+	}
+	path, err := writeArtifact(opts, name, data)
+	if err != nil {
+		fmt.Printf("    %sCould not save %s: %v%s\n", ColorRed, name, err, ColorReset)
+		return
+	}
+	fmt.Printf("    Saved: %s\n", path)
+}
+
+// savePNGArtifact saves an image artifact as PNG
+func savePNGArtifact(opts *Options, name string, img image.Image) {
+	if opts.ArtifactDir == "" {
+		return
+	}
+	data, err := encodePNG(img)
+	if err != nil {
+		fmt.Printf("    %sCould not encode %s: %v%s\n", ColorRed, name, err, ColorReset)
+		return
+	}
+	saveArtifact(opts, name, data)
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), err
+}
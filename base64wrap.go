@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strings"
+)
+
+var (
+	base64Line  = regexp.MustCompile(`^[A-Za-z0-9+/]+={0,2}$`)
+	mimeHeader  = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*:`)
+	armorMarker = regexp.MustCompile(`^-----[A-Z0-9 ]+-----$`)
+)
+
+// UnwrapBase64 extracts the Base64 body of line-wrapped text such as an
+// email attachment or a PEM-ish blob: header lines before the body
+// (Content-Type:, Proc-Type: and their folded continuations), MIME boundary
+// and -----BEGIN/END----- lines and blank lines are dropped and the body
+// lines joined. ok is false unless every remaining line is Base64, the body
+// decodes and something was actually stripped.
+func UnwrapBase64(s string) (string, bool) {
+	var body []string
+	stripped, inHeader, ended := false, false, false
+	for _, raw := range strings.Split(s, "\n") {
+		line := strings.TrimSpace(raw)
+		folded := line != "" && (raw[0] == ' ' || raw[0] == '\t')
+		switch {
+		case line == "":
+			ended = len(body) > 0
+			inHeader, stripped = false, true
+			continue
+		case armorMarker.MatchString(line) || strings.HasPrefix(line, "--"):
+			inHeader, stripped = false, true
+			continue
+		case len(body) == 0 && (mimeHeader.MatchString(line) || inHeader && folded):
+			inHeader, stripped = true, true
+			continue
+		}
+		if ended || !base64Line.MatchString(line) {
+			return "", false
+		}
+		// A line ending in padding closes the body
+		if strings.HasSuffix(line, "=") {
+			ended = true
+		}
+		body = append(body, line)
+	}
+	if len(body) == 0 || !stripped && len(body) < 2 {
+		return "", false
+	}
+	joined := strings.Join(body, "")
+	if _, err := base64.StdEncoding.DecodeString(joined); err != nil {
+		return "", false
+	}
+	return joined, true
+}
+
+// decodeBase64 decodes standard Base64, unwrapping line-wrapped input first
+func decodeBase64(s string) ([]byte, error) {
+	if body, ok := UnwrapBase64(s); ok {
+		s = body
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// maxBech32Len bounds the input tried as Bech32; Lightning invoices run to
+// a few thousand characters
+const maxBech32Len = 8192
+
+// Checksum constants of BIP-173 (Bech32) and BIP-350 (Bech32m)
+const (
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+// Bech32 is a decoded Bech32 or Bech32m string
+type Bech32 struct {
+	HRP     string // human-readable part, lower case
+	Data    []byte // 5-bit groups, checksum removed
+	Variant string // "Bech32" or "Bech32m"
+}
+
+// Bech32Info is what a Bech32 string holds, read according to its HRP
+type Bech32Info struct {
+	Kind    string   // e.g. "SegWit v0 P2WPKH", "age recipient"
+	Details []string // lines to print
+	Payload []byte   // the bytes worth analysing further
+}
+
+func bech32Polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := range 5 {
+			if top>>i&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	out := make([]byte, 0, 2*len(hrp)+1)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]>>5)
+	}
+	out = append(out, 0)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]&31)
+	}
+	return out
+}
+
+// DecodeBech32 parses and checksums a Bech32 or Bech32m string. The
+// 90-character limit of BIP-173 is not applied, as Lightning invoices
+// exceed it.
+func DecodeBech32(s string) (*Bech32, error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return nil, errors.New("mixed case")
+	}
+	s = strings.ToLower(s)
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 1 || sep+7 > len(s) {
+		return nil, errors.New("no separator or checksum")
+	}
+	hrp := s[:sep]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return nil, errors.New("invalid character in HRP")
+		}
+	}
+	data := make([]byte, 0, len(s)-sep-1)
+	for i := sep + 1; i < len(s); i++ {
+		v := strings.IndexByte(bech32Charset, s[i])
+		if v < 0 {
+			return nil, fmt.Errorf("invalid character %q", s[i])
+		}
+		data = append(data, byte(v))
+	}
+	b := &Bech32{HRP: hrp, Data: data[:len(data)-6]}
+	switch bech32Polymod(append(bech32HRPExpand(hrp), data...)) {
+	case bech32Const:
+		b.Variant = "Bech32"
+	case bech32mConst:
+		b.Variant = "Bech32m"
+	default:
+		return nil, errors.New("checksum mismatch")
+	}
+	return b, nil
+}
+
+// EncodeBech32 writes 5-bit groups under an HRP with a Bech32 checksum, or
+// a Bech32m one when m is set
+func EncodeBech32(hrp string, data []byte, m bool) string {
+	hrp = strings.ToLower(hrp)
+	values := append(bech32HRPExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	c := uint32(bech32Const)
+	if m {
+		c = bech32mConst
+	}
+	mod := bech32Polymod(values) ^ c
+	var sb strings.Builder
+	sb.WriteString(hrp + "1")
+	for _, v := range data {
+		sb.WriteByte(bech32Charset[v])
+	}
+	for i := range 6 {
+		sb.WriteByte(bech32Charset[mod>>(5*(5-i))&31])
+	}
+	return sb.String()
+}
+
+// convertBits regroups bits, e.g. 5-bit Bech32 groups into bytes. Without
+// padding, leftover bits must be zero and fewer than a group.
+func convertBits(data []byte, from, to uint, pad bool) ([]byte, error) {
+	var acc uint32
+	var bits uint
+	var out []byte
+	maxv := uint32(1)<<to - 1
+	for _, v := range data {
+		if uint32(v)>>from != 0 {
+			return nil, errors.New("value out of range")
+		}
+		acc = acc<<from | uint32(v)
+		bits += from
+		for bits >= to {
+			bits -= to
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(to-bits)&maxv))
+		}
+	} else if bits >= from || acc<<(to-bits)&maxv != 0 {
+		return nil, errors.New("non-zero padding")
+	}
+	return out, nil
+}
+
+// Describe reads the data according to the HRP: SegWit addresses, age
+// keys, Nostr keys and Lightning invoices are recognised, anything else is
+// taken as plain bytes
+func (b *Bech32) Describe() (*Bech32Info, error) {
+	switch {
+	case b.HRP == "bc" || b.HRP == "tb" || b.HRP == "bcrt" || b.HRP == "ltc" || b.HRP == "tltc":
+		return b.describeSegWit()
+	case strings.HasPrefix(b.HRP, "ln"):
+		return b.describeLightning()
+	}
+	payload, err := convertBits(b.Data, 5, 8, false)
+	if err != nil {
+		return nil, err
+	}
+	info := &Bech32Info{Kind: "data", Payload: payload}
+	switch b.HRP {
+	case "age":
+		info.Kind = "age recipient (X25519 public key)"
+	case "age-secret-key-":
+		info.Kind = "age identity (X25519 private key)"
+	case "npub", "nsec", "note", "nprofile", "nevent":
+		info.Kind = "Nostr " + b.HRP
+	}
+	info.Details = append(info.Details, fmt.Sprintf("Payload: %d bytes", len(payload)))
+	if isPrintable(payload) {
+		info.Details = append(info.Details, fmt.Sprintf("Text: %q", payload))
+	} else {
+		info.Details = append(info.Details, "Hex: "+hex.EncodeToString(payload))
+	}
+	return info, nil
+}
+
+func (b *Bech32) describeSegWit() (*Bech32Info, error) {
+	if len(b.Data) == 0 || b.Data[0] > 16 {
+		return nil, errors.New("no witness version")
+	}
+	version := int(b.Data[0])
+	program, err := convertBits(b.Data[1:], 5, 8, false)
+	if err != nil {
+		return nil, err
+	}
+	want := "Bech32m" // BIP-350: v1 and later
+	if version == 0 {
+		want = "Bech32"
+	}
+	if b.Variant != want {
+		return nil, fmt.Errorf("witness v%d must use %s", version, want)
+	}
+	kind := fmt.Sprintf("SegWit v%d", version)
+	switch {
+	case version == 0 && len(program) == 20:
+		kind += " P2WPKH"
+	case version == 0 && len(program) == 32:
+		kind += " P2WSH"
+	case version == 0:
+		return nil, fmt.Errorf("v0 program of %d bytes", len(program))
+	case version == 1 && len(program) == 32:
+		kind += " Taproot"
+	}
+	return &Bech32Info{
+		Kind:    kind + " address",
+		Details: []string{fmt.Sprintf("Witness program (%d bytes): %s", len(program), hex.EncodeToString(program))},
+		Payload: program,
+	}, nil
+}
+
+// describeLightning reads a BOLT 11 invoice: amount from the HRP, then a
+// timestamp and tagged fields ahead of the 104-group signature. The
+// description is the payload.
+func (b *Bech32) describeLightning() (*Bech32Info, error) {
+	if len(b.Data) < 7+104 {
+		return nil, errors.New("invoice too short")
+	}
+	info := &Bech32Info{Kind: "Lightning invoice"}
+	network, amount := b.HRP[2:], ""
+	if i := strings.IndexAny(network, "0123456789"); i >= 0 {
+		network, amount = network[:i], network[i:]
+	}
+	if amount != "" {
+		info.Details = append(info.Details, "Amount: "+lightningAmount(amount))
+	}
+	info.Details = append(info.Details, "Network: "+network)
+	var ts uint64
+	for _, v := range b.Data[:7] {
+		ts = ts<<5 | uint64(v)
+	}
+	info.Details = append(info.Details, "Timestamp: "+time.Unix(int64(ts), 0).UTC().Format(time.RFC3339))
+
+	fields := b.Data[7 : len(b.Data)-104]
+	for len(fields) >= 3 {
+		tag, n := bech32Charset[fields[0]], int(fields[1])<<5|int(fields[2])
+		if 3+n > len(fields) {
+			return nil, errors.New("truncated tagged field")
+		}
+		raw := fields[3 : 3+n]
+		value, _ := convertBits(raw, 5, 8, true)
+		fields = fields[3+n:]
+		switch tag {
+		case 'd':
+			info.Payload = value[:n*5/8]
+			info.Details = append(info.Details, fmt.Sprintf("Description: %q", info.Payload))
+		case 'p':
+			info.Details = append(info.Details, "Payment hash: "+hex.EncodeToString(value[:min(32, len(value))]))
+		case 'h':
+			info.Details = append(info.Details, "Description hash: "+hex.EncodeToString(value[:min(32, len(value))]))
+		case 'n':
+			info.Details = append(info.Details, "Payee: "+hex.EncodeToString(value[:min(33, len(value))]))
+		case 'x':
+			var secs uint64
+			for _, v := range raw {
+				secs = secs<<5 | uint64(v)
+			}
+			info.Details = append(info.Details, fmt.Sprintf("Expiry: %ds", secs))
+		}
+	}
+	return info, nil
+}
+
+// lightningAmount spells out a BOLT 11 amount: digits and a multiplier
+// (m, u, n or p) in bitcoin
+func lightningAmount(s string) string {
+	units := map[byte]string{'m': "mBTC", 'u': "µBTC", 'n': "nBTC", 'p': "pBTC"}
+	if unit, ok := units[s[len(s)-1]]; ok {
+		return s[:len(s)-1] + " " + unit
+	}
+	if _, err := strconv.Atoi(s); err == nil {
+		return s + " BTC"
+	}
+	return s
+}
+
+// decodeBech32Payload is the Magic operation: the payload of a valid
+// Bech32/Bech32m string
+func decodeBech32Payload(s string) ([]byte, error) {
+	b, err := DecodeBech32(s)
+	if err != nil {
+		return nil, errNoChange
+	}
+	info, err := b.Describe()
+	if err != nil || len(info.Payload) == 0 {
+		return nil, errNoChange
+	}
+	return info.Payload, nil
+}
+
+// bech32Solver prints what a Bech32 string holds
+type bech32Solver struct{}
+
+func (bech32Solver) Name() string { return "Bech32" }
+
+func (bech32Solver) Applicable(l *Layer) bool {
+	return strings.HasPrefix(l.Type, "Bech32")
+}
+
+func (bech32Solver) Solve(ctx context.Context, l *Layer) []Candidate {
+	analyzeBech32(l.Data)
+	return nil
+}
+
+// analyzeBech32 prints what a Bech32 string holds
+func analyzeBech32(data []byte) {
+	b, err := DecodeBech32(strings.TrimSpace(string(data)))
+	if err != nil {
+		fmt.Printf("    %s%v%s\n", ColorYellow, err, ColorReset)
+		return
+	}
+	fmt.Printf("    HRP: %q, %s checksum valid, %d data groups\n", b.HRP, b.Variant, len(b.Data))
+	info, err := b.Describe()
+	if err != nil {
+		fmt.Printf("    %sPayload: %v%s\n", ColorYellow, err, ColorReset)
+		return
+	}
+	fmt.Printf("    Kind: %s\n", info.Kind)
+	for _, d := range info.Details {
+		fmt.Printf("    %s\n", d)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// minBookRefs is the fewest references an input needs to be read as a book
+// cipher
+const minBookRefs = 3
+
+// bookRefPattern matches one reference: 1-3 numbers joined by : . - or /
+var bookRefPattern = regexp.MustCompile(`^\d{1,6}(?:[:./-]\d{1,6}){0,2}$`)
+
+// Book is the key text of a book cipher, split the ways references count:
+// pages (form feeds, or blank-line paragraphs when there are none), lines
+// and words
+type Book struct {
+	PageName string     // "page" or "paragraph"
+	Pages    [][]string // the non-blank lines of each page
+	Lines    []string   // every line, as an editor numbers them
+	Words    []string   // every word, punctuation trimmed
+}
+
+// NewBook splits a key text for book cipher lookups
+func NewBook(text string) *Book {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	b := &Book{PageName: "page", Lines: strings.Split(text, "\n")}
+	var pages []string
+	if strings.Contains(text, "\f") {
+		pages = strings.Split(text, "\f")
+	} else {
+		b.PageName = "paragraph"
+		pages = regexp.MustCompile(`\n[ \t]*\n`).Split(text, -1)
+	}
+	for _, p := range pages {
+		var lines []string
+		for _, l := range strings.Split(p, "\n") {
+			if strings.TrimSpace(l) != "" {
+				lines = append(lines, l)
+			}
+		}
+		if lines != nil {
+			b.Pages = append(b.Pages, lines)
+		}
+	}
+	b.Words = bookWords(text)
+	return b
+}
+
+// LoadBook reads a key text file (-book)
+func LoadBook(path string) (*Book, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewBook(string(data)), nil
+}
+
+// bookWords splits text into words with surrounding punctuation trimmed
+func bookWords(text string) []string {
+	var words []string
+	for _, w := range strings.Fields(text) {
+		w = strings.TrimFunc(w, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		if w != "" {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// ParseBookRefs reads book cipher references: numbers, pairs or triplets
+// (12:3:4, 12.3.4, 12-3-4, 12/3/4) separated by spaces, commas or
+// semicolons, all of the same length
+func ParseBookRefs(s string) ([][]int, bool) {
+	tokens := strings.FieldsFunc(s, func(r rune) bool { return unicode.IsSpace(r) || r == ',' || r == ';' })
+	if len(tokens) < minBookRefs {
+		return nil, false
+	}
+	refs := make([][]int, 0, len(tokens))
+	for _, tok := range tokens {
+		if !bookRefPattern.MatchString(tok) {
+			return nil, false
+		}
+		parts := strings.FieldsFunc(tok, func(r rune) bool { return !unicode.IsDigit(r) })
+		ref := make([]int, len(parts))
+		for i, p := range parts {
+			ref[i], _ = strconv.Atoi(p)
+		}
+		if len(refs) > 0 && len(ref) != len(refs[0]) {
+			return nil, false
+		}
+		refs = append(refs, ref)
+	}
+	return refs, true
+}
+
+// bookScheme is one way of reading a reference against the book
+type bookScheme struct {
+	Name  string
+	Arity int
+	Words bool // output whole words, space separated, rather than letters
+	look  func(b *Book, ref []int) (string, bool)
+}
+
+// at indexes a slice, failing out of range
+func at[T any](s []T, i int) (T, bool) {
+	var zero T
+	if i < 0 || i >= len(s) {
+		return zero, false
+	}
+	return s[i], true
+}
+
+func firstLetter(w string) (string, bool) {
+	for _, r := range w {
+		return string(r), true
+	}
+	return "", false
+}
+
+// lineWord is the n-th word of a line
+func lineWord(line string, n int) (string, bool) {
+	return at(bookWords(line), n)
+}
+
+// bookSchemes are the common reference conventions; indexes are given
+// 0-based here and shifted by the base being tried
+var bookSchemes = []bookScheme{
+	{Name: "word (first letter)", Arity: 1, look: func(b *Book, r []int) (string, bool) {
+		w, ok := at(b.Words, r[0])
+		if !ok {
+			return "", false
+		}
+		return firstLetter(w)
+	}},
+	{Name: "line:word (first letter)", Arity: 2, look: func(b *Book, r []int) (string, bool) {
+		l, ok := at(b.Lines, r[0])
+		if w, ok2 := lineWord(l, r[1]); ok && ok2 {
+			return firstLetter(w)
+		}
+		return "", false
+	}},
+	{Name: "line:word", Arity: 2, Words: true, look: func(b *Book, r []int) (string, bool) {
+		l, ok := at(b.Lines, r[0])
+		if !ok {
+			return "", false
+		}
+		return lineWord(l, r[1])
+	}},
+	{Name: "word:letter", Arity: 2, look: func(b *Book, r []int) (string, bool) {
+		w, ok := at(b.Words, r[0])
+		if !ok {
+			return "", false
+		}
+		c, ok := at([]rune(w), r[1])
+		return string(c), ok
+	}},
+	{Name: "line:char", Arity: 2, look: func(b *Book, r []int) (string, bool) {
+		l, ok := at(b.Lines, r[0])
+		if !ok {
+			return "", false
+		}
+		c, ok := at([]rune(l), r[1])
+		return string(c), ok && !unicode.IsSpace(c)
+	}},
+	{Name: "page:line:word (first letter)", Arity: 3, look: func(b *Book, r []int) (string, bool) {
+		if w, ok := pageWord(b, r); ok {
+			return firstLetter(w)
+		}
+		return "", false
+	}},
+	{Name: "page:line:word", Arity: 3, Words: true, look: pageWord},
+	{Name: "line:word:letter", Arity: 3, look: func(b *Book, r []int) (string, bool) {
+		l, ok := at(b.Lines, r[0])
+		if !ok {
+			return "", false
+		}
+		w, ok := lineWord(l, r[1])
+		if !ok {
+			return "", false
+		}
+		c, ok := at([]rune(w), r[2])
+		return string(c), ok
+	}},
+}
+
+// pageWord is the Ottendorf lookup: page (or paragraph), line, word
+func pageWord(b *Book, r []int) (string, bool) {
+	page, ok := at(b.Pages, r[0])
+	if !ok {
+		return "", false
+	}
+	l, ok := at(page, r[1])
+	if !ok {
+		return "", false
+	}
+	return lineWord(l, r[2])
+}
+
+// BookReading is the text one scheme and indexing base give
+type BookReading struct {
+	Scheme string
+	Text   string
+	Score  float64
+}
+
+// ReadBook decodes references with every scheme of their arity, 1- and
+// 0-based, keeping the readings where every reference resolves, best
+// first (a flag beats any score)
+func ReadBook(b *Book, refs [][]int) []BookReading {
+	var readings []BookReading
+	for _, s := range bookSchemes {
+		if s.Arity != len(refs[0]) {
+			continue
+		}
+		for _, base := range []int{1, 0} {
+			parts := make([]string, 0, len(refs))
+			for _, ref := range refs {
+				shifted := make([]int, len(ref))
+				for i, n := range ref {
+					shifted[i] = n - base
+				}
+				part, ok := s.look(b, shifted)
+				if !ok {
+					break
+				}
+				parts = append(parts, part)
+			}
+			if len(parts) < len(refs) {
+				continue
+			}
+			sep := ""
+			if s.Words {
+				sep = " "
+			}
+			name := strings.ReplaceAll(s.Name, "page", b.PageName)
+			text := strings.Join(parts, sep)
+			score := magicScore([]byte(text))
+			if hasFlag([]byte(text)) {
+				score += 1000
+			}
+			readings = append(readings, BookReading{Scheme: fmt.Sprintf("%s, %d-based", name, base), Text: text, Score: score})
+		}
+	}
+	sort.SliceStable(readings, func(i, j int) bool { return readings[i].Score > readings[j].Score })
+	return readings
+}
+
+// maxBookReadings is how many readings are printed
+const maxBookReadings = 3
+
+// analyzeBook decodes book cipher references against the -book text and
+// continues with the best reading
+func analyzeBook(ctx context.Context, data []byte, refs [][]int, opts *Options, chain []string) (string, bool) {
+	fmt.Printf("    Type: %sBook Cipher References (%d, %d numbers each)%s\n", ColorCyan, len(refs), len(refs[0]), ColorReset)
+	opts.progress.Layer(chain, "Book Cipher References", data)
+	fmt.Printf("%s[+] Book Cipher:%s\n", ColorBlue, ColorReset)
+	readings := ReadBook(opts.Book, refs)
+	if len(readings) == 0 {
+		fmt.Printf("    %sNo indexing scheme resolves every reference in the book.%s\n", ColorYellow, ColorReset)
+		return string(data), false
+	}
+	for _, r := range readings[:min(len(readings), maxBookReadings)] {
+		fmt.Printf("    %s: %q\n", r.Scheme, r.Text)
+	}
+	best := readings[0]
+	fmt.Printf("    %sBest: %s%s\n", ColorGreen, best.Scheme, ColorReset)
+	return orchestrate(ctx, []byte(best.Text), opts, withStep(chain, "Book Cipher ("+best.Scheme+")"))
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// runContext bounds one whole analysis by opts.Timeout. Every layer, solver
+// and network call below it stops once the context is done.
+func runContext(parent context.Context, opts *Options) (context.Context, context.CancelFunc) {
+	if opts.Timeout > 0 {
+		return context.WithTimeoutCause(parent, opts.Timeout, fmt.Errorf("-timeout %s reached", opts.Timeout))
+	}
+	return context.WithCancel(parent)
+}
+
+// attackContext bounds a single wordlist attack by opts.AttackTimeout, so one
+// slow KDF cannot use up the time left for the rest of the analysis
+func attackContext(ctx context.Context, opts *Options) (context.Context, context.CancelFunc) {
+	if opts.AttackTimeout > 0 {
+		return context.WithTimeoutCause(ctx, opts.AttackTimeout, fmt.Errorf("-attack-timeout %s reached", opts.AttackTimeout))
+	}
+	return context.WithCancel(ctx)
+}
+
+// reportStopped prints why an attack ended before exhausting the wordlist,
+// and reports whether it did
+func reportStopped(ctx context.Context) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	fmt.Printf("    %sAttack stopped before the wordlist was exhausted: %v.%s\n", ColorYellow, context.Cause(ctx), ColorReset)
+	return true
+}
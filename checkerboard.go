@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// minCheckerboardDigits is the shortest digit text tried as a straddling
+// checkerboard; shorter text has too few letters to score
+const minCheckerboardDigits = 20
+
+// maxCheckerboardKeywords bounds the wordlist entries tried as keywords
+const maxCheckerboardKeywords = 5000
+
+// The English score a plaintext needs for its board to count as found.
+// Some board reads random digits as English-looking letters, the more
+// easily the shorter they are: the best random score is about 20 / digits.
+// A crib already rules most boards out, so only the floor applies.
+const (
+	checkerboardNoise = 20.0
+	checkerboardFloor = 0.15
+)
+
+// checkerboardFrequent are the eight most frequent letters ("a sin to err"),
+// put on the top row so they take one digit
+const checkerboardFrequent = "ETAONRIS"
+
+// Checkerboard is a straddling checkerboard: the top row has eight symbols
+// under single digits, and its two blank columns head the rows reached with
+// two digits. The alphabet lists the 28 symbols row by row; '/' shifts to
+// and from figures.
+type Checkerboard struct {
+	Alphabet string
+	Blanks   [2]int
+	top      [10]byte
+	rows     [2][10]byte
+}
+
+// NewCheckerboard lays out a 28-symbol alphabet with blank columns b1 < b2
+func NewCheckerboard(alphabet string, b1, b2 int) (*Checkerboard, error) {
+	alphabet = strings.ToUpper(alphabet)
+	if len(alphabet) != 28 {
+		return nil, fmt.Errorf("alphabet needs 28 symbols, got %d", len(alphabet))
+	}
+	if b1 < 0 || b2 > 9 || b1 >= b2 {
+		return nil, fmt.Errorf("blank columns must be two different digits, got %d and %d", b1, b2)
+	}
+	c := &Checkerboard{Alphabet: alphabet, Blanks: [2]int{b1, b2}}
+	seen := make(map[byte]bool)
+	for i := 0; i < len(alphabet); i++ {
+		if seen[alphabet[i]] {
+			return nil, fmt.Errorf("symbol %q repeats", alphabet[i])
+		}
+		seen[alphabet[i]] = true
+	}
+	i := 0
+	for col := range 10 {
+		if col != b1 && col != b2 {
+			c.top[col] = alphabet[i]
+			i++
+		}
+	}
+	copy(c.rows[0][:], alphabet[8:18])
+	copy(c.rows[1][:], alphabet[18:28])
+	return c, nil
+}
+
+// code is the digit string for one symbol
+func (c *Checkerboard) code(sym byte) (string, bool) {
+	for col, s := range c.top {
+		if s == sym {
+			return strconv.Itoa(col), true
+		}
+	}
+	for r, row := range c.rows {
+		for col, s := range row {
+			if s == sym {
+				return fmt.Sprintf("%d%d", c.Blanks[r], col), true
+			}
+		}
+	}
+	return "", false
+}
+
+// Decode reads digits (other characters ignored) through the board. Between
+// figure shifts digits stand for themselves. It fails on a dangling row
+// digit or a figure shift that is never closed.
+func (c *Checkerboard) Decode(s string) (string, bool) {
+	var digits []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			digits = append(digits, s[i])
+		}
+	}
+	shift, _ := c.code('/')
+	var sb strings.Builder
+	figures := false
+	for i := 0; i < len(digits); i++ {
+		if figures {
+			if strings.HasPrefix(string(digits[i:]), shift) {
+				figures = false
+				i += len(shift) - 1
+			} else {
+				sb.WriteByte(digits[i])
+			}
+			continue
+		}
+		d := int(digits[i] - '0')
+		sym := c.top[d]
+		if sym == 0 {
+			if i+1 == len(digits) {
+				return "", false
+			}
+			r := 0
+			if d == c.Blanks[1] {
+				r = 1
+			}
+			i++
+			sym = c.rows[r][digits[i]-'0']
+		}
+		if sym == '/' {
+			figures = true
+			continue
+		}
+		sb.WriteByte(sym)
+	}
+	return sb.String(), !figures
+}
+
+// Encode writes text through the board: letters and '.' by their codes,
+// digits between figure shifts, anything else dropped
+func (c *Checkerboard) Encode(text string) string {
+	var sb strings.Builder
+	shift, _ := c.code('/')
+	figures := false
+	for _, r := range strings.ToUpper(text) {
+		if unicode.IsDigit(r) && r < unicode.MaxASCII {
+			if !figures {
+				sb.WriteString(shift)
+				figures = true
+			}
+			sb.WriteRune(r)
+			continue
+		}
+		if r >= unicode.MaxASCII || r == '/' {
+			continue
+		}
+		code, ok := c.code(byte(r))
+		if !ok {
+			continue
+		}
+		if figures {
+			sb.WriteString(shift)
+			figures = false
+		}
+		sb.WriteString(code)
+	}
+	if figures {
+		sb.WriteString(shift)
+	}
+	return sb.String()
+}
+
+// Key is the board as a recipe argument: alphabet, then the blank columns
+func (c *Checkerboard) Key() string {
+	return fmt.Sprintf("%s,%d%d", c.Alphabet, c.Blanks[0], c.Blanks[1])
+}
+
+// keywordAlphabet is the keyword's distinct letters followed by the rest
+// of A-Z
+func keywordAlphabet(keyword string) string {
+	var sb strings.Builder
+	seen := make(map[rune]bool)
+	for _, r := range strings.ToUpper(keyword) + "ABCDEFGHIJKLMNOPQRSTUVWXYZ" {
+		if r >= 'A' && r <= 'Z' && !seen[r] {
+			seen[r] = true
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// frequentFirst moves the "a sin to err" letters to the front, keeping
+// the order of the alphabet
+func frequentFirst(alphabet string) string {
+	var top, rest strings.Builder
+	for _, r := range alphabet {
+		if strings.ContainsRune(checkerboardFrequent, r) {
+			top.WriteRune(r)
+		} else {
+			rest.WriteRune(r)
+		}
+	}
+	return top.String() + rest.String()
+}
+
+// ParseCheckerboardKey reads "ALPHABET,B1B2": a full 28-symbol alphabet, or
+// a keyword whose mixed alphabet is followed by '.' and '/'
+func ParseCheckerboardKey(arg string) (*Checkerboard, error) {
+	alphabet, blanks, ok := strings.Cut(arg, ",")
+	if !ok || len(blanks) != 2 || blanks[0] < '0' || blanks[0] > '9' || blanks[1] < '0' || blanks[1] > '9' {
+		return nil, fmt.Errorf("key must be ALPHABET,B1B2 (e.g. ETAONRISBCDFGHJKLMPQ/UVWXYZ.,26), got %q", arg)
+	}
+	if len(alphabet) != 28 {
+		if !isAlphaKey(alphabet) {
+			return nil, fmt.Errorf("%q is neither a 28-symbol alphabet nor a keyword", alphabet)
+		}
+		alphabet = keywordAlphabet(alphabet) + "./"
+	}
+	return NewCheckerboard(alphabet, int(blanks[0]-'0'), int(blanks[1]-'0'))
+}
+
+// checkerboardLayouts are the alphabets tried: the textbook board, then
+// for every keyword its mixed alphabet as is and with the frequent letters
+// moved up
+func checkerboardLayouts(keywords []string) []string {
+	layouts := []string{
+		"ETAONRISBCDFGHJKLMPQ/UVWXYZ.", // the textbook "ET AON RIS" board
+		frequentFirst(keywordAlphabet("")) + "./",
+	}
+	seen := map[string]bool{layouts[0]: true, layouts[1]: true}
+	add := func(a string) {
+		if !seen[a] {
+			seen[a] = true
+			layouts = append(layouts, a)
+		}
+	}
+	add(keywordAlphabet("") + "./")
+	tried := 0
+	for _, kw := range keywords {
+		if tried == maxCheckerboardKeywords {
+			break
+		}
+		if !isAlphaKey(kw) {
+			continue
+		}
+		tried++
+		mixed := keywordAlphabet(kw)
+		add(mixed + "./")
+		add(frequentFirst(mixed) + "./")
+	}
+	return layouts
+}
+
+// isDigitText reports text of digits and separators only, with enough
+// digits to be a checkerboard message
+func isDigitText(s string) bool {
+	digits := 0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case !unicode.IsSpace(r):
+			return false
+		}
+	}
+	return digits >= minCheckerboardDigits
+}
+
+// SolveCheckerboard tries every layout and pair of blank columns on digit
+// text, keeping the plaintext that scores as most English; with a crib,
+// only plaintexts holding it count. It returns the best board, if any,
+// and whether its plaintext scores clearly above what random digits reach.
+func SolveCheckerboard(input string, keywords []string, crib string) (string, *Checkerboard, bool) {
+	if !isDigitText(input) {
+		return "", nil, false
+	}
+	bestRes, bestScore := "", 0.0
+	var best *Checkerboard
+	digits := 0
+	for _, alphabet := range checkerboardLayouts(keywords) {
+		for b1 := 0; b1 < 10; b1++ {
+			for b2 := b1 + 1; b2 < 10; b2++ {
+				c, err := NewCheckerboard(alphabet, b1, b2)
+				if err != nil {
+					continue
+				}
+				out, ok := c.Decode(input)
+				if !ok || len(out) < 3 || (crib != "" && !containsCrib(out, crib)) {
+					continue
+				}
+				if score := englishScore(out); best == nil || score > bestScore {
+					bestRes, bestScore, best = out, score, c
+				}
+			}
+		}
+	}
+	if best == nil {
+		return "", nil, false
+	}
+	for _, r := range input {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	threshold := checkerboardFloor
+	if crib == "" {
+		threshold = max(threshold, checkerboardNoise/float64(digits))
+	}
+	return bestRes, best, bestScore >= threshold
+}
+
+// checkerboardSolver reads digit-only text through textbook and
+// keyword-derived boards
+type checkerboardSolver struct{}
+
+func (checkerboardSolver) Name() string { return "Straddling Checkerboard" }
+
+func (checkerboardSolver) Applicable(l *Layer) bool { return isDigitText(string(l.Data)) }
+
+func (checkerboardSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	res, board, confident := SolveCheckerboard(string(l.Data), l.Opts.Wordlist, l.Opts.Crib)
+	switch {
+	case confident:
+		return []Candidate{{
+			Step:    "Straddling Checkerboard (" + board.Key() + ")",
+			Data:    []byte(res),
+			Details: []string{fmt.Sprintf("Board: %s, blanks %d and %d", board.Alphabet, board.Blanks[0], board.Blanks[1]), "Recipe: checkerboard:" + board.Key()},
+		}}
+	case board != nil:
+		fmt.Printf("    %sBest guess, not conclusive at this length (try -crib): checkerboard:%s%s\n", ColorYellow, board.Key(), ColorReset)
+		fmt.Printf("    Decoded: %s\n", res)
+	default:
+		fmt.Printf("    %sNo board layout decodes the digits.%s\n", ColorYellow, ColorReset)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardTool is a command reading or writing the system clipboard
+type clipboardTool struct {
+	name string
+	args []string
+}
+
+// clipboardTools lists the paste and copy commands to try, best first
+func clipboardTools() (pasters, copiers []clipboardTool) {
+	switch runtime.GOOS {
+	case "darwin":
+		return []clipboardTool{{"pbpaste", nil}}, []clipboardTool{{"pbcopy", nil}}
+	case "windows":
+		return []clipboardTool{{"powershell", []string{"-NoProfile", "-Command", "Get-Clipboard -Raw"}}},
+			[]clipboardTool{{"clip", nil}}
+	}
+	pasters = []clipboardTool{
+		{"xclip", []string{"-selection", "clipboard", "-o"}},
+		{"xsel", []string{"--clipboard", "--output"}},
+	}
+	copiers = []clipboardTool{
+		{"xclip", []string{"-selection", "clipboard"}},
+		{"xsel", []string{"--clipboard", "--input"}},
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		pasters = append([]clipboardTool{{"wl-paste", []string{"--no-newline"}}}, pasters...)
+		copiers = append([]clipboardTool{{"wl-copy", nil}}, copiers...)
+	}
+	return pasters, copiers
+}
+
+var errNoClipboard = errors.New("no clipboard tool found (install wl-clipboard, xclip or xsel)")
+
+// ReadClipboard returns the clipboard contents via the first available tool
+func ReadClipboard() ([]byte, error) {
+	pasters, _ := clipboardTools()
+	for _, tool := range pasters {
+		if _, err := exec.LookPath(tool.name); err != nil {
+			continue
+		}
+		return exec.Command(tool.name, tool.args...).Output()
+	}
+	return nil, errNoClipboard
+}
+
+// WriteClipboard replaces the clipboard contents via the first available tool
+func WriteClipboard(text string) error {
+	_, copiers := clipboardTools()
+	for _, tool := range copiers {
+		if _, err := exec.LookPath(tool.name); err != nil {
+			continue
+		}
+		cmd := exec.Command(tool.name, tool.args...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+	return errNoClipboard
+}
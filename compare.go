@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math/bits"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Bounds on the compare subcommand's work and output
+const (
+	compareMinRun    = 4    // shorter shared runs are coincidence
+	compareRuns      = 5    // common substrings listed
+	compareRanges    = 16   // differing-byte ranges listed
+	compareMaxSearch = 8192 // bytes of each input searched for common substrings
+	comparePreview   = 64   // bytes of the XOR shown
+)
+
+// CommonRun is a byte run found in both inputs
+type CommonRun struct {
+	A, B int // offsets in each input
+	Len  int
+}
+
+// Comparison is what comparing two inputs found. Position by position
+// measures cover the shorter input's length.
+type Comparison struct {
+	LenA, LenB int
+	Hamming    int      // differing bits
+	DiffBytes  int      // differing bytes
+	Diffs      [][2]int // half-open offset ranges of differing bytes
+	Runs       []CommonRun
+	XOR        []byte
+	Period     int // smallest period of the XOR, 0 when it has none
+}
+
+// Common is the number of bytes compared position by position
+func (c *Comparison) Common() int {
+	return len(c.XOR)
+}
+
+// CompareInputs measures how two inputs differ and what they share
+func CompareInputs(a, b []byte) *Comparison {
+	n := min(len(a), len(b))
+	c := &Comparison{LenA: len(a), LenB: len(b), XOR: make([]byte, n)}
+	for i := 0; i < n; i++ {
+		c.XOR[i] = a[i] ^ b[i]
+		if c.XOR[i] == 0 {
+			continue
+		}
+		c.Hamming += bits.OnesCount8(c.XOR[i])
+		c.DiffBytes++
+		if k := len(c.Diffs); k > 0 && c.Diffs[k-1][1] == i {
+			c.Diffs[k-1][1] = i + 1
+		} else {
+			c.Diffs = append(c.Diffs, [2]int{i, i + 1})
+		}
+	}
+	c.Runs = CommonRuns(a[:min(len(a), compareMaxSearch)], b[:min(len(b), compareMaxSearch)], compareMinRun, compareRuns)
+	c.Period = smallestPeriod(c.XOR)
+	return c
+}
+
+// CommonRuns returns up to n of the longest byte runs shared by a and b,
+// no two overlapping in either input. Every alignment of the two is
+// scanned, so a run is found wherever it sits in each.
+func CommonRuns(a, b []byte, minLen, n int) []CommonRun {
+	var runs []CommonRun
+	keep := func() {
+		// Only the longest runs can be chosen; drop the rest as they pile up
+		if len(runs) > 64*n {
+			sortRuns(runs)
+			runs = runs[:16*n]
+		}
+	}
+	for d := -(len(b) - 1); d < len(a); d++ { // d = offset in a - offset in b
+		start := -1
+		for i, j := max(d, 0), max(-d, 0); i <= len(a) && j <= len(b); i, j = i+1, j+1 {
+			if i < len(a) && j < len(b) && a[i] == b[j] {
+				if start < 0 {
+					start = i
+				}
+				continue
+			}
+			if start >= 0 && i-start >= minLen {
+				runs = append(runs, CommonRun{A: start, B: start - d, Len: i - start})
+				keep()
+			}
+			start = -1
+		}
+	}
+	sortRuns(runs)
+
+	var chosen []CommonRun
+	for _, r := range runs {
+		if len(chosen) == n {
+			break
+		}
+		overlaps := false
+		for _, c := range chosen {
+			if r.A < c.A+c.Len && c.A < r.A+r.Len || r.B < c.B+c.Len && c.B < r.B+r.Len {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			chosen = append(chosen, r)
+		}
+	}
+	return chosen
+}
+
+func sortRuns(runs []CommonRun) {
+	sort.SliceStable(runs, func(i, j int) bool {
+		if runs[i].Len != runs[j].Len {
+			return runs[i].Len > runs[j].Len
+		}
+		return runs[i].A < runs[j].A
+	})
+}
+
+// smallestPeriod returns the shortest p such that x repeats every p bytes
+// at least twice over, or 0
+func smallestPeriod(x []byte) int {
+	for p := 1; p <= len(x)/2; p++ {
+		if bytes.Equal(x[p:], x[:len(x)-p]) {
+			return p
+		}
+	}
+	return 0
+}
+
+// letterShift returns the Caesar shift taking a's letters to b's when every
+// letter moves by the same amount and every other byte is unchanged
+func letterShift(a, b []byte) (int, bool) {
+	shift, letters := -1, 0
+	for i := range min(len(a), len(b)) {
+		x, y := a[i]|0x20, b[i]|0x20
+		xLetter, yLetter := x >= 'a' && x <= 'z', y >= 'a' && y <= 'z'
+		switch {
+		case !xLetter && !yLetter:
+			if a[i] != b[i] {
+				return 0, false
+			}
+		case xLetter != yLetter:
+			return 0, false
+		default:
+			s := int(y-x+26) % 26
+			if shift >= 0 && s != shift {
+				return 0, false
+			}
+			shift = s
+			letters++
+		}
+	}
+	return shift, letters > 0 && shift > 0
+}
+
+// textXOR reports an XOR shaped like two ASCII texts XORed together: no
+// high bits anywhere, and letters wherever a space met a letter
+func textXOR(x []byte) bool {
+	if len(x) < 16 {
+		return false
+	}
+	letters := 0
+	for _, b := range x {
+		if b >= 0x80 {
+			return false
+		}
+		if (b|0x20) >= 'a' && (b|0x20) <= 'z' {
+			letters++
+		}
+	}
+	return letters*10 >= len(x)
+}
+
+// Relationships interprets the comparison: what the two inputs could be
+// to each other. a and b are the compared inputs.
+func (c *Comparison) Relationships(a, b []byte) []string {
+	var found []string
+	n := c.Common()
+	switch {
+	case n == 0:
+		return nil
+	case c.DiffBytes == 0 && c.LenA == c.LenB:
+		return []string{"Identical inputs"}
+	case c.DiffBytes == 0:
+		return []string{fmt.Sprintf("The shorter input is a prefix of the longer (%d bytes)", n)}
+	case c.Period == 1:
+		found = append(found, fmt.Sprintf("XOR is the constant 0x%02x: the same plaintext under single-byte XOR keys differing by 0x%02x", c.XOR[0], c.XOR[0]))
+	case c.Period > 1:
+		found = append(found, fmt.Sprintf("XOR repeats every %d bytes (%x): the same plaintext under repeating-key XOR, the keys differing by that pattern",
+			c.Period, c.XOR[:c.Period]))
+	}
+	if shift, ok := letterShift(a, b); ok {
+		found = append(found, fmt.Sprintf("Every letter moves by %d (ROT%d): the same plaintext under Caesar shifts differing by %d", shift, shift, shift))
+	} else if delta := b[0] - a[0]; delta != 0 {
+		constant := true
+		for i := 1; i < n && constant; i++ {
+			constant = b[i]-a[i] == delta
+		}
+		if constant {
+			found = append(found, fmt.Sprintf("B is A plus %d mod 256 throughout: the same plaintext under byte-shift keys differing by %d", delta, delta))
+		}
+	}
+	switch {
+	case c.Period != 0:
+	case looksLikeText([][]byte{c.XOR}):
+		found = append(found, "XOR reads as text: one input may be the plaintext of the other, the XOR its key")
+	case textXOR(c.XOR):
+		found = append(found, "XOR looks like two texts XORed: two plaintexts under the same keystream (two-time pad; crib-drag, or give both to the multi-input attacks)")
+	}
+	if hasFlag(c.XOR) {
+		found = append(found, "XOR contains a flag")
+	}
+	return found
+}
+
+func runCompare(args []string) {
+	var specs []inputSpec
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	fs.Var(inputFlag{&specs, false}, "t", "Text input (give two)")
+	fs.Var(inputFlag{&specs, true}, "f", "File input (give two)")
+	raw := fs.Bool("raw", false, "Compare the bytes as given instead of undoing hex or Base64 armor first")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: ./cipher-sleuth compare [-raw] (-t <text> | -f <file>) (-t <text> | -f <file>)")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if len(specs) != 2 || fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	var inputs [2][]byte
+	var names [2]string
+	for i, spec := range specs {
+		names[i] = "-t"
+		data := []byte(spec.value)
+		if spec.file {
+			names[i] = spec.value
+			var err error
+			if data, err = os.ReadFile(spec.value); err != nil {
+				fmt.Printf("%sError reading file: %v%s\n", ColorRed, err, ColorReset)
+				os.Exit(1)
+			}
+		}
+		if data = trimInput(data); !*raw {
+			data = ciphertextBytes(data)
+		}
+		inputs[i] = data
+	}
+	a, b := inputs[0], inputs[1]
+	c := CompareInputs(a, b)
+
+	fmt.Printf("%s[+] Compare: A = %s (%d bytes), B = %s (%d bytes)%s\n", ColorBlue, names[0], len(a), names[1], len(b), ColorReset)
+	n := c.Common()
+	if n == 0 {
+		fmt.Printf("    %sAn input is empty: nothing to compare.%s\n", ColorYellow, ColorReset)
+		return
+	}
+
+	fmt.Printf("%s[+] Hamming Distance:%s\n", ColorBlue, ColorReset)
+	fmt.Printf("    %d bits over %d bytes (%.1f%% of bits; unrelated data ~50%%), %d bytes differ\n",
+		c.Hamming, n, 100*float64(c.Hamming)/float64(8*n), c.DiffBytes)
+	if len(a) != len(b) {
+		fmt.Printf("    Lengths differ by %d bytes; the first %d are compared position by position\n", max(len(a), len(b))-n, n)
+	}
+
+	if len(c.Diffs) > 0 {
+		ranges := "ranges"
+		if len(c.Diffs) == 1 {
+			ranges = "range"
+		}
+		fmt.Printf("%s[+] Differing Bytes (%d in %d %s):%s\n", ColorBlue, c.DiffBytes, len(c.Diffs), ranges, ColorReset)
+		for i, r := range c.Diffs {
+			if i == compareRanges {
+				fmt.Printf("    ... %d more ranges\n", len(c.Diffs)-i)
+				break
+			}
+			if r[1]-r[0] == 1 {
+				fmt.Printf("    0x%04x\n", r[0])
+			} else {
+				fmt.Printf("    0x%04x-0x%04x (%d)\n", r[0], r[1]-1, r[1]-r[0])
+			}
+		}
+	}
+
+	fmt.Printf("%s[+] Longest Common Substrings:%s\n", ColorBlue, ColorReset)
+	if len(c.Runs) == 0 {
+		fmt.Printf("    %sNo shared run of %d bytes or more.%s\n", ColorYellow, compareMinRun, ColorReset)
+	}
+	for _, r := range c.Runs {
+		run := a[r.A : r.A+r.Len]
+		fmt.Printf("    %d bytes at A+0x%04x, B+0x%04x: %s\n", r.Len, r.A, r.B, renderGutter(run[:min(len(run), comparePreview)]))
+	}
+	if len(a) > compareMaxSearch || len(b) > compareMaxSearch {
+		fmt.Printf("    (searched the first %d bytes of each input)\n", compareMaxSearch)
+	}
+
+	fmt.Printf("%s[+] XOR (%d bytes):%s\n", ColorBlue, n, ColorReset)
+	shown := c.XOR[:min(n, comparePreview)]
+	fmt.Printf("    Hex: %x%s\n", shown, strings.Repeat(".", 3*min(1, n-len(shown))))
+	fmt.Printf("    Text: %s\n", renderGutter(shown))
+
+	fmt.Printf("%s[+] Relationship:%s\n", ColorBlue, ColorReset)
+	found := c.Relationships(a, b)
+	if len(found) == 0 {
+		fmt.Printf("    %sNo simple relationship found.%s\n", ColorYellow, ColorReset)
+	}
+	for _, f := range found {
+		fmt.Printf("    %s%s%s\n", ColorGreen, f, ColorReset)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// crypt(3) base64 alphabet, little-endian 6-bit groups
+const cryptB64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+const (
+	shaCryptDefaultRounds = 5000
+	shaCryptMinRounds     = 1000
+	shaCryptMaxRounds     = 999999999
+)
+
+func cryptEncode(sb *strings.Builder, b2, b1, b0 byte, n int) {
+	v := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+	for ; n > 0; n-- {
+		sb.WriteByte(cryptB64[v&0x3F])
+		v >>= 6
+	}
+}
+
+// MD5Crypt implements the FreeBSD "$1$" scheme, also used by Apache as
+// "$apr1$" with a different magic
+func MD5Crypt(password, salt, magic string) string {
+	if i := strings.IndexByte(salt, '$'); i >= 0 {
+		salt = salt[:i]
+	}
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	pw := []byte(password)
+
+	alt := md5.New()
+	alt.Write(pw)
+	alt.Write([]byte(salt))
+	alt.Write(pw)
+	altSum := alt.Sum(nil)
+
+	h := md5.New()
+	h.Write(pw)
+	h.Write([]byte(magic))
+	h.Write([]byte(salt))
+	for i := len(pw); i > 0; i -= 16 {
+		h.Write(altSum[:min(i, 16)])
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write([]byte{0})
+		} else {
+			h.Write(pw[:1])
+		}
+	}
+	final := h.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		h := md5.New()
+		if i&1 != 0 {
+			h.Write(pw)
+		} else {
+			h.Write(final)
+		}
+		if i%3 != 0 {
+			h.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			h.Write(pw)
+		}
+		if i&1 != 0 {
+			h.Write(final)
+		} else {
+			h.Write(pw)
+		}
+		final = h.Sum(final[:0])
+	}
+
+	var sb strings.Builder
+	sb.WriteString(magic + salt + "$")
+	cryptEncode(&sb, final[0], final[6], final[12], 4)
+	cryptEncode(&sb, final[1], final[7], final[13], 4)
+	cryptEncode(&sb, final[2], final[8], final[14], 4)
+	cryptEncode(&sb, final[3], final[9], final[15], 4)
+	cryptEncode(&sb, final[4], final[10], final[5], 4)
+	cryptEncode(&sb, 0, 0, final[11], 2)
+	return sb.String()
+}
+
+// Byte orders used when encoding SHA-crypt digests
+var (
+	sha256CryptOrder = [][3]int{
+		{0, 10, 20}, {21, 1, 11}, {12, 22, 2}, {3, 13, 23}, {24, 4, 14},
+		{15, 25, 5}, {6, 16, 26}, {27, 7, 17}, {18, 28, 8}, {9, 19, 29},
+	}
+	sha512CryptOrder = [][3]int{
+		{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4},
+		{47, 5, 26}, {6, 27, 48}, {28, 49, 7}, {50, 8, 29}, {9, 30, 51},
+		{31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13}, {56, 14, 35},
+		{15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19},
+		{62, 20, 41},
+	}
+)
+
+// SHACrypt implements Drepper's "$5$" (SHA-256) and "$6$" (SHA-512) schemes.
+// setting is everything after the prefix: "[rounds=N$]salt[$hash]".
+func SHACrypt(password, setting string, sha512Variant bool) string {
+	newHash, prefix := sha256.New, "$5$"
+	if sha512Variant {
+		newHash, prefix = sha512.New, "$6$"
+	}
+
+	rounds, custom := shaCryptDefaultRounds, false
+	if rest, ok := strings.CutPrefix(setting, "rounds="); ok {
+		if i := strings.IndexByte(rest, '$'); i >= 0 {
+			if n, err := strconv.Atoi(rest[:i]); err == nil {
+				rounds, custom, setting = max(shaCryptMinRounds, min(n, shaCryptMaxRounds)), true, rest[i+1:]
+			}
+		}
+	}
+	salt := setting
+	if i := strings.IndexByte(salt, '$'); i >= 0 {
+		salt = salt[:i]
+	}
+	if len(salt) > 16 {
+		salt = salt[:16]
+	}
+	pw, s := []byte(password), []byte(salt)
+
+	b := newHash()
+	b.Write(pw)
+	b.Write(s)
+	b.Write(pw)
+	bSum := b.Sum(nil)
+
+	a := newHash()
+	a.Write(pw)
+	a.Write(s)
+	i := len(pw)
+	for ; i > len(bSum); i -= len(bSum) {
+		a.Write(bSum)
+	}
+	a.Write(bSum[:i])
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			a.Write(bSum)
+		} else {
+			a.Write(pw)
+		}
+	}
+	c := a.Sum(nil)
+
+	p := repeatDigest(newHash, pw, len(pw), len(pw))
+	sp := repeatDigest(newHash, s, 16+int(c[0]), len(s))
+
+	for r := 0; r < rounds; r++ {
+		h := newHash()
+		if r&1 != 0 {
+			h.Write(p)
+		} else {
+			h.Write(c)
+		}
+		if r%3 != 0 {
+			h.Write(sp)
+		}
+		if r%7 != 0 {
+			h.Write(p)
+		}
+		if r&1 != 0 {
+			h.Write(c)
+		} else {
+			h.Write(p)
+		}
+		c = h.Sum(c[:0])
+	}
+
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	if custom {
+		sb.WriteString("rounds=" + strconv.Itoa(rounds) + "$")
+	}
+	sb.WriteString(salt + "$")
+	if sha512Variant {
+		for _, o := range sha512CryptOrder {
+			cryptEncode(&sb, c[o[0]], c[o[1]], c[o[2]], 4)
+		}
+		cryptEncode(&sb, 0, 0, c[63], 2)
+	} else {
+		for _, o := range sha256CryptOrder {
+			cryptEncode(&sb, c[o[0]], c[o[1]], c[o[2]], 4)
+		}
+		cryptEncode(&sb, 0, c[31], c[30], 3)
+	}
+	return sb.String()
+}
+
+// repeatDigest hashes input times copies of data and stretches the digest
+// to size bytes (the P and S sequences of SHA-crypt)
+func repeatDigest(newHash func() hash.Hash, data []byte, times, size int) []byte {
+	h := newHash()
+	for i := 0; i < times; i++ {
+		h.Write(data)
+	}
+	sum := h.Sum(nil)
+	out := make([]byte, 0, size)
+	for len(out) < size {
+		out = append(out, sum[:min(len(sum), size-len(out))]...)
+	}
+	return out
+}
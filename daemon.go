@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DaemonRequest is one analysis job sent to the daemon, as a JSON line
+type DaemonRequest struct {
+	Input   []byte `json:"input"`
+	PerLine bool   `json:"per_line,omitempty"`
+	Online  bool   `json:"online,omitempty"`
+}
+
+// daemonMaxRequest bounds a request line
+const daemonMaxRequest = 64 << 20
+
+// Daemon serves analysis requests over a Unix socket. The wordlist and
+// compiled patterns are loaded once; requests run one at a time because the
+// analysis writes to os.Stdout, which is pointed at the client while it runs.
+type Daemon struct {
+	opts *Options
+	mu   sync.Mutex
+}
+
+// Serve accepts connections until the listener is closed
+func (d *Daemon) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go d.handle(conn)
+	}
+}
+
+func (d *Daemon) handle(conn net.Conn) {
+	defer conn.Close()
+	var req DaemonRequest
+	if err := json.NewDecoder(io.LimitReader(conn, daemonMaxRequest)).Decode(&req); err != nil {
+		fmt.Fprintf(conn, "%sBad request: %v%s\n", ColorRed, err, ColorReset)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	opts := *d.opts
+	opts.flagsSeen, opts.flags = nil, nil
+	opts.PerLine = req.PerLine
+	opts.Online = opts.Online || req.Online
+
+	start := time.Now()
+	captureStdout(conn, func() {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Printf("%sInternal error: %v%s\n", ColorRed, r, ColorReset)
+			}
+		}()
+		ctx, cancel := runContext(context.Background(), &opts)
+		defer cancel()
+		orchestrate(ctx, trimInput(req.Input), &opts, nil)
+		if ctx.Err() != nil {
+			fmt.Printf("\n%s[!] Analysis stopped early: %v%s\n", ColorYellow, context.Cause(ctx), ColorReset)
+		}
+	})
+	fmt.Printf("[daemon] %d-byte request served in %s\n", len(req.Input), time.Since(start).Round(time.Millisecond))
+}
+
+// captureStdout runs fn with os.Stdout redirected to w
+func captureStdout(w io.Writer, fn func()) {
+	r, pw, err := os.Pipe()
+	if err != nil {
+		fmt.Fprintf(w, "%sError: %v%s\n", ColorRed, err, ColorReset)
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		io.Copy(w, r)
+		r.Close()
+		close(done)
+	}()
+
+	orig := os.Stdout
+	os.Stdout = pw
+	defer func() {
+		os.Stdout = orig
+		pw.Close()
+		<-done
+	}()
+	fn()
+}
+
+// RunDaemon listens on socketPath until interrupted, removing the socket on
+// exit. A stale socket left by a crashed daemon is replaced.
+func RunDaemon(socketPath string, opts *Options) error {
+	if conn, err := net.Dial("unix", socketPath); err == nil {
+		conn.Close()
+		return fmt.Errorf("a daemon is already listening on %s", socketPath)
+	}
+	os.Remove(socketPath)
+
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socketPath)
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		l.Close()
+		return err
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		l.Close()
+	}()
+
+	fmt.Printf("%s[+] Daemon listening on %s (Ctrl+C to stop)%s\n", ColorBlue, socketPath, ColorReset)
+	return (&Daemon{opts: opts}).Serve(l)
+}
+
+// runClient implements "cipher-sleuth client <socket>": it sends one input
+// to a running daemon and streams the analysis back
+func runClient(args []string) {
+	fs := flag.NewFlagSet("client", flag.ExitOnError)
+	textInput := fs.String("t", "", "Text input to analyze")
+	fileInput := fs.String("f", "", "File input to analyze")
+	perLine := fs.Bool("per-line", false, "Analyze every line separately")
+	online := fs.Bool("online", false, "Enable active online lookups")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: ./cipher-sleuth client [-t <text> | -f <file>] <socket>  (or pipe input)")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	var input []byte
+	var err error
+	switch {
+	case *textInput != "":
+		input = []byte(*textInput)
+	case *fileInput != "":
+		input, err = os.ReadFile(*fileInput)
+	default:
+		input, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		fmt.Printf("%sError reading input: %v%s\n", ColorRed, err, ColorReset)
+		os.Exit(1)
+	}
+
+	conn, err := net.Dial("unix", fs.Arg(0))
+	if err != nil {
+		fmt.Printf("%sError connecting to daemon: %v%s\n", ColorRed, err, ColorReset)
+		os.Exit(1)
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(DaemonRequest{Input: input, PerLine: *perLine, Online: *online}); err != nil {
+		fmt.Printf("%sError sending request: %v%s\n", ColorRed, err, ColorReset)
+		os.Exit(1)
+	}
+	io.Copy(os.Stdout, conn)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// minEmojiSymbols is the fewest emoji an input needs to be treated as
+// emoji text
+const minEmojiSymbols = 4
+
+// isEmojiBase reports runes that start an emoji: the pictograph, symbol,
+// dingbat and enclosed-letter blocks
+func isEmojiBase(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF, // pictographs, emoticons, enclosed supplements, flags
+		r >= 0x2600 && r <= 0x27BF, // symbols, dingbats
+		r >= 0x2300 && r <= 0x23FF, // technical (⌚, ⏰)
+		r >= 0x2B00 && r <= 0x2BFF, // arrows and shapes (⬛, ⭐)
+		r >= 0x2190 && r <= 0x21FF, // arrows
+		r >= 0x25A0 && r <= 0x25FF, // geometric shapes (▶)
+		r >= 0x2460 && r <= 0x24FF, // enclosed alphanumerics (Ⓜ)
+		r >= 0x2100 && r <= 0x214F, // letterlike (™, ℹ)
+		r == 0x00A9, r == 0x00AE, r == 0x203C, r == 0x2049, r == 0x3030, r == 0x303D, r == 0x3297, r == 0x3299:
+		return true
+	}
+	return false
+}
+
+// isEmojiModifier reports runes that attach to the emoji before them:
+// variation selectors, skin tones, the keycap and tag characters
+func isEmojiModifier(r rune) bool {
+	return r == 0xFE0E || r == 0xFE0F || r == 0x20E3 || (r >= 0x1F3FB && r <= 0x1F3FF) || (r >= 0xE0020 && r <= 0xE007F)
+}
+
+// EmojiSymbols splits emoji-only text into emoji (joined sequences, skin
+// tones and keycaps kept whole) and whitespace. It fails on any other
+// character or when there are too few emoji.
+func EmojiSymbols(s string) ([]string, bool) {
+	runes := []rune(s)
+	var symbols []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			symbols = append(symbols, string(cur))
+			cur = nil
+		}
+	}
+	count, join := 0, false
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+			symbols = append(symbols, string(r))
+		case len(cur) > 0 && isEmojiModifier(r):
+			cur = append(cur, r)
+		case len(cur) > 0 && r == 0x200D: // zero-width joiner
+			cur = append(cur, r)
+			join = true
+		case join && isEmojiBase(r):
+			cur = append(cur, r)
+			join = false
+		case isEmojiBase(r) || isKeycap(runes[i:]):
+			flush()
+			cur = []rune{r}
+			count++
+		default:
+			return nil, false
+		}
+	}
+	flush()
+	return symbols, count >= minEmojiSymbols
+}
+
+// isKeycap reports a digit, # or * starting a keycap sequence (1️⃣)
+func isKeycap(runes []rune) bool {
+	if !strings.ContainsRune("0123456789#*", runes[0]) || len(runes) < 2 {
+		return false
+	}
+	if runes[1] == 0xFE0F && len(runes) > 2 {
+		return runes[2] == 0x20E3
+	}
+	return runes[1] == 0x20E3
+}
+
+// emojiLetter reads a letter emoji: regional indicators (🇦), squared and
+// circled letters (🅰, 🄰, 🅐, Ⓐ, ⓐ, 🄐) and keycap digits
+func emojiLetter(symbol string) (string, bool) {
+	runes := []rune(strings.NewReplacer("\uFE0F", "", "\uFE0E", "").Replace(symbol))
+	if len(runes) == 2 && runes[1] == 0x20E3 {
+		return string(runes[0]), true
+	}
+	if len(runes) != 1 {
+		return "", false
+	}
+	r := runes[0]
+	for _, base := range []rune{0x1F1E6, 0x1F170, 0x1F130, 0x1F150, 0x1F110, 0x24B6} {
+		if r >= base && r < base+26 {
+			return string('A' + r - base), true
+		}
+	}
+	switch {
+	case r >= 0x24D0 && r <= 0x24E9:
+		return string('a' + r - 0x24D0), true
+	case r == 0x1F51F: // 🔟
+		return "10", true
+	case r == 0x2139: // ℹ
+		return "i", true
+	}
+	return "", false
+}
+
+// EmojiLetters spells out text written entirely in letter emoji
+func EmojiLetters(symbols []string) (string, bool) {
+	var sb strings.Builder
+	for _, s := range symbols {
+		if strings.TrimSpace(s) == "" {
+			sb.WriteString(s)
+			continue
+		}
+		letter, ok := emojiLetter(s)
+		if !ok {
+			return "", false
+		}
+		sb.WriteString(letter)
+	}
+	return sb.String(), true
+}
+
+// EmojiIsomorph substitutes one letter per distinct emoji, in order of
+// first appearance, keeping the whitespace. The result is a monoalphabetic
+// substitution of the hidden text, so it fails above 26 distinct emoji.
+func EmojiIsomorph(symbols []string) (string, []string, bool) {
+	letters := make(map[string]byte)
+	var key []string
+	var sb strings.Builder
+	for _, s := range symbols {
+		if strings.TrimSpace(s) == "" {
+			sb.WriteString(s)
+			continue
+		}
+		l, ok := letters[s]
+		if !ok {
+			if len(key) == 26 {
+				return "", nil, false
+			}
+			l = byte('a' + len(key))
+			letters[s] = l
+			key = append(key, s)
+		}
+		sb.WriteByte(l)
+	}
+	return sb.String(), key, true
+}
+
+// looksEcoji reports emoji text shaped like Ecoji output: an unbroken run of
+// 4 emoji per 5 bytes, usually ending in the ☕ padding, or using more
+// emoji than a letter substitution could
+func looksEcoji(symbols []string) bool {
+	distinct := make(map[string]bool)
+	for _, s := range symbols {
+		if strings.TrimSpace(s) == "" {
+			return false
+		}
+		distinct[s] = true
+	}
+	return len(symbols)%4 == 0 && (strings.HasPrefix(symbols[len(symbols)-1], "☕") || len(distinct) > 26)
+}
+
+// analyzeEmoji decodes emoji-only text: letter emoji are spelled out, other
+// emoji become one letter each so the substitution analysis can take over.
+// Ecoji needs its 1024-emoji table and is only pointed out.
+func analyzeEmoji(ctx context.Context, data []byte, symbols []string, opts *Options, chain []string) (string, bool) {
+	key := make(map[string]bool)
+	for _, s := range symbols {
+		if strings.TrimSpace(s) != "" {
+			key[s] = true
+		}
+	}
+	fmt.Printf("    Type: %sEmoji Text (%d distinct)%s\n", ColorCyan, len(key), ColorReset)
+	opts.progress.Layer(chain, "Emoji Text", data)
+
+	if text, ok := EmojiLetters(symbols); ok {
+		fmt.Printf("    Letter emoji: %q\n", text)
+		return orchestrate(ctx, []byte(text), opts, withStep(chain, "Emoji Letters"))
+	}
+	if looksEcoji(symbols) {
+		fmt.Printf("    %sLooks like Ecoji (4 emoji per 5 bytes): decode with `ecoji -d` (https://github.com/keith-turner/ecoji).%s\n", ColorYellow, ColorReset)
+	}
+	text, order, ok := EmojiIsomorph(symbols)
+	if !ok {
+		fmt.Printf("    %sMore than 26 distinct emoji: not a letter substitution.%s\n", ColorYellow, ColorReset)
+		return string(data), false
+	}
+	pairs := make([]string, len(order))
+	for i, s := range order {
+		pairs[i] = fmt.Sprintf("%s=%c", s, 'a'+i)
+	}
+	fmt.Printf("    Substitution: %s\n", strings.Join(pairs, " "))
+	return orchestrate(ctx, []byte(text), opts, withStep(chain, "Emoji Substitution"))
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/big"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// simpleEncodeOp wraps an argument-less forward transform
+func simpleEncodeOp(fn func([]byte) []byte) recipeOp {
+	return recipeOp{build: func(string) (func([]byte) ([]byte, error), error) {
+		return func(data []byte) ([]byte, error) { return fn(data), nil }, nil
+	}}
+}
+
+// encodeOps maps encode step names to forward transforms, the inverses of
+// the recipe operations
+var encodeOps = map[string]recipeOp{
+	"base64":    simpleEncodeOp(func(b []byte) []byte { return []byte(base64.StdEncoding.EncodeToString(b)) }),
+	"base64url": simpleEncodeOp(func(b []byte) []byte { return []byte(base64.RawURLEncoding.EncodeToString(b)) }),
+	"base32":    simpleEncodeOp(func(b []byte) []byte { return []byte(base32.StdEncoding.EncodeToString(b)) }),
+	"base58":    simpleEncodeOp(encodeBase58),
+	"hex":       simpleEncodeOp(func(b []byte) []byte { return []byte(hex.EncodeToString(b)) }),
+	"binary": simpleEncodeOp(func(b []byte) []byte {
+		return joinBytes(b, func(c byte) string { return fmt.Sprintf("%08b", c) })
+	}),
+	"decimal": simpleEncodeOp(func(b []byte) []byte {
+		return joinBytes(b, func(c byte) string { return strconv.Itoa(int(c)) })
+	}),
+	"url_encode": simpleEncodeOp(func(b []byte) []byte { return []byte(url.QueryEscape(string(b))) }),
+	"utf7":       simpleEncodeOp(EncodeUTF7),
+	"rot13":      simpleEncodeOp(func(b []byte) []byte { return []byte(caesarShift(string(b), 13)) }),
+	"reverse":    simpleEncodeOp(func(b []byte) []byte { return []byte(reverseString(string(b))) }),
+	"rot8000":    simpleEncodeOp(func(b []byte) []byte { return []byte(ROT8000(string(b))) }),
+	"base65536":  simpleEncodeOp(func(b []byte) []byte { return []byte(EncodeBase65536(b)) }),
+	"gzip": simpleEncodeOp(func(b []byte) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(b)
+		zw.Close()
+		return buf.Bytes()
+	}),
+	"rot":    recipeOps["rot"],
+	"add":    recipeOps["add"],
+	"affine": recipeOps["affine"],
+	"xor":    recipeOps["xor"],
+	"checkerboard": {arg: "28-symbol alphabet or keyword, then blank columns", build: func(arg string) (func([]byte) ([]byte, error), error) {
+		board, err := ParseCheckerboardKey(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(data []byte) ([]byte, error) { return []byte(board.Encode(string(data))), nil }, nil
+	}},
+	"vigenere": {arg: "alphabetic key", build: func(arg string) (func([]byte) ([]byte, error), error) {
+		if !isAlphaKey(arg) {
+			return nil, fmt.Errorf("key must be letters only, got %q", arg)
+		}
+		return func(data []byte) ([]byte, error) { return []byte(vigenereEncrypt(string(data), arg)), nil }, nil
+	}},
+	"crc32_forge": {arg: "target CRC-32 as 8 hex digits", build: func(arg string) (func([]byte) ([]byte, error), error) {
+		target, ok := ParseCRC32(arg)
+		if !ok {
+			return nil, fmt.Errorf("target must be 8 hex digits, got %q", arg)
+		}
+		return func(data []byte) ([]byte, error) {
+			suffix := ForgeCRC32(data, target, crc32.IEEETable)
+			return append(append([]byte{}, data...), suffix[:]...), nil
+		}, nil
+	}},
+}
+
+func joinBytes(data []byte, format func(byte) string) []byte {
+	parts := make([]string, len(data))
+	for i, c := range data {
+		parts[i] = format(c)
+	}
+	return []byte(strings.Join(parts, " "))
+}
+
+func encodeBase58(data []byte) []byte {
+	n := new(big.Int).SetBytes(data)
+	radix, mod := big.NewInt(58), new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append(out, '1')
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// ParseEncodeRecipe parses "op | op:arg | ..." into forward transforms
+func ParseEncodeRecipe(recipe string) ([]RecipeStep, error) {
+	return parseSteps(recipe, encodeOps)
+}
+
+// runEncode implements "cipher-sleuth encode <pipeline>": it applies the
+// forward transforms in order and writes the raw result to stdout, so the
+// output can be piped or redirected into a challenge file
+func runEncode(args []string) {
+	fs := flag.NewFlagSet("encode", flag.ExitOnError)
+	textInput := fs.String("t", "", "Text input to encode")
+	fileInput := fs.String("f", "", "File input to encode")
+	verbose := fs.Bool("v", false, "Print every intermediate step to stderr")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: ./cipher-sleuth encode [-t <text> | -f <file>] \"<op> | <op:arg> | ...\"  (or pipe input)")
+		fmt.Fprintf(fs.Output(), "Operations: %s\n", strings.Join(operationNames(encodeOps), ", "))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	steps, err := ParseEncodeRecipe(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sInvalid pipeline: %v%s\n", ColorRed, err, ColorReset)
+		os.Exit(1)
+	}
+
+	var data []byte
+	switch {
+	case *textInput != "":
+		data = []byte(*textInput)
+	case *fileInput != "":
+		data, err = os.ReadFile(*fileInput)
+	default:
+		data, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError reading input: %v%s\n", ColorRed, err, ColorReset)
+		os.Exit(1)
+	}
+
+	for i, step := range steps {
+		if data, err = step.Apply(data); err != nil {
+			fmt.Fprintf(os.Stderr, "%sStep %d (%s) failed: %v%s\n", ColorRed, i+1, step.Label(), err, ColorReset)
+			os.Exit(1)
+		}
+		if *verbose {
+			fmt.Fprintf(os.Stderr, "%d. %s: %s\n", i+1, step.Label(), displayData(data))
+		}
+	}
+	os.Stdout.Write(data)
+	if isPrintable(data) {
+		fmt.Println()
+	}
+}
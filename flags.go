@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// flagScanXORLimit bounds the inputs brute-forced with every XOR key while
+// scanning; larger layers are only scanned as-is and through the decoders
+const flagScanXORLimit = 64 * 1024
+
+// withStep returns a copy of chain with one more operation appended, so
+// sibling branches never share a backing array
+func withStep(chain []string, step string) []string {
+	out := make([]string, len(chain), len(chain)+1)
+	copy(out, chain)
+	return append(out, step)
+}
+
+func formatChain(chain []string) string {
+	if len(chain) == 0 {
+		return "(input)"
+	}
+	return strings.Join(chain, " -> ")
+}
+
+// reportFlags prints every flag in data not reported before, together with
+// the chain of operations that produced it. Matches failing a validator
+// are reported once as rejected.
+func reportFlags(opts *Options, data []byte, chain []string) {
+	if opts.flagsSeen == nil {
+		opts.flagsSeen = make(map[string]bool)
+	}
+	for _, match := range Config.FlagPattern.FindAll(data, -1) {
+		flag := string(match)
+		if opts.flagsSeen[flag] {
+			continue
+		}
+		opts.flagsSeen[flag] = true
+		if err := validateFlag(flag); err != nil {
+			fmt.Printf("    %sRejected flag-like %s: %v%s\n", ColorYellow, flag, err, ColorReset)
+			continue
+		}
+		opts.flags = append(opts.flags, flag)
+		fmt.Printf("%s[!] Flag found: %s%s\n", ColorGreen, flag, ColorReset)
+		fmt.Printf("    Chain: %s\n", formatChain(chain))
+	}
+}
+
+// scanForFlags checks a layer and the raw output of every cheap transform,
+// printable or not, so flags surface even when no solver accepts the output
+func scanForFlags(data []byte, opts *Options, chain []string) {
+	reportFlags(opts, data, chain)
+	s := strings.TrimSpace(string(data))
+
+	try := func(step string, out []byte, err error) {
+		if err == nil && len(out) > 0 {
+			reportFlags(opts, out, withStep(chain, step))
+		}
+	}
+	b, err := decodeBase64(s)
+	try("Base64", b, err)
+	b, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	try("Base64 (URL-safe)", b, err)
+	b, err = hex.DecodeString(s)
+	try("Hex", b, err)
+	b, err = base32.StdEncoding.DecodeString(s)
+	try("Base32", b, err)
+	if u, err := url.QueryUnescape(s); err == nil && u != s {
+		try("URL Encoding", []byte(u), nil)
+	}
+
+	for shift := 1; shift < 26; shift++ {
+		step := fmt.Sprintf("Caesar Cipher (Shift %d)", shift)
+		if shift == 13 {
+			step = "Rot13"
+		}
+		try(step, []byte(caesarShift(s, shift)), nil)
+	}
+	try("Reverse", []byte(reverseString(s)), nil)
+
+	if len(data) <= flagScanXORLimit {
+		out := make([]byte, len(data))
+		for k := 1; k < 256; k++ {
+			for i, c := range data {
+				out[i] = c ^ byte(k)
+			}
+			try(fmt.Sprintf("Single Byte XOR (Key: 0x%02X)", k), out, nil)
+		}
+		for k := 1; k < 256; k++ {
+			for i, c := range data {
+				out[i] = c + byte(k)
+			}
+			try(fmt.Sprintf("Byte Shift (+%d)", k), out, nil)
+		}
+	}
+}
+
+func reverseString(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
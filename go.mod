@@ -1,3 +1,14 @@
 module github.com/byteoverride/cipher-sleuth
 
 go 1.25.6
+
+require (
+	github.com/ProtonMail/go-crypto v1.5.1
+	github.com/ulikunitz/xz v0.5.15
+	golang.org/x/crypto v0.55.0
+)
+
+require (
+	github.com/cloudflare/circl v1.6.3 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+)
@@ -0,0 +1,31 @@
+package main
+
+import "fmt"
+
+// maxRegexInput bounds how much text the pattern-based parsers (RSA
+// parameters, credential dumps) look at; real inputs are far smaller
+const maxRegexInput = 1 << 20
+
+func limitRegexInput(s string) string {
+	if len(s) > maxRegexInput {
+		return s[:maxRegexInput]
+	}
+	return s
+}
+
+// safely runs one solver stage, turning a panic on malformed input into a
+// warning so the remaining stages still run. Returns false if fn panicked.
+func safely(stage string, fn func()) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			reportPanic(stage, r)
+			ok = false
+		}
+	}()
+	fn()
+	return true
+}
+
+func reportPanic(stage string, r any) {
+	fmt.Printf("    %s[!] %s crashed on this input: %v%s\n", ColorRed, stage, r, ColorReset)
+}
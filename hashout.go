@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// maxHashPreview caps how much of an extracted hash is echoed to the terminal.
+// Some formats (LUKS anti-forensic material, 7z streams) run to megabytes.
+const maxHashPreview = 160
+
+// EmitHash prints a crackable hash string for hashcat/john and, when -hash-out
+// is set, appends the full line to that file
+func EmitHash(opts *Options, format string, hash string) {
+	preview := hash
+	if len(preview) > maxHashPreview {
+		preview = fmt.Sprintf("%s... (%d chars)", hash[:maxHashPreview], len(hash))
+	}
+	fmt.Printf("    Hash (%s): %s\n", format, preview)
+
+	if opts.HashOut == "" {
+		if len(hash) > maxHashPreview {
+			fmt.Printf("    Use -hash-out <file> to save the full hash.\n")
+		}
+		return
+	}
+	f, err := os.OpenFile(opts.HashOut, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Printf("    %sError writing hash file: %v%s\n", ColorRed, err, ColorReset)
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, hash)
+	fmt.Printf("    Saved to %s\n", opts.HashOut)
+}
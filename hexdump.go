@@ -0,0 +1,203 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	hexdumpLine   = regexp.MustCompile(`^([0-9a-fA-F]{6,16})(:?)\s+(.*)$`)
+	hexdumpOffset = regexp.MustCompile(`^[0-9a-fA-F]{6,16}$`)
+	hexdumpToken  = regexp.MustCompile(`\S+`)
+)
+
+// dumpLine is one parsed row of a hex dump
+type dumpLine struct {
+	offset string // raw offset digits, base decided once all rows are read
+	data   []byte
+	repeat bool // "*": the previous row repeats until the next offset
+	strong bool // colon or ASCII gutter confirmed this is a dump row
+}
+
+// ReverseHexdump turns xxd, hexdump -C, hexdump and od -x output back into
+// the bytes it shows. Offsets must be contiguous ("*" repeats the previous
+// row) and a trailing offset-only row sets the final length. A lone row is
+// only accepted when a colon or a matching ASCII gutter marks it as a dump.
+func ReverseHexdump(input string) ([]byte, bool) {
+	var rows []dumpLine
+	strong := false
+	for _, line := range strings.Split(input, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case line == "*":
+			if len(rows) == 0 {
+				return nil, false
+			}
+			rows = append(rows, dumpLine{repeat: true})
+		case hexdumpOffset.MatchString(line):
+			rows = append(rows, dumpLine{offset: line})
+		default:
+			row, ok := parseDumpRow(line)
+			if !ok {
+				return nil, false
+			}
+			strong = strong || row.strong
+			rows = append(rows, row)
+		}
+	}
+
+	dataRows := 0
+	for _, row := range rows {
+		if len(row.data) > 0 {
+			dataRows++
+		}
+	}
+	if dataRows == 0 || (!strong && len(rows) < 2) {
+		return nil, false
+	}
+
+	// A "*" row repeats the last one up to the next offset, so a dump can
+	// expand like a compressed stream and is held to the same limits
+	limit := Config.Limits.outputLimit(len(input))
+
+	// hexdump and xxd print hex offsets, od prints octal ones
+	for _, base := range []int{16, 8} {
+		if out, ok := assembleDump(rows, base, limit); ok {
+			return out, true
+		}
+	}
+	return nil, false
+}
+
+// parseDumpRow splits a row into offset, hex columns and ASCII gutter. The
+// gutter is found by trying each split point and keeping the one whose
+// bytes render to the text that follows, so a gutter that happens to look
+// like hex ("cafe") is not mistaken for data.
+func parseDumpRow(line string) (dumpLine, bool) {
+	m := hexdumpLine.FindStringSubmatch(line)
+	if m == nil {
+		return dumpLine{}, false
+	}
+	row := dumpLine{offset: m[1], strong: m[2] == ":"}
+	rest := m[3]
+
+	spans := hexdumpToken.FindAllStringIndex(rest, -1)
+	var groups [][]byte
+	for _, span := range spans {
+		b, ok := decodeDumpGroup(rest[span[0]:span[1]])
+		if !ok {
+			break
+		}
+		groups = append(groups, b)
+	}
+	if len(groups) == 0 {
+		return dumpLine{}, false
+	}
+
+	for k := len(groups); k > 0; k-- {
+		var data []byte
+		for _, g := range groups[:k] {
+			data = append(data, g...)
+		}
+		gutter := strings.TrimSpace(rest[spans[k-1][1]:])
+		if strings.HasPrefix(gutter, "|") && strings.HasSuffix(gutter, "|") && len(gutter) >= 2 {
+			gutter = gutter[1 : len(gutter)-1]
+		}
+		if gutter != "" && strings.TrimSpace(gutter) == strings.TrimSpace(renderGutter(data)) {
+			row.data, row.strong = data, true
+			return row, true
+		}
+	}
+
+	// No gutter: every token must be hex
+	if len(groups) != len(spans) {
+		return dumpLine{}, false
+	}
+	wordSwap := !row.strong
+	for _, g := range groups {
+		row.data = append(row.data, g...)
+		wordSwap = wordSwap && len(g) == 2
+	}
+	if wordSwap {
+		// hexdump / od -x print little-endian 16-bit words
+		for i := 0; i+1 < len(row.data); i += 2 {
+			row.data[i], row.data[i+1] = row.data[i+1], row.data[i]
+		}
+	}
+	return row, true
+}
+
+func decodeDumpGroup(tok string) ([]byte, bool) {
+	if len(tok) < 2 || len(tok)%2 != 0 {
+		return nil, false
+	}
+	out := make([]byte, len(tok)/2)
+	for i := range out {
+		v, err := strconv.ParseUint(tok[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return nil, false
+		}
+		out[i] = byte(v)
+	}
+	return out, true
+}
+
+// renderGutter reproduces the ASCII column xxd and hexdump -C print
+func renderGutter(data []byte) string {
+	out := make([]byte, len(data))
+	for i, b := range data {
+		if b >= 32 && b <= 126 {
+			out[i] = b
+		} else {
+			out[i] = '.'
+		}
+	}
+	return string(out)
+}
+
+// assembleDump concatenates rows, checking offsets in the given base. It
+// gives up on offsets more than limit bytes past the first (-1: no limit).
+func assembleDump(rows []dumpLine, base int, limit int64) ([]byte, bool) {
+	var out, last []byte
+	var start, expected uint64
+	started, repeating := false, false
+	for _, row := range rows {
+		if row.repeat {
+			repeating = true
+			continue
+		}
+		off, err := strconv.ParseUint(row.offset, base, 64)
+		if err != nil {
+			return nil, false
+		}
+		if !started {
+			start, expected, started = off, off, true
+		}
+		if limit >= 0 && off > start && off-start > uint64(limit) {
+			return nil, false
+		}
+		for repeating && expected < off && len(last) > 0 {
+			out = append(out, last...)
+			expected += uint64(len(last))
+		}
+		repeating = false
+
+		if row.data == nil {
+			// Final length marker; trims the padding of an odd last word
+			if off < start || off > expected || expected-off >= 2 {
+				return nil, false
+			}
+			return out[:off-start], true
+		}
+		if off != expected {
+			return nil, false
+		}
+		out = append(out, row.data...)
+		expected += uint64(len(row.data))
+		last = row.data
+	}
+	return out, started
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxIdentifiers    = 8         // checksummed identifiers reported per layer
+	maxIdentifierText = 64 * 1024 // larger layers are not scanned
+)
+
+var (
+	// Card numbers and IMEIs: a bare digit run, digits grouped by spaces or
+	// dashes, or the 2-6-6-1 IMEI layout
+	luhnPattern = regexp.MustCompile(`\b(?:\d{13,19}|\d{4}(?: \d{3,6}){2,4}|\d{4}(?:-\d{3,6}){2,4}|\d{2}-\d{6}-\d{6}-\d)\b`)
+	ibanPattern = regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`)
+	// ISBNs need the ISBN label or the hyphenated group layout; a bare
+	// 13-digit 978/979 run is picked up with the digit runs
+	isbnPattern = regexp.MustCompile(`\bISBN(?:-1[03])?:? ?((?:97[89][- ]?)?\d[\d -]{7,14}[\dX])\b|\b((?:97[89]-)?\d{1,5}-\d{1,7}-\d{1,7}-[\dX])\b`)
+)
+
+// Identifier is a checksummed number found in text
+type Identifier struct {
+	Kind      string // "Card Number", "IMEI", "IBAN", "ISBN-10", "ISBN-13"
+	Value     string // as written
+	Structure string
+	Check     string // the checksum used
+	Valid     bool
+	Expected  string // the check digits that would make it valid
+	Offset    int    // where it starts in the text
+}
+
+// cardNetworks maps issuer number prefixes to card networks, by prefix range
+var cardNetworks = []struct {
+	Name   string
+	Lo, Hi int
+}{
+	{"American Express", 34, 34},
+	{"American Express", 37, 37},
+	{"Visa", 4, 4},
+	{"Mastercard", 51, 55},
+	{"Mastercard", 2221, 2720},
+	{"Discover", 6011, 6011},
+	{"Discover", 644, 649},
+	{"Discover", 65, 65},
+	{"JCB", 3528, 3589},
+	{"Diners Club", 300, 305},
+	{"Diners Club", 36, 36},
+	{"Diners Club", 38, 39},
+	{"UnionPay", 62, 62},
+	{"Maestro", 50, 50},
+	{"Maestro", 56, 58},
+}
+
+// ibanCountries maps IBAN country codes to country names and IBAN lengths
+var ibanCountries = map[string]struct {
+	Name string
+	Len  int
+}{
+	"AD": {"Andorra", 24}, "AE": {"United Arab Emirates", 23}, "AT": {"Austria", 20},
+	"BE": {"Belgium", 16}, "BG": {"Bulgaria", 22}, "BR": {"Brazil", 29},
+	"CH": {"Switzerland", 21}, "CY": {"Cyprus", 28}, "CZ": {"Czechia", 24},
+	"DE": {"Germany", 22}, "DK": {"Denmark", 18}, "EE": {"Estonia", 20},
+	"ES": {"Spain", 24}, "FI": {"Finland", 18}, "FR": {"France", 27},
+	"GB": {"United Kingdom", 22}, "GR": {"Greece", 27}, "HR": {"Croatia", 21},
+	"HU": {"Hungary", 28}, "IE": {"Ireland", 22}, "IL": {"Israel", 23},
+	"IS": {"Iceland", 26}, "IT": {"Italy", 27}, "LI": {"Liechtenstein", 21},
+	"LT": {"Lithuania", 20}, "LU": {"Luxembourg", 20}, "LV": {"Latvia", 21},
+	"MC": {"Monaco", 27}, "MT": {"Malta", 31}, "NL": {"Netherlands", 18},
+	"NO": {"Norway", 15}, "PL": {"Poland", 28}, "PT": {"Portugal", 25},
+	"RO": {"Romania", 24}, "SA": {"Saudi Arabia", 24}, "SE": {"Sweden", 24},
+	"SI": {"Slovenia", 19}, "SK": {"Slovakia", 24}, "SM": {"San Marino", 27},
+	"TR": {"Turkey", 26}, "UA": {"Ukraine", 29},
+}
+
+// LuhnCheckDigit returns the digit that, appended to digits, passes the
+// Luhn check
+func LuhnCheckDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-1-i)%2 == 0 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// Luhn reports whether a digit string passes the Luhn (mod 10) check
+func Luhn(digits string) bool {
+	return len(digits) > 1 && LuhnCheckDigit(digits[:len(digits)-1]) == digits[len(digits)-1]
+}
+
+// ibanRemainder is the IBAN's mod-97 remainder: 1 for a valid IBAN
+func ibanRemainder(iban string) int64 {
+	var num strings.Builder
+	for _, c := range iban[4:] + iban[:4] {
+		if c >= 'A' && c <= 'Z' {
+			num.WriteString(strconv.Itoa(int(c-'A') + 10))
+		} else {
+			num.WriteRune(c)
+		}
+	}
+	n, _ := new(big.Int).SetString(num.String(), 10)
+	return new(big.Int).Mod(n, big.NewInt(97)).Int64()
+}
+
+// ISBN10CheckDigit returns the check character for the first nine digits
+// of an ISBN-10
+func ISBN10CheckDigit(digits string) byte {
+	sum := 0
+	for i := range 9 {
+		sum += (10 - i) * int(digits[i]-'0')
+	}
+	switch c := (11 - sum%11) % 11; c {
+	case 10:
+		return 'X'
+	default:
+		return byte('0' + c)
+	}
+}
+
+// EAN13CheckDigit returns the check digit for the first twelve digits of
+// an EAN-13 (ISBN-13) number
+func EAN13CheckDigit(digits string) byte {
+	sum := 0
+	for i := range 12 {
+		sum += int(digits[i]-'0') * (1 + 2*(i%2))
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func cardNetwork(digits string) string {
+	for _, n := range cardNetworks {
+		width := len(strconv.Itoa(n.Lo))
+		if p, err := strconv.Atoi(digits[:width]); err == nil && p >= n.Lo && p <= n.Hi {
+			return n.Name
+		}
+	}
+	return ""
+}
+
+func stripSeparators(s string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(s)
+}
+
+// isbnIdentifier checks an ISBN given as written; ok is false when it has
+// the wrong number of digits
+func isbnIdentifier(value string) (Identifier, bool) {
+	digits := stripSeparators(value)
+	switch {
+	case len(digits) == 10 && !strings.Contains(digits[:9], "X"):
+		check := ISBN10CheckDigit(digits)
+		isbn13 := "978" + digits[:9]
+		return Identifier{
+			Kind:      "ISBN-10",
+			Value:     value,
+			Structure: fmt.Sprintf("check character %c; as ISBN-13 %s%c", digits[9], isbn13, EAN13CheckDigit(isbn13)),
+			Check:     "mod 11",
+			Valid:     digits[9] == check,
+			Expected:  string(check),
+		}, true
+	case len(digits) == 13 && !strings.Contains(digits, "X") && (strings.HasPrefix(digits, "978") || strings.HasPrefix(digits, "979")):
+		check := EAN13CheckDigit(digits)
+		structure := fmt.Sprintf("prefix %s, check digit %c", digits[:3], digits[12])
+		if strings.HasPrefix(digits, "978") {
+			structure += fmt.Sprintf("; as ISBN-10 %s%c", digits[3:12], ISBN10CheckDigit(digits[3:12]))
+		}
+		return Identifier{
+			Kind:      "ISBN-13",
+			Value:     value,
+			Structure: structure,
+			Check:     "EAN-13",
+			Valid:     digits[12] == check,
+			Expected:  string(check),
+		}, true
+	}
+	return Identifier{}, false
+}
+
+// luhnIdentifier classifies a digit run as a card number or an IMEI; ok is
+// false when it is neither
+func luhnIdentifier(value string) (Identifier, bool) {
+	digits := stripSeparators(value)
+	if len(digits) < 13 || len(digits) > 19 {
+		return Identifier{}, false
+	}
+	if id, ok := isbnIdentifier(value); ok && len(digits) == 13 {
+		return id, true
+	}
+	id := Identifier{
+		Value:    value,
+		Check:    "Luhn",
+		Valid:    Luhn(digits),
+		Expected: string(LuhnCheckDigit(digits[:len(digits)-1])),
+	}
+	last := len(digits) - 1
+	network := cardNetwork(digits)
+	switch {
+	case network != "" && (len(digits) != 15 || network == "American Express"):
+		id.Kind = "Card Number"
+		id.Structure = fmt.Sprintf("%s, %d digits, issuer %s, account %s, check digit %c", network, len(digits), digits[:6], digits[6:last], digits[last])
+	case len(digits) == 15 && (id.Valid || strings.Count(value, "-") == 3):
+		// Unchecked 15-digit runs are too common to call IMEIs
+		id.Kind = "IMEI"
+		id.Structure = fmt.Sprintf("TAC %s, serial %s, check digit %c", digits[:8], digits[8:14], digits[14])
+	default:
+		return Identifier{}, false
+	}
+	return id, true
+}
+
+// FindIdentifiers finds checksummed identifiers in a text layer: card
+// numbers and IMEIs (Luhn), IBANs (mod 97) and ISBNs (mod 11 / EAN-13),
+// each with its structure and whether its check digits hold
+func FindIdentifiers(data []byte) []Identifier {
+	if len(data) > maxIdentifierText || !isPrintable(data) {
+		return nil
+	}
+	text := string(data)
+	var found []Identifier
+	var taken [][]int
+	add := func(id Identifier, span []int) {
+		for _, t := range taken {
+			if span[0] < t[1] && t[0] < span[1] {
+				return
+			}
+		}
+		taken = append(taken, span)
+		id.Offset = span[0]
+		found = append(found, id)
+	}
+
+	for _, m := range isbnPattern.FindAllStringSubmatchIndex(text, -1) {
+		span := m[2:4]
+		if span[0] < 0 {
+			span = m[4:6]
+		}
+		if id, ok := isbnIdentifier(text[span[0]:span[1]]); ok {
+			add(id, m[:2])
+		}
+	}
+	for _, span := range ibanPattern.FindAllStringIndex(text, -1) {
+		value := text[span[0]:span[1]]
+		iban := stripSeparators(value)
+		country, ok := ibanCountries[iban[:2]]
+		if !ok || len(iban) != country.Len {
+			continue
+		}
+		rem := ibanRemainder(iban[:2] + "00" + iban[4:])
+		add(Identifier{
+			Kind:      "IBAN",
+			Value:     value,
+			Structure: fmt.Sprintf("%s, check digits %s, BBAN %s", country.Name, iban[2:4], iban[4:]),
+			Check:     "mod 97",
+			Valid:     ibanRemainder(iban) == 1,
+			Expected:  fmt.Sprintf("%02d", 98-rem),
+		}, span)
+	}
+	for _, span := range luhnPattern.FindAllStringIndex(text, -1) {
+		if id, ok := luhnIdentifier(text[span[0]:span[1]]); ok {
+			add(id, span)
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Offset < found[j].Offset })
+	return found[:min(len(found), maxIdentifiers)]
+}
+
+// reportIdentifiers prints each identifier with its check result
+func reportIdentifiers(ids []Identifier) {
+	for _, id := range ids {
+		fmt.Printf("    %s: %s (%s)\n", id.Kind, id.Value, id.Structure)
+		if id.Valid {
+			fmt.Printf("        %s%s check valid%s\n", ColorGreen, id.Check, ColorReset)
+		} else {
+			fmt.Printf("        %s%s check fails (check digits should be %s)%s\n", ColorYellow, id.Check, id.Expected, ColorReset)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Fixed priority orders so identification never depends on map iteration.
+// Within a digest length the more common algorithm comes first.
+var (
+	hashPriority     = []string{"CRC32", "MD5", "NTLM", "SHA1", "RIPEMD-160", "SHA256", "SHA512", "Bcrypt", "Argon2"}
+	encodingPriority = []string{"Hex", "Base32", "Base64", "Base58", "URL", "UTF-7", "Base65536", "ROT8000"}
+)
+
+// encodingLengthOK applies the length constraints the loose regexes ignore
+func encodingLengthOK(name, s string) bool {
+	switch name {
+	case "Hex":
+		return len(s)%2 == 0
+	case "Base32":
+		return len(s)%8 == 0
+	case "Base64":
+		return len(s)%4 == 0 || !strings.HasSuffix(s, "=")
+	}
+	return true
+}
+
+// encodingOperation maps an encoding name to the Magic operation decoding it
+func encodingOperation(name string) *Operation {
+	if name == "URL" {
+		return OperationByName("URL Encoding")
+	}
+	return OperationByName(name)
+}
+
+// IdentifyType classifies data deterministically: file signatures (longest
+// first), Bech32, otpauth:// URIs, armor and DER, then hashes, encodings
+// that actually decode, and key armor.
+// Overlaps are settled by decoding each candidate and keeping the
+// best-scoring result, so a 32-char hex string is "Hex" when it decodes to
+// text and "MD5" otherwise. The other matching candidates are returned for
+// display.
+func IdentifyType(data []byte) (string, []string) {
+	names := make([]string, 0, len(Config.MagicBytes))
+	for name := range Config.MagicBytes {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := Config.MagicBytes[names[i]], Config.MagicBytes[names[j]]
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		if bytes.HasPrefix(data, Config.MagicBytes[name]) {
+			return fmt.Sprintf("File (%s)", name), nil
+		}
+	}
+
+	// A valid Bech32 checksum is as decisive as a signature
+	if len(data) <= maxBech32Len {
+		if b, err := DecodeBech32(strings.TrimSpace(string(data))); err == nil {
+			kind := "data"
+			if info, err := b.Describe(); err == nil {
+				kind = info.Kind
+			}
+			return fmt.Sprintf("Bech32 (%s)", kind), nil
+		}
+	}
+
+	// An otpauth:// URI is unambiguous once it parses
+	if p, err := ParseOTPAuthURI(string(data)); err == nil {
+		return "OTP Auth URI (" + strings.ToUpper(p.Type) + ")", nil
+	}
+
+	// So are PEM-style armor and a complete DER structure
+	if blocks := FindArmorBlocks(data); len(blocks) > 0 {
+		return identifyArmor(string(data), blocks[0].Label), nil
+	}
+	if kind := DERKind(data); kind != "" {
+		return "ASN.1 DER (" + kind + ")", nil
+	}
+
+	s := string(data)
+	var hashes, encodings []string
+	for _, name := range hashPriority {
+		if re, ok := Config.HashPatterns[name]; ok && re.MatchString(s) {
+			hashes = append(hashes, name)
+		}
+	}
+
+	// Decode trial: candidates must actually decode; the one whose output
+	// scores best wins if it beats the input
+	best, bestScore, bestPrintable := "", magicScore(data), false
+	unwrapped, wrapped := UnwrapBase64(s)
+	for _, name := range encodingPriority {
+		re, ok := EncodingChecks[name]
+		in := s
+		if name == "Base64" && wrapped {
+			in = unwrapped
+		}
+		if !ok || in == "" || !re.MatchString(in) || !encodingLengthOK(name, in) {
+			continue
+		}
+		out, err := encodingOperation(name).Apply(data)
+		if err != nil || len(out) == 0 {
+			continue
+		}
+		encodings = append(encodings, name)
+		if score := magicScore(out); score > bestScore {
+			best, bestScore, bestPrintable = name, score, isPrintable(out)
+		}
+	}
+
+	var candidates []string
+	for _, h := range hashes {
+		candidates = append(candidates, "Hash ("+h+")")
+	}
+	for _, e := range encodings {
+		candidates = append(candidates, "Encoded Text ("+e+"?)")
+	}
+
+	// A hash only loses to an encoding that decodes to printable text
+	var chosen string
+	switch {
+	case best != "" && (len(hashes) == 0 || bestPrintable):
+		chosen = "Encoded Text (" + best + "?)"
+	case len(hashes) > 0:
+		chosen = "Hash (" + hashes[0] + ")"
+	case len(encodings) > 0:
+		chosen = "Encoded Text (" + encodings[0] + "?)"
+	default:
+		return identifyKey(s), nil
+	}
+
+	var others []string
+	for _, c := range candidates {
+		if c != chosen {
+			others = append(others, c)
+		}
+	}
+	return chosen, others
+}
+
+// identifyKey matches PEM / OpenSSH armor, in name order
+func identifyKey(s string) string {
+	keys := make([]string, 0, len(Config.AsymmetricKeys))
+	for name := range Config.AsymmetricKeys {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	for _, name := range keys {
+		if Config.AsymmetricKeys[name].MatchString(s) {
+			return fmt.Sprintf("Key (%s)", name)
+		}
+	}
+	return "Unknown"
+}
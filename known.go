@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// KnownCipher is the cipher and key recovered from a plaintext/ciphertext
+// pair, with the --recipe step that decrypts more of the same ciphertext
+type KnownCipher struct {
+	Cipher string
+	Key    string
+	Recipe string
+	Note   string // caveat about applying the key elsewhere, if any
+}
+
+// errNoKnownCipher means no supported cipher maps the pair
+var errNoKnownCipher = errors.New("no Caesar, Vigenère, substitution or XOR key maps the plaintext to the ciphertext")
+
+// IdentifyKnown works out which cipher and key turn plaintext into
+// ciphertext. The most specific explanation wins: a Caesar shift before a
+// Vigenère key, either before a substitution table, and letter ciphers
+// before XOR. A periodic key must repeat in full within the pair; one that
+// does not is only accepted once nothing more specific fits.
+func IdentifyKnown(plaintext, ciphertext []byte) (*KnownCipher, error) {
+	if len(plaintext) == 0 || len(ciphertext) == 0 {
+		return nil, errors.New("plaintext and ciphertext must not be empty")
+	}
+	pt, ct := []rune(string(plaintext)), []rune(string(ciphertext))
+
+	shifts, letterCipher := letterShifts(pt, ct)
+	var vigenere *KnownCipher
+	if letterCipher && len(shifts) > 0 {
+		period := minimalPeriod(shifts)
+		key := make([]byte, period)
+		for i := range key {
+			key[i] = byte('A' + shifts[i])
+		}
+		switch {
+		case period == 1 && shifts[0] == 0:
+			return nil, errors.New("plaintext and ciphertext are identical")
+		case period == 1:
+			shift := (26 - shifts[0]) % 26
+			return &KnownCipher{Cipher: "Caesar", Key: fmt.Sprintf("shift %d", shifts[0]), Recipe: fmt.Sprintf("rot:%d", shift)}, nil
+		case 2*period <= len(shifts):
+			return &KnownCipher{Cipher: "Vigenère", Key: string(key), Recipe: "vigenere:" + string(key)}, nil
+		}
+		vigenere = &KnownCipher{Cipher: "Vigenère", Key: string(key), Recipe: "vigenere:" + string(key),
+			Note: "the key does not repeat in full within the pair, so it may be longer (or a running key)"}
+	}
+
+	if alphabet, ok := substitutionTable(pt, ct); ok {
+		k := &KnownCipher{Cipher: "Monoalphabetic Substitution", Key: alphabet, Recipe: "substitute:" + alphabet}
+		if strings.Contains(alphabet, "_") {
+			k.Note = "cipher letters missing from the pair decrypt to '_'"
+		}
+		return k, nil
+	}
+
+	n := min(len(plaintext), len(ciphertext))
+	stream := make([]int, n)
+	for i := range stream {
+		stream[i] = int(plaintext[i] ^ ciphertext[i])
+	}
+	period := minimalPeriod(stream)
+	key := make([]byte, period)
+	for i := range key {
+		key[i] = byte(stream[i])
+	}
+	xor := &KnownCipher{Cipher: "XOR", Key: "0x" + hex.EncodeToString(key), Recipe: "xor:0x" + hex.EncodeToString(key)}
+	if period == 1 {
+		xor.Cipher = "Single Byte XOR"
+	}
+	if 2*period <= n {
+		return xor, nil
+	}
+	if vigenere != nil {
+		return vigenere, nil
+	}
+	if len(plaintext) == len(ciphertext) {
+		xor.Cipher = "XOR Keystream"
+		xor.Note = fmt.Sprintf("the keystream does not repeat in full, so it may only decrypt the first %d bytes of other ciphertext", len(key))
+		return xor, nil
+	}
+	return nil, errNoKnownCipher
+}
+
+// IdentifyKnownFiles runs IdentifyKnown on the contents of two files.
+// Only trailing newlines are trimmed, so binary ciphertext stays aligned.
+func IdentifyKnownFiles(plainFile, cipherFile string) (*KnownCipher, error) {
+	plaintext, err := os.ReadFile(plainFile)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := os.ReadFile(cipherFile)
+	if err != nil {
+		return nil, err
+	}
+	return IdentifyKnown(bytes.TrimRight(plaintext, "\r\n"), bytes.TrimRight(ciphertext, "\r\n"))
+}
+
+// letterShifts returns the shift from each plaintext letter to its
+// ciphertext letter, indexed as vigenereDecrypt counts letters. It fails
+// unless the texts match everywhere else and case is preserved.
+func letterShifts(pt, ct []rune) ([]int, bool) {
+	if len(pt) != len(ct) {
+		return nil, false
+	}
+	var shifts []int
+	for i := range pt {
+		p, c := pt[i], ct[i]
+		if !unicode.IsLetter(p) || p > unicode.MaxASCII {
+			if p != c {
+				return nil, false
+			}
+			if unicode.IsLetter(p) {
+				shifts = append(shifts, 0)
+			}
+			continue
+		}
+		if c > unicode.MaxASCII || letterIndex(byte(c)) < 0 || unicode.IsUpper(p) != unicode.IsUpper(c) {
+			return nil, false
+		}
+		shifts = append(shifts, (letterIndex(byte(c))-letterIndex(byte(p))+26)%26)
+	}
+	return shifts, true
+}
+
+// substitutionTable returns the plain letter of every cipher letter A-Z
+// ('_' when the pair never shows it), if one consistent one-to-one table
+// maps the texts, keeping case and leaving everything else alone
+func substitutionTable(pt, ct []rune) (string, bool) {
+	if len(pt) != len(ct) {
+		return "", false
+	}
+	var key, used [26]byte
+	for i := range pt {
+		p, c := pt[i], ct[i]
+		pi, ci := -1, -1
+		if p <= unicode.MaxASCII {
+			pi = letterIndex(byte(p))
+		}
+		if c <= unicode.MaxASCII {
+			ci = letterIndex(byte(c))
+		}
+		if pi < 0 || ci < 0 {
+			if p != c {
+				return "", false
+			}
+			continue
+		}
+		if unicode.IsUpper(p) != unicode.IsUpper(c) {
+			return "", false
+		}
+		plain := byte('a' + pi)
+		if (key[ci] != 0 && key[ci] != plain) || (used[pi] != 0 && used[pi] != byte('A'+ci)) {
+			return "", false
+		}
+		key[ci], used[pi] = plain, byte('A'+ci)
+	}
+
+	alphabet := make([]byte, 26)
+	for i, p := range key {
+		alphabet[i] = '_'
+		if p != 0 {
+			alphabet[i] = p
+		}
+	}
+	return string(alphabet), true
+}
+
+// minimalPeriod is the shortest p with seq[i] == seq[i+p] throughout
+func minimalPeriod(seq []int) int {
+	for p := 1; p < len(seq); p++ {
+		repeats := true
+		for i := 0; i+p < len(seq); i++ {
+			if seq[i] != seq[i+p] {
+				repeats = false
+				break
+			}
+		}
+		if repeats {
+			return p
+		}
+	}
+	return len(seq)
+}
+
+// printKnownCipher reports a recovered cipher and how to reuse it
+func printKnownCipher(k *KnownCipher) {
+	fmt.Printf("%s[+] Known Plaintext:%s\n", ColorBlue, ColorReset)
+	fmt.Printf("    %sCipher: %s%s\n", ColorGreen, k.Cipher, ColorReset)
+	fmt.Printf("    Key: %s\n", k.Key)
+	fmt.Printf("    Recipe: --recipe %q\n", k.Recipe)
+	if k.Note != "" {
+		fmt.Printf("    %sNote: %s.%s\n", ColorYellow, k.Note, ColorReset)
+	}
+}
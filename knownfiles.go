@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// KnownFileSet holds the digests of stock OS and application files (an
+// NSRL-style known-good set), so files extracted from disk images that
+// were never modified can be skipped instead of analysed
+type KnownFileSet struct {
+	names  map[string]string // lower-case hex digest -> file name
+	digest map[int]bool      // digest lengths (hex) present in the set
+}
+
+// sumLine is one line of md5sum/sha1sum/sha256sum output
+var sumLine = regexp.MustCompile(`^([0-9a-fA-F]{32}|[0-9a-fA-F]{40}|[0-9a-fA-F]{64})\s+\*?(.*)$`)
+
+// LoadKnownFiles reads a known-hash set. It understands NSRL RDS CSV
+// exports and hashdeep output (by their headers), md5sum/sha1sum/sha256sum
+// output and bare lists of digests. MD5, SHA1 and SHA256 may be mixed.
+func LoadKnownFiles(path string) (*KnownFileSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	set := &KnownFileSet{names: make(map[string]string), digest: make(map[int]bool)}
+	var columns map[string]int // from a CSV header, when there is one
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, "%%%% HASHDEEP") {
+			continue
+		}
+		// hashdeep announces its columns as "%%%% size,md5,sha256,filename"
+		if header, ok := strings.CutPrefix(text, "%%%% "); ok {
+			columns = knownFileColumns(header)
+			continue
+		}
+		if strings.HasPrefix(text, "%%%%") {
+			continue
+		}
+
+		if m := sumLine.FindStringSubmatch(text); m != nil {
+			set.add(m[1], m[2])
+			continue
+		}
+		fields, err := csv.NewReader(strings.NewReader(text)).Read()
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if columns == nil {
+			if cols := knownFileColumns(text); cols != nil {
+				columns = cols
+				continue
+			}
+		}
+		added := false
+		name := ""
+		if i, ok := columns["name"]; ok && i < len(fields) {
+			name = fields[i]
+		}
+		for _, field := range fields {
+			if isDigest(field) {
+				set.add(field, name)
+				added = true
+			}
+		}
+		if !added {
+			return nil, fmt.Errorf("%s:%d: no MD5, SHA1 or SHA256 digest", path, line)
+		}
+	}
+	return set, scanner.Err()
+}
+
+// knownFileColumns maps the columns of a CSV header naming a file name
+// column, or returns nil when the line is no such header
+func knownFileColumns(header string) map[string]int {
+	fields, err := csv.NewReader(strings.NewReader(header)).Read()
+	if err != nil {
+		return nil
+	}
+	columns := make(map[string]int)
+	for i, f := range fields {
+		switch strings.ToLower(strings.TrimSpace(f)) {
+		case "filename", "file_name", "name":
+			columns["name"] = i
+		case "sha-1", "sha1", "md5", "sha-256", "sha256", "size", "filesize", "crc32":
+			columns[strings.ToLower(f)] = i
+		}
+	}
+	if len(columns) < 2 {
+		return nil
+	}
+	return columns
+}
+
+func isDigest(s string) bool {
+	switch len(s) {
+	case 32, 40, 64:
+		_, err := hex.DecodeString(s)
+		return err == nil
+	}
+	return false
+}
+
+func (s *KnownFileSet) add(digest, name string) {
+	s.names[strings.ToLower(digest)] = name
+	s.digest[len(digest)] = true
+}
+
+// Len is the number of digests in the set
+func (s *KnownFileSet) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.names)
+}
+
+// Lookup reports whether data is a file in the set, describing the match.
+// Only the digest kinds the set holds are computed. A nil set knows nothing.
+func (s *KnownFileSet) Lookup(data []byte) (string, bool) {
+	if s == nil || len(data) == 0 {
+		return "", false
+	}
+	check := func(kind string, sum []byte) (string, bool) {
+		name, ok := s.names[hex.EncodeToString(sum)]
+		if !ok {
+			return "", false
+		}
+		if name == "" {
+			return kind + " match", true
+		}
+		return name + ", " + kind + " match", true
+	}
+	if s.digest[40] {
+		sum := sha1.Sum(data)
+		if desc, ok := check("SHA1", sum[:]); ok {
+			return desc, true
+		}
+	}
+	if s.digest[32] {
+		sum := md5.Sum(data)
+		if desc, ok := check("MD5", sum[:]); ok {
+			return desc, true
+		}
+	}
+	if s.digest[64] {
+		sum := sha256.Sum256(data)
+		if desc, ok := check("SHA256", sum[:]); ok {
+			return desc, true
+		}
+	}
+	return "", false
+}
+
+// skipKnownFile reports an input file found in the known-hash set, which
+// then needs no analysis
+func skipKnownFile(opts *Options, name string, data []byte) bool {
+	desc, ok := opts.KnownFiles.Lookup(data)
+	if ok {
+		fmt.Printf("%s[=] %s: stock OS/application file (%s), skipped.%s\n", ColorYellow, name, desc, ColorReset)
+	}
+	return ok
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DecompressLimits bound what decompression may produce, so nested or
+// high-ratio archives (42.zip style bombs) cannot exhaust memory. A zero
+// value disables that limit.
+type DecompressLimits struct {
+	MaxOutput int64   // largest output of one decompression step
+	MaxRatio  float64 // largest output/input ratio of one step
+	MaxTotal  int64   // total growth across all layers of one analysis
+}
+
+// errDecompressLimit marks a decompression refused by Config.Limits
+var errDecompressLimit = errors.New("decompression limit reached")
+
+// ratioFloor is the output size below which the ratio is not enforced:
+// small outputs are harmless however well they compressed
+const ratioFloor = 1 << 20
+
+// outputLimit is the most one step may produce from inLen input bytes, or
+// -1 when unlimited
+func (l DecompressLimits) outputLimit(inLen int) int64 {
+	limit := int64(-1)
+	if l.MaxOutput > 0 {
+		limit = l.MaxOutput
+	}
+	if l.MaxRatio > 0 {
+		byRatio := max(int64(l.MaxRatio*float64(inLen)), ratioFloor)
+		if limit < 0 || byRatio < limit {
+			limit = byRatio
+		}
+	}
+	return limit
+}
+
+// CheckStep reports whether a step turning inLen bytes into outLen bytes
+// breaks a per-step limit, naming the flag that raises it
+func (l DecompressLimits) CheckStep(inLen int, outLen int64) error {
+	if l.MaxOutput > 0 && outLen > l.MaxOutput {
+		return fmt.Errorf("%w: output exceeds %s (-max-output)", errDecompressLimit, formatSize(l.MaxOutput))
+	}
+	if l.MaxRatio > 0 && outLen > ratioFloor && float64(outLen) > l.MaxRatio*float64(max(inLen, 1)) {
+		return fmt.Errorf("%w: expansion ratio exceeds %g:1 (-max-ratio)", errDecompressLimit, l.MaxRatio)
+	}
+	return nil
+}
+
+// reportDecompressLimits says when a decompressor recognised the layer but
+// its output broke a limit, since the Magic search drops such branches
+// silently
+func reportDecompressLimits(data []byte) {
+	for _, op := range MagicOperations {
+		if _, err := op.Apply(data); errors.Is(err, errDecompressLimit) {
+			fmt.Printf("    %s%s refused: %v%s\n", ColorYellow, op.Name, err, ColorReset)
+		}
+	}
+}
+
+// admitLayer charges a decoded layer's growth over its parent against the
+// run's MaxTotal budget, and reports whether it may still be analysed
+func admitLayer(opts *Options, parentLen, childLen int) bool {
+	grown := int64(childLen - parentLen)
+	if grown <= 0 {
+		return true
+	}
+	if limit := Config.Limits.MaxTotal; limit > 0 && opts.inflated+grown > limit {
+		fmt.Printf("    %sSkipped: %s more would exceed the %s decompression budget (-max-total).%s\n",
+			ColorYellow, formatSize(grown), formatSize(limit), ColorReset)
+		return false
+	}
+	opts.inflated += grown
+	return true
+}
+
+// byteSize is a flag.Value taking sizes like 4096, 512K, 16M or 1G
+type byteSize int64
+
+func (s *byteSize) String() string { return formatSize(int64(*s)) }
+
+func (s *byteSize) Set(value string) error {
+	n, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+	*s = byteSize(n)
+	return nil
+}
+
+func parseSize(value string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(value))
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "B"), "I")
+	shift := 0
+	if v != "" {
+		if i := strings.IndexByte("KMGT", v[len(v)-1]); i >= 0 {
+			shift = 10 * (i + 1)
+			v = v[:len(v)-1]
+		}
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 || n > (1<<62)>>shift {
+		return 0, errors.New("want a size such as 4096, 512K, 16M or 1G")
+	}
+	return n << shift, nil
+}
+
+func formatSize(n int64) string {
+	for _, unit := range []struct {
+		shift  uint
+		suffix string
+	}{{30, "GiB"}, {20, "MiB"}, {10, "KiB"}} {
+		if n >= 1<<unit.shift && n%(1<<unit.shift) == 0 {
+			return fmt.Sprintf("%d %s", n>>unit.shift, unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ulikunitz/xz"
+)
+
+// Magic search bounds
+const (
+	magicDepth     = 5 // operations per chain
+	magicBeamWidth = 8 // nodes kept per level
+	maxBacktrack   = 3 // alternative first steps tried per layer
+	magicMinGain   = 8 // score gain that counts as a find; Rot13 of a hash gains ~5
+
+	// English text averages ~6.5 on the englishFreq scale, Base64/hex ~2-3
+	solvedFreqThreshold = 4.5
+)
+
+// Operation is one decode step the Magic search can apply
+type Operation struct {
+	Name  string
+	Apply func([]byte) ([]byte, error)
+}
+
+// MagicNode is a point in the search tree: data plus the chain producing it
+type MagicNode struct {
+	Data  []byte
+	Chain []string
+	Score float64
+}
+
+var (
+	binaryInput  = regexp.MustCompile(`^[01]{8}(?:[\s,]*[01]{8})*$`)
+	decimalInput = regexp.MustCompile(`^\d{1,3}(?:[\s,]+\d{1,3})+$`)
+	errNoChange  = errors.New("operation does not apply")
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// MagicOperations lists the decode steps explored by the search
+var MagicOperations = []Operation{
+	{"Base64", textOp(decodeBase64)},
+	{"Base64 (URL-safe)", textOp(func(s string) ([]byte, error) {
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	})},
+	{"Base32", textOp(func(s string) ([]byte, error) { return base32.StdEncoding.DecodeString(s) })},
+	{"Base58", textOp(decodeBase58)},
+	{"Hex", textOp(func(s string) ([]byte, error) {
+		return hex.DecodeString(strings.NewReplacer(" ", "", ":", "", "0x", "").Replace(s))
+	})},
+	{"Binary", textOp(decodeBinary)},
+	{"Decimal", textOp(decodeDecimal)},
+	{"URL Encoding", textOp(func(s string) ([]byte, error) {
+		u, err := url.QueryUnescape(s)
+		if err == nil && u == s {
+			err = errNoChange
+		}
+		return []byte(u), err
+	})},
+	{"UTF-7", textOp(DecodeUTF7)},
+	{"Bech32", textOp(decodeBech32Payload)},
+	{"Base65536", func(data []byte) ([]byte, error) { return DecodeBase65536(string(data)) }},
+	{"ROT8000", decodeROT8000},
+	{"Rot13", textOp(func(s string) ([]byte, error) { return []byte(caesarShift(s, 13)), nil })},
+	{"Reverse", textOp(func(s string) ([]byte, error) { return []byte(reverseString(s)), nil })},
+	{"Gunzip", readerOp(func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) })},
+	{"Zlib Inflate", readerOp(func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) })},
+	{"Raw Inflate", readerOp(func(r io.Reader) (io.Reader, error) { return flate.NewReader(r), nil })},
+	{"Bunzip2", readerOp(func(r io.Reader) (io.Reader, error) { return bzip2.NewReader(r), nil })},
+	{"XZ Decompress", readerOp(func(r io.Reader) (io.Reader, error) { return xz.NewReader(r) })},
+}
+
+// textOp wraps a decoder that only makes sense on printable input
+func textOp(decode func(string) ([]byte, error)) func([]byte) ([]byte, error) {
+	return func(data []byte) ([]byte, error) {
+		if !isPrintable(data) {
+			return nil, errNoChange
+		}
+		return decode(strings.TrimSpace(string(data)))
+	}
+}
+
+// readerOp wraps a decompressor, bounding its output by Config.Limits
+func readerOp(open func(io.Reader) (io.Reader, error)) func([]byte) ([]byte, error) {
+	return func(data []byte) ([]byte, error) {
+		r, err := open(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if limit := Config.Limits.outputLimit(len(data)); limit >= 0 {
+			r = io.LimitReader(r, limit+1)
+		}
+		out, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := Config.Limits.CheckStep(len(data), int64(len(out))); err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+}
+
+func decodeBase58(s string) ([]byte, error) {
+	if s == "" {
+		return nil, errNoChange
+	}
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, r := range s {
+		i := strings.IndexRune(base58Alphabet, r)
+		if i < 0 {
+			return nil, errNoChange
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+	leading := len(s) - len(strings.TrimLeft(s, "1"))
+	return append(make([]byte, leading), n.Bytes()...), nil
+}
+
+func decodeBinary(s string) ([]byte, error) {
+	if !binaryInput.MatchString(s) {
+		return nil, errNoChange
+	}
+	bits := strings.Map(func(r rune) rune {
+		if r == '0' || r == '1' {
+			return r
+		}
+		return -1
+	}, s)
+	if len(bits)%8 != 0 {
+		return nil, errNoChange
+	}
+	out := make([]byte, len(bits)/8)
+	for i := range out {
+		v, _ := strconv.ParseUint(bits[i*8:i*8+8], 2, 8)
+		out[i] = byte(v)
+	}
+	return out, nil
+}
+
+func decodeDecimal(s string) ([]byte, error) {
+	if !decimalInput.MatchString(s) {
+		return nil, errNoChange
+	}
+	var out []byte
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		v, err := strconv.Atoi(field)
+		if err != nil || v > 255 {
+			return nil, errNoChange
+		}
+		out = append(out, byte(v))
+	}
+	return out, nil
+}
+
+// magicScore rates how "finished" data looks: printable, English-like, low
+// entropy, a known file signature, and (decisively) containing a flag
+func magicScore(data []byte) float64 {
+	if len(data) == 0 {
+		return -1000
+	}
+	printable, freq := 0, 0.0
+	for _, b := range data {
+		if (b >= 32 && b <= 126) || b == '\n' || b == '\r' || b == '\t' {
+			printable++
+		}
+		if v, ok := englishFreq[byte(unicode.ToLower(rune(b)))]; ok {
+			freq += v
+		}
+	}
+	n := float64(len(data))
+	score := 50*float64(printable)/n + 3*freq/n - 4*CalculateShannonEntropy(data)
+	for _, sig := range Config.MagicBytes {
+		if len(sig) >= 2 && bytes.HasPrefix(data, sig) {
+			score += 25
+			break
+		}
+	}
+	if hasFlag(data) {
+		score += 1000 // decisive, but a clean plaintext still beats a flag inside binary
+	}
+	return score
+}
+
+// MagicSearch runs a bounded beam search over MagicOperations, keeping the
+// best magicBeamWidth nodes per level so a red-herring first decode cannot
+// hide a better chain. Returns every explored node that scores clearly
+// above the input (by magicMinGain, or by reading as solved), best first. The search ends early, with what it found so far, once
+// ctx is done.
+func MagicSearch(ctx context.Context, data []byte) []MagicNode {
+	root := MagicNode{Data: data, Score: magicScore(data)}
+	seen := map[[32]byte]bool{sha256.Sum256(data): true}
+	beam := []MagicNode{root}
+	var found []MagicNode
+
+	for level := 0; level < magicDepth && len(beam) > 0; level++ {
+		var next []MagicNode
+		for _, node := range beam {
+			if ctx.Err() != nil {
+				break
+			}
+			for _, op := range MagicOperations {
+				out, err := op.Apply(node.Data)
+				if err != nil || len(out) == 0 {
+					continue
+				}
+				key := sha256.Sum256(out)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				child := MagicNode{Data: out, Chain: withStep(node.Chain, op.Name), Score: magicScore(out)}
+				next = append(next, child)
+				if child.Score > root.Score && (child.Score-root.Score >= magicMinGain || LooksSolved(out)) {
+					found = append(found, child)
+				}
+			}
+		}
+		sort.SliceStable(next, func(i, j int) bool { return next[i].Score > next[j].Score })
+		if len(next) > magicBeamWidth {
+			next = next[:magicBeamWidth]
+		}
+		beam = next
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].Score != found[j].Score {
+			return found[i].Score > found[j].Score
+		}
+		return len(found[i].Chain) < len(found[j].Chain)
+	})
+	return found
+}
+
+// OperationByName looks up a Magic operation
+func OperationByName(name string) *Operation {
+	for i := range MagicOperations {
+		if MagicOperations[i].Name == name {
+			return &MagicOperations[i]
+		}
+	}
+	return nil
+}
+
+// displayData renders decoded output for the terminal, falling back to a
+// short hex preview for binary data
+func displayData(data []byte) string {
+	if isPrintable(data) {
+		return string(data)
+	}
+	preview := data
+	if len(preview) > 32 {
+		preview = preview[:32]
+	}
+	return fmt.Sprintf("<%d bytes binary> %x...", len(data), preview)
+}
+
+// magicFirstSteps keeps the best node for each distinct first operation, so
+// backtracking tries genuinely different branches
+func magicFirstSteps(found []MagicNode, limit int) []MagicNode {
+	var steps []MagicNode
+	seen := make(map[string]bool)
+	for _, node := range found {
+		if len(steps) == limit {
+			break
+		}
+		if !seen[node.Chain[0]] {
+			seen[node.Chain[0]] = true
+			steps = append(steps, node)
+		}
+	}
+	return steps
+}
+
+// LooksSolved reports whether a chain's final output is an answer rather
+// than a dead end: it holds a flag, or is printable, English-like text
+func LooksSolved(data []byte) bool {
+	if hasFlag(data) {
+		return true
+	}
+	if len(data) == 0 || !isPrintable(data) {
+		return false
+	}
+	freq := 0.0
+	for _, b := range data {
+		freq += englishFreq[byte(unicode.ToLower(rune(b)))]
+	}
+	return freq/float64(len(data)) >= solvedFreqThreshold
+}
+
+// localSolver follows the best Magic branches, falling back to the classic
+// encodings and Caesar shifts when the search finds nothing
+type localSolver struct{}
+
+func (localSolver) Name() string { return "Local Solver" }
+
+func (localSolver) Applicable(l *Layer) bool {
+	return l.Depth() == 0 || strings.Contains(l.Type, "Encoded") || strings.HasPrefix(l.Type, "File") ||
+		l.Entropy < 7.5 || l.Randomness.Verdict == VerdictCompressed
+}
+
+func (localSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	found := MagicSearch(ctx, l.Data)
+	reportDecompressLimits(l.Data)
+	for _, node := range found {
+		l.Opts.progress.Candidate(append(withStep(l.Chain, node.Chain[0]), node.Chain[1:]...), node.Data)
+	}
+	var candidates []Candidate
+	for _, step := range magicFirstSteps(found, maxBacktrack) {
+		op := OperationByName(step.Chain[0])
+		decoded, _ := op.Apply(l.Data)
+		candidates = append(candidates, Candidate{
+			Step:    op.Name,
+			Data:    decoded,
+			Score:   step.Score,
+			Details: []string{fmt.Sprintf("Magic: %s (score %.1f, %d candidates)", formatChain(step.Chain), step.Score, len(found))},
+		})
+	}
+	if len(found) > 0 {
+		return candidates
+	}
+	solver := NewClassicSolver()
+	solver.Crib = l.Opts.Crib
+	if result := solver.TryDecode(string(l.Data)); result.Success {
+		return []Candidate{{Step: result.Algorithm, Data: []byte(result.DecodedData)}}
+	}
+	fmt.Printf("    %sFailed to decode locally.%s\n", ColorYellow, ColorReset)
+	return nil
+}
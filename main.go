@@ -2,11 +2,15 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"io"
+	"math/big"
 	"os"
+	"regexp"
 	"strings"
+	"time"
 )
 
 // ANSI Colors
@@ -19,105 +23,500 @@ const (
 	ColorCyan   = "\033[36m"
 )
 
+// Options carries user settings down through every analysis layer
+type Options struct {
+	Online    bool
+	Wordlist  []string
+	HashOut   string // file collecting extracted hashcat/john hashes
+	PerLine   bool   // force per-line analysis of multi-line input
+	LayerDiff bool   // print what each transform changed (-layer-diff)
+	Crib      string // word known to be in the plaintext, constraining classical attacks
+	Book      *Book  // key text that book cipher references index (-book)
+
+	KnownFiles  *KnownFileSet // stock file digests (-known-hashes), skipped unanalysed
+	ArtifactDir string        // where extracted frames and other artifacts are saved (-artifacts)
+	Stats       *StatsCSV     // per-input statistics report (-stats-csv)
+
+	SeedTime   time.Time     // centre of the time(NULL) seed search (-seed-time)
+	SeedWindow time.Duration // seeds searched either side of it (-seed-window)
+
+	Timeout       time.Duration // bound on one whole analysis (0: none)
+	AttackTimeout time.Duration // bound on each wordlist attack (0: none)
+
+	flagsSeen map[string]bool // flags already reported this run
+	flags     []string        // the same flags, in the order found
+	progress  *Progress       // layers and candidates so far, reported if the run stops early
+	inflated  int64           // layer growth so far, bounded by Config.Limits.MaxTotal
+	layerData [][]byte        // the data of each layer on the current chain, for -layer-diff
+	dnsSeen   map[string]bool // hostnames whose TXT records were fetched this run
+	artifacts map[string]int  // artifact names saved this run, for unique file names
+}
+
 func main() {
-	textInput := flag.String("t", "", "Text input to analyze")
-	fileInput := flag.String("f", "", "File input to analyze")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "client":
+			runClient(os.Args[2:])
+			return
+		case "encode":
+			runEncode(os.Args[2:])
+			return
+		case "workbench":
+			runWorkbench(os.Args[2:])
+			return
+		case "compare":
+			runCompare(os.Args[2:])
+			return
+		}
+	}
+
+	var specs []inputSpec
+	flag.Var(inputFlag{&specs, false}, "t", "Text input to analyze (repeatable)")
+	flag.Var(inputFlag{&specs, true}, "f", "File input to analyze (repeatable)")
+	urlInput := flag.String("u", "", "Fetch and analyze the body served at an http(s) URL")
+	dnsName := flag.String("dns", "", "Fetch and analyze the TXT records of a DNS name")
+	tlsAddr := flag.String("tls", "", "Fetch the certificate chain of a TLS server (host:port) and check its RSA keys")
 	onlineMode := flag.Bool("online", false, "Enable active online lookups")
+	wordlistFile := flag.String("w", "", "Wordlist for passphrase attacks (default: small built-in list)")
+	knownHashes := flag.String("known-hashes", "", "Known-file hash set (NSRL CSV, hashdeep, md5sum/sha1sum/sha256sum): skip stock OS/application files")
+	hashOut := flag.String("hash-out", "", "Append extracted hashcat/john hashes to this file")
+	artifactDir := flag.String("artifacts", "", "Save extracted artifacts (bit planes, GIF frames and diffs, trailing data) to this directory")
+	daemonSocket := flag.String("daemon", "", "Serve analysis requests on a Unix socket (see the client subcommand)")
+	watchPath := flag.String("watch", "", "Analyze every new or changed file under a file or directory")
+	clipIn := flag.Bool("clip-in", false, "Read the input from the system clipboard")
+	clipOut := flag.Bool("clip-out", false, "Copy the flag (or solved output) to the system clipboard")
+	perLine := flag.Bool("per-line", false, "Analyze every line separately (default: auto-detect)")
+	statsCSV := flag.String("stats-csv", "", "Write one row of statistics per input (size, entropy, IoC, type, solved, flags) to this CSV file (.tsv: tab-separated)")
+	layerDiff := flag.Bool("layer-diff", false, "Print what each transform changed between consecutive layers (length, entropy, charset)")
+	flagFormat := flag.String("flag-format", DefaultFlagPattern, "Regex matching the flag format, scanned for in every layer")
+	flag.Var((*flagValidators)(&Config.FlagValidators), "flag-validator", "Extra check candidate flags must pass: re:REGEX, expr:EXPR or cmd:COMMAND (repeatable)")
+	timeout := flag.Duration("timeout", 0, "Stop the whole analysis after this long, e.g. 30s (default: no limit)")
+	attackTimeout := flag.Duration("attack-timeout", 0, "Give up on each wordlist attack after this long (default: no limit)")
+	flag.Var((*byteSize)(&Config.Limits.MaxOutput), "max-output", "Largest output of one decompression step, e.g. 16M (0: no limit)")
+	flag.Float64Var(&Config.Limits.MaxRatio, "max-ratio", Config.Limits.MaxRatio, "Largest expansion ratio of one decompression step above 1 MiB (0: no limit)")
+	flag.Var((*byteSize)(&Config.Limits.MaxTotal), "max-total", "Total growth allowed across all decoded layers, e.g. 256M (0: no limit)")
+	known := flag.String("known", "", "Known plaintext file, followed by its ciphertext file: recover the cipher and key, then decrypt -t/-f with it")
+	crib := flag.String("crib", "", "Word known to be in the plaintext; classical attacks must produce it")
+	book := flag.String("book", "", "Key text for book (Ottendorf) ciphers: number references in the input are looked up in it")
+	seedTime := flag.String("seed-time", "", "Search PRNG seeds (srand(time(NULL)) and the like) around this time: Unix seconds or RFC 3339")
+	seedWindow := flag.Duration("seed-window", prngSeedWindow, "How far either side of -seed-time and timestamps in the input to search for seeds")
+	recipe := flag.String("recipe", "", `Apply an explicit pipeline instead of auto-detection, e.g. "from_base64 | xor:0x42 | rot13"`)
 	flag.Parse()
 
-	var inputData []byte
-	var err error
+	pattern, err := regexp.Compile(*flagFormat)
+	if err != nil {
+		fmt.Printf("%sInvalid -flag-format: %v%s\n", ColorRed, err, ColorReset)
+		os.Exit(1)
+	}
+	Config.FlagPattern = pattern
+
+	var steps []RecipeStep
+	if *recipe != "" {
+		if steps, err = ParseRecipe(*recipe); err != nil {
+			fmt.Printf("%sInvalid -recipe: %v%s\n", ColorRed, err, ColorReset)
+			os.Exit(1)
+		}
+	}
+
+	// --known pt.txt ct.txt: the ciphertext file is the first argument, and
+	// flags may follow it
+	if *known != "" {
+		if flag.NArg() == 0 || steps != nil {
+			fmt.Printf("%sUsage: --known <plaintext file> <ciphertext file> [-t|-f more ciphertext] (not with --recipe)%s\n", ColorRed, ColorReset)
+			os.Exit(1)
+		}
+		cipherFile := flag.Arg(0)
+		flag.CommandLine.Parse(flag.Args()[1:])
+
+		k, err := IdentifyKnownFiles(*known, cipherFile)
+		if err != nil {
+			fmt.Printf("%sKnown plaintext: %v%s\n", ColorRed, err, ColorReset)
+			os.Exit(1)
+		}
+		printKnownCipher(k)
+		if steps, err = ParseRecipe(k.Recipe); err != nil {
+			fmt.Printf("%sKnown plaintext: %v%s\n", ColorRed, err, ColorReset)
+			os.Exit(1)
+		}
+		if len(specs) == 0 && *urlInput == "" && *dnsName == "" && !*clipIn {
+			if stat, _ := os.Stdin.Stat(); stat.Mode()&os.ModeCharDevice != 0 {
+				return
+			}
+		}
+	}
 
-	// 1. Read Input
-	if *textInput != "" {
-		inputData = []byte(*textInput)
-	} else if *fileInput != "" {
-		inputData, err = os.ReadFile(*fileInput)
+	opts := &Options{Online: *onlineMode, Wordlist: DefaultWordlist, HashOut: *hashOut, ArtifactDir: *artifactDir, PerLine: *perLine,
+		LayerDiff: *layerDiff, Crib: *crib, Timeout: *timeout, AttackTimeout: *attackTimeout, SeedWindow: *seedWindow}
+	if *wordlistFile != "" {
+		words, err := LoadWordlist(*wordlistFile)
+		if err != nil {
+			fmt.Printf("%sError reading wordlist: %v%s\n", ColorRed, err, ColorReset)
+			os.Exit(1)
+		}
+		opts.Wordlist = words
+	}
+	if *seedTime != "" {
+		t, err := ParseSeedTime(*seedTime)
+		if err != nil {
+			fmt.Printf("%sError parsing -seed-time: %v%s\n", ColorRed, err, ColorReset)
+			os.Exit(1)
+		}
+		opts.SeedTime = t
+	}
+	if *book != "" {
+		b, err := LoadBook(*book)
+		if err != nil {
+			fmt.Printf("%sError reading book: %v%s\n", ColorRed, err, ColorReset)
+			os.Exit(1)
+		}
+		opts.Book = b
+	}
+	if *knownHashes != "" {
+		set, err := LoadKnownFiles(*knownHashes)
+		if err != nil {
+			fmt.Printf("%sError reading known-hash set: %v%s\n", ColorRed, err, ColorReset)
+			os.Exit(1)
+		}
+		opts.KnownFiles = set
+	}
+
+	if *statsCSV != "" {
+		stats, err := CreateStatsCSV(*statsCSV)
+		if err != nil {
+			fmt.Printf("%sError creating stats file: %v%s\n", ColorRed, err, ColorReset)
+			os.Exit(1)
+		}
+		defer stats.Close()
+		opts.Stats = stats
+	}
+
+	if *daemonSocket != "" {
+		if err := RunDaemon(*daemonSocket, opts); err != nil {
+			fmt.Printf("%sDaemon error: %v%s\n", ColorRed, err, ColorReset)
+			os.Exit(1)
+		}
+		return
+	}
+	if *watchPath != "" {
+		if err := Watch(*watchPath, opts); err != nil {
+			fmt.Printf("%sError watching %s: %v%s\n", ColorRed, *watchPath, err, ColorReset)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Ctrl+C stops the attacks in flight; what was found so far is reported
+	ctx, stop := interruptContext(context.Background())
+	defer stop()
+	ctx, cancel := runContext(ctx, opts)
+	defer cancel()
+	opts.progress = &Progress{}
+
+	// TLS certificates go through the RSA weak-key checks, not the orchestrator
+	if *tlsAddr != "" {
+		certs, err := FetchCertificates(ctx, *tlsAddr)
+		if err != nil {
+			fmt.Printf("%sError fetching certificates: %v%s\n", ColorRed, err, ColorReset)
+			os.Exit(1)
+		}
+		analyzeCertificates(ctx, *tlsAddr, certs, opts)
+		if len(specs) == 0 && *urlInput == "" && *dnsName == "" && !*clipIn {
+			return
+		}
+	}
+
+	var inputs []Input
+	knownSkipped := 0
+
+	// 1. Read Input (-t and -f may repeat, in command-line order)
+	for _, spec := range specs {
+		if !spec.file {
+			if spec.value != "" {
+				inputs = append(inputs, Input{Name: "-t", Data: []byte(spec.value)})
+			}
+			continue
+		}
+		data, err := os.ReadFile(spec.value)
 		if err != nil {
 			fmt.Printf("%sError reading file: %v%s\n", ColorRed, err, ColorReset)
 			os.Exit(1)
 		}
-	} else {
+		if skipKnownFile(opts, spec.value, data) {
+			knownSkipped++
+			continue
+		}
+		inputs = append(inputs, Input{Name: spec.value, Data: data})
+		if opts.Online {
+			reportFileReputation(ctx, spec.value, data)
+		}
+	}
+	if *urlInput != "" {
+		data, err := NewOnlineSolver().FetchURL(ctx, *urlInput)
+		if err != nil {
+			fmt.Printf("%sError fetching URL: %v%s\n", ColorRed, err, ColorReset)
+			os.Exit(1)
+		}
+		inputs = append(inputs, Input{Name: *urlInput, Data: data})
+	}
+	if *dnsName != "" {
+		records, err := LookupTXT(ctx, *dnsName)
+		if err != nil {
+			fmt.Printf("%sError looking up TXT records: %v%s\n", ColorRed, err, ColorReset)
+			os.Exit(1)
+		}
+		if len(records) == 0 {
+			fmt.Printf("%s%s has no TXT records%s\n", ColorRed, *dnsName, ColorReset)
+			os.Exit(1)
+		}
+		printTXTRecords(*dnsName, records)
+		opts.dnsSeen = map[string]bool{strings.ToLower(strings.TrimSuffix(*dnsName, ".")): true}
+		inputs = append(inputs, Input{Name: "dns:" + *dnsName, Data: txtData(records)})
+	}
+	if *clipIn {
+		data, err := ReadClipboard()
+		if err != nil {
+			fmt.Printf("%sError reading clipboard: %v%s\n", ColorRed, err, ColorReset)
+			os.Exit(1)
+		}
+		inputs = append(inputs, Input{Name: "clipboard", Data: data})
+	}
+	if len(inputs) == 0 && knownSkipped > 0 {
+		return
+	}
+	if len(inputs) == 0 {
 		// Check for stdin
 		stat, _ := os.Stdin.Stat()
 		if (stat.Mode() & os.ModeCharDevice) == 0 {
-			inputData, err = io.ReadAll(os.Stdin)
+			data, err := io.ReadAll(os.Stdin)
 			if err != nil {
 				fmt.Printf("%sError reading stdin: %v%s\n", ColorRed, err, ColorReset)
 				os.Exit(1)
 			}
+			inputs = append(inputs, Input{Name: "stdin", Data: data})
 		} else {
-			fmt.Println("Usage: ./cipher-sleuth -t <text> | -f <file> or pipe input")
+			fmt.Println("Usage: ./cipher-sleuth -t <text> | -f <file> | -u <url> | --dns <name> | --tls <host:port> | --clip-in or pipe input")
 			flag.PrintDefaults()
 			os.Exit(1)
 		}
 	}
 
-	// Trim whitespace for text processing if it's likely text (no null bytes)
-	if !bytes.Contains(inputData, []byte{0}) {
-		inputData = bytes.TrimSpace(inputData)
+	for i := range inputs {
+		inputs[i].Data = trimInput(inputs[i].Data)
+	}
+
+	// Orchestrator Logic: several inputs enable the multi-part attacks
+	var output string
+	var solved bool
+	if steps != nil {
+		var outputs []string
+		for _, in := range inputs {
+			out, err := RunRecipe(in.Data, steps, opts)
+			if err != nil {
+				fmt.Printf("    %sRecipe failed at %v%s\n", ColorRed, err, ColorReset)
+				os.Exit(1)
+			}
+			outputs = append(outputs, string(out))
+		}
+		output, solved = strings.Join(outputs, "\n"), true
+	} else if len(inputs) > 1 {
+		output, solved = analyzeInputs(ctx, inputs, opts)
+	} else {
+		output, solved = analyzeInput(ctx, inputs[0], opts)
+	}
+	if ctx.Err() != nil {
+		fmt.Printf("\n%s[!] Analysis stopped early: %v%s\n", ColorYellow, context.Cause(ctx), ColorReset)
+		opts.progress.Report(opts)
+	}
+
+	if *clipOut {
+		copyResult(opts, output, solved)
+	}
+}
+
+// copyResult puts the flags found (or else a solved final output) on the
+// clipboard
+func copyResult(opts *Options, output string, solved bool) {
+	text := strings.Join(opts.flags, "\n")
+	if text == "" && solved {
+		text = output
+	}
+	if text == "" {
+		fmt.Printf("\n%s[-] Nothing solved; clipboard left unchanged.%s\n", ColorYellow, ColorReset)
+		return
+	}
+	if err := WriteClipboard(text); err != nil {
+		fmt.Printf("\n%sError writing clipboard: %v%s\n", ColorRed, err, ColorReset)
+		return
 	}
+	fmt.Printf("\n%s[+] Copied to clipboard: %s%s\n", ColorGreen, text, ColorReset)
+}
 
-	// Orchestrator Logic
-	orchestrate(inputData, *onlineMode, 0)
+// trimInput trims whitespace for text processing if the input is likely text
+// (no null bytes)
+func trimInput(data []byte) []byte {
+	if bytes.Contains(data, []byte{0}) {
+		return data
+	}
+	return bytes.TrimSpace(data)
 }
 
-func orchestrate(data []byte, online bool, depth int) {
+// inputSpec is one -t or -f occurrence
+type inputSpec struct {
+	file  bool
+	value string
+}
+
+// inputFlag collects repeated -t/-f flags into one ordered list
+type inputFlag struct {
+	specs *[]inputSpec
+	file  bool
+}
+
+func (f inputFlag) String() string { return "" }
+
+func (f inputFlag) Set(value string) error {
+	*f.specs = append(*f.specs, inputSpec{file: f.file, value: value})
+	return nil
+}
+
+// orchestrate analyses one layer and recurses into whatever it decodes.
+// chain names the operations that produced data. Returns the output of the
+// deepest layer reached (a solver's plaintext, or the undecoded input when
+// nothing worked) and whether that output looks like a solution rather than
+// a dead end.
+func orchestrate(ctx context.Context, data []byte, opts *Options, chain []string) (output string, solved bool) {
+	depth := len(chain)
+	// A panic anywhere in this layer abandons only this layer, so the parent
+	// can still backtrack to its other candidates
+	defer func() {
+		if r := recover(); r != nil {
+			reportPanic(fmt.Sprintf("Layer %d", depth), r)
+			output, solved = string(data), false
+		}
+	}()
+	if ctx.Err() != nil {
+		return string(data), false
+	}
 	if depth > 5 {
 		fmt.Printf("%s[!] Max recursion depth reached. Stopping.%s\n", ColorYellow, ColorReset)
-		return
+		return string(data), false
 	}
 
 	fmt.Printf("\n%s[+] Analysis (Layer %d):%s\n", ColorBlue, depth, ColorReset)
+	if opts.LayerDiff {
+		// Parent layers are on the stack below; siblings are overwritten
+		opts.layerData = append(opts.layerData[:min(depth, len(opts.layerData))], data)
+	}
 
-	// 2. Identification
-	identifiedType := "Unknown"
+	// Stock OS and application files (-known-hashes) hold nothing to solve
+	if desc, ok := opts.KnownFiles.Lookup(data); ok {
+		fmt.Printf("    Type: %sKnown File (%s)%s\n", ColorCyan, desc, ColorReset)
+		fmt.Printf("    %sUnmodified stock file: skipped.%s\n", ColorYellow, ColorReset)
+		opts.progress.Layer(chain, "Known File", data)
+		return string(data), false
+	}
+	scanForFlags(data, opts, chain)
 
-	// Check Magic Bytes
-	for name, signature := range Config.MagicBytes {
-		if len(data) >= len(signature) && bytes.Equal(data[:len(signature)], signature) {
-			identifiedType = fmt.Sprintf("File (%s)", name)
-			break
+	// Hex dumps (xxd, hexdump -C, od -x) are reversed to the bytes they show
+	if raw, ok := ReverseHexdump(string(data)); ok {
+		fmt.Printf("    Type: %sHexdump (%d bytes)%s\n", ColorCyan, len(raw), ColorReset)
+		opts.progress.Layer(chain, "Hexdump", data)
+		if !admitLayer(opts, len(data), len(raw)) {
+			return string(data), false
 		}
+		return orchestrate(ctx, raw, opts, withStep(chain, "From Hexdump"))
 	}
 
-	// Check Hashes (if text)
-	dataStr := string(data)
-	if identifiedType == "Unknown" {
-		for name, regex := range Config.HashPatterns {
-			if regex.MatchString(dataStr) {
-				identifiedType = fmt.Sprintf("Hash (%s)", name)
-				break
-			}
-		}
+	// Credential dumps (/etc/shadow, htpasswd, user:hash) are analysed per entry
+	if kind, entries := ParseCredentialDump(string(data)); entries != nil {
+		fmt.Printf("    Type: %sCredential Dump (%s)%s\n", ColorCyan, kind, ColorReset)
+		opts.progress.Layer(chain, "Credential Dump ("+kind+")", data)
+		analyzeCredentials(ctx, kind, entries, opts)
+		return string(data), true
 	}
 
-	// Check Encodings (roughly)
-	if identifiedType == "Unknown" {
-		for name, regex := range EncodingChecks {
-			if regex.MatchString(dataStr) {
-				identifiedType = fmt.Sprintf("Encoded Text (%s?)", name)
-				break // Just a guess, continue analysis
-			}
+	// Emoji-only text is spelled out or turned into a letter substitution
+	if symbols, ok := EmojiSymbols(string(data)); ok {
+		return analyzeEmoji(ctx, data, symbols, opts, chain)
+	}
+
+	// Book cipher references are looked up in the -book text
+	if opts.Book != nil {
+		if refs, ok := ParseBookRefs(string(data)); ok {
+			return analyzeBook(ctx, data, refs, opts, chain)
 		}
 	}
 
+	// 2. Identification (deterministic, overlaps settled by decode trials)
+	identifiedType, alternatives := IdentifyType(data)
+	dataStr := string(data)
+
+	// High entropy alone cannot tell compressed data from ciphertext
+	entropy := CalculateShannonEntropy(data)
+	var randomness Randomness
+	if entropy > 7.5 {
+		randomness = AnalyzeRandomness(data)
+	}
+
 	// NEW: Check for RSA Parameters (N, e, c pattern)
 	rsaParams := ParseRSA(dataStr)
+
+	// Private Key Parsing (feeds RSA components to the RSA solver)
+	keyBlock := FindPrivateKeyBlock(data)
+	var key *KeyInfo
+	var keyErr error
+	if keyBlock != nil {
+		key, keyErr = AnalyzePrivateKey(keyBlock)
+		if keyErr == nil && key.Encrypted {
+			attackCtx, cancel := attackContext(ctx, opts)
+			if cracked, ok := CrackPrivateKey(attackCtx, keyBlock, opts.Wordlist); ok {
+				key = cracked
+			} else if attackCtx.Err() != nil {
+				key.Stopped = context.Cause(attackCtx)
+			}
+			cancel()
+		}
+		if keyErr == nil && key.RSA != nil {
+			rsaParams.N = key.RSA.N
+			rsaParams.E = big.NewInt(int64(key.RSA.E))
+			rsaParams.D = key.RSA.D
+		}
+	}
+
 	isRSA := rsaParams.N != nil && rsaParams.E != nil && rsaParams.C != nil
 	if isRSA {
 		identifiedType = "RSA Challenge Data"
 	}
 
+	// Encrypted containers without a signature (VeraCrypt/TrueCrypt)
+	if !strings.HasPrefix(identifiedType, "File") && !isRSA {
+		// Compressed streams are as random-looking but keep a byte bias
+		if suspect, _ := DetectVeraCrypt(data); suspect && randomness.Verdict != VerdictCompressed {
+			identifiedType = veraCryptType
+		}
+	}
+
+	// Passphrase-encrypted OpenPGP message (gpg -c)
+	pgpInfo := ParsePGPSymmetric(data)
+	if pgpInfo != nil {
+		identifiedType = "OpenPGP Symmetric Encrypted Data"
+	}
+
 	fmt.Printf("    Type: %s%s%s\n", ColorCyan, identifiedType, ColorReset)
+	if len(alternatives) > 0 {
+		fmt.Printf("    Also matches: %s\n", strings.Join(alternatives, ", "))
+	}
+	if !strings.HasPrefix(identifiedType, "File") {
+		reportIdentifiers(FindIdentifiers(data))
+	}
+	opts.progress.Layer(chain, identifiedType, data)
 
 	// 3. Statistics
-	entropy := CalculateShannonEntropy(data)
 	ioc := CalculateIoC(data)
 
 	entropyDesc := "Low"
 	if entropy > 7.5 {
-		entropyDesc = "High (Encrypted/Compressed)"
+		entropyDesc = "High (" + randomness.Verdict + ")"
 	} else if entropy > 5.0 {
 		entropyDesc = "Medium (Random Text/Code)"
 	} else {
@@ -125,92 +524,49 @@ func orchestrate(data []byte, online bool, depth int) {
 	}
 
 	fmt.Printf("    Entropy: %.2f (%s)\n", entropy, entropyDesc)
+	if randomness.Verdict != "" {
+		fmt.Printf("    Randomness: chi-square %.1f (z %.2f, uniform ~255), deflate ratio %.2f: %s\n",
+			randomness.ChiSquare, randomness.Z, randomness.Deflate, randomness.Reason)
+	}
 	fmt.Printf("    IoC: %.2f (English ~1.73, Random ~1.0)\n", ioc)
-
-	// NEW: RSA Solver Hook
-	if isRSA {
-		fmt.Printf("%s[+] RSA Solver:%s\n", ColorBlue, ColorReset)
-		rsaResult := SolveRSA(rsaParams, online)
-		if rsaResult.Success {
-			fmt.Printf("    %sSuccess! Algorithm: %s%s\n", ColorGreen, rsaResult.Algorithm, ColorReset)
-			fmt.Printf("    Decoded: %s\n", rsaResult.DecodedData)
-			return // RSA solved, usually final flag
-		} else {
-			fmt.Printf("    %sFailed to solve RSA (Small E or FactorDB failed).%s\n", ColorYellow, ColorReset)
-		}
+	if opts.LayerDiff && depth > 0 && len(opts.layerData) == depth+1 {
+		fmt.Printf("    Diff: %s\n", LayerDiff(opts.layerData[depth-1], data))
 	}
 
-	// 4. Local Solver
-	if depth == 0 || strings.Contains(identifiedType, "Encoded") || entropy < 7.5 {
-		fmt.Printf("%s[+] Local Solver:%s\n", ColorBlue, ColorReset)
-		solver := NewSolver()
-		result := solver.TryDecode(dataStr)
-
-		if result.Success {
-			fmt.Printf("    %sSuccess! Algorithm: %s%s\n", ColorGreen, result.Algorithm, ColorReset)
-			fmt.Printf("    Decoded: %s\n", result.DecodedData)
-
-			// Recurse!
-			orchestrate([]byte(result.DecodedData), online, depth+1)
-			return // Stop current layer processing if successfully decoded to avoid double noise
+	// Base64 padding-bit steganography needs several padded lines, so it
+	// runs on the whole layer before the lines are split apart below
+	if lines := Base64Lines(dataStr); lines != nil {
+		fmt.Printf("%s[+] Base64 Steganography (%d lines):%s\n", ColorBlue, len(lines), ColorReset)
+		hidden := bytes.TrimRight(ExtractBase64Steg(lines), "\x00")
+		if len(hidden) > 0 && isPrintable(hidden) {
+			fmt.Printf("    %sSuccess! Hidden bits decode to printable text%s\n", ColorGreen, ColorReset)
+			fmt.Printf("    Hidden: %s\n", hidden)
 		} else {
-			fmt.Printf("    %sFailed to decode locally.%s\n", ColorYellow, ColorReset)
+			fmt.Printf("    %sNo printable data in padding bits.%s\n", ColorYellow, ColorReset)
 		}
 	}
 
-	// NEW: Poly Solver (XOR & Vigenère)
-	if identifiedType == "Unknown" || entropy > 3.0 {
-		fmt.Printf("%s[+] Poly Solver:%s\n", ColorBlue, ColorReset)
-
-		// 1. XOR
-		xorRes, xorKey, xorScore := SolveSingleByteXOR(data)
-		// Threshold for "Success": Score > 70% of length? Or just high confidence?
-		// Relative score is hard without length normalization in stats, but let's use a heuristic.
-		// If score is high enough or "flag" found (score 1000).
-		if xorScore >= 1000.0 {
-			fmt.Printf("    %sSuccess! Algorithm: Single Byte XOR (Key: 0x%02X)%s\n", ColorGreen, xorKey, ColorReset)
-			fmt.Printf("    Decoded: %s\n", xorRes)
-			return
-		}
-
-		// 2. Vigenère (Only if text-like)
-		if entropy < 6.0 {
-			vigRes, vigKey := SolveVigenere(dataStr)
-			if vigRes != "" {
-				fmt.Printf("    %sSuccess! Algorithm: Vigenère (Key: %s)%s\n", ColorGreen, vigKey, ColorReset)
-				fmt.Printf("    Decoded: %s\n", vigRes)
-				return
-			}
-		}
-
-		// If we found a decent XOR candidate but it wasn't a "win", maybe print it?
-		// For now, only print wins to avoid noise as requested ("Return... winner").
-		fmt.Printf("    %sNo Poly-Alphabetic, XOR, or weak RSA matches found.%s\n", ColorYellow, ColorReset)
-	}
-
-	// 5. Online Solver (Fallback)
-	fmt.Printf("%s[+] Online Fallback:%s\n", ColorBlue, ColorReset)
-	onlineSolver := NewOnlineSolver()
-
-	if online {
-		// Attempt Active Lookup if it looks like a hash
-		if strings.Contains(identifiedType, "Hash") {
-			// Extract hash type name for lookup
-			parts := strings.Split(identifiedType, "(")
-			if len(parts) > 1 {
-				hashType := strings.TrimRight(parts[1], ")")
-				success, result := onlineSolver.ActiveLookup(dataStr, hashType)
-				if success {
-					fmt.Printf("    %sActive Lookup: Success!%s\n", ColorGreen, ColorReset)
-					fmt.Printf("    Results: %s\n", result)
-					return
-				} else {
-					fmt.Printf("    %sActive Lookup: Failed or Not Supported.%s\n", ColorRed, ColorReset)
-				}
-			}
+	// Line-per-encoding input: analyse each line as its own chain
+	if keyBlock == nil && !isRSA && pgpInfo == nil && !strings.HasPrefix(identifiedType, "File") {
+		if lines := SplitIndependentLines(dataStr, opts.PerLine); lines != nil {
+			return analyzeLines(ctx, lines, opts, chain)
 		}
 	}
 
-	// Always show passive links
-	onlineSolver.GenerateMagicLinks(dataStr)
+	// 4. Solvers (format analysers, RSA, Magic and classic decodes, keyed
+	// ciphers, online fallback), each in turn until one leads to a solution
+	return runSolvers(ctx, &Layer{
+		Data:       data,
+		Type:       identifiedType,
+		Entropy:    entropy,
+		IoC:        ioc,
+		Randomness: randomness,
+		RSA:        rsaParams,
+		KeyBlock:   keyBlock,
+		Key:        key,
+		KeyErr:     keyErr,
+		PGP:        pgpInfo,
+		Chain:      chain,
+		Opts:       opts,
+	})
 }
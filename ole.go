@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"unicode/utf16"
+)
+
+// OLE2 / Compound File Binary constants
+const (
+	oleEndOfChain  = 0xFFFFFFFE
+	oleDirEntry    = 128
+	oleHeaderDIFAT = 109
+)
+
+var oleMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// OLEFile is a minimal read-only Compound File Binary reader, enough to pull
+// named streams out of Office documents
+type OLEFile struct {
+	data       []byte
+	sectorSize int
+	miniSize   int
+	miniCutoff uint32
+	fat        []uint32
+	miniFAT    []uint32
+	miniStream []byte
+	entries    []oleEntry
+}
+
+type oleEntry struct {
+	Name  string
+	Type  byte // 1 storage, 2 stream, 5 root
+	Start uint32
+	Size  uint64
+}
+
+// ParseOLE reads the header, FAT and directory of a compound file
+func ParseOLE(data []byte) (*OLEFile, error) {
+	if len(data) < 512 || string(data[:8]) != string(oleMagic) {
+		return nil, errors.New("not an OLE2 compound file")
+	}
+	le := binary.LittleEndian
+
+	sectorShift := le.Uint16(data[30:32])
+	miniShift := le.Uint16(data[32:34])
+	if sectorShift < 7 || sectorShift > 16 || miniShift > sectorShift {
+		return nil, errors.New("bad OLE sector size")
+	}
+	f := &OLEFile{
+		data:       data,
+		sectorSize: 1 << sectorShift,
+		miniSize:   1 << miniShift,
+		miniCutoff: le.Uint32(data[56:60]),
+	}
+
+	// Collect FAT sector numbers from the header DIFAT and any DIFAT chain,
+	// once each: a sector listed over and over would multiply the FAT
+	var fatSectors []uint32
+	listed := make(map[uint32]bool)
+	addFAT := func(s uint32) {
+		if s < oleEndOfChain && !listed[s] {
+			listed[s] = true
+			fatSectors = append(fatSectors, s)
+		}
+	}
+	for i := 0; i < oleHeaderDIFAT; i++ {
+		addFAT(le.Uint32(data[76+i*4:]))
+	}
+	perSector := f.sectorSize/4 - 1
+	seen := make(map[uint32]bool)
+	for s, n := le.Uint32(data[68:72]), le.Uint32(data[72:76]); s < oleEndOfChain && n > 0 && !seen[s]; n-- {
+		seen[s] = true
+		sector := f.sector(s)
+		if sector == nil {
+			break
+		}
+		for i := 0; i < perSector; i++ {
+			addFAT(le.Uint32(sector[i*4:]))
+		}
+		s = le.Uint32(sector[perSector*4:])
+	}
+	for _, s := range fatSectors {
+		sector := f.sector(s)
+		if sector == nil {
+			return nil, errors.New("FAT sector out of range")
+		}
+		for i := 0; i+4 <= len(sector); i += 4 {
+			f.fat = append(f.fat, le.Uint32(sector[i:]))
+		}
+	}
+
+	// Directory
+	dir := f.readChain(le.Uint32(data[48:52]), f.fat, f.sector, 0)
+	for off := 0; off+oleDirEntry <= len(dir); off += oleDirEntry {
+		e := dir[off : off+oleDirEntry]
+		nameLen := int(le.Uint16(e[64:66]))
+		if nameLen > 64 {
+			nameLen = 64
+		}
+		units := make([]uint16, 0, nameLen/2)
+		for i := 0; i+1 < nameLen; i += 2 {
+			if u := le.Uint16(e[i:]); u != 0 {
+				units = append(units, u)
+			}
+		}
+		f.entries = append(f.entries, oleEntry{
+			Name:  string(utf16.Decode(units)),
+			Type:  e[66],
+			Start: le.Uint32(e[116:120]),
+			Size:  le.Uint64(e[120:128]) & 0xFFFFFFFF, // v3 files leave the high dword undefined
+		})
+	}
+	if len(f.entries) == 0 || f.entries[0].Type != 5 {
+		return nil, errors.New("missing OLE root entry")
+	}
+
+	// Mini FAT and mini stream (held by the root entry)
+	miniFATData := f.readChain(le.Uint32(data[60:64]), f.fat, f.sector, 0)
+	for i := 0; i+4 <= len(miniFATData); i += 4 {
+		f.miniFAT = append(f.miniFAT, le.Uint32(miniFATData[i:]))
+	}
+	root := f.entries[0]
+	f.miniStream = f.readChain(root.Start, f.fat, f.sector, root.Size)
+	return f, nil
+}
+
+// Stream returns the contents of the first stream with the given name
+func (f *OLEFile) Stream(name string) ([]byte, bool) {
+	for _, e := range f.entries {
+		if e.Type != 2 || !strings.EqualFold(e.Name, name) {
+			continue
+		}
+		if e.Size < uint64(f.miniCutoff) {
+			return f.readChain(e.Start, f.miniFAT, f.miniSector, e.Size), true
+		}
+		return f.readChain(e.Start, f.fat, f.sector, e.Size), true
+	}
+	return nil, false
+}
+
+// StreamNames lists every stream in the file
+func (f *OLEFile) StreamNames() []string {
+	var names []string
+	for _, e := range f.entries {
+		if e.Type == 2 {
+			names = append(names, e.Name)
+		}
+	}
+	return names
+}
+
+func (f *OLEFile) sector(n uint32) []byte {
+	start := (int(n) + 1) * f.sectorSize
+	if n >= oleEndOfChain || start < 0 || start+f.sectorSize > len(f.data) {
+		return nil
+	}
+	return f.data[start : start+f.sectorSize]
+}
+
+func (f *OLEFile) miniSector(n uint32) []byte {
+	start := int(n) * f.miniSize
+	if n >= oleEndOfChain || start < 0 || start+f.miniSize > len(f.miniStream) {
+		return nil
+	}
+	return f.miniStream[start : start+f.miniSize]
+}
+
+// readChain follows an allocation chain, stopping at end-of-chain, bad
+// sectors or loops. size 0 means read the whole chain.
+func (f *OLEFile) readChain(start uint32, table []uint32, get func(uint32) []byte, size uint64) []byte {
+	var out []byte
+	seen := make(map[uint32]bool)
+	for s := start; s < oleEndOfChain && !seen[s]; {
+		seen[s] = true
+		chunk := get(s)
+		if chunk == nil {
+			break
+		}
+		out = append(out, chunk...)
+		if size > 0 && uint64(len(out)) >= size {
+			return out[:size]
+		}
+		if int(s) >= len(table) {
+			break
+		}
+		s = table[s]
+	}
+	return out
+}
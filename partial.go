@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+)
+
+// errInterrupted is the cancellation cause when the user presses Ctrl+C
+var errInterrupted = errors.New("interrupted")
+
+// Number of candidates and preview width in the partial results report
+const (
+	partialCandidates = 5
+	partialPreview    = 64
+)
+
+// interruptContext cancels the returned context on the first SIGINT, so
+// in-flight attacks stop and the partial results can still be printed. The
+// second SIGINT gets the default behaviour and exits at once.
+func interruptContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		defer signal.Reset(os.Interrupt)
+		select {
+		case <-sig:
+			fmt.Printf("\n%s[!] Interrupted: stopping attacks (Ctrl+C again to quit now)...%s\n", ColorYellow, ColorReset)
+			cancel(errInterrupted)
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() { cancel(nil) }
+}
+
+// progressEntry is one layer reached, or one candidate output seen
+type progressEntry struct {
+	chain []string
+	kind  string // identified type, for layers
+	data  []byte
+}
+
+// Progress records what an analysis has reached so far: every layer it
+// entered and every candidate decode, so a run that is interrupted or times
+// out can still report them. A nil *Progress records nothing.
+type Progress struct {
+	layers     []progressEntry
+	candidates []progressEntry
+}
+
+// Layer records entering an analysis layer. Decoded layers are candidates
+// in their own right.
+func (p *Progress) Layer(chain []string, kind string, data []byte) {
+	if p == nil {
+		return
+	}
+	p.layers = append(p.layers, progressEntry{chain: chain, kind: kind, data: data})
+	if len(chain) > 0 {
+		p.Candidate(chain, data)
+	}
+}
+
+// Candidate records a decode that no layer has taken up (yet)
+func (p *Progress) Candidate(chain []string, data []byte) {
+	if p == nil || len(data) == 0 {
+		return
+	}
+	p.candidates = append(p.candidates, progressEntry{chain: chain, data: data})
+}
+
+// Best returns up to n distinct candidates, highest Magic score first
+func (p *Progress) Best(n int) []progressEntry {
+	if p == nil {
+		return nil
+	}
+	type scored struct {
+		progressEntry
+		score float64
+	}
+	// Outputs differing only in surrounding whitespace are one candidate,
+	// credited to the shortest chain that produced it
+	var all []scored
+	seen := make(map[[32]byte]int)
+	for _, c := range p.candidates {
+		key := sha256.Sum256(bytes.TrimSpace(c.data))
+		if i, ok := seen[key]; ok {
+			if len(c.chain) < len(all[i].chain) {
+				all[i].progressEntry = c
+			}
+			continue
+		}
+		seen[key] = len(all)
+		all = append(all, scored{c, magicScore(c.data)})
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].score > all[j].score })
+
+	best := make([]progressEntry, 0, n)
+	for i := 0; i < len(all) && i < n; i++ {
+		best = append(best, all[i].progressEntry)
+	}
+	return best
+}
+
+// Report prints the layer tree, the best candidates and the flags found
+// before the analysis stopped
+func (p *Progress) Report(opts *Options) {
+	if p == nil {
+		return
+	}
+	fmt.Printf("\n%s[+] Partial Results:%s\n", ColorBlue, ColorReset)
+	fmt.Printf("    Layers reached: %d\n", len(p.layers))
+	for _, l := range p.layers {
+		step := "Input"
+		if len(l.chain) > 0 {
+			step = l.chain[len(l.chain)-1]
+		}
+		fmt.Printf("    %s%s -> %s: %s\n", strings.Repeat("  ", len(l.chain)), step, l.kind, previewData(l.data))
+	}
+
+	if best := p.Best(partialCandidates); len(best) > 0 {
+		fmt.Printf("    Best candidates:\n")
+		for i, c := range best {
+			fmt.Printf("      %d. %s\n", i+1, formatChain(c.chain))
+			fmt.Printf("         %s\n", previewData(c.data))
+		}
+	}
+	if len(opts.flags) > 0 {
+		fmt.Printf("    %sFlags: %s%s\n", ColorGreen, strings.Join(opts.flags, ", "), ColorReset)
+	}
+}
+
+// previewData is displayData cut to one terminal line
+func previewData(data []byte) string {
+	s := strings.Join(strings.Fields(displayData(data)), " ")
+	if r := []rune(s); len(r) > partialPreview {
+		return string(r[:partialPreview]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SplitIndependentLines decides whether multi-line input holds one encoding
+// per line. With force set any input of two or more lines qualifies;
+// otherwise the text must not decode as a whole while every line decodes on
+// its own or looks like a hash.
+func SplitIndependentLines(input string, force bool) []string {
+	var lines []string
+	for _, line := range strings.Split(input, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) < 2 {
+		return nil
+	}
+	if force {
+		return lines
+	}
+
+	solver := NewClassicSolver()
+	if solver.TryDecode(input).Success {
+		return nil
+	}
+	for _, line := range lines {
+		if !solver.TryDecode(line).Success && !isHashLine(line) {
+			return nil
+		}
+	}
+	return lines
+}
+
+func isHashLine(line string) bool {
+	for _, regex := range Config.HashPatterns {
+		if regex.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// analyzeLines runs every line through its own analysis chain and merges the
+// final outputs in input order
+func analyzeLines(ctx context.Context, lines []string, opts *Options, chain []string) (string, bool) {
+	fmt.Printf("%s[+] Per-Line Analysis (%d lines):%s\n", ColorBlue, len(lines), ColorReset)
+	results := make([]string, len(lines))
+	allSolved := true
+	for i, line := range lines {
+		fmt.Printf("\n%s[+] Line %d/%d:%s %s\n", ColorBlue, i+1, len(lines), ColorReset, line)
+		var solved bool
+		results[i], solved = orchestrate(ctx, []byte(line), opts, withStep(chain, fmt.Sprintf("Line %d", i+1)))
+		allSolved = allSolved && solved
+	}
+
+	fmt.Printf("\n%s[+] Per-Line Results:%s\n", ColorBlue, ColorReset)
+	for i, result := range results {
+		if result == lines[i] {
+			fmt.Printf("    %d: %s%s (undecoded)%s\n", i+1, ColorYellow, result, ColorReset)
+		} else {
+			fmt.Printf("    %d: %s\n", i+1, result)
+		}
+	}
+	merged := strings.Join(results, "")
+	fmt.Printf("    %sMerged: %s%s\n", ColorGreen, merged, ColorReset)
+	return merged, allSolved || LooksSolved([]byte(merged))
+}
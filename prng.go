@@ -0,0 +1,254 @@
+package main
+
+import (
+	"math/big"
+)
+
+// MT19937 is the 32-bit Mersenne Twister behind C++ std::mt19937, PHP's
+// mt_rand and Python's random module
+type MT19937 struct {
+	mt [624]uint32
+	i  int
+}
+
+// NewMT19937 seeds the generator as init_genrand does (std::mt19937(seed))
+func NewMT19937(seed uint32) *MT19937 {
+	m := &MT19937{i: 624}
+	m.mt[0] = seed
+	for i := 1; i < 624; i++ {
+		m.mt[i] = 1812433253*(m.mt[i-1]^m.mt[i-1]>>30) + uint32(i)
+	}
+	return m
+}
+
+// NewPythonRandom seeds the generator as Python's random.seed(n) does for
+// a non-negative integer: init_by_array over its 32-bit words
+func NewPythonRandom(seed uint64) *MT19937 {
+	key := []uint32{uint32(seed)}
+	if seed>>32 != 0 {
+		key = append(key, uint32(seed>>32))
+	}
+	m := NewMT19937(19650218)
+	i, j := 1, 0
+	for k := max(624, len(key)); k > 0; k-- {
+		m.mt[i] = (m.mt[i] ^ (m.mt[i-1]^m.mt[i-1]>>30)*1664525) + key[j] + uint32(j)
+		if i++; i >= 624 {
+			m.mt[0], i = m.mt[623], 1
+		}
+		if j++; j >= len(key) {
+			j = 0
+		}
+	}
+	for k := 623; k > 0; k-- {
+		m.mt[i] = (m.mt[i] ^ (m.mt[i-1]^m.mt[i-1]>>30)*1566083941) - uint32(i)
+		if i++; i >= 624 {
+			m.mt[0], i = m.mt[623], 1
+		}
+	}
+	m.mt[0] = 0x80000000
+	return m
+}
+
+func (m *MT19937) twist() {
+	for i := range 624 {
+		y := m.mt[i]&0x80000000 | m.mt[(i+1)%624]&0x7fffffff
+		m.mt[i] = m.mt[(i+397)%624] ^ y>>1
+		if y&1 != 0 {
+			m.mt[i] ^= 0x9908b0df
+		}
+	}
+	m.i = 0
+}
+
+// Uint32 returns the next tempered output
+func (m *MT19937) Uint32() uint32 {
+	if m.i >= 624 {
+		m.twist()
+	}
+	y := m.mt[m.i]
+	m.i++
+	y ^= y >> 11
+	y ^= y << 7 & 0x9d2c5680
+	y ^= y << 15 & 0xefc60000
+	return y ^ y>>18
+}
+
+// untemper inverts the output tempering, giving back the state word
+func untemper(y uint32) uint32 {
+	y ^= y >> 18
+	y ^= y << 15 & 0xefc60000
+	// Each pass recovers 7 more bits of the << 7 step
+	x := y
+	for range 4 {
+		x = y ^ x<<7&0x9d2c5680
+	}
+	y = x
+	x = y
+	for range 2 {
+		x = y ^ x>>11
+	}
+	return x
+}
+
+// CloneMT19937 rebuilds the generator from 624 consecutive outputs, leaving
+// it positioned to produce the output that followed them
+func CloneMT19937(outputs []uint32) *MT19937 {
+	if len(outputs) < 624 {
+		return nil
+	}
+	m := &MT19937{i: 624}
+	for i, y := range outputs[:624] {
+		m.mt[i] = untemper(y)
+	}
+	return m
+}
+
+// GlibcRand is glibc's random()/rand() in its default TYPE_3 state: an
+// additive feedback generator over 34 words seeded by srand()
+type GlibcRand struct {
+	r []uint32
+}
+
+// NewGlibcRand seeds the generator as srand(seed) does
+func NewGlibcRand(seed uint32) *GlibcRand {
+	if seed == 0 {
+		seed = 1
+	}
+	r := make([]uint32, 344, 344+64)
+	r[0] = seed
+	for i := 1; i < 31; i++ {
+		v := 16807 * int64(int32(r[i-1])) % 2147483647
+		if v < 0 {
+			v += 2147483647
+		}
+		r[i] = uint32(v)
+	}
+	for i := 31; i < 34; i++ {
+		r[i] = r[i-31]
+	}
+	for i := 34; i < 344; i++ {
+		r[i] = r[i-31] + r[i-3]
+	}
+	return &GlibcRand{r: r}
+}
+
+// Rand returns the next rand() output, 31 bits wide
+func (g *GlibcRand) Rand() uint32 {
+	n := len(g.r)
+	v := g.r[n-31] + g.r[n-3]
+	// Only the last 34 words are ever read again
+	if n == cap(g.r) {
+		g.r = append(g.r[:0:0], g.r[n-34:]...)
+	}
+	g.r = append(g.r, v)
+	return v >> 1
+}
+
+// LCG is a linear congruential generator x' = (A*x + C) mod M
+type LCG struct {
+	A, C, M *big.Int
+	State   *big.Int
+}
+
+// Next steps the generator and returns the new state
+func (l *LCG) Next() *big.Int {
+	l.State = new(big.Int).Mul(l.A, l.State)
+	l.State.Add(l.State, l.C).Mod(l.State, l.M)
+	return l.State
+}
+
+// Prev steps the generator back, returning the state before the current
+// one; ok is false when A has no inverse mod M
+func (l *LCG) Prev() (*big.Int, bool) {
+	inv := new(big.Int).ModInverse(l.A, l.M)
+	if inv == nil {
+		return nil, false
+	}
+	prev := new(big.Int).Sub(l.State, l.C)
+	l.State = prev.Mul(prev, inv).Mod(prev, l.M)
+	return l.State, true
+}
+
+// lcgModuli are the moduli of common library LCGs, tried before the
+// modulus is derived from the outputs
+var lcgModuli = []*big.Int{
+	big.NewInt(1 << 31),
+	big.NewInt(1<<31 - 1),
+	big.NewInt(1 << 32),
+	big.NewInt(1 << 48),
+	new(big.Int).Lsh(big.NewInt(1), 63),
+	new(big.Int).Lsh(big.NewInt(1), 64),
+}
+
+// RecoverLCG finds the parameters of an LCG from its consecutive full-state
+// outputs, trying the common library moduli and then the one the outputs
+// imply: for the differences t, t[i+2]*t[i] - t[i+1]^2 are multiples of the
+// modulus, so their gcd reveals it. The returned generator predicts every
+// given output and is positioned at the last one.
+func RecoverLCG(outputs []*big.Int) *LCG {
+	// Three outputs fit some A and C under any modulus: a fourth checks them
+	if len(outputs) < 4 {
+		return nil
+	}
+	largest := new(big.Int)
+	for _, x := range outputs {
+		if x.Cmp(largest) > 0 {
+			largest = x
+		}
+	}
+	for _, m := range lcgModuli {
+		if m.Cmp(largest) > 0 {
+			if l := lcgForModulus(outputs, m); l != nil {
+				return l
+			}
+		}
+	}
+	if len(outputs) < 5 {
+		return nil
+	}
+	diffs := make([]*big.Int, len(outputs)-1)
+	for i := range diffs {
+		diffs[i] = new(big.Int).Sub(outputs[i+1], outputs[i])
+	}
+	m := new(big.Int)
+	for i := 0; i+2 < len(diffs); i++ {
+		u := new(big.Int).Mul(diffs[i+2], diffs[i])
+		u.Sub(u, new(big.Int).Mul(diffs[i+1], diffs[i+1]))
+		m.GCD(nil, nil, m, u.Abs(u))
+	}
+	// Too few outputs can leave a small multiple of the modulus
+	for k := int64(1); k <= 64 && m.Cmp(largest) > 0; k++ {
+		q, r := new(big.Int).QuoRem(m, big.NewInt(k), new(big.Int))
+		if r.Sign() != 0 || q.Cmp(largest) <= 0 {
+			continue
+		}
+		if l := lcgForModulus(outputs, q); l != nil {
+			return l
+		}
+	}
+	return nil
+}
+
+// lcgForModulus solves for A and C under a known modulus and checks them
+// against every output
+func lcgForModulus(outputs []*big.Int, m *big.Int) *LCG {
+	for i := 0; i+2 < len(outputs); i++ {
+		d0 := new(big.Int).Sub(outputs[i+1], outputs[i])
+		d1 := new(big.Int).Sub(outputs[i+2], outputs[i+1])
+		inv := new(big.Int).ModInverse(d0.Mod(d0, m), m)
+		if inv == nil {
+			continue
+		}
+		a := d1.Mul(d1, inv).Mod(d1, m)
+		c := new(big.Int).Mul(a, outputs[i])
+		c.Sub(outputs[i+1], c).Mod(c, m)
+		l := &LCG{A: a, C: c, M: m, State: outputs[0]}
+		for _, x := range outputs[1:] {
+			if l.Next().Cmp(x) != 0 {
+				return nil
+			}
+		}
+		return l
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// RecipeStep is one parsed "name[:arg]" entry of a --recipe pipeline
+type RecipeStep struct {
+	Name  string
+	Arg   string
+	Apply func([]byte) ([]byte, error)
+}
+
+// Label names the step as it was written
+func (s RecipeStep) Label() string {
+	if s.Arg == "" {
+		return s.Name
+	}
+	return s.Name + ":" + s.Arg
+}
+
+// recipeOp builds a step's function from its argument
+type recipeOp struct {
+	arg   string // argument description, empty when none is taken
+	build func(arg string) (func([]byte) ([]byte, error), error)
+}
+
+// magicRecipeOp reuses a Magic operation, which takes no argument
+func magicRecipeOp(name string) recipeOp {
+	return recipeOp{build: func(string) (func([]byte) ([]byte, error), error) {
+		return OperationByName(name).Apply, nil
+	}}
+}
+
+// recipeOps maps recipe names to operations
+var recipeOps = map[string]recipeOp{
+	"from_base64":    magicRecipeOp("Base64"),
+	"from_base64url": magicRecipeOp("Base64 (URL-safe)"),
+	"from_base32":    magicRecipeOp("Base32"),
+	"from_base58":    magicRecipeOp("Base58"),
+	"from_hex":       magicRecipeOp("Hex"),
+	"from_binary":    magicRecipeOp("Binary"),
+	"from_decimal":   magicRecipeOp("Decimal"),
+	"url_decode":     magicRecipeOp("URL Encoding"),
+	"from_utf7":      magicRecipeOp("UTF-7"),
+	"from_bech32":    magicRecipeOp("Bech32"),
+	"rot13":          magicRecipeOp("Rot13"),
+	"rot8000":        magicRecipeOp("ROT8000"),
+	"from_base65536": magicRecipeOp("Base65536"),
+	"reverse":        magicRecipeOp("Reverse"),
+	"gunzip":         magicRecipeOp("Gunzip"),
+	"zlib_inflate":   magicRecipeOp("Zlib Inflate"),
+	"raw_inflate":    magicRecipeOp("Raw Inflate"),
+	"bunzip2":        magicRecipeOp("Bunzip2"),
+	"unxz":           magicRecipeOp("XZ Decompress"),
+	"from_hexdump": {build: func(string) (func([]byte) ([]byte, error), error) {
+		return func(data []byte) ([]byte, error) {
+			out, ok := ReverseHexdump(string(data))
+			if !ok {
+				return nil, fmt.Errorf("input is not a hex dump")
+			}
+			return out, nil
+		}, nil
+	}},
+	"rot": {arg: "shift 1-25", build: func(arg string) (func([]byte) ([]byte, error), error) {
+		shift, err := strconv.Atoi(arg)
+		if err != nil || shift < 1 || shift > 25 {
+			return nil, fmt.Errorf("shift must be 1-25, got %q", arg)
+		}
+		return func(data []byte) ([]byte, error) { return []byte(caesarShift(string(data), shift)), nil }, nil
+	}},
+	"add": {arg: "byte shift 1-255", build: func(arg string) (func([]byte) ([]byte, error), error) {
+		shift, err := strconv.Atoi(arg)
+		if err != nil || shift < 1 || shift > 255 {
+			return nil, fmt.Errorf("shift must be 1-255, got %q", arg)
+		}
+		return func(data []byte) ([]byte, error) { return byteShift(data, byte(shift)), nil }, nil
+	}},
+	"affine": {arg: "A,B: each byte x becomes A*x+B mod 256, A odd", build: func(arg string) (func([]byte) ([]byte, error), error) {
+		a, b, err := parseAffineKey(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(data []byte) ([]byte, error) { return byteAffine(data, a, b), nil }, nil
+	}},
+	"xor": {arg: "key as 0x-prefixed hex or text", build: func(arg string) (func([]byte) ([]byte, error), error) {
+		key, err := parseRecipeKey(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(data []byte) ([]byte, error) { return xorKey(data, key), nil }, nil
+	}},
+	"checkerboard": {arg: "28-symbol alphabet or keyword, then blank columns, e.g. ETAONRISBCDFGHJKLMPQ/UVWXYZ.,26", build: func(arg string) (func([]byte) ([]byte, error), error) {
+		board, err := ParseCheckerboardKey(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(data []byte) ([]byte, error) {
+			out, ok := board.Decode(string(data))
+			if !ok {
+				return nil, fmt.Errorf("digits end inside a two-digit code or figure shift")
+			}
+			return []byte(out), nil
+		}, nil
+	}},
+	"vigenere": {arg: "alphabetic key", build: func(arg string) (func([]byte) ([]byte, error), error) {
+		if !isAlphaKey(arg) {
+			return nil, fmt.Errorf("key must be letters only, got %q", arg)
+		}
+		return func(data []byte) ([]byte, error) { return []byte(vigenereDecrypt(string(data), arg)), nil }, nil
+	}},
+	"substitute": {arg: "plain letters for cipher A-Z, _ when unknown", build: func(arg string) (func([]byte) ([]byte, error), error) {
+		key, err := parseSubstitutionKey(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(data []byte) ([]byte, error) {
+			w := NewWorkbench(string(data))
+			w.key = key
+			return []byte(w.Plaintext()), nil
+		}, nil
+	}},
+}
+
+// ParseRecipe parses "op | op:arg | ..." into steps, rejecting unknown
+// operations and bad arguments up front so nothing runs half a pipeline
+func ParseRecipe(recipe string) ([]RecipeStep, error) {
+	return parseSteps(recipe, recipeOps)
+}
+
+func parseSteps(recipe string, ops map[string]recipeOp) ([]RecipeStep, error) {
+	var steps []RecipeStep
+	for i, part := range strings.Split(recipe, "|") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("step %d is empty", i+1)
+		}
+		name, arg, _ := strings.Cut(part, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		arg = strings.TrimSpace(arg)
+
+		op, ok := ops[name]
+		if !ok {
+			return nil, fmt.Errorf("step %d: unknown operation %q (known: %s)", i+1, name, strings.Join(operationNames(ops), ", "))
+		}
+		if op.arg == "" && arg != "" {
+			return nil, fmt.Errorf("step %d: %s takes no argument", i+1, name)
+		}
+		if op.arg != "" && arg == "" {
+			return nil, fmt.Errorf("step %d: %s needs an argument (%s)", i+1, name, op.arg)
+		}
+		apply, err := op.build(arg)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %s: %v", i+1, name, err)
+		}
+		steps = append(steps, RecipeStep{Name: name, Arg: arg, Apply: apply})
+	}
+	return steps, nil
+}
+
+// operationNames lists the names in an operation table, sorted
+func operationNames(ops map[string]recipeOp) []string {
+	names := make([]string, 0, len(ops))
+	for name, op := range ops {
+		if op.arg != "" {
+			name += ":<" + op.arg + ">"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RunRecipe applies steps in order, printing each intermediate result.
+// Returns the final output, or an error naming the step that failed.
+func RunRecipe(data []byte, steps []RecipeStep, opts *Options) ([]byte, error) {
+	fmt.Printf("\n%s[+] Recipe (%d steps):%s\n", ColorBlue, len(steps), ColorReset)
+	var chain []string
+	for i, step := range steps {
+		out, err := step.Apply(data)
+		if err != nil {
+			return data, fmt.Errorf("step %d (%s): %v", i+1, step.Label(), err)
+		}
+		data = out
+		chain = append(chain, step.Label())
+		fmt.Printf("    %d. %s: %s\n", i+1, step.Label(), displayData(data))
+	}
+	fmt.Printf("    %sResult: %s%s\n", ColorGreen, displayData(data), ColorReset)
+	reportFlags(opts, data, chain)
+	return data, nil
+}
+
+// parseRecipeKey reads "0x4142" as hex bytes and anything else as text
+func parseRecipeKey(arg string) ([]byte, error) {
+	if h, ok := strings.CutPrefix(strings.ToLower(arg), "0x"); ok {
+		key, err := hex.DecodeString(h)
+		if err != nil || len(key) == 0 {
+			return nil, fmt.Errorf("bad hex key %q", arg)
+		}
+		return key, nil
+	}
+	return []byte(arg), nil
+}
+
+// xorKey XORs data with a repeating key
+func xorKey(data, key []byte) []byte {
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[i] = b ^ key[i%len(key)]
+	}
+	return out
+}
+
+// parseAffineKey reads "A,B" with A odd and both in 0-255
+func parseAffineKey(arg string) (byte, byte, error) {
+	as, bs, found := strings.Cut(arg, ",")
+	a, errA := strconv.Atoi(strings.TrimSpace(as))
+	b, errB := strconv.Atoi(strings.TrimSpace(bs))
+	if !found || errA != nil || errB != nil || a < 1 || a > 255 || a%2 == 0 || b < 0 || b > 255 {
+		return 0, 0, fmt.Errorf("key must be A,B with A odd, both 0-255, got %q", arg)
+	}
+	return byte(a), byte(b), nil
+}
+
+// byteShift adds shift to every byte, wrapping mod 256
+func byteShift(data []byte, shift byte) []byte {
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[i] = b + shift
+	}
+	return out
+}
+
+// parseSubstitutionKey reads a 26-letter plain alphabet, one letter per
+// cipher letter A-Z, with '_' for letters not known
+func parseSubstitutionKey(arg string) ([26]byte, error) {
+	var key [26]byte
+	if len(arg) != 26 {
+		return key, fmt.Errorf("want 26 letters, got %d", len(arg))
+	}
+	seen := make(map[byte]bool)
+	for i := 0; i < 26; i++ {
+		if arg[i] == '_' {
+			continue
+		}
+		p := letterIndex(arg[i])
+		if p < 0 || seen[byte(p)] {
+			return key, fmt.Errorf("%q is not a letter used once", arg[i])
+		}
+		seen[byte(p)] = true
+		key[i] = byte('a' + p)
+	}
+	return key, nil
+}
+
+func isAlphaKey(key string) bool {
+	for _, r := range key {
+		if !unicode.IsLetter(r) || r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return key != ""
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Layer is one layer under analysis with what identification and statistics
+// found about it, shared by every solver
+type Layer struct {
+	Data       []byte
+	Type       string // identified type, e.g. "Hash (MD5)" or "File (PNG)"
+	Entropy    float64
+	IoC        float64
+	Randomness Randomness // set for high-entropy layers only
+	RSA        *RSAParams // whatever RSA values were parsed, possibly none
+	KeyBlock   *pem.Block // private key block, if any
+	Key        *KeyInfo   // the key parsed from it, cracked if protected
+	KeyErr     error      // why it failed to parse
+	PGP        *PGPSymmetricInfo
+	Chain      []string
+	Opts       *Options
+}
+
+// Depth is the layer's recursion depth
+func (l *Layer) Depth() int {
+	return len(l.Chain)
+}
+
+// Candidate is one output a solver proposes for a layer
+type Candidate struct {
+	Step    string   // the chain step naming the transform and its key
+	Data    []byte   // the transform's output
+	Score   float64  // higher is tried first
+	Details []string // printed under the success line
+	Final   bool     // a plaintext to report, not a layer to analyse further
+}
+
+// Solver is one solving stage. Applicable gates it on the layer's type and
+// statistics; Solve prints its own findings and returns the candidates
+// worth following, if any.
+type Solver interface {
+	Name() string
+	Applicable(l *Layer) bool
+	Solve(ctx context.Context, l *Layer) []Candidate
+}
+
+// solverRegistry holds the solvers run on every layer once it is
+// identified, lowest priority first. The format analysers share priority 1
+// and run in the order listed. New solvers plug in here.
+var solverRegistry = []struct {
+	Priority int
+	Solver   Solver
+}{
+	{1, privateKeySolver{}},
+	{1, pgpSolver{}},
+	{1, luksSolver{}},
+	{1, veraCryptSolver{}},
+	{1, gifSolver{}},
+	{1, bitplaneSolver{}},
+	{1, audioSolver{}},
+	{1, sevenZipSolver{}},
+	{1, ageSolver{}},
+	{1, rarSolver{}},
+	{1, ansibleSolver{}},
+	{1, officeSolver{}},
+	{1, pdfSolver{}},
+	{1, bech32Solver{}},
+	{10, rsaSolver{}},
+	{12, sqliteSolver{}},
+	{15, pemSolver{}},
+	{16, otpSolver{}},
+	{18, crcSolver{}},
+	{19, prngSolver{}},
+	{20, localSolver{}},
+	{30, checkerboardSolver{}},
+	{40, polySolver{}},
+	{50, hostnameSolver{}},
+	{60, fallbackSolver{}},
+}
+
+// Solvers lists the registered solvers in priority order
+func Solvers() []Solver {
+	entries := append(solverRegistry[:0:0], solverRegistry...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Priority < entries[j].Priority })
+	solvers := make([]Solver, len(entries))
+	for i, e := range entries {
+		solvers[i] = e.Solver
+	}
+	return solvers
+}
+
+// flagCandidate is the result of a solver that follows its own layers (the
+// frames of a GIF, the cells of a database): the flags they turned up since
+// flagsBefore, as a final candidate, or nothing
+func flagCandidate(l *Layer, flagsBefore int, step string) []Candidate {
+	if len(l.Opts.flags) == flagsBefore {
+		return nil
+	}
+	found := strings.Join(l.Opts.flags[flagsBefore:], "\n")
+	return []Candidate{{Step: step, Data: []byte(found), Final: true}}
+}
+
+// runSolvers gives each applicable solver its turn on a layer until one
+// leads to a solution. Returns like orchestrate.
+func runSolvers(ctx context.Context, l *Layer) (string, bool) {
+	for _, s := range Solvers() {
+		// An interrupted or timed out run skips the remaining solvers
+		if ctx.Err() != nil {
+			return string(l.Data), false
+		}
+		if !s.Applicable(l) {
+			continue
+		}
+		fmt.Printf("%s[+] %s:%s\n", ColorBlue, s.Name(), ColorReset)
+		var candidates []Candidate
+		safely(s.Name(), func() { candidates = s.Solve(ctx, l) })
+		if output, solved, done := followCandidates(ctx, l, candidates); done {
+			return output, solved
+		}
+	}
+	return string(l.Data), LooksSolved(l.Data)
+}
+
+// followCandidates reports a final candidate as the solution, or analyses
+// the candidates as the next layer best first, backtracking to this layer
+// when one dead-ends. done is false when there was nothing to follow or
+// every candidate dead-ended, so the next solver gets its turn.
+func followCandidates(ctx context.Context, l *Layer, candidates []Candidate) (output string, solved, done bool) {
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	followed := 0
+	for i, c := range candidates {
+		if i > 0 {
+			fmt.Printf("\n%s[<] Backtracking to Layer %d: trying %s%s\n", ColorYellow, l.Depth(), c.Step, ColorReset)
+		}
+		fmt.Printf("    %sSuccess! Algorithm: %s%s\n", ColorGreen, c.Step, ColorReset)
+		for _, d := range c.Details {
+			fmt.Printf("    %s\n", d)
+		}
+		fmt.Printf("    Decoded: %s\n", displayData(c.Data))
+		if c.Final {
+			reportFlags(l.Opts, c.Data, withStep(l.Chain, c.Step))
+			return string(c.Data), true, true
+		}
+
+		// Recurse one step at a time so every layer is analysed
+		if !admitLayer(l.Opts, len(l.Data), len(c.Data)) {
+			continue
+		}
+		followed++
+		output, solved := orchestrate(ctx, c.Data, l.Opts, withStep(l.Chain, c.Step))
+		if solved || ctx.Err() != nil {
+			return output, solved, true
+		}
+		fmt.Printf("%s[-] Dead end below Layer %d via %s.%s\n", ColorYellow, l.Depth(), c.Step, ColorReset)
+	}
+	if followed > 0 {
+		fmt.Printf("    %sEvery decode branch dead-ended; continuing with Layer %d.%s\n", ColorYellow, l.Depth(), ColorReset)
+	}
+	return "", false, false
+}
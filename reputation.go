@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Environment variables holding the reputation services' API keys
+const (
+	virusTotalKeyEnv    = "VT_API_KEY"
+	malwareBazaarKeyEnv = "MALWAREBAZAAR_AUTH_KEY"
+)
+
+// Reputation service endpoints (variables so tests can point them elsewhere)
+var (
+	virusTotalURL    = "https://www.virustotal.com/api/v3/files/"
+	malwareBazaarURL = "https://mb-api.abuse.ch/api/v1/"
+)
+
+// maxReputationSize bounds a reputation service's response body
+const maxReputationSize = 1 << 20
+
+// FileHashes are the digests reputation services are keyed by
+type FileHashes struct {
+	MD5, SHA1, SHA256 string
+}
+
+// HashFile computes the MD5, SHA1 and SHA256 of a file's contents
+func HashFile(data []byte) FileHashes {
+	m, s1, s256 := md5.Sum(data), sha1.Sum(data), sha256.Sum256(data)
+	return FileHashes{MD5: hex.EncodeToString(m[:]), SHA1: hex.EncodeToString(s1[:]), SHA256: hex.EncodeToString(s256[:])}
+}
+
+// Reputation is one service's verdict on a file hash
+type Reputation struct {
+	Service string
+	Known   bool   // the service has seen the sample
+	Verdict string // detections or classification, when known
+	Name    string // file or threat name, when known
+}
+
+// LookupVirusTotal asks VirusTotal for the analysis of a SHA256
+func (s *OnlineSolver) LookupVirusTotal(ctx context.Context, sha256, apiKey string) (*Reputation, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", virusTotalURL+sha256, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-apikey", apiKey)
+	req.Header.Set("User-Agent", userAgent)
+
+	var result struct {
+		Data struct {
+			Attributes struct {
+				MeaningfulName string         `json:"meaningful_name"`
+				Stats          map[string]int `json:"last_analysis_stats"`
+				Threat         struct {
+					Label string `json:"suggested_threat_label"`
+				} `json:"popular_threat_classification"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	rep := &Reputation{Service: "VirusTotal"}
+	status, err := s.getJSON(req, &result)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return rep, nil
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("VirusTotal returned HTTP %d", status)
+	}
+
+	attrs := result.Data.Attributes
+	total := 0
+	for _, n := range attrs.Stats {
+		total += n
+	}
+	rep.Known = true
+	rep.Verdict = fmt.Sprintf("%d/%d engines flag it as malicious", attrs.Stats["malicious"], total)
+	if n := attrs.Stats["suspicious"]; n > 0 {
+		rep.Verdict += fmt.Sprintf(", %d as suspicious", n)
+	}
+	rep.Name = attrs.MeaningfulName
+	if attrs.Threat.Label != "" {
+		rep.Name = strings.TrimSpace(attrs.Threat.Label + " " + rep.Name)
+	}
+	return rep, nil
+}
+
+// LookupMalwareBazaar asks MalwareBazaar whether a SHA256 is a known sample
+func (s *OnlineSolver) LookupMalwareBazaar(ctx context.Context, sha256, authKey string) (*Reputation, error) {
+	form := url.Values{"query": {"get_info"}, "hash": {sha256}}
+	req, err := http.NewRequestWithContext(ctx, "POST", malwareBazaarURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Auth-Key", authKey)
+	req.Header.Set("User-Agent", userAgent)
+
+	var result struct {
+		Status string `json:"query_status"`
+		Data   []struct {
+			FileName  string   `json:"file_name"`
+			FileType  string   `json:"file_type"`
+			Signature string   `json:"signature"`
+			FirstSeen string   `json:"first_seen"`
+			Tags      []string `json:"tags"`
+		} `json:"data"`
+	}
+	rep := &Reputation{Service: "MalwareBazaar"}
+	status, err := s.getJSON(req, &result)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("MalwareBazaar returned HTTP %d", status)
+	}
+	switch {
+	case result.Status == "hash_not_found":
+		return rep, nil
+	case result.Status != "ok" || len(result.Data) == 0:
+		return nil, fmt.Errorf("MalwareBazaar query failed: %s", result.Status)
+	}
+
+	sample := result.Data[0]
+	rep.Known = true
+	rep.Verdict = "known malware sample, first seen " + sample.FirstSeen
+	if sample.Signature != "" {
+		rep.Verdict = sample.Signature + " " + rep.Verdict
+	}
+	if len(sample.Tags) > 0 {
+		rep.Verdict += " (tags: " + strings.Join(sample.Tags, ", ") + ")"
+	}
+	rep.Name = strings.TrimSpace(sample.FileName + " " + sample.FileType)
+	return rep, nil
+}
+
+// getJSON runs req and decodes a JSON body into v, returning the status.
+// Bodies of error statuses are not decoded.
+func (s *OnlineSolver) getJSON(req *http.Request, v any) (int, error) {
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxReputationSize))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	return resp.StatusCode, json.Unmarshal(body, v)
+}
+
+// reportFileReputation prints a file input's hashes and what VirusTotal and
+// MalwareBazaar know about it. A service is skipped when its API key is not
+// set in the environment.
+func reportFileReputation(ctx context.Context, name string, data []byte) {
+	hashes := HashFile(data)
+	fmt.Printf("%s[+] File Reputation (%s):%s\n", ColorBlue, name, ColorReset)
+	fmt.Printf("    MD5:    %s\n    SHA1:   %s\n    SHA256: %s\n", hashes.MD5, hashes.SHA1, hashes.SHA256)
+
+	s := NewOnlineSolver()
+	services := []struct {
+		name, env string
+		lookup    func(context.Context, string, string) (*Reputation, error)
+	}{
+		{"VirusTotal", virusTotalKeyEnv, s.LookupVirusTotal},
+		{"MalwareBazaar", malwareBazaarKeyEnv, s.LookupMalwareBazaar},
+	}
+	for _, svc := range services {
+		key := os.Getenv(svc.env)
+		if key == "" {
+			fmt.Printf("    %s: skipped (set %s to query it)\n", svc.name, svc.env)
+			continue
+		}
+		rep, err := svc.lookup(ctx, hashes.SHA256, key)
+		switch {
+		case err != nil:
+			fmt.Printf("    %s%s: lookup failed: %v%s\n", ColorRed, svc.name, err, ColorReset)
+		case !rep.Known:
+			fmt.Printf("    %s: not a known sample\n", svc.name)
+		default:
+			fmt.Printf("    %s%s: %s%s\n", ColorYellow, svc.name, rep.Verdict, ColorReset)
+			if rep.Name != "" {
+				fmt.Printf("      Name: %s\n", rep.Name)
+			}
+		}
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"encoding/base32"
-	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"net/url"
@@ -16,18 +15,20 @@ type SolveResult struct {
 	DecodedData string
 }
 
-// Solver encapsulates local solving logic
-type Solver struct{}
+// ClassicSolver tries the standard encodings and Caesar shifts on text
+type ClassicSolver struct {
+	Crib string // known plaintext word; when set, Caesar shifts must produce it
+}
 
-// NewSolver creates a new local solver instance
-func NewSolver() *Solver {
-	return &Solver{}
+// NewClassicSolver creates a new local solver instance
+func NewClassicSolver() *ClassicSolver {
+	return &ClassicSolver{}
 }
 
 // TryDecode attempts all standard encodings
-func (s *Solver) TryDecode(input string) *SolveResult {
+func (s *ClassicSolver) TryDecode(input string) *SolveResult {
 	// Try Base64
-	if data, err := base64.StdEncoding.DecodeString(input); err == nil {
+	if data, err := decodeBase64(input); err == nil {
 		// Heuristic: if it decodes to only printable chars, it's likely correct
 		if isPrintable(data) {
 			return &SolveResult{Success: true, Algorithm: "Base64", DecodedData: string(data)}
@@ -53,13 +54,9 @@ func (s *Solver) TryDecode(input string) *SolveResult {
 		}
 	}
 
-	// Try Rot13
+	// Try Rot13, kept only when it reveals a flag
 	rot13 := s.Rot13(input)
-	// Simple check: does it look like a flag or English?
-	// The prompt implies "Auto-solve" Rot13. We'll just return it if it contains "pico" or similar,
-	// or we can just return it as a candidate if requested.
-	// For "Auto-solve", we might need a heuristic.
-	if strings.Contains(strings.ToLower(rot13.DecodedData), "pico") {
+	if hasFlag([]byte(rot13.DecodedData)) {
 		return rot13
 	}
 
@@ -73,41 +70,21 @@ func (s *Solver) TryDecode(input string) *SolveResult {
 }
 
 // Rot13 implementation
-func (s *Solver) Rot13(input string) *SolveResult {
-	var result strings.Builder
-	for _, r := range input {
-		switch {
-		case r >= 'a' && r <= 'z':
-			result.WriteRune('a' + (r-'a'+13)%26)
-		case r >= 'A' && r <= 'Z':
-			result.WriteRune('A' + (r-'A'+13)%26)
-		default:
-			result.WriteRune(r)
-		}
-	}
-	return &SolveResult{Success: true, Algorithm: "Rot13", DecodedData: result.String()}
+func (s *ClassicSolver) Rot13(input string) *SolveResult {
+	return &SolveResult{Success: true, Algorithm: "Rot13", DecodedData: caesarShift(input, 13)}
 }
 
-// BruteForceCaesar shifts 1-25 looking for "picoCTF{"
-func (s *Solver) BruteForceCaesar(input string) *SolveResult {
-	target := "picoctf" // Case insensitive check
-
+// BruteForceCaesar shifts 1-25 looking for a flag, or for the crib when
+// one is set
+func (s *ClassicSolver) BruteForceCaesar(input string) *SolveResult {
+	crib := strings.ToLower(s.Crib)
 	for shift := 1; shift < 26; shift++ {
-		var result strings.Builder
-		for _, r := range input {
-			switch {
-			case r >= 'a' && r <= 'z':
-				// Python: chr((ord(char) - 97 + shift) % 26 + 97)
-				// Go: 'a' + (r-'a'+rune(shift))%26
-				result.WriteRune('a' + (r-'a'+rune(shift))%26)
-			case r >= 'A' && r <= 'Z':
-				result.WriteRune('A' + (r-'A'+rune(shift))%26)
-			default:
-				result.WriteRune(r)
-			}
+		candidate := caesarShift(input, shift)
+		found := hasFlag([]byte(candidate))
+		if crib != "" {
+			found = strings.Contains(strings.ToLower(candidate), crib)
 		}
-		candidate := result.String()
-		if strings.Contains(strings.ToLower(candidate), target) {
+		if found {
 			return &SolveResult{
 				Success:     true,
 				Algorithm:   fmt.Sprintf("Caesar Cipher (Shift %d)", shift),
@@ -118,6 +95,22 @@ func (s *Solver) BruteForceCaesar(input string) *SolveResult {
 	return &SolveResult{Success: false}
 }
 
+// caesarShift rotates the ASCII letters of s forward by shift (0-25)
+func caesarShift(s string, shift int) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z':
+			sb.WriteRune('a' + (r-'a'+rune(shift))%26)
+		case r >= 'A' && r <= 'Z':
+			sb.WriteRune('A' + (r-'A'+rune(shift))%26)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
 func isPrintable(data []byte) bool {
 	for _, b := range data {
 		// Allow some standard whitespace
@@ -0,0 +1,893 @@
+package main
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/flate"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"unicode/utf16"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// 7z property IDs
+const (
+	sz7End                = 0x00
+	sz7Header             = 0x01
+	sz7ArchiveProperties  = 0x02
+	sz7AdditionalStreams  = 0x03
+	sz7MainStreamsInfo    = 0x04
+	sz7FilesInfo          = 0x05
+	sz7PackInfo           = 0x06
+	sz7UnpackInfo         = 0x07
+	sz7SubStreamsInfo     = 0x08
+	sz7Size               = 0x09
+	sz7CRC                = 0x0A
+	sz7Folder             = 0x0B
+	sz7CodersUnpackSize   = 0x0C
+	sz7NumUnpackStream    = 0x0D
+	sz7EmptyStream        = 0x0E
+	sz7Name               = 0x11
+	sz7EncodedHeader      = 0x17
+	sz7SignatureHeaderLen = 32
+	sz7MaxCycles          = 24       // refuse absurd KDF costs from hostile headers
+	sz7MaxStream          = 64 << 20 // largest decoded stream we allocate
+)
+
+// 7z coder method IDs
+const (
+	sz7Copy  = "00"
+	sz7LZMA  = "030101"
+	sz7LZMA2 = "21"
+	sz7AES   = "06f10701"
+	sz7Defl  = "040108"
+	sz7BZip2 = "040202"
+)
+
+var sevenZipMagic = []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}
+
+type sevenZipCoder struct {
+	ID    string // hex method ID
+	Props []byte
+}
+
+type sevenZipFolder struct {
+	Coders      []sevenZipCoder
+	BindPairs   [][2]int // {inIndex, outIndex}
+	PackedIn    int      // coder consuming the packed stream
+	UnpackSizes []uint64 // one per coder output
+	CRC         uint32
+	HasCRC      bool
+	firstPack   int // index of this folder's packed stream
+}
+
+// SevenZipFile is one entry of the archive listing
+type SevenZipFile struct {
+	Name   string
+	Size   uint64
+	CRC    uint32
+	HasCRC bool
+	folder int // -1 for empty files
+}
+
+// SevenZipAES describes the 7zAES coder protecting a folder
+type SevenZipAES struct {
+	Cycles int
+	Salt   []byte
+	IV     []byte // padded to 16 bytes
+	ivLen  int
+}
+
+// SevenZipArchive is a parsed 7z archive
+type SevenZipArchive struct {
+	data            []byte
+	packPos         uint64
+	packSizes       []uint64
+	folders         []*sevenZipFolder
+	Files           []SevenZipFile
+	HeaderEncrypted bool
+	headerFolders   []*sevenZipFolder // encoded header, kept when it needs a password
+	headerPackPos   uint64
+	headerPackSizes []uint64
+}
+
+// sz7Reader reads 7z header structures with a sticky error
+type sz7Reader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (r *sz7Reader) byte() byte {
+	if r.err != nil || r.pos >= len(r.b) {
+		r.err = errors.New("7z header truncated")
+		return 0
+	}
+	r.pos++
+	return r.b[r.pos-1]
+}
+
+func (r *sz7Reader) bytes(n uint64) []byte {
+	if r.err != nil || n > uint64(len(r.b)-r.pos) {
+		r.err = errors.New("7z header truncated")
+		return nil
+	}
+	r.pos += int(n)
+	return r.b[r.pos-int(n) : r.pos]
+}
+
+// number decodes the 7z variable length integer
+func (r *sz7Reader) number() uint64 {
+	first := r.byte()
+	var value uint64
+	mask := byte(0x80)
+	for i := 0; i < 8; i++ {
+		if first&mask == 0 {
+			return value | uint64(first&(mask-1))<<(8*i)
+		}
+		value |= uint64(r.byte()) << (8 * i)
+		mask >>= 1
+	}
+	return value
+}
+
+// count reads a number used as an element count, bounded by the remaining
+// input so hostile headers cannot trigger huge allocations
+func (r *sz7Reader) count() int {
+	n := r.number()
+	if n > uint64(len(r.b)) {
+		r.err = errors.New("7z count out of range")
+		return 0
+	}
+	return int(n)
+}
+
+func (r *sz7Reader) bitVector(n int) []bool {
+	bits := make([]bool, n)
+	var cur, mask byte
+	for i := range bits {
+		if mask == 0 {
+			cur, mask = r.byte(), 0x80
+		}
+		bits[i] = cur&mask != 0
+		mask >>= 1
+	}
+	return bits
+}
+
+func (r *sz7Reader) digests(n int) ([]bool, []uint32) {
+	defined := make([]bool, n)
+	if allDefined := r.byte(); allDefined != 0 {
+		for i := range defined {
+			defined[i] = true
+		}
+	} else {
+		defined = r.bitVector(n)
+	}
+	crcs := make([]uint32, n)
+	for i := range crcs {
+		if !defined[i] {
+			continue
+		}
+		// A truncated header leaves r.err set and nothing to read
+		if b := r.bytes(4); b != nil {
+			crcs[i] = binary.LittleEndian.Uint32(b)
+		}
+	}
+	return defined, crcs
+}
+
+// ParseSevenZip reads the archive headers. If the header itself is encrypted
+// (7z -mhe) only the encryption parameters are available until Unlock.
+func ParseSevenZip(data []byte) (*SevenZipArchive, error) {
+	if len(data) < sz7SignatureHeaderLen || !bytes.HasPrefix(data, sevenZipMagic) {
+		return nil, errors.New("not a 7z archive")
+	}
+	nextOffset := binary.LittleEndian.Uint64(data[12:20])
+	nextSize := binary.LittleEndian.Uint64(data[20:28])
+	start := uint64(sz7SignatureHeaderLen) + nextOffset
+	if nextOffset > uint64(len(data)) || nextSize > uint64(len(data)) || start+nextSize > uint64(len(data)) {
+		return nil, errors.New("7z next header out of range")
+	}
+
+	a := &SevenZipArchive{data: data}
+	r := &sz7Reader{b: data[start : start+nextSize]}
+	switch r.byte() {
+	case sz7Header:
+		a.readHeader(r)
+	case sz7EncodedHeader:
+		a.readStreamsInfo(r)
+		if r.err != nil {
+			return nil, r.err
+		}
+		a.headerFolders, a.headerPackPos, a.headerPackSizes = a.folders, a.packPos, a.packSizes
+		a.folders, a.packSizes = nil, nil
+		if len(a.headerFolders) == 0 {
+			return nil, errors.New("no folders in encoded header")
+		}
+		if a.headerFolders[0].aes() != nil {
+			a.HeaderEncrypted = true
+			return a, nil
+		}
+		if err := a.decodeHeader(nil); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("unknown 7z header type")
+	}
+	return a, r.err
+}
+
+// decodeHeader decodes an encoded (compressed and possibly encrypted) header
+func (a *SevenZipArchive) decodeHeader(key []byte) error {
+	if len(a.headerFolders) == 0 {
+		return errors.New("no encoded header folder")
+	}
+	folder := a.headerFolders[0]
+	packed, err := a.packedStream(a.headerPackPos, a.headerPackSizes, folder.firstPack)
+	if err != nil {
+		return err
+	}
+	header, err := decodeSevenZipFolder(folder, packed, key)
+	if err != nil {
+		return err
+	}
+	if folder.HasCRC && crc32.ChecksumIEEE(header) != folder.CRC {
+		return errors.New("header CRC mismatch")
+	}
+	r := &sz7Reader{b: header}
+	if r.byte() != sz7Header {
+		return errors.New("decoded header has wrong type")
+	}
+	a.readHeader(r)
+	return r.err
+}
+
+func (a *SevenZipArchive) readHeader(r *sz7Reader) {
+	for r.err == nil {
+		switch id := r.byte(); id {
+		case sz7End:
+			return
+		case sz7ArchiveProperties:
+			for r.err == nil && r.byte() != 0 {
+				r.bytes(r.number())
+			}
+		case sz7AdditionalStreams, sz7MainStreamsInfo:
+			a.readStreamsInfo(r)
+		case sz7FilesInfo:
+			a.readFilesInfo(r)
+		default:
+			r.err = fmt.Errorf("unexpected 7z property 0x%02x", id)
+		}
+	}
+}
+
+func (a *SevenZipArchive) readStreamsInfo(r *sz7Reader) {
+	var numUnpack []int
+	var subSizes []uint64
+	var subDefined []bool
+	var subCRCs []uint32
+	for r.err == nil {
+		switch id := r.byte(); id {
+		case sz7End:
+			a.assignFileStreams(numUnpack, subSizes, subDefined, subCRCs)
+			return
+		case sz7PackInfo:
+			a.packPos = r.number()
+			n := r.count()
+			for r.err == nil {
+				prop := r.byte()
+				if prop == sz7End {
+					break
+				}
+				switch prop {
+				case sz7Size:
+					a.packSizes = make([]uint64, n)
+					for i := range a.packSizes {
+						a.packSizes[i] = r.number()
+					}
+				case sz7CRC:
+					r.digests(n)
+				default:
+					r.bytes(r.number())
+				}
+			}
+		case sz7UnpackInfo:
+			a.readUnpackInfo(r)
+		case sz7SubStreamsInfo:
+			numUnpack = make([]int, len(a.folders))
+			for i := range numUnpack {
+				numUnpack[i] = 1
+			}
+			for r.err == nil {
+				prop := r.byte()
+				if prop == sz7End {
+					break
+				}
+				switch prop {
+				case sz7NumUnpackStream:
+					for i := range numUnpack {
+						numUnpack[i] = r.count()
+					}
+				case sz7Size:
+					for i, f := range a.folders {
+						var sum uint64
+						for j := 1; j < numUnpack[i]; j++ {
+							size := r.number()
+							subSizes = append(subSizes, size)
+							sum += size
+						}
+						if numUnpack[i] > 0 {
+							subSizes = append(subSizes, f.finalSize()-sum)
+						}
+					}
+				case sz7CRC:
+					n := 0
+					for i, f := range a.folders {
+						if numUnpack[i] != 1 || !f.HasCRC {
+							n += numUnpack[i]
+						}
+					}
+					subDefined, subCRCs = r.digests(n)
+				default:
+					r.bytes(r.number())
+				}
+			}
+		default:
+			r.err = fmt.Errorf("unexpected 7z streams property 0x%02x", id)
+		}
+	}
+}
+
+func (a *SevenZipArchive) readUnpackInfo(r *sz7Reader) {
+	if r.byte() != sz7Folder {
+		r.err = errors.New("7z folder info missing")
+		return
+	}
+	n := r.count()
+	if r.byte() != 0 {
+		r.err = errors.New("external 7z folders are not supported")
+		return
+	}
+	pack := 0
+	for i := 0; i < n && r.err == nil; i++ {
+		f := &sevenZipFolder{firstPack: pack}
+		numCoders := r.count()
+		totalOut := 0
+		for c := 0; c < numCoders && r.err == nil; c++ {
+			flags := r.byte()
+			coder := sevenZipCoder{ID: hex.EncodeToString(r.bytes(uint64(flags & 0x0F)))}
+			if flags&0x10 != 0 {
+				r.err = errors.New("complex 7z coders (BCJ2) are not supported")
+				return
+			}
+			if flags&0x20 != 0 {
+				coder.Props = r.bytes(r.number())
+			}
+			f.Coders = append(f.Coders, coder)
+			totalOut++
+		}
+		for b := 0; b < totalOut-1; b++ {
+			f.BindPairs = append(f.BindPairs, [2]int{r.count(), r.count()})
+		}
+		// With single-stream coders exactly one input is left unbound
+		bound := make(map[int]bool)
+		for _, bp := range f.BindPairs {
+			bound[bp[0]] = true
+		}
+		for c := range f.Coders {
+			if !bound[c] {
+				f.PackedIn = c
+			}
+		}
+		pack++
+		a.folders = append(a.folders, f)
+	}
+	if r.byte() != sz7CodersUnpackSize {
+		r.err = errors.New("7z coder sizes missing")
+		return
+	}
+	for _, f := range a.folders {
+		f.UnpackSizes = make([]uint64, len(f.Coders))
+		for i := range f.UnpackSizes {
+			f.UnpackSizes[i] = r.number()
+		}
+	}
+	for r.err == nil {
+		prop := r.byte()
+		if prop == sz7End {
+			return
+		}
+		if prop == sz7CRC {
+			defined, crcs := r.digests(len(a.folders))
+			for i, f := range a.folders {
+				f.HasCRC, f.CRC = defined[i], crcs[i]
+			}
+		} else {
+			r.bytes(r.number())
+		}
+	}
+}
+
+func (a *SevenZipArchive) readFilesInfo(r *sz7Reader) {
+	n := r.count()
+	files := make([]SevenZipFile, n)
+	emptyStream := make([]bool, n)
+	for r.err == nil {
+		prop := r.byte()
+		if prop == sz7End {
+			break
+		}
+		size := r.number()
+		switch prop {
+		case sz7EmptyStream:
+			emptyStream = r.bitVector(n)
+		case sz7Name:
+			body := r.bytes(size)
+			if len(body) == 0 || body[0] != 0 {
+				continue
+			}
+			var units []uint16
+			idx := 0
+			for i := 1; i+1 < len(body) && idx < n; i += 2 {
+				u := binary.LittleEndian.Uint16(body[i:])
+				if u == 0 {
+					files[idx].Name = string(utf16.Decode(units))
+					units = units[:0]
+					idx++
+					continue
+				}
+				units = append(units, u)
+			}
+		default:
+			r.bytes(size)
+		}
+	}
+
+	// Attach stream sizes and CRCs gathered from SubStreamsInfo
+	s := 0
+	for i := range files {
+		files[i].folder = -1
+		if emptyStream[i] {
+			continue
+		}
+		if s < len(a.Files) {
+			files[i].Size, files[i].CRC, files[i].HasCRC, files[i].folder =
+				a.Files[s].Size, a.Files[s].CRC, a.Files[s].HasCRC, a.Files[s].folder
+		}
+		s++
+	}
+	a.Files = files
+}
+
+// assignFileStreams records per-stream sizes/CRCs as placeholder files, later
+// matched to names by readFilesInfo
+func (a *SevenZipArchive) assignFileStreams(numUnpack []int, sizes []uint64, defined []bool, crcs []uint32) {
+	if numUnpack == nil {
+		numUnpack = make([]int, len(a.folders))
+		for i := range numUnpack {
+			numUnpack[i] = 1
+		}
+	}
+	a.Files = nil
+	s, d := 0, 0
+	for fi, f := range a.folders {
+		for j := 0; j < numUnpack[fi]; j++ {
+			file := SevenZipFile{folder: fi, Size: f.finalSize()}
+			if s < len(sizes) {
+				file.Size = sizes[s]
+			}
+			if numUnpack[fi] == 1 && f.HasCRC {
+				file.CRC, file.HasCRC = f.CRC, true
+			} else if d < len(defined) {
+				file.CRC, file.HasCRC = crcs[d], defined[d]
+				d++
+			}
+			a.Files = append(a.Files, file)
+			s++
+		}
+	}
+}
+
+// finalSize is the size of the folder output no bind pair consumes
+func (f *sevenZipFolder) finalSize() uint64 {
+	bound := make(map[int]bool)
+	for _, bp := range f.BindPairs {
+		bound[bp[1]] = true
+	}
+	for i, size := range f.UnpackSizes {
+		if !bound[i] {
+			return size
+		}
+	}
+	return 0
+}
+
+// aes returns the 7zAES parameters of the folder, or nil if unencrypted
+func (f *sevenZipFolder) aes() *SevenZipAES {
+	for _, c := range f.Coders {
+		if c.ID == sz7AES {
+			return parseSevenZipAES(c.Props)
+		}
+	}
+	return nil
+}
+
+// parseSevenZipAES decodes the coder properties: NumCyclesPower in the low six
+// bits, then salt and IV lengths split across the first two bytes
+func parseSevenZipAES(p []byte) *SevenZipAES {
+	info := &SevenZipAES{IV: make([]byte, 16)}
+	if len(p) == 0 {
+		return info
+	}
+	info.Cycles = int(p[0] & 0x3F)
+	if p[0]&0xC0 == 0 || len(p) < 2 {
+		return info
+	}
+	saltLen := int(p[0]>>7&1) + int(p[1]>>4)
+	ivLen := int(p[0]>>6&1) + int(p[1]&0x0F)
+	if len(p) < 2+saltLen+ivLen {
+		return info
+	}
+	info.Salt = p[2 : 2+saltLen]
+	copy(info.IV, p[2+saltLen:2+saltLen+ivLen])
+	info.ivLen = ivLen
+	return info
+}
+
+// Encryption returns the AES parameters of the first protected folder
+func (a *SevenZipArchive) Encryption() *SevenZipAES {
+	if a.HeaderEncrypted {
+		return a.headerFolders[0].aes()
+	}
+	for _, f := range a.folders {
+		if info := f.aes(); info != nil {
+			return info
+		}
+	}
+	return nil
+}
+
+func (a *SevenZipArchive) packedStream(packPos uint64, sizes []uint64, index int) ([]byte, error) {
+	if index >= len(sizes) {
+		return nil, errors.New("7z pack stream missing")
+	}
+	start := uint64(sz7SignatureHeaderLen) + packPos
+	for _, s := range sizes[:index] {
+		start += s
+	}
+	end := start + sizes[index]
+	if start > uint64(len(a.data)) || end > uint64(len(a.data)) || end < start {
+		return nil, errors.New("7z pack stream out of range")
+	}
+	return a.data[start:end], nil
+}
+
+// decodeSevenZipFolder runs packed data through the folder's coder chain
+func decodeSevenZipFolder(f *sevenZipFolder, packed []byte, key []byte) ([]byte, error) {
+	data := packed
+	c := f.PackedIn
+	for steps := 0; steps <= len(f.Coders); steps++ {
+		if c < 0 || c >= len(f.Coders) {
+			return nil, errors.New("bad 7z coder index")
+		}
+		var err error
+		data, err = runSevenZipCoder(f.Coders[c], data, f.UnpackSizes[c], key)
+		if err != nil {
+			return nil, err
+		}
+		next := -1
+		for _, bp := range f.BindPairs {
+			if bp[1] == c {
+				next = bp[0]
+			}
+		}
+		if next < 0 {
+			return data, nil
+		}
+		c = next
+	}
+	return nil, errors.New("7z coder loop")
+}
+
+func runSevenZipCoder(c sevenZipCoder, in []byte, size uint64, key []byte) ([]byte, error) {
+	if size > sz7MaxStream {
+		return nil, fmt.Errorf("7z stream too large (%d bytes)", size)
+	}
+	if err := Config.Limits.CheckStep(len(in), int64(size)); err != nil {
+		return nil, err
+	}
+	var r io.Reader
+	switch c.ID {
+	case sz7Copy:
+		r = bytes.NewReader(in)
+	case sz7AES:
+		if key == nil {
+			return nil, errors.New("password required")
+		}
+		info := parseSevenZipAES(c.Props)
+		if len(in)%aes.BlockSize != 0 {
+			return nil, errors.New("7z AES stream not block aligned")
+		}
+		block, _ := aes.NewCipher(key)
+		out := make([]byte, len(in))
+		cipher.NewCBCDecrypter(block, info.IV).CryptBlocks(out, in)
+		r = bytes.NewReader(out)
+	case sz7LZMA:
+		if len(c.Props) < 5 {
+			return nil, errors.New("bad LZMA properties")
+		}
+		header := make([]byte, 13)
+		copy(header, c.Props[:5])
+		binary.LittleEndian.PutUint64(header[5:], size)
+		lr, err := lzma.NewReader(io.MultiReader(bytes.NewReader(header), bytes.NewReader(in)))
+		if err != nil {
+			return nil, err
+		}
+		r = lr
+	case sz7LZMA2:
+		if len(c.Props) < 1 || c.Props[0] > 40 {
+			return nil, errors.New("bad LZMA2 properties")
+		}
+		dictCap := lzma.MinDictCap
+		if p := int(c.Props[0]); p < 40 {
+			if d := (2 | p&1) << (p/2 + 11); d > dictCap {
+				dictCap = d
+			}
+		}
+		lr, err := lzma.Reader2Config{DictCap: dictCap}.NewReader2(bytes.NewReader(in))
+		if err != nil {
+			return nil, err
+		}
+		r = lr
+	case sz7Defl:
+		r = flate.NewReader(bytes.NewReader(in))
+	case sz7BZip2:
+		r = bzip2.NewReader(bytes.NewReader(in))
+	default:
+		return nil, fmt.Errorf("unsupported 7z coder %s", c.ID)
+	}
+
+	out := make([]byte, size)
+	if _, err := io.ReadFull(r, out); err != nil {
+		return nil, fmt.Errorf("7z decode failed: %v", err)
+	}
+	return out, nil
+}
+
+// SevenZipKey derives the AES-256 key: SHA256 over 2^cycles rounds of
+// salt + UTF-16LE password + 64-bit counter
+func SevenZipKey(password string, info *SevenZipAES) []byte {
+	pw := utf16le(password)
+	if info.Cycles == 0x3F {
+		key := make([]byte, 32)
+		n := copy(key, info.Salt)
+		copy(key[n:], pw)
+		return key
+	}
+	h := sha256.New()
+	buf := make([]byte, len(info.Salt)+len(pw)+8)
+	copy(buf, info.Salt)
+	copy(buf[len(info.Salt):], pw)
+	ctr := buf[len(buf)-8:]
+	for i := uint64(0); i < 1<<uint(info.Cycles); i++ {
+		binary.LittleEndian.PutUint64(ctr, i)
+		h.Write(buf)
+	}
+	return h.Sum(nil)
+}
+
+// Unlock checks a key against the archive, decoding the encrypted header if
+// there is one, otherwise the first encrypted folder, and verifying CRCs
+func (a *SevenZipArchive) Unlock(key []byte) bool {
+	if a.HeaderEncrypted {
+		return a.decodeHeader(key) == nil
+	}
+	for i, f := range a.folders {
+		if f.aes() == nil {
+			continue
+		}
+		_, err := a.ExtractFolder(i, key)
+		return err == nil
+	}
+	return false
+}
+
+// ExtractFolder decodes a folder and verifies its CRC (or its files' CRCs)
+func (a *SevenZipArchive) ExtractFolder(index int, key []byte) ([]byte, error) {
+	f := a.folders[index]
+	packed, err := a.packedStream(a.packPos, a.packSizes, f.firstPack)
+	if err != nil {
+		return nil, err
+	}
+	out, err := decodeSevenZipFolder(f, packed, key)
+	if err != nil {
+		return nil, err
+	}
+	if f.HasCRC {
+		if crc32.ChecksumIEEE(out) != f.CRC {
+			return nil, errors.New("CRC mismatch")
+		}
+		return out, nil
+	}
+	off := uint64(0)
+	for _, file := range a.Files {
+		if file.folder != index {
+			continue
+		}
+		if off+file.Size > uint64(len(out)) {
+			return nil, errors.New("file sizes exceed folder")
+		}
+		if file.HasCRC && crc32.ChecksumIEEE(out[off:off+file.Size]) != file.CRC {
+			return nil, errors.New("CRC mismatch")
+		}
+		off += file.Size
+	}
+	return out, nil
+}
+
+// ExtractFiles returns the contents of every non-empty file
+func (a *SevenZipArchive) ExtractFiles(key []byte) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	offsets := make(map[int]uint64)
+	decoded := make(map[int][]byte)
+	for _, file := range a.Files {
+		if file.folder < 0 {
+			continue
+		}
+		out, ok := decoded[file.folder]
+		if !ok {
+			var err error
+			if out, err = a.ExtractFolder(file.folder, key); err != nil {
+				return nil, err
+			}
+			decoded[file.folder] = out
+		}
+		off := offsets[file.folder]
+		if off+file.Size > uint64(len(out)) {
+			return nil, errors.New("file sizes exceed folder")
+		}
+		files[file.Name] = out[off : off+file.Size]
+		offsets[file.folder] = off + file.Size
+	}
+	return files, nil
+}
+
+// Hash returns the 7z2john / hashcat (-m 11600) representation of the first
+// encrypted folder: AES parameters, CRC of the decoded data and the packed
+// stream itself
+func (a *SevenZipArchive) Hash() (string, error) {
+	folders, packPos, packSizes := a.folders, a.packPos, a.packSizes
+	if a.HeaderEncrypted {
+		folders, packPos, packSizes = a.headerFolders, a.headerPackPos, a.headerPackSizes
+	}
+	for i, f := range folders {
+		info := f.aes()
+		if info == nil {
+			continue
+		}
+		packed, err := a.packedStream(packPos, packSizes, f.firstPack)
+		if err != nil {
+			return "", err
+		}
+
+		crc, hasCRC := f.CRC, f.HasCRC
+		if !hasCRC && !a.HeaderEncrypted {
+			for _, file := range a.Files {
+				if file.folder == i && file.HasCRC {
+					crc, hasCRC = file.CRC, true
+					break
+				}
+			}
+		}
+		if !hasCRC {
+			return "", errors.New("no CRC to verify against")
+		}
+
+		// Type 0 is plain AES, 1 and 2 are AES followed by LZMA/LZMA2
+		aesIndex := f.PackedIn
+		if f.Coders[aesIndex].ID != sz7AES {
+			return "", errors.New("AES is not the first coder")
+		}
+		typ, tail := 0, ""
+		for _, bp := range f.BindPairs {
+			if bp[1] != aesIndex {
+				continue
+			}
+			switch next := f.Coders[bp[0]]; next.ID {
+			case sz7LZMA:
+				typ = 1
+			case sz7LZMA2:
+				typ = 2
+			default:
+				return "", fmt.Errorf("unsupported coder %s after AES", next.ID)
+			}
+			tail = fmt.Sprintf("$%d$%x", f.finalSize(), f.Coders[bp[0]].Props)
+		}
+		return fmt.Sprintf("$7z$%d$%d$%d$%x$%d$%x$%d$%d$%d$%x%s", typ, info.Cycles, len(info.Salt), info.Salt,
+			info.ivLen, info.IV, crc, len(packed), f.UnpackSizes[aesIndex], packed, tail), nil
+	}
+	return "", errors.New("no encrypted folder")
+}
+
+// sevenZipSolver reports the archive and cracks it if encrypted. Each
+// extracted member is a candidate next layer.
+type sevenZipSolver struct{}
+
+func (sevenZipSolver) Name() string { return "7z Archive" }
+
+func (sevenZipSolver) Applicable(l *Layer) bool {
+	return l.Type == "File (7z)"
+}
+
+func (sevenZipSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	opts := l.Opts
+	archive, err := ParseSevenZip(l.Data)
+	if err != nil {
+		fmt.Printf("    %sFailed to parse: %v%s\n", ColorYellow, err, ColorReset)
+		return nil
+	}
+
+	var key []byte
+	if info := archive.Encryption(); info != nil {
+		fmt.Printf("    Encryption: 7zAES (AES-256 + SHA256 x 2^%d)", info.Cycles)
+		if archive.HeaderEncrypted {
+			fmt.Printf(", file names encrypted")
+		}
+		fmt.Println()
+		if h, err := archive.Hash(); err == nil {
+			EmitHash(opts, "7z2john", h)
+		} else {
+			fmt.Printf("    %sNo hash: %v%s\n", ColorYellow, err, ColorReset)
+		}
+
+		if info.Cycles > sz7MaxCycles {
+			fmt.Printf("    %sKDF cost 2^%d too high for a wordlist attack.%s\n", ColorYellow, info.Cycles, ColorReset)
+			return nil
+		}
+		attackCtx, cancel := attackContext(ctx, opts)
+		defer cancel()
+		for _, word := range opts.Wordlist {
+			if attackCtx.Err() != nil {
+				break
+			}
+			if k := SevenZipKey(word, info); archive.Unlock(k) {
+				key = k
+				fmt.Printf("    %sSuccess! Password: %s%s\n", ColorGreen, word, ColorReset)
+				break
+			}
+		}
+		if key == nil {
+			if reportStopped(attackCtx) {
+				return nil
+			}
+			fmt.Printf("    %sPassword not found in wordlist (%d tried).%s\n", ColorYellow, len(opts.Wordlist), ColorReset)
+			return nil
+		}
+	}
+
+	files, err := archive.ExtractFiles(key)
+	if err != nil {
+		fmt.Printf("    %sFailed to extract: %v%s\n", ColorYellow, err, ColorReset)
+		return nil
+	}
+	fmt.Printf("    Files: %d\n", len(archive.Files))
+	var candidates []Candidate
+	for _, f := range archive.Files {
+		content, ok := files[f.Name]
+		fmt.Printf("      - %s (%d bytes)\n", f.Name, f.Size)
+		if ok && len(content) > 0 {
+			candidates = append(candidates, Candidate{Step: "7z Extract (" + f.Name + ")", Data: content})
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	ageIntro       = "age-encryption.org/v1"
+	ageArmorBegin  = "-----BEGIN AGE ENCRYPTED FILE-----"
+	ageArmorEnd    = "-----END AGE ENCRYPTED FILE-----"
+	ageScryptLabel = "age-encryption.org/v1/scrypt"
+	ageChunkSize   = 64 << 10
+)
+
+// ageMaxLogN bounds the scrypt work factor attacked: age -p uses 2^18,
+// about a second and 256 MiB per guess
+const ageMaxLogN = 20
+
+// AgeStanza is one recipient stanza of an age header
+type AgeStanza struct {
+	Type string
+	Args []string
+	Body []byte
+}
+
+// AgeFile is a parsed age file: the recipient stanzas, the header MAC with
+// the bytes it covers, and the encrypted payload
+type AgeFile struct {
+	Stanzas []AgeStanza
+	MAC     []byte
+	Header  []byte // up to and including "---"
+	Payload []byte // 16-byte nonce, then the STREAM chunks
+	Armored bool
+}
+
+// ParseAge parses a binary or ASCII-armored age file
+func ParseAge(data []byte) (*AgeFile, error) {
+	f := &AgeFile{}
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte(ageArmorBegin)) {
+		end := bytes.Index(trimmed, []byte(ageArmorEnd))
+		if end < 0 {
+			return nil, errors.New("armor end line missing")
+		}
+		body := strings.Join(strings.Fields(string(trimmed[len(ageArmorBegin):end])), "")
+		raw, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, fmt.Errorf("armor: %v", err)
+		}
+		data, f.Armored = raw, true
+	}
+	if !bytes.HasPrefix(data, []byte(ageIntro+"\n")) {
+		return nil, errors.New("no age-encryption.org/v1 header")
+	}
+
+	pos := len(ageIntro) + 1
+	line := func() (string, bool) {
+		i := bytes.IndexByte(data[pos:], '\n')
+		if i < 0 {
+			return "", false
+		}
+		l := string(data[pos : pos+i])
+		pos += i + 1
+		return l, true
+	}
+	for {
+		start := pos
+		l, ok := line()
+		if !ok {
+			return nil, errors.New("header is truncated")
+		}
+		if strings.HasPrefix(l, "--- ") {
+			mac, err := base64.RawStdEncoding.DecodeString(l[4:])
+			if err != nil {
+				return nil, fmt.Errorf("header MAC: %v", err)
+			}
+			f.MAC, f.Header, f.Payload = mac, data[:start+3], data[pos:]
+			return f, nil
+		}
+		fields := strings.Split(l, " ")
+		if fields[0] != "->" || len(fields) < 2 {
+			return nil, fmt.Errorf("unexpected header line %q", l)
+		}
+		st := AgeStanza{Type: fields[1], Args: fields[2:]}
+		for {
+			b, ok := line()
+			if !ok {
+				return nil, errors.New("stanza body is truncated")
+			}
+			chunk, err := base64.RawStdEncoding.DecodeString(b)
+			if err != nil {
+				return nil, fmt.Errorf("%s stanza body: %v", st.Type, err)
+			}
+			st.Body = append(st.Body, chunk...)
+			if len(b) < 64 {
+				break
+			}
+		}
+		f.Stanzas = append(f.Stanzas, st)
+	}
+}
+
+// describe names a stanza and what it would take to open it
+func (s AgeStanza) describe() string {
+	switch s.Type {
+	case "X25519":
+		return "X25519 (needs the AGE-SECRET-KEY-1... identity)"
+	case "scrypt":
+		if len(s.Args) == 2 {
+			return fmt.Sprintf("scrypt passphrase (work factor 2^%s)", s.Args[1])
+		}
+	case "ssh-rsa", "ssh-ed25519":
+		if len(s.Args) > 0 {
+			return fmt.Sprintf("%s (needs the SSH private key, tag %s)", s.Type, s.Args[0])
+		}
+	}
+	if strings.Contains(s.Type, "-") && !strings.HasPrefix(s.Type, "ssh-") {
+		return s.Type + " (plugin or unknown recipient)"
+	}
+	return s.Type
+}
+
+// scryptParams returns the salt and work factor of an scrypt stanza
+func (s AgeStanza) scryptParams() ([]byte, int, error) {
+	if s.Type != "scrypt" || len(s.Args) != 2 {
+		return nil, 0, errors.New("not an scrypt stanza")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(s.Args[0])
+	if err != nil || len(salt) != 16 {
+		return nil, 0, errors.New("bad scrypt salt")
+	}
+	logN, err := strconv.Atoi(s.Args[1])
+	if err != nil || logN <= 0 || logN > 30 {
+		return nil, 0, errors.New("bad scrypt work factor")
+	}
+	return salt, logN, nil
+}
+
+// UnwrapAgeScrypt derives the wrapping key from a passphrase and opens the
+// file key of an scrypt stanza
+func UnwrapAgeScrypt(s AgeStanza, passphrase string) ([]byte, bool) {
+	salt, logN, err := s.scryptParams()
+	if err != nil {
+		return nil, false
+	}
+	key, err := scrypt.Key([]byte(passphrase), append([]byte(ageScryptLabel), salt...), 1<<logN, 8, 1, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, false
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, false
+	}
+	fileKey, err := aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), s.Body, nil)
+	return fileKey, err == nil && len(fileKey) == 16
+}
+
+// VerifyMAC checks the header MAC under a file key
+func (f *AgeFile) VerifyMAC(fileKey []byte) bool {
+	key, err := hkdf.Key(sha256.New, fileKey, nil, "header", 32)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(f.Header)
+	return hmac.Equal(mac.Sum(nil), f.MAC)
+}
+
+// Decrypt opens the payload: a 16-byte nonce seeds the stream key, then
+// 64 KiB ChaCha20-Poly1305 chunks with a counter nonce whose last byte
+// marks the final chunk
+func (f *AgeFile) Decrypt(fileKey []byte) ([]byte, error) {
+	if len(f.Payload) < 16 {
+		return nil, errors.New("payload is truncated")
+	}
+	key, err := hkdf.Key(sha256.New, fileKey, f.Payload[:16], "payload", chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	rest := f.Payload[16:]
+	for counter := uint64(0); ; counter++ {
+		n := min(len(rest), ageChunkSize+aead.Overhead())
+		last := n == len(rest)
+		for i := range 8 {
+			nonce[10-i] = byte(counter >> (8 * i))
+		}
+		if last {
+			nonce[11] = 1
+		}
+		chunk, err := aead.Open(nil, nonce, rest[:n], nil)
+		if err != nil {
+			return nil, fmt.Errorf("chunk %d: %v", counter, err)
+		}
+		out = append(out, chunk...)
+		rest = rest[n:]
+		if last {
+			return out, nil
+		}
+	}
+}
+
+// ageSolver reports an age file's recipients and runs the wordlist against
+// an scrypt (passphrase) recipient. The decrypted payload is the next layer.
+type ageSolver struct{}
+
+func (ageSolver) Name() string { return "age Encryption" }
+
+func (ageSolver) Applicable(l *Layer) bool {
+	return l.Type == "File (age)" || l.Type == "File (age Armored)"
+}
+
+func (ageSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	opts := l.Opts
+	f, err := ParseAge(l.Data)
+	if err != nil {
+		fmt.Printf("    %sFailed to parse: %v%s\n", ColorYellow, err, ColorReset)
+		return nil
+	}
+	if f.Armored {
+		fmt.Printf("    Armored: yes\n")
+	}
+	var passphrase *AgeStanza
+	for i, st := range f.Stanzas {
+		fmt.Printf("    Recipient %d: %s\n", i+1, st.describe())
+		if st.Type == "scrypt" {
+			passphrase = &f.Stanzas[i]
+		}
+	}
+	fmt.Printf("    Payload: %d bytes\n", len(f.Payload))
+	if passphrase == nil {
+		fmt.Printf("    %sNo passphrase recipient: the file opens only with a recipient's private key.%s\n", ColorYellow, ColorReset)
+		return nil
+	}
+	_, logN, err := passphrase.scryptParams()
+	if err != nil {
+		fmt.Printf("    %s%v%s\n", ColorYellow, err, ColorReset)
+		return nil
+	}
+	if logN > ageMaxLogN {
+		fmt.Printf("    %sKDF cost 2^%d too high for a wordlist attack.%s\n", ColorYellow, logN, ColorReset)
+		return nil
+	}
+
+	attackCtx, cancel := attackContext(ctx, opts)
+	defer cancel()
+	var fileKey []byte
+	var found string
+	for _, word := range opts.Wordlist {
+		if attackCtx.Err() != nil {
+			break
+		}
+		if k, ok := UnwrapAgeScrypt(*passphrase, word); ok {
+			fileKey, found = k, word
+			break
+		}
+	}
+	if fileKey == nil {
+		if !reportStopped(attackCtx) {
+			fmt.Printf("    %sPassphrase not found in wordlist (%d tried).%s\n", ColorYellow, len(opts.Wordlist), ColorReset)
+		}
+		return nil
+	}
+	if !f.VerifyMAC(fileKey) {
+		fmt.Printf("    %sHeader MAC does not verify: the header was modified.%s\n", ColorYellow, ColorReset)
+	}
+	plaintext, err := f.Decrypt(fileKey)
+	if err != nil {
+		fmt.Printf("    %sFailed to decrypt: %v%s\n", ColorYellow, err, ColorReset)
+		return nil
+	}
+	return []Candidate{{Step: "age Decrypt", Data: plaintext, Details: []string{"Passphrase: " + found}}}
+}
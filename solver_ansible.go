@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const ansibleVaultHeader = "$ANSIBLE_VAULT;"
+
+// ansibleVaultIterations is the fixed PBKDF2-SHA256 count of the AES256
+// vault format
+const ansibleVaultIterations = 10000
+
+// AnsibleVault is a parsed $ANSIBLE_VAULT envelope
+type AnsibleVault struct {
+	Version    string // 1.1, or 1.2 with a vault ID
+	Cipher     string
+	VaultID    string
+	Salt       []byte
+	HMAC       []byte
+	Ciphertext []byte
+}
+
+// FindAnsibleVault locates a vault blob in data, either a whole vaulted
+// file or a `!vault |` value indented inside YAML, and parses it
+func FindAnsibleVault(data []byte) (*AnsibleVault, error) {
+	start := bytes.Index(data, []byte(ansibleVaultHeader))
+	if start < 0 {
+		return nil, nil
+	}
+	lines := strings.Split(string(data[start:]), "\n")
+	fields := strings.Split(strings.TrimSpace(lines[0]), ";")
+	if len(fields) < 3 {
+		return nil, errors.New("truncated header line")
+	}
+	v := &AnsibleVault{Version: fields[1], Cipher: fields[2]}
+	if len(fields) > 3 {
+		v.VaultID = fields[3]
+	}
+	if v.Cipher != "AES256" {
+		return v, fmt.Errorf("unsupported cipher %s", v.Cipher)
+	}
+
+	// The body is hex, wrapped at 80 columns, of three hex lines: salt,
+	// HMAC and ciphertext
+	var body strings.Builder
+	for _, l := range lines[1:] {
+		l = strings.TrimSpace(l)
+		if l == "" || !isHexString(l) {
+			break
+		}
+		body.WriteString(l)
+	}
+	inner, err := hex.DecodeString(body.String())
+	if err != nil {
+		return v, fmt.Errorf("body: %v", err)
+	}
+	parts := strings.Split(strings.TrimSpace(string(inner)), "\n")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("expected salt, HMAC and ciphertext, got %d parts", len(parts))
+	}
+	decoded := make([][]byte, 3)
+	for i, p := range parts {
+		if decoded[i], err = hex.DecodeString(strings.TrimSpace(p)); err != nil {
+			return v, fmt.Errorf("envelope part %d: %v", i+1, err)
+		}
+	}
+	v.Salt, v.HMAC, v.Ciphertext = decoded[0], decoded[1], decoded[2]
+	if len(v.HMAC) != sha256.Size || len(v.Ciphertext) == 0 || len(v.Ciphertext)%aes.BlockSize != 0 {
+		return v, errors.New("malformed envelope")
+	}
+	return v, nil
+}
+
+func isHexString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", rune(s[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash is the ansible2john line (hashcat mode 16900)
+func (v *AnsibleVault) Hash() string {
+	return fmt.Sprintf("$ansible$0*0*%x*%x*%x", v.Salt, v.Ciphertext, v.HMAC)
+}
+
+// Decrypt checks a password against the HMAC and returns the plaintext:
+// PBKDF2 yields the AES key, the HMAC key and the CTR IV
+func (v *AnsibleVault) Decrypt(password string) ([]byte, bool) {
+	derived, err := pbkdf2.Key(sha256.New, password, v.Salt, ansibleVaultIterations, 80)
+	if err != nil {
+		return nil, false
+	}
+	mac := hmac.New(sha256.New, derived[32:64])
+	mac.Write(v.Ciphertext)
+	if !hmac.Equal(mac.Sum(nil), v.HMAC) {
+		return nil, false
+	}
+	block, err := aes.NewCipher(derived[:32])
+	if err != nil {
+		return nil, false
+	}
+	plain := make([]byte, len(v.Ciphertext))
+	cipher.NewCTR(block, derived[64:80]).XORKeyStream(plain, v.Ciphertext)
+	if pad := int(plain[len(plain)-1]); pad >= 1 && pad <= aes.BlockSize {
+		plain = plain[:len(plain)-pad]
+	}
+	return plain, true
+}
+
+// ansibleSolver reports a vault envelope, whole or a `!vault |` value
+// inside YAML, emits its hash and tries the wordlist. The decrypted content
+// is the next layer.
+type ansibleSolver struct{}
+
+func (ansibleSolver) Name() string { return "Ansible Vault" }
+
+func (ansibleSolver) Applicable(l *Layer) bool {
+	return bytes.Contains(l.Data, []byte(ansibleVaultHeader))
+}
+
+func (ansibleSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	opts := l.Opts
+	v, err := FindAnsibleVault(l.Data)
+	if v == nil && err == nil {
+		fmt.Printf("    %sNo complete vault envelope found.%s\n", ColorYellow, ColorReset)
+		return nil
+	}
+	if err != nil {
+		fmt.Printf("    %sFailed to parse: %v%s\n", ColorYellow, err, ColorReset)
+		return nil
+	}
+	fmt.Printf("    Format: %s %s, PBKDF2-SHA256 x %d\n", v.Version, v.Cipher, ansibleVaultIterations)
+	if v.VaultID != "" {
+		fmt.Printf("    Vault ID: %s\n", v.VaultID)
+	}
+	EmitHash(opts, "ansible2john", v.Hash())
+
+	attackCtx, cancel := attackContext(ctx, opts)
+	defer cancel()
+	for _, word := range opts.Wordlist {
+		if attackCtx.Err() != nil {
+			break
+		}
+		plain, ok := v.Decrypt(word)
+		if !ok {
+			continue
+		}
+		return []Candidate{{Step: "Ansible Vault Decrypt", Data: plain, Details: []string{"Password: " + word}}}
+	}
+	if !reportStopped(attackCtx) {
+		fmt.Printf("    %sPassword not found in wordlist (%d tried).%s\n", ColorYellow, len(opts.Wordlist), ColorReset)
+	}
+	return nil
+}
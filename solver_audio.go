@@ -0,0 +1,695 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxOggHeaderPackets bounds the packets of each Ogg stream searched for
+// comment headers; the tags always come in the first few
+const maxOggHeaderPackets = 8
+
+// minFieldLayer is the shortest field value analysed as a layer: shorter
+// titles and numbers match an encoding alphabet by chance
+const minFieldLayer = 16
+
+// maxFieldShown is the longest field value printed in full
+const maxFieldShown = 200
+
+// AudioField is a text tag: an ID3 text frame, an ID3v1 field or a Vorbis
+// comment
+type AudioField struct {
+	Name string // frame ID or comment key, e.g. TIT2, COMM (desc), ARTIST
+	Text string
+}
+
+// AudioAttachment is binary data carried in the tags: embedded pictures,
+// encapsulated objects, private and unknown frames
+type AudioAttachment struct {
+	Name        string // APIC, GEOB, PRIV, FLAC Picture, ...
+	MIME        string
+	Description string
+	Data        []byte
+}
+
+// AudioMetadata is everything readable from the tags of an audio file
+type AudioMetadata struct {
+	Format      string // ID3v2.3, FLAC, Ogg Vorbis, ...
+	Fields      []AudioField
+	Attachments []AudioAttachment
+	Padding     []byte // tag or block padding that is not all zero
+}
+
+// id3PictureTypes names the APIC picture types
+var id3PictureTypes = []string{
+	"other", "file icon", "other file icon", "front cover", "back cover", "leaflet page", "media",
+	"lead artist", "artist", "conductor", "band", "composer", "lyricist", "recording location",
+	"during recording", "during performance", "video capture", "bright coloured fish", "illustration",
+	"band logotype", "publisher logotype",
+}
+
+// ParseAudioMetadata reads the tags of an MP3 (ID3v2 and ID3v1), FLAC or
+// Ogg (Vorbis, Opus, FLAC, Theora) file. A damaged tag returns what was read
+// before the damage together with the error.
+func ParseAudioMetadata(data []byte) (*AudioMetadata, error) {
+	var meta *AudioMetadata
+	var err error
+	switch {
+	case bytes.HasPrefix(data, []byte("ID3")):
+		meta, err = ParseID3v2(data)
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		meta, err = ParseFLAC(data)
+	case bytes.HasPrefix(data, []byte("OggS")):
+		meta, err = ParseOgg(data)
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		meta = &AudioMetadata{Format: "MPEG audio"}
+	default:
+		return nil, errors.New("not an MP3, FLAC or Ogg file")
+	}
+	if meta != nil && (meta.Format == "MPEG audio" || strings.HasPrefix(meta.Format, "ID3")) {
+		meta.Fields = append(meta.Fields, ParseID3v1(data)...)
+	}
+	return meta, err
+}
+
+// ParseID3v2 reads the ID3v2.2, 2.3 or 2.4 tag at the start of data
+func ParseID3v2(data []byte) (*AudioMetadata, error) {
+	if len(data) < 10 || !bytes.HasPrefix(data, []byte("ID3")) {
+		return nil, errors.New("no ID3v2 tag")
+	}
+	version, flags := data[3], data[5]
+	meta := &AudioMetadata{Format: fmt.Sprintf("ID3v2.%d", version)}
+	if version < 2 || version > 4 {
+		return meta, fmt.Errorf("unsupported ID3v2 version %d", version)
+	}
+	end := 10 + synchsafe(data[6:10])
+	if end > len(data) {
+		end = len(data)
+	}
+	tag := data[10:end]
+	if flags&0x80 != 0 && version < 4 {
+		tag = id3Unsynchronise(tag)
+	}
+	if flags&0x40 != 0 && version > 2 && len(tag) >= 4 {
+		size := int(binary.BigEndian.Uint32(tag)) + 4 // v2.3 excludes the size itself
+		if version == 4 {
+			size = synchsafe(tag[:4])
+		}
+		tag = tag[min(size, len(tag)):]
+	}
+
+	idLen, headerLen := 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+	pos := 0
+	for pos+headerLen <= len(tag) {
+		if tag[pos] == 0 { // padding
+			if rest := tag[pos:]; !allZero(rest) {
+				meta.Padding = rest
+			}
+			return meta, nil
+		}
+		id := string(tag[pos : pos+idLen])
+		var size int
+		var format byte
+		switch version {
+		case 2:
+			size = int(tag[pos+3])<<16 | int(tag[pos+4])<<8 | int(tag[pos+5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(tag[pos+4:]))
+			format = tag[pos+9]
+		case 4:
+			size = synchsafe(tag[pos+4 : pos+8])
+			format = tag[pos+9]
+		}
+		pos += headerLen
+		if size < 0 || pos+size > len(tag) {
+			return meta, fmt.Errorf("frame %q overruns the tag", id)
+		}
+		body := tag[pos : pos+size]
+		pos += size
+
+		body, err := id3FrameBody(body, version, format)
+		if err != nil {
+			meta.Attachments = append(meta.Attachments, AudioAttachment{Name: id, Description: err.Error(), Data: body})
+			continue
+		}
+		meta.addID3Frame(id, body)
+	}
+	return meta, nil
+}
+
+// id3FrameBody strips the per-frame prefixes and undoes the unsynchronisation
+// and compression flagged in a v2.3/v2.4 frame header. Encrypted frames are
+// returned as they are, with an error.
+func id3FrameBody(body []byte, version, format byte) ([]byte, error) {
+	var compressed, encrypted bool
+	switch version {
+	case 3:
+		compressed, encrypted = format&0x80 != 0, format&0x40 != 0
+		if compressed && len(body) >= 4 {
+			body = body[4:] // decompressed size
+		}
+		if encrypted && len(body) >= 1 {
+			body = body[1:]
+		}
+		if format&0x20 != 0 && len(body) >= 1 {
+			body = body[1:] // group
+		}
+	case 4:
+		compressed, encrypted = format&0x08 != 0, format&0x04 != 0
+		if format&0x40 != 0 && len(body) >= 1 {
+			body = body[1:] // group
+		}
+		if encrypted && len(body) >= 1 {
+			body = body[1:]
+		}
+		if format&0x01 != 0 && len(body) >= 4 {
+			body = body[4:] // data length indicator
+		}
+		if format&0x02 != 0 {
+			body = id3Unsynchronise(body)
+		}
+	}
+	if encrypted {
+		return body, errors.New("encrypted frame")
+	}
+	if compressed {
+		out, err := OperationByName("Zlib Inflate").Apply(body)
+		if err != nil {
+			return body, errors.New("compressed frame: " + err.Error())
+		}
+		return out, nil
+	}
+	return body, nil
+}
+
+// addID3Frame decodes one frame into a field or an attachment
+func (m *AudioMetadata) addID3Frame(id string, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	enc, rest := body[0], body[1:]
+	switch {
+	case id == "TXXX" || id == "TXX" || id == "WXXX" || id == "WXX":
+		desc, value := id3Split(rest, enc)
+		text := id3Text(value, enc)
+		if id[0] == 'W' {
+			text = latin1(value)
+		}
+		m.Fields = append(m.Fields, AudioField{Name: id3Named(id, id3Text(desc, enc)), Text: text})
+	case id[0] == 'T':
+		m.Fields = append(m.Fields, AudioField{Name: id, Text: strings.ReplaceAll(id3Text(rest, enc), "\x00", " / ")})
+	case id[0] == 'W':
+		m.Fields = append(m.Fields, AudioField{Name: id, Text: latin1(trimNUL(body))})
+	case (id == "COMM" || id == "COM" || id == "USLT" || id == "ULT") && len(rest) >= 3:
+		desc, text := id3Split(rest[3:], enc)
+		m.Fields = append(m.Fields, AudioField{Name: id3Named(id, id3Text(desc, enc)), Text: id3Text(text, enc)})
+	case id == "APIC":
+		mime, rest := id3Split(rest, 0)
+		if len(rest) == 0 {
+			return
+		}
+		kind := rest[0]
+		desc, picture := id3Split(rest[1:], enc)
+		m.Attachments = append(m.Attachments, AudioAttachment{Name: id, MIME: latin1(mime), Description: pictureDescription(uint32(kind), id3Text(desc, enc)), Data: picture})
+	case id == "PIC" && len(rest) >= 4:
+		desc, picture := id3Split(rest[4:], enc)
+		m.Attachments = append(m.Attachments, AudioAttachment{Name: id, MIME: "image/" + strings.ToLower(latin1(rest[:3])), Description: pictureDescription(uint32(rest[3]), id3Text(desc, enc)), Data: picture})
+	case id == "GEOB" || id == "GEO":
+		mime, rest := id3Split(rest, 0)
+		name, rest := id3Split(rest, enc)
+		desc, object := id3Split(rest, enc)
+		m.Attachments = append(m.Attachments, AudioAttachment{Name: id, MIME: latin1(mime), Description: strings.TrimSpace(id3Text(name, enc) + " " + id3Text(desc, enc)), Data: object})
+	case id == "PRIV":
+		owner, data := id3Split(body, 0)
+		m.Attachments = append(m.Attachments, AudioAttachment{Name: id, Description: latin1(owner), Data: data})
+	default:
+		m.Attachments = append(m.Attachments, AudioAttachment{Name: id, Data: body})
+	}
+}
+
+// ParseID3v1 reads the 128-byte ID3v1 (or v1.1) tag at the end of data
+func ParseID3v1(data []byte) []AudioField {
+	if len(data) < 128 || !bytes.HasPrefix(data[len(data)-128:], []byte("TAG")) {
+		return nil
+	}
+	tag := data[len(data)-128:]
+	var fields []AudioField
+	add := func(name string, b []byte) {
+		if s := strings.TrimSpace(latin1(trimNUL(b))); s != "" {
+			fields = append(fields, AudioField{Name: "ID3v1 " + name, Text: s})
+		}
+	}
+	add("Title", tag[3:33])
+	add("Artist", tag[33:63])
+	add("Album", tag[63:93])
+	add("Year", tag[93:97])
+	comment := tag[97:127]
+	if comment[28] == 0 && comment[29] != 0 { // v1.1 track number
+		add("Track", []byte(fmt.Sprint(comment[29])))
+		comment = comment[:28]
+	}
+	add("Comment", comment)
+	return fields
+}
+
+// ParseFLAC reads the metadata blocks of a native FLAC file
+func ParseFLAC(data []byte) (*AudioMetadata, error) {
+	if !bytes.HasPrefix(data, []byte("fLaC")) {
+		return nil, errors.New("not a FLAC file")
+	}
+	meta := &AudioMetadata{Format: "FLAC"}
+	pos := 4
+	for {
+		if pos+4 > len(data) {
+			return meta, errors.New("truncated metadata block")
+		}
+		last, kind := data[pos]&0x80 != 0, data[pos]&0x7F
+		size := int(data[pos+1])<<16 | int(data[pos+2])<<8 | int(data[pos+3])
+		pos += 4
+		if pos+size > len(data) {
+			return meta, errors.New("truncated metadata block")
+		}
+		if err := meta.addFLACBlock(kind, data[pos:pos+size]); err != nil {
+			return meta, err
+		}
+		pos += size
+		if last {
+			return meta, nil
+		}
+	}
+}
+
+// addFLACBlock decodes one FLAC metadata block, in a native file or an Ogg
+// FLAC stream
+func (m *AudioMetadata) addFLACBlock(kind byte, block []byte) error {
+	switch kind {
+	case 0, 3, 5: // STREAMINFO, SEEKTABLE, CUESHEET
+	case 1:
+		if !allZero(block) {
+			m.Padding = block
+		}
+	case 2:
+		if len(block) >= 4 {
+			m.Attachments = append(m.Attachments, AudioAttachment{Name: "FLAC Application", Description: latin1(block[:4]), Data: block[4:]})
+		}
+	case 4:
+		return m.addVorbisComment(block)
+	case 6:
+		picture, err := parseFLACPicture(block)
+		if err != nil {
+			return err
+		}
+		m.Attachments = append(m.Attachments, picture)
+	default:
+		m.Attachments = append(m.Attachments, AudioAttachment{Name: fmt.Sprintf("FLAC Block %d", kind), Data: block})
+	}
+	return nil
+}
+
+// parseFLACPicture decodes a FLAC PICTURE block, also found base64-encoded
+// in METADATA_BLOCK_PICTURE comments
+func parseFLACPicture(block []byte) (AudioAttachment, error) {
+	errShort := errors.New("truncated picture block")
+	if len(block) < 8 {
+		return AudioAttachment{}, errShort
+	}
+	kind := binary.BigEndian.Uint32(block)
+	n := int(binary.BigEndian.Uint32(block[4:]))
+	if 8+n+4 > len(block) || n < 0 {
+		return AudioAttachment{}, errShort
+	}
+	mime, rest := string(block[8:8+n]), block[8+n:]
+	n = int(binary.BigEndian.Uint32(rest))
+	if 4+n+20 > len(rest) || n < 0 {
+		return AudioAttachment{}, errShort
+	}
+	desc, rest := string(rest[4:4+n]), rest[4+n:]
+	n = int(binary.BigEndian.Uint32(rest[16:])) // after width, height, depth, colours
+	if 20+n > len(rest) || n < 0 {
+		return AudioAttachment{}, errShort
+	}
+	return AudioAttachment{Name: "FLAC Picture", MIME: mime, Description: pictureDescription(kind, desc), Data: rest[20 : 20+n]}, nil
+}
+
+// addVorbisComment decodes a Vorbis comment header (vendor string, then
+// KEY=value pairs) as used by Vorbis, Opus, Theora and FLAC
+func (m *AudioMetadata) addVorbisComment(data []byte) error {
+	errShort := errors.New("truncated Vorbis comment")
+	next := func() ([]byte, bool) {
+		if len(data) < 4 {
+			return nil, false
+		}
+		n := binary.LittleEndian.Uint32(data)
+		if uint64(n) > uint64(len(data)-4) {
+			return nil, false
+		}
+		s := data[4 : 4+n]
+		data = data[4+n:]
+		return s, true
+	}
+	vendor, ok := next()
+	if !ok || len(data) < 4 {
+		return errShort
+	}
+	if len(vendor) > 0 {
+		m.Fields = append(m.Fields, AudioField{Name: "Vendor", Text: string(vendor)})
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+	for range count {
+		comment, ok := next()
+		if !ok {
+			return errShort
+		}
+		key, value, _ := strings.Cut(string(comment), "=")
+		key = strings.ToUpper(key)
+		switch key {
+		case "METADATA_BLOCK_PICTURE", "COVERART":
+			raw, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				m.Fields = append(m.Fields, AudioField{Name: key, Text: value})
+				continue
+			}
+			if key == "COVERART" {
+				m.Attachments = append(m.Attachments, AudioAttachment{Name: key, Data: raw})
+			} else if picture, err := parseFLACPicture(raw); err == nil {
+				m.Attachments = append(m.Attachments, picture)
+			} else {
+				m.Attachments = append(m.Attachments, AudioAttachment{Name: key, Data: raw})
+			}
+		default:
+			m.Fields = append(m.Fields, AudioField{Name: key, Text: value})
+		}
+	}
+	return nil
+}
+
+// ParseOgg reassembles the first packets of every logical stream of an Ogg
+// file and decodes their comment headers
+func ParseOgg(data []byte) (*AudioMetadata, error) {
+	meta := &AudioMetadata{Format: "Ogg"}
+	type stream struct {
+		packets int
+		partial []byte
+		flac    bool
+	}
+	streams := make(map[uint32]*stream)
+	var codecs []string
+	pos := 0
+	for pos < len(data) {
+		if len(data)-pos < 27 || !bytes.HasPrefix(data[pos:], []byte("OggS")) {
+			return meta, fmt.Errorf("bad Ogg page at offset %d", pos)
+		}
+		page := data[pos:]
+		serial := binary.LittleEndian.Uint32(page[14:])
+		nsegs := int(page[26])
+		if len(page) < 27+nsegs {
+			return meta, errors.New("truncated Ogg page")
+		}
+		lacing := page[27 : 27+nsegs]
+		body := page[27+nsegs:]
+		size := 0
+		for _, l := range lacing {
+			size += int(l)
+		}
+		if size > len(body) {
+			return meta, errors.New("truncated Ogg page")
+		}
+		pos += 27 + nsegs + size
+
+		s := streams[serial]
+		if s == nil {
+			s = &stream{}
+			streams[serial] = s
+		}
+		off := 0
+		for _, l := range lacing {
+			if s.packets >= maxOggHeaderPackets {
+				break
+			}
+			s.partial = append(s.partial, body[off:off+int(l)]...)
+			off += int(l)
+			if l == 255 {
+				continue
+			}
+			packet := s.partial
+			s.partial = nil
+			s.packets++
+			if s.packets == 1 {
+				if codec := oggCodec(packet); codec != "" {
+					codecs = append(codecs, codec)
+					s.flac = codec == "FLAC"
+				}
+				continue
+			}
+			var err error
+			switch {
+			case bytes.HasPrefix(packet, []byte("\x03vorbis")):
+				err = meta.addVorbisComment(packet[7:])
+			case bytes.HasPrefix(packet, []byte("OpusTags")):
+				err = meta.addVorbisComment(packet[8:])
+			case bytes.HasPrefix(packet, []byte("\x81theora")):
+				err = meta.addVorbisComment(packet[7:])
+			case s.flac && len(packet) >= 4:
+				err = meta.addFLACBlock(packet[0]&0x7F, packet[4:])
+			}
+			if err != nil {
+				return meta, err
+			}
+		}
+	}
+	if len(codecs) > 0 {
+		meta.Format = "Ogg " + strings.Join(codecs, ", ")
+	}
+	return meta, nil
+}
+
+// oggCodec names the codec of an Ogg stream from its first packet
+func oggCodec(packet []byte) string {
+	for prefix, codec := range map[string]string{"\x01vorbis": "Vorbis", "OpusHead": "Opus", "\x7FFLAC": "FLAC", "\x80theora": "Theora", "Speex   ": "Speex"} {
+		if bytes.HasPrefix(packet, []byte(prefix)) {
+			return codec
+		}
+	}
+	return ""
+}
+
+// synchsafe decodes an ID3 integer holding 7 bits per byte
+func synchsafe(b []byte) int {
+	n := 0
+	for _, c := range b {
+		n = n<<7 | int(c&0x7F)
+	}
+	return n
+}
+
+// id3Unsynchronise removes the 0x00 stuffed after every 0xFF
+func id3Unsynchronise(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xFF, 0x00}, []byte{0xFF})
+}
+
+// id3Split cuts b at the string terminator of encoding enc: a single NUL,
+// or an aligned NUL pair for UTF-16
+func id3Split(b []byte, enc byte) ([]byte, []byte) {
+	if enc == 1 || enc == 2 {
+		for i := 0; i+1 < len(b); i += 2 {
+			if b[i] == 0 && b[i+1] == 0 {
+				return b[:i], b[i+2:]
+			}
+		}
+		return b, nil
+	}
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		return b[:i], b[i+1:]
+	}
+	return b, nil
+}
+
+// id3Text decodes an ID3 string: ISO-8859-1, UTF-16 with a BOM, UTF-16BE
+// or UTF-8
+func id3Text(b []byte, enc byte) string {
+	switch enc {
+	case 1, 2:
+		bigEndian := enc == 2
+		if len(b) >= 2 && (b[0] == 0xFE && b[1] == 0xFF || b[0] == 0xFF && b[1] == 0xFE) {
+			bigEndian = b[0] == 0xFE
+			b = b[2:]
+		}
+		units := make([]uint16, len(b)/2)
+		for i := range units {
+			if bigEndian {
+				units[i] = binary.BigEndian.Uint16(b[2*i:])
+			} else {
+				units[i] = binary.LittleEndian.Uint16(b[2*i:])
+			}
+		}
+		return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+	case 3:
+		return string(trimNUL(b))
+	}
+	return latin1(trimNUL(b))
+}
+
+func latin1(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+func allZero(b []byte) bool {
+	return len(trimNUL(b)) == 0
+}
+
+func trimNUL(b []byte) []byte {
+	return bytes.TrimRight(b, "\x00")
+}
+
+// id3Named appends a frame's description to its ID
+func id3Named(id, desc string) string {
+	if desc == "" {
+		return id
+	}
+	return fmt.Sprintf("%s (%s)", id, desc)
+}
+
+func pictureDescription(kind uint32, desc string) string {
+	name := fmt.Sprintf("picture type %d", kind)
+	if int(kind) < len(id3PictureTypes) {
+		name = id3PictureTypes[kind]
+	}
+	if desc != "" {
+		name += fmt.Sprintf(" %q", desc)
+	}
+	return name
+}
+
+// attachmentName is the artifact name of an attachment, its extension
+// taken from the content
+func attachmentName(a AudioAttachment) string {
+	name := strings.ToLower(strings.NewReplacer(" ", "_", "/", "_").Replace(a.Name))
+	ext := ".bin"
+	switch http.DetectContentType(a.Data) {
+	case "image/png":
+		ext = ".png"
+	case "image/jpeg":
+		ext = ".jpg"
+	case "image/gif":
+		ext = ".gif"
+	case "image/bmp":
+		ext = ".bmp"
+	case "image/webp":
+		ext = ".webp"
+	case "text/plain; charset=utf-8":
+		ext = ".txt"
+	}
+	return "audio_" + name + ext
+}
+
+// shortText quotes s, cutting it to maxFieldShown runes
+func shortText(s string) string {
+	if r := []rune(s); len(r) > maxFieldShown {
+		return fmt.Sprintf("%q... (%d chars)", string(r[:maxFieldShown]), len(r))
+	}
+	return fmt.Sprintf("%q", s)
+}
+
+// analyzeAudio prints the tags of an audio file. Every field is scanned
+// for flags; long fields and those identifying as an encoding, hash or file,
+// embedded pictures and objects, and non-zero padding are analysed as
+// layers (pictures going through the image analysis). It reports whether a
+// flag was found.
+func analyzeAudio(ctx context.Context, data []byte, opts *Options, chain []string) bool {
+	flagsBefore := len(opts.flags)
+	meta, err := ParseAudioMetadata(data)
+	if meta == nil {
+		fmt.Printf("    %sFailed to parse: %v%s\n", ColorYellow, err, ColorReset)
+		return false
+	}
+	fmt.Printf("    Format: %s, %d fields, %d attachments\n", meta.Format, len(meta.Fields), len(meta.Attachments))
+	if err != nil {
+		fmt.Printf("    %sTag structure: %v%s\n", ColorYellow, err, ColorReset)
+	}
+
+	layer := func(name string, payload []byte) {
+		fmt.Printf("%s[+] Audio %s (%d bytes):%s\n", ColorBlue, name, len(payload), ColorReset)
+		orchestrate(ctx, payload, opts, withStep(chain, "Audio "+name))
+	}
+	var layers []AudioField
+	seen := make(map[string]bool)
+	for _, f := range meta.Fields {
+		fmt.Printf("    %s: %s\n", f.Name, shortText(f.Text))
+		reportFlags(opts, []byte(f.Text), withStep(chain, "Audio "+f.Name))
+		text := strings.TrimSpace(f.Text)
+		if seen[text] || text == "" {
+			continue
+		}
+		seen[text] = true
+		if len(text) < minFieldLayer {
+			continue
+		}
+		if kind, _ := IdentifyType([]byte(text)); kind != "Unknown" || len(text) >= 2*minFieldLayer {
+			layers = append(layers, f)
+		}
+	}
+	for _, a := range meta.Attachments {
+		line := fmt.Sprintf("%s: %d bytes", a.Name, len(a.Data))
+		if a.MIME != "" {
+			line += ", " + a.MIME
+		}
+		if a.Description != "" {
+			line += ", " + a.Description
+		}
+		fmt.Printf("    %s%s%s\n", ColorYellow, line, ColorReset)
+	}
+	if len(meta.Padding) > 0 {
+		fmt.Printf("    %sPadding: %d bytes, not all zero%s\n", ColorYellow, len(meta.Padding), ColorReset)
+	}
+
+	for _, f := range layers {
+		layer(f.Name, []byte(strings.TrimSpace(f.Text)))
+	}
+	for _, a := range meta.Attachments {
+		if len(a.Data) == 0 {
+			continue
+		}
+		saveArtifact(opts, attachmentName(a), a.Data)
+		layer(a.Name, a.Data)
+	}
+	if len(meta.Padding) > 0 {
+		saveArtifact(opts, "audio_padding.bin", meta.Padding)
+		layer("Padding", meta.Padding)
+	}
+	return len(opts.flags) > flagsBefore
+}
+
+// audioSolver runs analyzeAudio; a flag in the tags or attachments ends the
+// chain
+type audioSolver struct{}
+
+func (audioSolver) Name() string { return "Audio Metadata" }
+
+func (audioSolver) Applicable(l *Layer) bool {
+	switch l.Type {
+	case "File (MP3)", "File (MPEG Audio)", "File (OGG)", "File (FLAC)":
+		return true
+	}
+	return false
+}
+
+func (audioSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	flagsBefore := len(l.Opts.flags)
+	analyzeAudio(ctx, l.Data, l.Opts, l.Chain)
+	return flagCandidate(l, flagsBefore, "Audio Metadata")
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"math/bits"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxCRCPreimage = 4         // longest preimage searched for; longer ones are not unique
+	maxCRCClaims   = 16        // 8-hex-digit values checked against the rest of a layer
+	maxCRCText     = 64 * 1024 // larger layers are not scanned for CRC claims
+)
+
+// crc32Variants are the CRC-32 polynomials tried, the common one first
+var crc32Variants = []struct {
+	Name  string
+	Table *crc32.Table
+}{
+	{"CRC-32", crc32.IEEETable},
+	{"CRC-32C", crc32.MakeTable(crc32.Castagnoli)},
+}
+
+var crc32Claim = regexp.MustCompile(`\b(?:0[xX])?[0-9a-fA-F]{8}\b`)
+
+// ParseCRC32 reads an 8-hex-digit CRC, with or without 0x
+func ParseCRC32(s string) (uint32, bool) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "0x"), "0X")
+	if len(s) != 8 {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	return uint32(v), err == nil
+}
+
+// ForgeCRC32 returns the 4 bytes that, appended to data, make its CRC the
+// target. CRC-32 is linear, so nothing is searched: walking back from the
+// target fixes which table entry each appended byte has to select, and
+// walking forward from the data's CRC gives the bytes that select them.
+func ForgeCRC32(data []byte, target uint32, table *crc32.Table) [4]byte {
+	// The top bytes of a CRC-32 table's entries are all distinct
+	var byTop [256]byte
+	for i, v := range table {
+		byTop[v>>24] = byte(i)
+	}
+	var index [4]byte
+	reg := ^target
+	for k := 3; k >= 0; k-- {
+		index[k] = byTop[reg>>24]
+		reg = (reg ^ table[index[k]]) << 8
+	}
+
+	var out [4]byte
+	reg = ^crc32.Checksum(data, table)
+	for k := range out {
+		out[k] = byte(reg) ^ index[k]
+		reg = table[index[k]] ^ reg>>8
+	}
+	return out
+}
+
+// CRC32Preimages returns the printable inputs of up to 3 bytes with the
+// given CRC, found by exhaustive search.
+func CRC32Preimages(target uint32, table *crc32.Table) []string {
+	var found []string
+	buf := make([]byte, 0, maxCRCPreimage)
+	var search func()
+	search = func() {
+		if len(buf) > 0 && crc32.Checksum(buf, table) == target {
+			found = append(found, string(buf))
+		}
+		if len(buf) == maxCRCPreimage-1 {
+			return
+		}
+		for c := byte(' '); c <= '~'; c++ {
+			buf = append(buf, c)
+			search()
+			buf = buf[:len(buf)-1]
+		}
+	}
+	search()
+	return found
+}
+
+// crcClaims finds the distinct 8-hex-digit values in a text layer
+func crcClaims(text string) []string {
+	if len(text) > maxCRCText {
+		return nil
+	}
+	var claims []string
+	seen := make(map[string]bool)
+	for _, c := range crc32Claim.FindAllString(text, -1) {
+		if !seen[strings.ToLower(c)] && len(claims) < maxCRCClaims {
+			seen[strings.ToLower(c)] = true
+			claims = append(claims, c)
+		}
+	}
+	return claims
+}
+
+// crcPiece is accompanying data a CRC claim may cover
+type crcPiece struct {
+	label string
+	data  []byte
+}
+
+// crcPieces lists what a claim may be the CRC of: each line and the whole
+// text, with the claims and the separators before them cut out
+func crcPieces(text string, claims []string) []crcPiece {
+	strip := func(s string) string {
+		for _, c := range claims {
+			s = strings.ReplaceAll(s, c, "")
+		}
+		return strings.TrimSpace(strings.Trim(strings.TrimSpace(s), ":=,;"))
+	}
+	var pieces []crcPiece
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if s := strip(line); s != "" && len(lines) > 1 {
+			pieces = append(pieces, crcPiece{fmt.Sprintf("line %d (%s)", i+1, previewData([]byte(s))), []byte(s)})
+		}
+	}
+	if s := strip(text); s != "" {
+		pieces = append(pieces, crcPiece{"the rest of the input", []byte(s)})
+	}
+	return pieces
+}
+
+// crcSolver inverts a lone CRC-32 to its short printable input, and checks
+// CRC-looking values against the data that accompanies them, printing the
+// bytes that would make the data match when none does
+type crcSolver struct{}
+
+func (crcSolver) Name() string { return "CRC32" }
+
+func (crcSolver) Applicable(l *Layer) bool {
+	if l.Type == "Hash (CRC32)" {
+		return true
+	}
+	if !isPrintable(l.Data) {
+		return false
+	}
+	claims := crcClaims(string(l.Data))
+	return len(claims) > 0 && len(crcPieces(string(l.Data), claims)) > 0
+}
+
+func (crcSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	text := string(l.Data)
+	if target, ok := ParseCRC32(text); ok {
+		var candidates []Candidate
+		for _, v := range crc32Variants {
+			for _, p := range CRC32Preimages(target, v.Table) {
+				candidates = append(candidates, Candidate{
+					Step:    v.Name + " Preimage",
+					Data:    []byte(p),
+					Score:   magicScore([]byte(p)),
+					Details: []string{fmt.Sprintf("%s(%q) = %08x", v.Name, p, target)},
+					Final:   true,
+				})
+			}
+			// Every CRC has exactly one 4-byte input, so a printable one only
+			// says the value could be a CRC, not that it is one.
+			if forged := ForgeCRC32(nil, target, v.Table); isPrintable(forged[:]) {
+				candidates = append(candidates, Candidate{
+					Step:    v.Name + " Forged Input",
+					Data:    forged[:],
+					Score:   magicScore(forged[:]),
+					Details: []string{fmt.Sprintf("%s(%q) = %08x", v.Name, forged[:], target)},
+				})
+			}
+		}
+		if len(candidates) == 0 {
+			fmt.Printf("    %sNo printable input of %d bytes or fewer has this CRC; longer inputs are not unique.%s\n", ColorYellow, maxCRCPreimage, ColorReset)
+		}
+		return candidates
+	}
+
+	claims := crcClaims(text)
+	pieces := crcPieces(text, claims)
+	for _, claim := range claims {
+		target, _ := ParseCRC32(claim)
+		matched := false
+		for _, v := range crc32Variants {
+			for _, p := range pieces {
+				switch crc := crc32.Checksum(p.data, v.Table); crc {
+				case target:
+					fmt.Printf("    %s%s: %s of %s%s\n", ColorGreen, claim, v.Name, p.label, ColorReset)
+					matched = true
+				case bits.ReverseBytes32(target):
+					fmt.Printf("    %s%s: %s of %s, bytes little-endian%s\n", ColorGreen, claim, v.Name, p.label, ColorReset)
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			rest := pieces[len(pieces)-1]
+			forged := ForgeCRC32(rest.data, target, crc32.IEEETable)
+			fmt.Printf("    %s%s: no CRC-32 or CRC-32C of the accompanying data matches%s\n", ColorYellow, claim, ColorReset)
+			fmt.Printf("    Appending %x to %s gives CRC-32 %08x\n", forged, rest.label, target)
+		}
+	}
+	return nil
+}
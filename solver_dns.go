@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// dnsTimeout bounds one TXT lookup
+const dnsTimeout = 5 * time.Second
+
+// maxDNSNames bounds the hostnames of one layer whose TXT records are fetched
+const maxDNSNames = 8
+
+// dnsResolver answers the TXT lookups (a variable so tests can point it at
+// a local server)
+var dnsResolver = net.DefaultResolver
+
+// hostnamePattern matches dotted names; hostnameTLDs then keeps those that
+// end in a TLD, so file names like notes.txt or main.go are not looked up
+var hostnamePattern = regexp.MustCompile(`(?i)\b(?:[a-z0-9_](?:[a-z0-9_-]{0,61}[a-z0-9])?\.)+([a-z]{2,24})\b`)
+
+// hostnameTLDs are the generic, common country and CTF-network TLDs
+// recognised in input. Country codes that double as file extensions (.py,
+// .sh, .md, .pl, .rs, ...) are left out.
+var hostnameTLDs = map[string]bool{
+	"com": true, "net": true, "org": true, "io": true, "xyz": true, "me": true, "info": true, "dev": true,
+	"app": true, "co": true, "cloud": true, "site": true, "online": true, "tech": true, "top": true, "biz": true,
+	"pw": true, "cc": true, "tk": true, "ml": true, "su": true, "uk": true, "de": true, "ru": true, "cn": true,
+	"fr": true, "nl": true, "us": true, "eu": true, "ctf": true, "htb": true, "thm": true, "local": true,
+	"lan": true, "internal": true,
+}
+
+// FindHostnames returns the distinct hostnames in data, lower-cased, in
+// order of appearance
+func FindHostnames(data []byte) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range hostnamePattern.FindAllSubmatch(data, -1) {
+		name := strings.ToLower(string(m[0]))
+		if !hostnameTLDs[strings.ToLower(string(m[1]))] || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// LookupTXT returns the TXT records of name, the strings of each record
+// joined. A name without TXT records yields none and no error.
+func LookupTXT(ctx context.Context, name string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout)
+	defer cancel()
+	records, err := dnsResolver.LookupTXT(ctx, strings.TrimSuffix(name, "."))
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil, nil
+	}
+	return records, err
+}
+
+// txtData is the layer analysed for a name's TXT records: one record per
+// line, so records that decode on their own are analysed line by line
+func txtData(records []string) []byte {
+	return []byte(strings.Join(records, "\n"))
+}
+
+func printTXTRecords(name string, records []string) {
+	fmt.Printf("%s[+] DNS TXT (%s, %d records):%s\n", ColorBlue, name, len(records), ColorReset)
+	for _, r := range records {
+		fmt.Printf("    %q\n", r)
+	}
+}
+
+// hostnameSolver fetches the TXT records of hostnames found in a layer
+// (--online) and analyses each name's records as the next layer
+type hostnameSolver struct{}
+
+func (hostnameSolver) Name() string { return "Hostnames" }
+
+func (hostnameSolver) Applicable(l *Layer) bool {
+	return l.Opts.Online && isPrintable(l.Data) && len(FindHostnames(l.Data)) > 0
+}
+
+func (hostnameSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	names := FindHostnames(l.Data)
+	fmt.Printf("    Names: %s\n", strings.Join(names, ", "))
+	return hostnameCandidates(ctx, names, l.Opts)
+}
+
+// hostnameCandidates looks up the TXT records of each name, in order, as
+// candidate layers. Every name is looked up once per run.
+func hostnameCandidates(ctx context.Context, names []string, opts *Options) []Candidate {
+	if opts.dnsSeen == nil {
+		opts.dnsSeen = make(map[string]bool)
+	}
+	var candidates []Candidate
+	looked := 0
+	for _, name := range names {
+		if opts.dnsSeen[name] || looked == maxDNSNames {
+			continue
+		}
+		opts.dnsSeen[name] = true
+		looked++
+		records, err := LookupTXT(ctx, name)
+		switch {
+		case err != nil:
+			fmt.Printf("    %sDNS TXT %s: %v%s\n", ColorYellow, name, err, ColorReset)
+			continue
+		case len(records) == 0:
+			fmt.Printf("    DNS TXT %s: no records\n", name)
+			continue
+		}
+		printTXTRecords(name, records)
+		candidates = append(candidates, Candidate{Step: "DNS TXT (" + name + ")", Data: txtData(records)})
+	}
+	return candidates
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"slices"
+	"strings"
+)
+
+// maxGIFPixels bounds the pixels of all frames decoded from one GIF, so a
+// tiny file announcing a huge canvas cannot exhaust memory
+const maxGIFPixels = 64 << 20
+
+// maxGIFRenders bounds the frame diffs drawn in the terminal per GIF
+const maxGIFRenders = 4
+
+// maxRenderWidth is the widest a diff is drawn, in characters
+const maxRenderWidth = 100
+
+// subtleDelta is the largest channel change still invisible to the eye
+const subtleDelta = 2
+
+// GIFExtension is a comment, plain text or application extension block
+type GIFExtension struct {
+	Kind string // "Comment", "Plain Text" or "Application"
+	App  string // application identifier, e.g. NETSCAPE2.0
+	Data []byte
+}
+
+// GIFFile is the block structure of a GIF: everything but the pixels
+type GIFFile struct {
+	Width, Height int
+	Frames        []image.Rectangle // image descriptors, in order
+	Extensions    []GIFExtension
+	Trailing      []byte // data after the trailer
+}
+
+// ParseGIF walks the blocks of a GIF. A truncated file returns what was
+// read before the end together with the error.
+func ParseGIF(data []byte) (*GIFFile, error) {
+	if len(data) < 13 || (!bytes.HasPrefix(data, []byte("GIF87a")) && !bytes.HasPrefix(data, []byte("GIF89a"))) {
+		return nil, errors.New("not a GIF87a/GIF89a file")
+	}
+	f := &GIFFile{Width: int(binary.LittleEndian.Uint16(data[6:])), Height: int(binary.LittleEndian.Uint16(data[8:]))}
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&7 + 1)
+	}
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x3B:
+			f.Trailing = data[pos+1:]
+			return f, nil
+		case 0x21:
+			if pos+2 > len(data) {
+				return f, errors.New("truncated extension")
+			}
+			label := data[pos+1]
+			body, next, err := gifSubBlocks(data, pos+2, label != 0xF9)
+			if err != nil {
+				return f, err
+			}
+			switch label {
+			case 0xFE:
+				f.Extensions = append(f.Extensions, GIFExtension{Kind: "Comment", Data: body})
+			case 0x01: // 12-byte text grid header, then the text
+				f.Extensions = append(f.Extensions, GIFExtension{Kind: "Plain Text", Data: body[min(12, len(body)):]})
+			case 0xFF:
+				id := body[:min(11, len(body))]
+				f.Extensions = append(f.Extensions, GIFExtension{Kind: "Application", App: string(id), Data: body[len(id):]})
+			}
+			pos = next
+		case 0x2C:
+			if pos+10 > len(data) {
+				return f, errors.New("truncated image descriptor")
+			}
+			d := data[pos+1:]
+			x, y := int(binary.LittleEndian.Uint16(d)), int(binary.LittleEndian.Uint16(d[2:]))
+			w, h := int(binary.LittleEndian.Uint16(d[4:])), int(binary.LittleEndian.Uint16(d[6:]))
+			f.Frames = append(f.Frames, image.Rect(x, y, x+w, y+h))
+			pos += 10
+			if d[8]&0x80 != 0 {
+				pos += 3 << (d[8]&7 + 1)
+			}
+			_, next, err := gifSubBlocks(data, pos+1, false) // after the LZW code size
+			if err != nil {
+				return f, err
+			}
+			pos = next
+		default:
+			return f, fmt.Errorf("unknown block 0x%02x at offset %d", data[pos], pos)
+		}
+	}
+	return f, errors.New("no trailer: file is truncated")
+}
+
+// isLoopExtension reports whether ext is the animation loop count
+func isLoopExtension(ext GIFExtension) bool {
+	return ext.App == "NETSCAPE2.0" || ext.App == "ANIMEXTS1.0"
+}
+
+// loops describes the animation loop count, if the GIF sets one
+func (f *GIFFile) loops() string {
+	for _, ext := range f.Extensions {
+		if isLoopExtension(ext) && len(ext.Data) >= 3 && ext.Data[0] == 1 {
+			if n := binary.LittleEndian.Uint16(ext.Data[1:]); n > 0 {
+				return fmt.Sprintf(", loops %d times", n)
+			}
+			return ", loops forever"
+		}
+	}
+	return ""
+}
+
+// gifSubBlocks reads the length-prefixed sub-blocks starting at pos,
+// returning their data (when collect is set) and the offset after them
+func gifSubBlocks(data []byte, pos int, collect bool) ([]byte, int, error) {
+	var body []byte
+	for {
+		if pos >= len(data) {
+			return body, pos, errors.New("truncated data sub-blocks")
+		}
+		n := int(data[pos])
+		if n == 0 {
+			return body, pos + 1, nil
+		}
+		if pos+1+n > len(data) {
+			return body, pos, errors.New("truncated data sub-blocks")
+		}
+		if collect {
+			body = append(body, data[pos+1:pos+1+n]...)
+		}
+		pos += 1 + n
+	}
+}
+
+// ComposeGIFFrames renders every frame as it is shown, on the full canvas,
+// honouring transparency and each frame's disposal method
+func ComposeGIFFrames(g *gif.GIF) []*image.RGBA {
+	canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	frames := make([]*image.RGBA, 0, len(g.Image))
+	for i, frame := range g.Image {
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var saved *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			saved = cloneRGBA(canvas)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		frames = append(frames, cloneRGBA(canvas))
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = saved
+		}
+	}
+	return frames
+}
+
+func cloneRGBA(img *image.RGBA) *image.RGBA {
+	return &image.RGBA{Pix: slices.Clone(img.Pix), Stride: img.Stride, Rect: img.Rect}
+}
+
+// analyzeGIF reports a GIF's frames and extension blocks. Frames are
+// composited and diffed against their neighbours: a frame shown for a
+// single step, shown much more briefly than the rest, or changing pixels
+// by an invisible amount is drawn and saved, and every frame's LSBs are
+// scanned for flags. Comments, text blocks, unknown application data and
+// trailing data are analysed as layers. It reports whether a flag was found.
+func analyzeGIF(ctx context.Context, data []byte, opts *Options, chain []string) bool {
+	flagsBefore := len(opts.flags)
+	f, err := ParseGIF(data)
+	if f == nil {
+		fmt.Printf("    %sFailed to parse: %v%s\n", ColorYellow, err, ColorReset)
+		return false
+	}
+	fmt.Printf("    Screen: %dx%d, %d frames%s\n", f.Width, f.Height, len(f.Frames), f.loops())
+	if err != nil {
+		fmt.Printf("    %sBlock structure: %v%s\n", ColorYellow, err, ColorReset)
+	}
+
+	pixels := 0
+	for _, r := range f.Frames {
+		pixels += f.Width*f.Height + r.Dx()*r.Dy()
+	}
+	switch {
+	case pixels > maxGIFPixels:
+		fmt.Printf("    %sFrames skipped: %d pixels exceed the %d decode limit.%s\n", ColorYellow, pixels, maxGIFPixels, ColorReset)
+	case len(f.Frames) > 0:
+		if g, err := gif.DecodeAll(bytes.NewReader(data)); err != nil {
+			fmt.Printf("    %sFailed to decode frames: %v%s\n", ColorYellow, err, ColorReset)
+		} else {
+			analyzeGIFFrames(g, opts, chain)
+		}
+	}
+
+	layer := func(name string, payload []byte) {
+		if len(bytes.TrimSpace(payload)) == 0 {
+			return
+		}
+		fmt.Printf("%s[+] GIF %s (%d bytes):%s\n", ColorBlue, name, len(payload), ColorReset)
+		orchestrate(ctx, payload, opts, withStep(chain, "GIF "+name))
+	}
+	for i, ext := range f.Extensions {
+		switch {
+		case ext.Kind != "Application":
+			layer(fmt.Sprintf("%s %d", ext.Kind, i+1), ext.Data)
+		case isLoopExtension(ext): // reported with the screen
+		case strings.HasPrefix(ext.App, "XMP Data"):
+			fmt.Printf("    Application Extension: XMP metadata (%d bytes)\n", len(ext.Data))
+			reportFlags(opts, ext.Data, withStep(chain, "GIF XMP"))
+		default:
+			fmt.Printf("    %sApplication Extension: %q (%d bytes)%s\n", ColorYellow, ext.App, len(ext.Data), ColorReset)
+			layer(fmt.Sprintf("Application Data (%s)", strings.TrimSpace(ext.App)), ext.Data)
+		}
+	}
+	if len(f.Trailing) > 0 {
+		fmt.Printf("    %sTrailing data: %d bytes after the GIF trailer%s\n", ColorYellow, len(f.Trailing), ColorReset)
+		saveArtifact(opts, "gif_trailing.bin", f.Trailing)
+		layer("Trailing Data", f.Trailing)
+	}
+	return len(opts.flags) > flagsBefore
+}
+
+// gifSolver runs analyzeGIF; a flag in the frames or blocks ends the chain
+type gifSolver struct{}
+
+func (gifSolver) Name() string { return "GIF Analysis" }
+
+func (gifSolver) Applicable(l *Layer) bool {
+	return l.Type == "File (GIF)"
+}
+
+func (gifSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	flagsBefore := len(l.Opts.flags)
+	analyzeGIF(ctx, l.Data, l.Opts, l.Chain)
+	return flagCandidate(l, flagsBefore, "GIF Frames & Blocks")
+}
+
+// analyzeGIFFrames prints the frames of a decoded GIF and what their
+// deltas give away
+func analyzeGIFFrames(g *gif.GIF, opts *Options, chain []string) {
+	frames := ComposeGIFFrames(g)
+	delays := slices.Clone(g.Delay)
+	slices.Sort(delays)
+	median := 0
+	if len(delays) > 0 {
+		median = delays[len(delays)/2]
+	}
+	diffs := make([]PixelDiff, len(frames))
+	for i := 1; i < len(frames); i++ {
+		diffs[i] = diffPixels(frames[i-1].Pix, frames[i].Pix, frames[i].Rect)
+	}
+
+	renders := 0
+	for i, frame := range frames {
+		var notes []string
+		if i < len(g.Delay) && median > 0 && g.Delay[i]*4 <= median {
+			notes = append(notes, fmt.Sprintf("shown briefly (%dms, most frames %dms)", g.Delay[i]*10, median*10))
+		}
+		if i > 0 && i+1 < len(frames) && diffs[i].Changed > 0 && diffPixels(frames[i-1].Pix, frames[i+1].Pix, frames[i].Rect).Changed == 0 {
+			notes = append(notes, "appears for one frame only")
+		}
+		if i > 0 && diffs[i].Changed > 0 && diffs[i].MaxDelta <= subtleDelta {
+			notes = append(notes, fmt.Sprintf("invisible change (channels differ by at most %d)", diffs[i].MaxDelta))
+		}
+
+		if len(frames) <= 16 || len(notes) > 0 {
+			line := fmt.Sprintf("Frame %d: %v", i+1, g.Image[i].Bounds())
+			if i < len(g.Delay) {
+				line += fmt.Sprintf(", delay %dms", g.Delay[i]*10)
+			}
+			if d := diffs[i]; d.Changed > 0 {
+				line += fmt.Sprintf(", %d pixels changed in %v", d.Changed, d.Box)
+			}
+			if len(notes) > 0 {
+				fmt.Printf("    %s%s: %s%s\n", ColorYellow, line, strings.Join(notes, ", "), ColorReset)
+			} else {
+				fmt.Printf("    %s\n", line)
+			}
+		}
+		savePNGArtifact(opts, fmt.Sprintf("gif_frame_%03d.png", i+1), frame)
+		if len(notes) > 0 && diffs[i].Changed > 0 {
+			mask := diffMask(frames[i-1].Pix, frame.Pix, frame.Rect)
+			savePNGArtifact(opts, fmt.Sprintf("gif_diff_%03d.png", i+1), mask)
+			if renders < maxGIFRenders {
+				renders++
+				for _, l := range renderMask(mask, diffs[i].Box) {
+					fmt.Printf("      %s\n", l)
+				}
+			}
+		}
+		reportFlags(opts, ExtractLSB(frame), withStep(chain, fmt.Sprintf("GIF Frame %d LSB", i+1)))
+	}
+	if len(frames) > 16 {
+		fmt.Printf("    (%d frames; only notable ones listed)\n", len(frames))
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// PGPSymmetricInfo describes the Symmetric-Key Encrypted Session Key
+// (SKESK, tag 3) packet that starts a passphrase-encrypted OpenPGP message
+type PGPSymmetricInfo struct {
+	Cipher     string
+	S2K        string
+	Hash       string
+	Salt       []byte
+	Iterations int    // bytes hashed by iterated S2K, 0 otherwise
+	Message    []byte // binary (de-armored) message
+}
+
+var pgpCiphers = map[byte]string{
+	1: "IDEA", 2: "3DES", 3: "CAST5", 4: "Blowfish",
+	7: "AES-128", 8: "AES-192", 9: "AES-256", 10: "Twofish",
+}
+
+var pgpHashes = map[byte]string{
+	1: "MD5", 2: "SHA1", 3: "RIPEMD-160",
+	8: "SHA256", 9: "SHA384", 10: "SHA512", 11: "SHA224",
+}
+
+// ParsePGPSymmetric returns the SKESK details when data (binary or ASCII
+// armored) is a passphrase-encrypted OpenPGP message, nil otherwise
+func ParsePGPSymmetric(data []byte) *PGPSymmetricInfo {
+	msg := data
+	if bytes.Contains(data, []byte("-----BEGIN PGP MESSAGE-----")) {
+		block, err := armor.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil
+		}
+		msg, err = io.ReadAll(block.Body)
+		if err != nil {
+			return nil
+		}
+	}
+
+	tag, body, ok := readPGPPacketHeader(msg)
+	if !ok || tag != 3 || len(body) < 4 || body[0] != 4 {
+		return nil
+	}
+
+	info := &PGPSymmetricInfo{Message: msg, Cipher: pgpName(pgpCiphers, body[1])}
+	s2k := body[2:]
+	info.Hash = pgpName(pgpHashes, s2k[1])
+	switch s2k[0] {
+	case 0:
+		info.S2K = "Simple"
+	case 1:
+		if len(s2k) < 10 {
+			return nil
+		}
+		info.S2K = "Salted"
+		info.Salt = s2k[2:10]
+	case 3:
+		if len(s2k) < 11 {
+			return nil
+		}
+		info.S2K = "Iterated+Salted"
+		info.Salt = s2k[2:10]
+		c := int(s2k[10])
+		info.Iterations = (16 + (c & 15)) << ((c >> 4) + 6)
+	default:
+		return nil
+	}
+	return info
+}
+
+// readPGPPacketHeader decodes an old- or new-format packet header and returns
+// the tag and packet body (truncated to what is available)
+func readPGPPacketHeader(data []byte) (byte, []byte, bool) {
+	if len(data) < 2 || data[0]&0x80 == 0 {
+		return 0, nil, false
+	}
+
+	var tag byte
+	var length, offset int
+	if data[0]&0x40 != 0 {
+		// New format
+		tag = data[0] & 0x3F
+		switch l := int(data[1]); {
+		case l < 192:
+			length, offset = l, 2
+		case l < 224 && len(data) >= 3:
+			length, offset = (l-192)<<8+int(data[2])+192, 3
+		case l == 255 && len(data) >= 6:
+			length = int(data[2])<<24 | int(data[3])<<16 | int(data[4])<<8 | int(data[5])
+			offset = 6
+		default:
+			return 0, nil, false
+		}
+	} else {
+		// Old format
+		tag = (data[0] >> 2) & 0x0F
+		switch data[0] & 3 {
+		case 0:
+			length, offset = int(data[1]), 2
+		case 1:
+			if len(data) < 3 {
+				return 0, nil, false
+			}
+			length, offset = int(data[1])<<8|int(data[2]), 3
+		case 2:
+			if len(data) < 5 {
+				return 0, nil, false
+			}
+			length = int(data[1])<<24 | int(data[2])<<16 | int(data[3])<<8 | int(data[4])
+			offset = 5
+		default:
+			length, offset = len(data)-1, 1
+		}
+	}
+
+	end := offset + length
+	if end > len(data) || end < offset {
+		end = len(data)
+	}
+	return tag, data[offset:end], true
+}
+
+func pgpName(names map[byte]string, id byte) string {
+	if name, ok := names[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown (%d)", id)
+}
+
+// pgpSolver runs the wordlist against a passphrase-encrypted OpenPGP
+// message (gpg -c). The plaintext is the next layer.
+type pgpSolver struct{}
+
+func (pgpSolver) Name() string { return "OpenPGP Symmetric Encryption" }
+
+func (pgpSolver) Applicable(l *Layer) bool {
+	return l.PGP != nil
+}
+
+func (pgpSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	info, opts := l.PGP, l.Opts
+	fmt.Printf("    Cipher: %s\n", info.Cipher)
+	fmt.Printf("    S2K: %s (%s)\n", info.S2K, info.Hash)
+	if info.Iterations > 0 {
+		fmt.Printf("    S2K Count: %d bytes, Salt: %x\n", info.Iterations, info.Salt)
+	}
+	attackCtx, cancel := attackContext(ctx, opts)
+	defer cancel()
+	passphrase, plaintext, ok := CrackPGPSymmetric(attackCtx, info, opts.Wordlist)
+	if !ok {
+		if !reportStopped(attackCtx) {
+			fmt.Printf("    %sPassphrase not found in wordlist (%d tried).%s\n", ColorYellow, len(opts.Wordlist), ColorReset)
+		}
+		return nil
+	}
+	details := []string{"Passphrase: " + passphrase}
+	if err := Config.Limits.CheckStep(len(info.Message), int64(len(plaintext))); err != nil {
+		plaintext = plaintext[:len(plaintext)-1]
+		details = append(details, fmt.Sprintf("%sPlaintext truncated to %s: %v%s", ColorYellow, formatSize(int64(len(plaintext))), err, ColorReset))
+	}
+	return []Candidate{{Step: "OpenPGP Decrypt", Data: plaintext, Details: details}}
+}
+
+// CrackPGPSymmetric tries each passphrase against the message. A candidate is
+// only accepted once the whole body decrypts and the MDC verifies, since the
+// two-byte quick check alone gives false positives. gpg compresses by
+// default, so the plaintext is read through Config.Limits: past the limit
+// one extra byte is returned for CheckStep to catch, and the MDC of the
+// unread remainder goes unchecked.
+func CrackPGPSymmetric(ctx context.Context, info *PGPSymmetricInfo, words []string) (string, []byte, bool) {
+	for _, word := range words {
+		if ctx.Err() != nil {
+			break
+		}
+		tried := false
+		prompt := func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+			if tried || !symmetric {
+				return nil, errors.New("wrong passphrase")
+			}
+			tried = true
+			return []byte(word), nil
+		}
+
+		md, err := openpgp.ReadMessage(bytes.NewReader(info.Message), nil, prompt, nil)
+		if err != nil {
+			continue
+		}
+		body := md.UnverifiedBody
+		if limit := Config.Limits.outputLimit(len(info.Message)); limit >= 0 {
+			body = io.LimitReader(body, limit+1)
+		}
+		plaintext, err := io.ReadAll(body)
+		if err != nil {
+			continue
+		}
+		return word, plaintext, true
+	}
+	return "", nil, false
+}
@@ -0,0 +1,562 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // registered for image.Decode
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"strings"
+)
+
+// maxBitplanePixels bounds the images split into bit planes
+const maxBitplanePixels = 4096 * 4096
+
+// minFinderModule is the smallest QR module, in pixels, looked for. One
+// pixel modules would match the 1:1:3:1:1 finder ratio all over noise.
+const minFinderModule = 2
+
+// bitplaneChannels name the channels of an NRGBA pixel, in byte order
+var bitplaneChannels = []string{"R", "G", "B", "A"}
+
+// ExtractLSB reads the least significant bit of the red, green and blue
+// channels of every pixel, row by row, packed most significant bit first
+// (zsteg's b1,rgb,lsb,xy), the most common image LSB embedding
+func ExtractLSB(img image.Image) []byte {
+	b := img.Bounds()
+	out := make([]byte, 0, b.Dx()*b.Dy()*3/8+1)
+	var acc byte
+	bits := 0
+	push := func(v uint32) {
+		acc = acc<<1 | byte(v>>8)&1
+		if bits++; bits == 8 {
+			out = append(out, acc)
+			acc, bits = 0, 0
+		}
+	}
+	if rgba, ok := img.(*image.RGBA); ok {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			row := rgba.Pix[rgba.PixOffset(b.Min.X, y):rgba.PixOffset(b.Max.X, y)]
+			for i := 0; i < len(row); i += 4 {
+				push(uint32(row[i]) << 8)
+				push(uint32(row[i+1]) << 8)
+				push(uint32(row[i+2]) << 8)
+			}
+		}
+		return out
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			push(r)
+			push(g)
+			push(bl)
+		}
+	}
+	return out
+}
+
+// Bitplane is one bit of one channel across an image: white where the bit
+// is set
+type Bitplane struct {
+	Channel string
+	Bit     int
+	Image   *image.Gray
+}
+
+// Name is how a plane is reported, e.g. "R bit 0"
+func (p Bitplane) Name() string { return fmt.Sprintf("%s bit %d", p.Channel, p.Bit) }
+
+// toNRGBA converts an image to non-premultiplied RGBA, the values stego
+// tools embed into
+func toNRGBA(img image.Image) *image.NRGBA {
+	if n, ok := img.(*image.NRGBA); ok && n.Rect.Min == (image.Point{}) {
+		return n
+	}
+	b := img.Bounds()
+	n := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(n, n.Rect, img, b.Min, draw.Src)
+	return n
+}
+
+// ExtractBitplane cuts one bit of one channel (0-3: R, G, B, A) out of img
+func ExtractBitplane(img *image.NRGBA, channel, bit int) Bitplane {
+	plane := image.NewGray(img.Rect)
+	for i := range plane.Pix {
+		if img.Pix[4*i+channel]>>bit&1 != 0 {
+			plane.Pix[i] = 0xFF
+		}
+	}
+	return Bitplane{Channel: bitplaneChannels[channel], Bit: bit, Image: plane}
+}
+
+func isConstant(pix []byte) bool {
+	for _, v := range pix {
+		if v != pix[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// packPlane packs a plane's bits row by row, most significant bit first
+func packPlane(plane *image.Gray) []byte {
+	out := make([]byte, (len(plane.Pix)+7)/8)
+	for i, v := range plane.Pix {
+		if v != 0 {
+			out[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return out
+}
+
+// textLike reports whether data starts with at least 16 bytes of mostly
+// printable text, returning that text
+func textLike(data []byte) (string, bool) {
+	head := data[:min(len(data), 256)]
+	if end := bytes.IndexByte(head, 0); end >= 0 {
+		head = head[:end]
+	}
+	if len(head) < 16 {
+		return "", false
+	}
+	printable := 0
+	for _, c := range head {
+		if c >= 0x20 && c < 0x7F || c == '\n' || c == '\r' || c == '\t' {
+			printable++
+		}
+	}
+	return string(head), printable*100 >= 95*len(head)
+}
+
+// planeStructure measures how image-like a plane is. smooth is the share of
+// horizontally adjacent pixels with the same bit (about 0.5 for noise).
+// independent is the share of bit changes where the bits above it in the
+// channel stay the same: near 0 when the plane only traces the picture's
+// own colour edges, high when something else was written into it.
+func planeStructure(img *image.NRGBA, channel, bit int) (smooth, independent float64) {
+	same, changes, inside, pairs := 0, 0, 0, 0
+	w := img.Rect.Dx()
+	for y := range img.Rect.Dy() {
+		row := img.Pix[y*img.Stride : y*img.Stride+4*w]
+		for x := 1; x < w; x++ {
+			a, b := row[4*(x-1)+channel], row[4*x+channel]
+			pairs++
+			if (a^b)>>bit&1 == 0 {
+				same++
+				continue
+			}
+			changes++
+			if a>>(bit+1) == b>>(bit+1) {
+				inside++
+			}
+		}
+	}
+	if pairs == 0 || changes == 0 {
+		return 1, 0
+	}
+	return float64(same) / float64(pairs), float64(inside) / float64(changes)
+}
+
+// PixelDiff is how one image differs from another of the same size
+type PixelDiff struct {
+	Changed  int             // pixels that differ
+	Box      image.Rectangle // bounding box of the changes
+	MaxDelta int             // largest change of any channel
+}
+
+// diffPixels compares the 4-byte pixels of two images of size rect
+func diffPixels(a, b []byte, rect image.Rectangle) PixelDiff {
+	var d PixelDiff
+	for i := 0; i < len(a); i += 4 {
+		delta := 0
+		for c := range 4 {
+			delta = max(delta, absInt(int(a[i+c])-int(b[i+c])))
+		}
+		if delta == 0 {
+			continue
+		}
+		p := i / 4
+		pt := image.Pt(p%rect.Dx(), p/rect.Dx())
+		d.Box = d.Box.Union(image.Rectangle{Min: pt, Max: pt.Add(image.Pt(1, 1))})
+		d.Changed++
+		d.MaxDelta = max(d.MaxDelta, delta)
+	}
+	return d
+}
+
+func absInt(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// diffMask is a black image with the pixels that differ between a and b in
+// white, the form a hidden delta is easiest to read in
+func diffMask(a, b []byte, rect image.Rectangle) *image.Gray {
+	mask := image.NewGray(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	for i := 0; i < len(a); i += 4 {
+		if !bytes.Equal(a[i:i+4], b[i:i+4]) {
+			mask.Pix[i/4] = 0xFF
+		}
+	}
+	return mask
+}
+
+// renderMask draws the box of a diff mask with '#' for changed pixels,
+// scaled down to fit the terminal (a cell is twice as tall as wide)
+func renderMask(mask *image.Gray, box image.Rectangle) []string {
+	sx := max(1, (box.Dx()+maxRenderWidth-1)/maxRenderWidth)
+	sy := 2 * sx
+	var lines []string
+	for y := box.Min.Y; y < box.Max.Y; y += sy {
+		var line strings.Builder
+		for x := box.Min.X; x < box.Max.X; x += sx {
+			cell := image.Rect(x, y, x+sx, y+sy).Intersect(box)
+			ch := byte('.')
+			for py := cell.Min.Y; py < cell.Max.Y && ch == '.'; py++ {
+				for px := cell.Min.X; px < cell.Max.X; px++ {
+					if mask.GrayAt(px, py).Y != 0 {
+						ch = '#'
+						break
+					}
+				}
+			}
+			line.WriteByte(ch)
+		}
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+// finder is a QR finder pattern candidate: its centre, module size and how
+// many scan lines crossed it
+type finder struct {
+	x, y, module float64
+	hits         int
+}
+
+// finderRatio checks five runs against the 1:1:3:1:1 finder pattern,
+// returning the module size
+func finderRatio(runs [5]int) (float64, bool) {
+	total := 0
+	for _, r := range runs {
+		total += r
+	}
+	module := float64(total) / 7
+	if module < minFinderModule {
+		return 0, false
+	}
+	for i, r := range runs {
+		want := module
+		if i == 2 {
+			want = 3 * module
+		}
+		if math.Abs(float64(r)-want) >= want/2 {
+			return 0, false
+		}
+	}
+	return module, true
+}
+
+// verticalFinder checks the column through (x, y) for a finder pattern
+// centred on y's run, returning the centre row
+func verticalFinder(plane *image.Gray, x, y int) (float64, bool) {
+	h, at := plane.Rect.Dy(), func(y int) uint8 { return plane.Pix[y*plane.Stride+x] }
+	var runs [5]int
+	top := y
+	for top > 0 && at(top-1) == at(y) {
+		top--
+	}
+	bottom := y
+	for bottom+1 < h && at(bottom+1) == at(y) {
+		bottom++
+	}
+	runs[2] = bottom - top + 1
+	up, down := top-1, bottom+1
+	for i := 1; i >= 0; i-- {
+		if up < 0 {
+			return 0, false
+		}
+		start := up
+		for up >= 0 && at(up) == at(start) {
+			up--
+			runs[i]++
+		}
+	}
+	for i := 3; i < 5; i++ {
+		if down >= h {
+			return 0, false
+		}
+		start := down
+		for down < h && at(down) == at(start) {
+			down++
+			runs[i]++
+		}
+	}
+	if _, ok := finderRatio(runs); !ok {
+		return 0, false
+	}
+	return float64(top+bottom) / 2, true
+}
+
+// HasQRPattern reports whether a plane shows three QR finder patterns of
+// one module size laid out as the corners of a square code
+func HasQRPattern(plane *image.Gray) bool {
+	var finders []*finder
+	w, h := plane.Rect.Dx(), plane.Rect.Dy()
+	for y := range h {
+		row := plane.Pix[y*plane.Stride : y*plane.Stride+w]
+		// Run lengths of the row, then every window of five runs
+		var runs, starts []int
+		for x := 0; x < w; {
+			start := x
+			for x < w && row[x] == row[start] {
+				x++
+			}
+			runs, starts = append(runs, x-start), append(starts, start)
+		}
+		for i := 0; i+5 <= len(runs); i++ {
+			module, ok := finderRatio([5]int(runs[i : i+5]))
+			if !ok {
+				continue
+			}
+			cx := starts[i+2] + runs[i+2]/2
+			cy, ok := verticalFinder(plane, cx, y)
+			if !ok {
+				continue
+			}
+			merged := false
+			for _, f := range finders {
+				if math.Abs(f.x-float64(cx)) < 2*f.module && math.Abs(f.y-cy) < 2*f.module {
+					f.hits++
+					merged = true
+					break
+				}
+			}
+			if !merged && len(finders) < 64 {
+				finders = append(finders, &finder{x: float64(cx), y: cy, module: module, hits: 1})
+			}
+		}
+	}
+
+	var solid []*finder
+	for _, f := range finders {
+		if f.hits >= 2 {
+			solid = append(solid, f)
+		}
+	}
+	dist := func(a, b *finder) float64 { return math.Hypot(a.x-b.x, a.y-b.y) }
+	for _, a := range solid {
+		for j, b := range solid {
+			for _, c := range solid[j+1:] {
+				if a == b || a == c {
+					continue
+				}
+				// a is the corner: equal legs, hypotenuse sqrt(2) longer
+				ab, ac, bc := dist(a, b), dist(a, c), dist(b, c)
+				if ab < 7*a.module || math.Abs(ab-ac) > 0.15*ab || math.Abs(bc-math.Sqrt2*ab) > 0.15*bc {
+					continue
+				}
+				if math.Abs(b.module-a.module) < 0.3*a.module && math.Abs(c.module-a.module) < 0.3*a.module {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// analyzeBitplanes splits an image into the bit planes of every channel,
+// saving them with -artifacts, and reports the planes holding text, a flag,
+// a QR-like pattern or a picture of their own. The combined RGB LSBs are
+// checked the same way. It reports whether a flag was found.
+func analyzeBitplanes(data []byte, opts *Options, chain []string) bool {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		fmt.Printf("    %sFailed to decode: %v%s\n", ColorYellow, err, ColorReset)
+		return false
+	}
+	fmt.Printf("    Image: %s %dx%d\n", strings.ToUpper(format), cfg.Width, cfg.Height)
+	if cfg.Width*cfg.Height > maxBitplanePixels {
+		fmt.Printf("    %sSkipped: %d pixels exceed the %d limit.%s\n", ColorYellow, cfg.Width*cfg.Height, maxBitplanePixels, ColorReset)
+		return false
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		fmt.Printf("    %sFailed to decode: %v%s\n", ColorYellow, err, ColorReset)
+		return false
+	}
+	return scanBitplanes(toNRGBA(decoded), "bitplane", opts, chain)
+}
+
+// bitplaneSolver runs analyzeBitplanes on still images (and GIFs, whose
+// first frame it sees); a flag in a plane ends the chain
+type bitplaneSolver struct{}
+
+func (bitplaneSolver) Name() string { return "Bitplanes" }
+
+func (bitplaneSolver) Applicable(l *Layer) bool {
+	return l.Type == "File (PNG)" || l.Type == "File (JPG)" || l.Type == "File (GIF)"
+}
+
+func (bitplaneSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	flagsBefore := len(l.Opts.flags)
+	analyzeBitplanes(l.Data, l.Opts, l.Chain)
+	return flagCandidate(l, flagsBefore, "Bitplanes")
+}
+
+// scanBitplanes is analyzeBitplanes on a decoded image, saving its planes
+// as <prefix>_<channel><bit>.png
+func scanBitplanes(img *image.NRGBA, prefix string, opts *Options, chain []string) bool {
+	flagsBefore := len(opts.flags)
+	notable := 0
+	note := func(name, what string) {
+		notable++
+		fmt.Printf("    %s%s: %s%s\n", ColorYellow, name, what, ColorReset)
+	}
+
+	lsb := ExtractLSB(img)
+	reportFlags(opts, lsb, withStep(chain, "RGB LSB"))
+	if text, ok := textLike(lsb); ok {
+		note("RGB LSB", fmt.Sprintf("text-like: %q", text))
+	}
+
+	saved, saveErr := 0, error(nil)
+	for channel := range bitplaneChannels {
+		for bit := 7; bit >= 0; bit-- {
+			plane := ExtractBitplane(img, channel, bit)
+			if opts.ArtifactDir != "" && saveErr == nil {
+				var png []byte
+				if png, saveErr = encodePNG(plane.Image); saveErr == nil {
+					_, saveErr = writeArtifact(opts, fmt.Sprintf("%s_%s%d.png", prefix, plane.Channel, bit), png)
+				}
+				if saveErr == nil {
+					saved++
+				}
+			}
+
+			if isConstant(plane.Image.Pix) {
+				continue
+			}
+			packed := packPlane(plane.Image)
+			reportFlags(opts, packed, withStep(chain, plane.Name()))
+			text, isText := textLike(packed)
+			if isText {
+				note(plane.Name(), fmt.Sprintf("text-like: %q", text))
+			}
+			if HasQRPattern(plane.Image) {
+				note(plane.Name(), "QR-like pattern (three finder patterns); scan the saved plane")
+			}
+			if bit <= 3 && !isText {
+				if smooth, independent := planeStructure(img, channel, bit); smooth > 0.8 && independent > 0.5 {
+					note(plane.Name(), fmt.Sprintf("structured (%.0f%% of neighbours equal, not following the picture): likely a hidden image", 100*smooth))
+				}
+			}
+		}
+	}
+	if notable == 0 {
+		fmt.Printf("    No text, QR-like or hidden-image planes among %d.\n", 8*len(bitplaneChannels))
+	}
+	if saveErr != nil {
+		fmt.Printf("    %sCould not save bit planes: %v%s\n", ColorRed, saveErr, ColorReset)
+	} else if saved > 0 {
+		fmt.Printf("    Saved: %d planes to %s as %s_<channel><bit>.png\n", saved, opts.ArtifactDir, prefix)
+	}
+	return len(opts.flags) > flagsBefore
+}
+
+// maxRenderLines bounds the rows of a picture drawn in the terminal
+const maxRenderLines = 60
+
+// decodeImage decodes a PNG, JPEG or GIF (its first frame) within the
+// bitplane size limit
+func decodeImage(data []byte) (*image.NRGBA, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxBitplanePixels {
+		return nil, fmt.Errorf("%d pixels exceed the %d limit", cfg.Width*cfg.Height, maxBitplanePixels)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return toNRGBA(img), nil
+}
+
+// XORImages XORs the red, green and blue channels of two images of the
+// same size; the result is opaque
+func XORImages(a, b *image.NRGBA) *image.NRGBA {
+	out := image.NewNRGBA(a.Rect)
+	for i := 0; i < len(out.Pix); i += 4 {
+		out.Pix[i] = a.Pix[i] ^ b.Pix[i]
+		out.Pix[i+1] = a.Pix[i+1] ^ b.Pix[i+1]
+		out.Pix[i+2] = a.Pix[i+2] ^ b.Pix[i+2]
+		out.Pix[i+3] = 0xFF
+	}
+	return out
+}
+
+// brightMask is white where an image is brighter than mid-grey
+func brightMask(img *image.NRGBA) *image.Gray {
+	mask := image.NewGray(img.Rect)
+	for i := range mask.Pix {
+		p := img.Pix[4*i:]
+		if (299*int(p[0])+587*int(p[1])+114*int(p[2]))/1000 > 127 {
+			mask.Pix[i] = 0xFF
+		}
+	}
+	return mask
+}
+
+// analyzeImagePair XORs and diffs two images of identical dimensions, for
+// flags that only show when the pictures are combined. The pixels that
+// differ are drawn (or, when nearly all differ, the XOR itself), the XOR
+// and difference mask are saved with -artifacts, and the XOR is scanned
+// like any image. handled is false unless both inputs are images.
+func analyzeImagePair(a, b Input, opts *Options) (handled, found bool) {
+	imgA, errA := decodeImage(a.Data)
+	imgB, errB := decodeImage(b.Data)
+	if errA != nil || errB != nil {
+		return false, false
+	}
+	rect := imgA.Rect
+	fmt.Printf("%s[+] Image XOR of %s and %s:%s\n", ColorBlue, a.Name, b.Name, ColorReset)
+	if imgB.Rect != rect {
+		fmt.Printf("    %sSizes differ (%dx%d and %dx%d): pixels cannot be combined.%s\n",
+			ColorYellow, rect.Dx(), rect.Dy(), imgB.Rect.Dx(), imgB.Rect.Dy(), ColorReset)
+		return true, false
+	}
+	d := diffPixels(imgA.Pix, imgB.Pix, rect)
+	if d.Changed == 0 {
+		fmt.Printf("    Identical pixels (%dx%d).\n", rect.Dx(), rect.Dy())
+		return true, false
+	}
+	total := rect.Dx() * rect.Dy()
+	fmt.Printf("    %d of %d pixels differ (%.1f%%) in %v, channels by up to %d\n",
+		d.Changed, total, 100*float64(d.Changed)/float64(total), d.Box, d.MaxDelta)
+
+	xor, mask := XORImages(imgA, imgB), diffMask(imgA.Pix, imgB.Pix, rect)
+	picture, box := mask, d.Box
+	if 2*d.Changed > total {
+		fmt.Printf("    Nearly every pixel differs; the XOR itself:\n")
+		picture, box = brightMask(xor), rect
+	}
+	lines := renderMask(picture, box)
+	for _, l := range lines[:min(len(lines), maxRenderLines)] {
+		fmt.Printf("      %s\n", l)
+	}
+	if len(lines) > maxRenderLines {
+		fmt.Printf("      (%d more rows)\n", len(lines)-maxRenderLines)
+	}
+	savePNGArtifact(opts, "image_xor.png", xor)
+	savePNGArtifact(opts, "image_diff.png", mask)
+	return true, scanBitplanes(xor, "xor_bitplane", opts, []string{"XOR Images"})
+}
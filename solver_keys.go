@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// KeyInfo describes a private key found in the input
+type KeyInfo struct {
+	Label       string // PEM block label, e.g. "OPENSSH PRIVATE KEY"
+	Type        string // RSA, ECDSA, Ed25519, DSA
+	Bits        int
+	Encrypted   bool
+	Fingerprint string // OpenSSH style SHA256 fingerprint, empty if unknown
+	Passphrase  string // Recovered passphrase for a protected key
+	Stopped     error  // why the passphrase attack ended early, if it did
+	RSA         *rsa.PrivateKey
+}
+
+// FindPrivateKeyBlock returns the first PEM block whose label is a private key
+func FindPrivateKeyBlock(input []byte) *pem.Block {
+	rest := input
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil
+		}
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			return block
+		}
+	}
+}
+
+// AnalyzePrivateKey parses an OpenSSH or PEM private key block. Encrypted keys
+// still yield their type and, for OpenSSH, the public key fingerprint since
+// the public half is stored in the clear.
+func AnalyzePrivateKey(block *pem.Block) (*KeyInfo, error) {
+	info := &KeyInfo{Label: block.Type}
+	raw := pem.EncodeToMemory(block)
+
+	key, err := ssh.ParseRawPrivateKey(raw)
+	if err != nil {
+		var missing *ssh.PassphraseMissingError
+		if !errors.As(err, &missing) {
+			return nil, err
+		}
+		info.Encrypted = true
+		if missing.PublicKey != nil {
+			info.Fingerprint = ssh.FingerprintSHA256(missing.PublicKey)
+			if cpk, ok := missing.PublicKey.(ssh.CryptoPublicKey); ok {
+				info.Type, info.Bits = describePublicKey(cpk.CryptoPublicKey())
+			}
+		} else {
+			// Legacy PEM encryption hides everything but the label
+			info.Type = strings.TrimSuffix(strings.TrimSuffix(block.Type, "PRIVATE KEY"), " ")
+		}
+		return info, nil
+	}
+
+	return fillKeyInfo(info, key), nil
+}
+
+// CrackPrivateKey runs a wordlist attack against a passphrase-protected key.
+// OpenSSH keys use bcrypt_pbkdf and legacy PEM keys the OpenSSL MD5 KDF; both
+// are handled by the ssh package. On success the decrypted key is returned.
+func CrackPrivateKey(ctx context.Context, block *pem.Block, words []string) (*KeyInfo, bool) {
+	raw := pem.EncodeToMemory(block)
+	for _, word := range words {
+		if ctx.Err() != nil {
+			break
+		}
+		key, err := ssh.ParseRawPrivateKeyWithPassphrase(raw, []byte(word))
+		if err != nil {
+			continue
+		}
+		info := fillKeyInfo(&KeyInfo{Label: block.Type, Encrypted: true}, key)
+		info.Passphrase = word
+		return info, true
+	}
+	return nil, false
+}
+
+// fillKeyInfo populates type, size and fingerprint from a decrypted key
+func fillKeyInfo(info *KeyInfo, key interface{}) *KeyInfo {
+	if k, ok := key.(*ed25519.PrivateKey); ok {
+		key = *k // OpenSSH Ed25519 keys come back as a pointer
+	}
+	if signer, ok := key.(crypto.Signer); ok {
+		info.Type, info.Bits = describePublicKey(signer.Public())
+		if pub, err := ssh.NewPublicKey(signer.Public()); err == nil {
+			info.Fingerprint = ssh.FingerprintSHA256(pub)
+		}
+	} else if k, ok := key.(*dsa.PrivateKey); ok {
+		info.Type, info.Bits = describePublicKey(&k.PublicKey)
+		if pub, err := ssh.NewPublicKey(&k.PublicKey); err == nil {
+			info.Fingerprint = ssh.FingerprintSHA256(pub)
+		}
+	}
+	if k, ok := key.(*rsa.PrivateKey); ok {
+		info.RSA = k
+	}
+	return info
+}
+
+func describePublicKey(pub crypto.PublicKey) (string, int) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return "RSA", k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("ECDSA %s", k.Curve.Params().Name), k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "Ed25519", 256
+	case *dsa.PublicKey:
+		return "DSA", k.P.BitLen()
+	}
+	return "Unknown", 0
+}
+
+// privateKeySolver reports the private key found in a layer. Its RSA
+// components were already handed to the RSA solver.
+type privateKeySolver struct{}
+
+func (privateKeySolver) Name() string { return "Private Key Analysis" }
+
+func (privateKeySolver) Applicable(l *Layer) bool {
+	return l.KeyBlock != nil
+}
+
+func (privateKeySolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	if l.KeyErr != nil {
+		fmt.Printf("    %sFailed to parse %s: %v%s\n", ColorYellow, l.KeyBlock.Type, l.KeyErr, ColorReset)
+		return nil
+	}
+	printKeyInfo(l.Key)
+	if l.Key.RSA != nil && (l.RSA == nil || l.RSA.C == nil) {
+		fmt.Printf("    No ciphertext (c = ...) found alongside the key.\n")
+	}
+	return nil
+}
+
+func printKeyInfo(key *KeyInfo) {
+	fmt.Printf("    Format: %s\n", key.Label)
+	if key.Bits > 0 {
+		fmt.Printf("    Key: %s (%d bits)\n", key.Type, key.Bits)
+	} else {
+		fmt.Printf("    Key: %s\n", key.Type)
+	}
+	if key.Passphrase != "" {
+		fmt.Printf("    Protected: %sYes (passphrase cracked: %s)%s\n", ColorGreen, key.Passphrase, ColorReset)
+	} else if key.Encrypted && key.Stopped != nil {
+		fmt.Printf("    Protected: %sYes (passphrase attack stopped: %v)%s\n", ColorYellow, key.Stopped, ColorReset)
+	} else if key.Encrypted {
+		fmt.Printf("    Protected: %sYes (passphrase required, not in wordlist)%s\n", ColorYellow, ColorReset)
+	} else {
+		fmt.Printf("    Protected: %sNo%s\n", ColorGreen, ColorReset)
+	}
+	if key.Fingerprint != "" {
+		fmt.Printf("    Fingerprint: %s\n", key.Fingerprint)
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Input is one artifact given on the command line
+type Input struct {
+	Name string
+	Data []byte
+}
+
+// hastadMaxE bounds the public exponents tried by the broadcast attack
+const hastadMaxE = 17
+
+// analyzeInputs runs the attacks that need several artifacts together, then
+// falls back to analysing every input on its own
+func analyzeInputs(ctx context.Context, inputs []Input, opts *Options) (string, bool) {
+	fmt.Printf("\n%s[+] Multi-Input Analysis (%d inputs):%s\n", ColorBlue, len(inputs), ColorReset)
+
+	var params []*RSAParams
+	for _, in := range inputs {
+		if p := ParseRSA(string(in.Data)); p.N != nil && p.E != nil {
+			params = append(params, p)
+		}
+	}
+	if len(params) >= 2 {
+		fmt.Printf("%s[+] Multi-Key RSA (%d public keys):%s\n", ColorBlue, len(params), ColorReset)
+		res := &SolveResult{}
+		safely("Multi-key RSA", func() { res = SolveMultiRSA(params) })
+		if res.Success {
+			fmt.Printf("    %sSuccess! Algorithm: %s%s\n", ColorGreen, res.Algorithm, ColorReset)
+			fmt.Printf("    Decoded: %s\n", res.DecodedData)
+			reportFlags(opts, []byte(res.DecodedData), []string{res.Algorithm})
+			return res.DecodedData, true
+		}
+		fmt.Printf("    %sNo shared factor, common modulus or broadcast found.%s\n", ColorYellow, ColorReset)
+	} else {
+		raw := make([][]byte, len(inputs))
+		for i, in := range inputs {
+			raw[i] = ciphertextBytes(in.Data)
+		}
+
+		// Two pictures may only show the flag combined; a byte XOR of two
+		// image files would mean nothing
+		imagePair := false
+		if len(inputs) == 2 {
+			found := false
+			safely("Image XOR", func() { imagePair, found = analyzeImagePair(inputs[0], inputs[1], opts) })
+			if found {
+				return strings.Join(opts.flags, "\n"), true
+			}
+		}
+
+		// Two artifacts: one may be the key for the other
+		if len(raw) == 2 && !imagePair {
+			x := xorRepeating(raw[0], raw[1])
+			fmt.Printf("%s[+] XOR of Inputs 1 and 2 (%d bytes):%s\n", ColorBlue, len(x), ColorReset)
+			fmt.Printf("    Result: %s\n", displayData(x))
+			if LooksSolved(x) || hasFlag(x) {
+				return orchestrate(ctx, x, opts, []string{"XOR Inputs"})
+			}
+		}
+
+		// A reused keystream only makes sense for real ciphertexts
+		if allBinary(raw) && !imagePair {
+			fmt.Printf("%s[+] Many-Time Pad (%d ciphertexts):%s\n", ColorBlue, len(raw), ColorReset)
+			key, plaintexts := SolveManyTimePad(raw)
+			if looksLikeText(plaintexts) {
+				fmt.Printf("    %sSuccess! Algorithm: Many-Time Pad (Key: %x)%s\n", ColorGreen, key, ColorReset)
+				for i, p := range plaintexts {
+					fmt.Printf("    %d: %s\n", i+1, displayData(p))
+					reportFlags(opts, p, []string{fmt.Sprintf("Many-Time Pad (Input %d)", i+1)})
+				}
+				return string(bytes.Join(plaintexts, []byte("\n"))), true
+			}
+			fmt.Printf("    %sRecovered keystream does not yield clean text; best guess:%s\n", ColorYellow, ColorReset)
+			for i, p := range plaintexts {
+				fmt.Printf("    %d: %s\n", i+1, renderGutter(p))
+			}
+		}
+	}
+
+	// Nothing joint worked: analyse each artifact separately
+	var outputs []string
+	allSolved := true
+	for i, in := range inputs {
+		fmt.Printf("\n%s[+] Input %d/%d: %s%s\n", ColorBlue, i+1, len(inputs), in.Name, ColorReset)
+		out, solved := analyzeInput(ctx, in, opts)
+		outputs = append(outputs, out)
+		allSolved = allSolved && solved
+	}
+	return strings.Join(outputs, "\n"), allSolved
+}
+
+// SolveMultiRSA tries the attacks that combine several public keys: a
+// shared prime (batch GCD), one modulus under coprime exponents (common
+// modulus) and one message sent to e recipients (Håstad broadcast)
+func SolveMultiRSA(params []*RSAParams) *SolveResult {
+	// Batch GCD: any factor shared between moduli breaks both keys
+	moduli := make([]*big.Int, len(params))
+	for i, p := range params {
+		moduli[i] = p.N
+	}
+	for i, g := range BatchGCD(moduli) {
+		p := params[i]
+		if g.Cmp(big.NewInt(1)) == 0 || g.Cmp(p.N) == 0 || p.C == nil {
+			continue
+		}
+		q := new(big.Int).Div(p.N, g)
+		if m := decryptWithFactors(p, g, q); m != nil {
+			return &SolveResult{Success: true, Algorithm: fmt.Sprintf("RSA Batch GCD (Shared Prime, Key %d)", i+1), DecodedData: bigIntToString(m)}
+		}
+	}
+
+	// Common modulus: m^e1 and m^e2 mod the same N with gcd(e1, e2) = 1
+	for i := range params {
+		for j := i + 1; j < len(params); j++ {
+			if m := commonModulus(params[i], params[j]); m != nil {
+				return &SolveResult{Success: true, Algorithm: fmt.Sprintf("RSA Common Modulus (Keys %d and %d)", i+1, j+1), DecodedData: bigIntToString(m)}
+			}
+		}
+	}
+
+	// Håstad: e ciphertexts of one message under small e and distinct moduli
+	byE := make(map[int64][]*RSAParams)
+	var order []int64
+	for _, p := range params {
+		if p.C == nil || !p.E.IsInt64() || p.E.Int64() < 2 || p.E.Int64() > hastadMaxE {
+			continue
+		}
+		e := p.E.Int64()
+		if byE[e] == nil {
+			order = append(order, e)
+		}
+		byE[e] = append(byE[e], p)
+	}
+	for _, e := range order {
+		if m := hastadBroadcast(byE[e], e); m != nil {
+			return &SolveResult{Success: true, Algorithm: fmt.Sprintf("RSA Håstad Broadcast (e=%d)", e), DecodedData: bigIntToString(m)}
+		}
+	}
+	return &SolveResult{Success: false}
+}
+
+// BatchGCD returns gcd(N_i, product of all other moduli) for every modulus,
+// using a product tree and a remainder tree (Bernstein) rather than
+// pairwise GCDs
+func BatchGCD(moduli []*big.Int) []*big.Int {
+	if len(moduli) == 0 {
+		return nil
+	}
+	// Product tree: levels[0] are the moduli, the last level is the product
+	levels := [][]*big.Int{moduli}
+	for len(levels[len(levels)-1]) > 1 {
+		prev := levels[len(levels)-1]
+		var next []*big.Int
+		for i := 0; i < len(prev); i += 2 {
+			if i+1 < len(prev) {
+				next = append(next, new(big.Int).Mul(prev[i], prev[i+1]))
+			} else {
+				next = append(next, prev[i])
+			}
+		}
+		levels = append(levels, next)
+	}
+
+	// Remainder tree: reduce the product modulo each node squared
+	rems := levels[len(levels)-1]
+	for l := len(levels) - 2; l >= 0; l-- {
+		next := make([]*big.Int, len(levels[l]))
+		for i, n := range levels[l] {
+			sq := new(big.Int).Mul(n, n)
+			next[i] = new(big.Int).Mod(rems[i/2], sq)
+		}
+		rems = next
+	}
+
+	out := make([]*big.Int, len(moduli))
+	for i, n := range moduli {
+		q := new(big.Int).Div(rems[i], n)
+		out[i] = new(big.Int).GCD(nil, nil, q, n)
+	}
+	return out
+}
+
+func decryptWithFactors(p *RSAParams, a, b *big.Int) *big.Int {
+	one := big.NewInt(1)
+	phi := new(big.Int).Mul(new(big.Int).Sub(a, one), new(big.Int).Sub(b, one))
+	d := new(big.Int).ModInverse(p.E, phi)
+	if d == nil {
+		return nil
+	}
+	return new(big.Int).Exp(p.C, d, p.N)
+}
+
+func commonModulus(p1, p2 *RSAParams) *big.Int {
+	if p1.C == nil || p2.C == nil || p1.N.Cmp(p2.N) != 0 || p1.E.Cmp(p2.E) == 0 {
+		return nil
+	}
+	a, b := new(big.Int), new(big.Int)
+	if g := new(big.Int).GCD(a, b, p1.E, p2.E); g.Cmp(big.NewInt(1)) != 0 {
+		return nil
+	}
+	// m = c1^a * c2^b mod N, inverting a ciphertext for a negative exponent
+	pow := func(c, x *big.Int) *big.Int {
+		if x.Sign() < 0 {
+			inv := new(big.Int).ModInverse(c, p1.N)
+			if inv == nil {
+				return nil
+			}
+			return new(big.Int).Exp(inv, new(big.Int).Neg(x), p1.N)
+		}
+		return new(big.Int).Exp(c, x, p1.N)
+	}
+	x, y := pow(p1.C, a), pow(p2.C, b)
+	if x == nil || y == nil {
+		return nil
+	}
+	return x.Mul(x, y).Mod(x, p1.N)
+}
+
+func hastadBroadcast(params []*RSAParams, e int64) *big.Int {
+	// Keep the first e keys with pairwise coprime moduli
+	var picked []*RSAParams
+	for _, p := range params {
+		ok := true
+		for _, q := range picked {
+			if new(big.Int).GCD(nil, nil, p.N, q.N).Cmp(big.NewInt(1)) != 0 {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			picked = append(picked, p)
+		}
+		if int64(len(picked)) == e {
+			break
+		}
+	}
+	if int64(len(picked)) < e {
+		return nil
+	}
+
+	// Chinese Remainder Theorem: x = m^e mod (N1 * ... * Ne), and m^e is
+	// smaller than that product, so x is m^e itself
+	product := big.NewInt(1)
+	for _, p := range picked {
+		product.Mul(product, p.N)
+	}
+	x := new(big.Int)
+	for _, p := range picked {
+		rest := new(big.Int).Div(product, p.N)
+		inv := new(big.Int).ModInverse(rest, p.N)
+		if inv == nil {
+			return nil
+		}
+		term := new(big.Int).Mul(p.C, rest)
+		x.Add(x, term.Mul(term, inv))
+	}
+	x.Mod(x, product)
+
+	bigE := big.NewInt(e)
+	for _, m := range []*big.Int{iroot(x, bigE), new(big.Int).Add(iroot(x, bigE), big.NewInt(1))} {
+		if new(big.Int).Exp(m, bigE, nil).Cmp(x) == 0 {
+			return m
+		}
+	}
+	return nil
+}
+
+// SolveManyTimePad recovers a keystream reused across ciphertexts (two-time
+// pad) column by column: each key byte is the one making the bytes of every
+// ciphertext in that column look most like English
+func SolveManyTimePad(ciphertexts [][]byte) ([]byte, [][]byte) {
+	width := 0
+	for _, c := range ciphertexts {
+		if len(c) > width {
+			width = len(c)
+		}
+	}
+	key := make([]byte, width)
+	for col := range key {
+		best := -1e9
+		for k := 0; k < 256; k++ {
+			score := 0.0
+			for _, c := range ciphertexts {
+				if col < len(c) {
+					score += plainByteScore(c[col] ^ byte(k))
+				}
+			}
+			if score > best {
+				best, key[col] = score, byte(k)
+			}
+		}
+	}
+
+	plaintexts := make([][]byte, len(ciphertexts))
+	for i, c := range ciphertexts {
+		plaintexts[i] = xorRepeating(c, key[:len(c)])
+	}
+	return key, plaintexts
+}
+
+// plainByteScore rates one byte of a candidate plaintext: lowercase
+// letters by English frequency, space highest, capitals and punctuation
+// only slightly (a wrong key byte often flips case or lands on a symbol)
+func plainByteScore(b byte) float64 {
+	switch {
+	case b == ' ':
+		return 15
+	case b >= 'a' && b <= 'z':
+		return letterFreq[b-'a']
+	case b >= 'A' && b <= 'Z':
+		return letterFreq[b-'A'] / 4
+	case b >= '0' && b <= '9', b == '.', b == ',', b == '\'', b == '_', b == '{', b == '}', b == '\n':
+		return 1
+	case b >= 32 && b <= 126:
+		return -2
+	}
+	return -20
+}
+
+// letterFreq is the frequency (%) of a-z in English text
+var letterFreq = [26]float64{
+	8.2, 1.5, 2.8, 4.3, 12.7, 2.2, 2.0, 6.1, 7.0, 0.15, 0.77, 4.0, 2.4,
+	6.7, 7.5, 1.9, 0.1, 6.0, 6.3, 9.1, 2.8, 0.98, 2.4, 0.15, 2.0, 0.07,
+}
+
+func allBinary(data [][]byte) bool {
+	for _, d := range data {
+		if len(d) == 0 || isPrintable(d) {
+			return false
+		}
+	}
+	return true
+}
+
+// looksLikeText requires printable, mostly alphabetic plaintexts; runs of
+// spaces alone also score as English
+func looksLikeText(plaintexts [][]byte) bool {
+	letters, total := 0, 0
+	for _, p := range plaintexts {
+		if !isPrintable(p) {
+			return false
+		}
+		for _, b := range p {
+			if (b|0x20) >= 'a' && (b|0x20) <= 'z' {
+				letters++
+			}
+		}
+		total += len(p)
+	}
+	return letters*2 >= total && LooksSolved(bytes.Join(plaintexts, []byte(" ")))
+}
+
+// xorRepeating XORs data with key, repeating the shorter operand
+func xorRepeating(a, b []byte) []byte {
+	if len(b) > len(a) {
+		a, b = b, a
+	}
+	if len(b) == 0 {
+		return append([]byte(nil), a...)
+	}
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i%len(b)]
+	}
+	return out
+}
+
+// ciphertextBytes undoes the hex or Base64 armor ciphertexts usually come in
+func ciphertextBytes(data []byte) []byte {
+	s := strings.TrimSpace(string(data))
+	if b, err := hex.DecodeString(s); err == nil && len(b) > 0 {
+		return b
+	}
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil && len(b) > 0 && !isPrintable(b) {
+		return b
+	}
+	return data
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"unicode/utf16"
+)
+
+// Block keys from [MS-OFFCRYPTO] 2.3.4.13 for the agile password verifier
+var (
+	agileVerifierInputKey = []byte{0xfe, 0xa7, 0xd2, 0x76, 0x3b, 0x4b, 0x9e, 0x79}
+	agileVerifierValueKey = []byte{0xd7, 0xaa, 0x0f, 0x6d, 0x30, 0x61, 0x34, 0x4e}
+)
+
+// officeMaxSpinCount is the largest spinCount [MS-OFFCRYPTO] allows
+const officeMaxSpinCount = 10000000
+
+// OfficeEncryption describes a password-protected Office document
+type OfficeEncryption struct {
+	Scheme string
+	Hash   string // office2john / hashcat compatible
+	verify func(password string) bool
+}
+
+// Crack tries each password against the document's password verifier until
+// one matches or ctx is done
+func (e *OfficeEncryption) Crack(ctx context.Context, words []string) (string, bool) {
+	for _, word := range words {
+		if ctx.Err() != nil {
+			break
+		}
+		if e.verify(word) {
+			return word, true
+		}
+	}
+	return "", false
+}
+
+// ParseOfficeEncryption finds the encryption metadata in an OLE2 container:
+// the EncryptionInfo stream of an encrypted OOXML package, or the RC4 header
+// of a legacy Word/Excel binary document
+func ParseOfficeEncryption(ole *OLEFile) (*OfficeEncryption, error) {
+	if info, ok := ole.Stream("EncryptionInfo"); ok {
+		return parseEncryptionInfo(info)
+	}
+
+	if doc, ok := ole.Stream("WordDocument"); ok && len(doc) >= 12 {
+		flags := binary.LittleEndian.Uint16(doc[0x0A:])
+		if flags&0x0100 == 0 {
+			return nil, nil
+		}
+		if flags&0x8000 != 0 {
+			return nil, errors.New("Word XOR obfuscation is not supported")
+		}
+		tableName := "0Table"
+		if flags&0x0200 != 0 {
+			tableName = "1Table"
+		}
+		table, ok := ole.Stream(tableName)
+		if !ok {
+			return nil, fmt.Errorf("missing %s stream", tableName)
+		}
+		return parseRC4Header(table)
+	}
+
+	if book, ok := ole.Stream("Workbook"); ok {
+		// Walk BIFF records looking for FilePass (0x002F)
+		for off := 0; off+4 <= len(book) && off < 64*1024; {
+			typ := binary.LittleEndian.Uint16(book[off:])
+			size := int(binary.LittleEndian.Uint16(book[off+2:]))
+			body := book[off+4:]
+			if size > len(body) {
+				break
+			}
+			if typ == 0x002F {
+				if size < 2 || binary.LittleEndian.Uint16(body) != 1 {
+					return nil, errors.New("Excel XOR obfuscation is not supported")
+				}
+				return parseRC4Header(body[2:size])
+			}
+			off += 4 + size
+		}
+	}
+	return nil, nil
+}
+
+func parseEncryptionInfo(info []byte) (*OfficeEncryption, error) {
+	if len(info) < 8 {
+		return nil, errors.New("EncryptionInfo truncated")
+	}
+	major := binary.LittleEndian.Uint16(info[0:])
+	minor := binary.LittleEndian.Uint16(info[2:])
+	switch {
+	case major == 4 && minor == 4:
+		return parseAgileEncryption(info[8:])
+	case minor == 2 && major >= 2 && major <= 4:
+		return parseStandardEncryption(info)
+	}
+	return nil, fmt.Errorf("unsupported EncryptionInfo version %d.%d", major, minor)
+}
+
+// standardVerifier holds the fixed EncryptionHeader/EncryptionVerifier fields
+// shared by Office 2007 "Standard" AES and Office XP/2003 CryptoAPI RC4
+type standardVerifier struct {
+	AlgID                 uint32
+	KeyBits               int
+	Salt                  []byte
+	EncryptedVerifier     []byte
+	EncryptedVerifierHash []byte
+}
+
+func readStandardVerifier(info []byte) (*standardVerifier, error) {
+	le := binary.LittleEndian
+	if len(info) < 12 {
+		return nil, errors.New("encryption header truncated")
+	}
+	headerSize := int(le.Uint32(info[8:]))
+	v := 12 + headerSize
+	if headerSize < 32 || v+40 > len(info) {
+		return nil, errors.New("encryption header truncated")
+	}
+	header := info[12:]
+	saltSize := int(le.Uint32(info[v:]))
+	if saltSize != 16 {
+		return nil, fmt.Errorf("unexpected salt size %d", saltSize)
+	}
+	sv := &standardVerifier{
+		AlgID:             le.Uint32(header[8:]),
+		KeyBits:           int(le.Uint32(header[16:])),
+		Salt:              info[v+4 : v+20],
+		EncryptedVerifier: info[v+20 : v+36],
+	}
+	hashLen := 20
+	if sv.AlgID != 0x6801 {
+		hashLen = 32 // AES pads the SHA1 verifier hash to two blocks
+	}
+	if v+40+hashLen > len(info) {
+		return nil, errors.New("encryption verifier truncated")
+	}
+	sv.EncryptedVerifierHash = info[v+40 : v+40+hashLen]
+	if sv.KeyBits == 0 {
+		sv.KeyBits = 40 // CryptoAPI RC4 default
+	}
+	// The key is cut from SHA-1 output, so the header's size has to fit it
+	if sv.AlgID == 0x6801 {
+		if sv.KeyBits < 40 || sv.KeyBits > 128 || sv.KeyBits%8 != 0 {
+			return nil, fmt.Errorf("unsupported RC4 key size %d", sv.KeyBits)
+		}
+	} else if !validAESKeyBits(sv.KeyBits) {
+		return nil, fmt.Errorf("unsupported AES key size %d", sv.KeyBits)
+	}
+	return sv, nil
+}
+
+func parseStandardEncryption(info []byte) (*OfficeEncryption, error) {
+	sv, err := readStandardVerifier(info)
+	if err != nil {
+		return nil, err
+	}
+	if sv.AlgID == 0x6801 {
+		return cryptoAPIRC4(sv), nil
+	}
+
+	enc := &OfficeEncryption{
+		Scheme: fmt.Sprintf("Standard Encryption (Office 2007, AES-%d)", sv.KeyBits),
+		Hash: fmt.Sprintf("$office$*2007*20*%d*16*%x*%x*%x", sv.KeyBits,
+			sv.Salt, sv.EncryptedVerifier, sv.EncryptedVerifierHash[:20]),
+	}
+	enc.verify = func(password string) bool {
+		h := sha1.New()
+		hv := officeIteratedHash(h, sv.Salt, password, 50000)
+		h.Reset()
+		h.Write(hv)
+		h.Write([]byte{0, 0, 0, 0})
+		key := standardDeriveKey(h.Sum(nil), sv.KeyBits/8)
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return false
+		}
+		verifier := make([]byte, 16)
+		block.Decrypt(verifier, sv.EncryptedVerifier)
+		// The first ECB block of the verifier hash is enough to confirm
+		verifierHash := make([]byte, 16)
+		block.Decrypt(verifierHash, sv.EncryptedVerifierHash[:16])
+		sum := sha1.Sum(verifier)
+		return bytes.Equal(sum[:16], verifierHash)
+	}
+	return enc, nil
+}
+
+func validAESKeyBits(bits int) bool {
+	return bits == 128 || bits == 192 || bits == 256
+}
+
+// standardDeriveKey is the CryptDeriveKey construction from [MS-OFFCRYPTO] 2.3.4.7
+func standardDeriveKey(hv []byte, keyLen int) []byte {
+	buf1 := bytes.Repeat([]byte{0x36}, 64)
+	buf2 := bytes.Repeat([]byte{0x5c}, 64)
+	for i, b := range hv {
+		buf1[i] ^= b
+		buf2[i] ^= b
+	}
+	x1 := sha1.Sum(buf1)
+	x2 := sha1.Sum(buf2)
+	return append(x1[:], x2[:]...)[:keyLen]
+}
+
+func cryptoAPIRC4(sv *standardVerifier) *OfficeEncryption {
+	typ := 4
+	if sv.KeyBits == 40 {
+		typ = 3
+	}
+	enc := &OfficeEncryption{
+		Scheme: fmt.Sprintf("RC4 CryptoAPI (Office XP-2003, %d-bit)", sv.KeyBits),
+		Hash:   fmt.Sprintf("$oldoffice$%d*%x*%x*%x", typ, sv.Salt, sv.EncryptedVerifier, sv.EncryptedVerifierHash),
+	}
+	enc.verify = func(password string) bool {
+		h0 := sha1.Sum(append(append([]byte{}, sv.Salt...), utf16le(password)...))
+		hf := sha1.Sum(append(h0[:], 0, 0, 0, 0))
+		key := hf[:sv.KeyBits/8]
+		if sv.KeyBits == 40 {
+			key = append(append([]byte{}, key...), make([]byte, 11)...)
+		}
+		return rc4VerifierMatches(key, sv.EncryptedVerifier, sv.EncryptedVerifierHash, func(b []byte) []byte {
+			s := sha1.Sum(b)
+			return s[:]
+		})
+	}
+	return enc
+}
+
+// parseRC4Header handles the encryption header at the start of a legacy
+// Word table stream or inside an Excel FilePass record
+func parseRC4Header(b []byte) (*OfficeEncryption, error) {
+	if len(b) < 4 {
+		return nil, errors.New("RC4 encryption header truncated")
+	}
+	major := binary.LittleEndian.Uint16(b[0:])
+	minor := binary.LittleEndian.Uint16(b[2:])
+	if major >= 2 && minor == 2 {
+		sv, err := readStandardVerifier(b)
+		if err != nil {
+			return nil, err
+		}
+		return cryptoAPIRC4(sv), nil
+	}
+	if major != 1 || minor != 1 || len(b) < 52 {
+		return nil, fmt.Errorf("unsupported RC4 header version %d.%d", major, minor)
+	}
+
+	salt, encVerifier, encHash := b[4:20], b[20:36], b[36:52]
+	enc := &OfficeEncryption{
+		Scheme: "RC4 (Office 97-2003, 40-bit MD5)",
+		Hash:   fmt.Sprintf("$oldoffice$0*%x*%x*%x", salt, encVerifier, encHash),
+	}
+	enc.verify = func(password string) bool {
+		h0 := md5.Sum(utf16le(password))
+		var buf []byte
+		for i := 0; i < 16; i++ {
+			buf = append(buf, h0[:5]...)
+			buf = append(buf, salt...)
+		}
+		h1 := md5.Sum(buf)
+		key := md5.Sum(append(h1[:5], 0, 0, 0, 0))
+		return rc4VerifierMatches(key[:], encVerifier, encHash, func(b []byte) []byte {
+			s := md5.Sum(b)
+			return s[:]
+		})
+	}
+	return enc, nil
+}
+
+// rc4VerifierMatches decrypts verifier and verifier hash as one RC4 stream
+// and checks the hash
+func rc4VerifierMatches(key, encVerifier, encHash []byte, sum func([]byte) []byte) bool {
+	c, err := rc4.NewCipher(key)
+	if err != nil {
+		return false
+	}
+	verifier := make([]byte, len(encVerifier))
+	c.XORKeyStream(verifier, encVerifier)
+	verifierHash := make([]byte, len(encHash))
+	c.XORKeyStream(verifierHash, encHash)
+	return bytes.Equal(sum(verifier), verifierHash)
+}
+
+// agileEncryption mirrors the XML descriptor of Office 2010+ encryption
+type agileEncryption struct {
+	KeyEncryptors []struct {
+		URI          string `xml:"uri,attr"`
+		EncryptedKey struct {
+			SpinCount                  int    `xml:"spinCount,attr"`
+			KeyBits                    int    `xml:"keyBits,attr"`
+			HashSize                   int    `xml:"hashSize,attr"`
+			HashAlgorithm              string `xml:"hashAlgorithm,attr"`
+			SaltValue                  string `xml:"saltValue,attr"`
+			EncryptedVerifierHashInput string `xml:"encryptedVerifierHashInput,attr"`
+			EncryptedVerifierHashValue string `xml:"encryptedVerifierHashValue,attr"`
+		} `xml:"encryptedKey"`
+	} `xml:"keyEncryptors>keyEncryptor"`
+}
+
+func parseAgileEncryption(descriptor []byte) (*OfficeEncryption, error) {
+	var desc agileEncryption
+	if err := xml.Unmarshal(descriptor, &desc); err != nil {
+		return nil, fmt.Errorf("bad agile descriptor: %v", err)
+	}
+	for _, ke := range desc.KeyEncryptors {
+		if ke.URI != "http://schemas.microsoft.com/office/2006/keyEncryptor/password" {
+			continue
+		}
+		k := ke.EncryptedKey
+		salt, err1 := base64.StdEncoding.DecodeString(k.SaltValue)
+		encInput, err2 := base64.StdEncoding.DecodeString(k.EncryptedVerifierHashInput)
+		encValue, err3 := base64.StdEncoding.DecodeString(k.EncryptedVerifierHashValue)
+		if err1 != nil || err2 != nil || err3 != nil || len(encValue) < 32 || len(encInput) < 16 {
+			return nil, errors.New("bad agile password key encryptor")
+		}
+		// [MS-OFFCRYPTO] caps spinCount; a crafted value would stall cracking
+		if k.SpinCount < 0 || k.SpinCount > officeMaxSpinCount {
+			return nil, fmt.Errorf("spinCount %d outside 0-%d", k.SpinCount, officeMaxSpinCount)
+		}
+		if !validAESKeyBits(k.KeyBits) {
+			return nil, fmt.Errorf("unsupported AES key size %d", k.KeyBits)
+		}
+
+		var newHash func() hash.Hash
+		version := "2013"
+		switch k.HashAlgorithm {
+		case "SHA1":
+			newHash, version = sha1.New, "2010"
+		case "SHA256":
+			newHash = sha256.New
+		case "SHA384":
+			newHash = sha512.New384
+		case "SHA512":
+			newHash = sha512.New
+		default:
+			return nil, fmt.Errorf("unsupported agile hash %s", k.HashAlgorithm)
+		}
+
+		enc := &OfficeEncryption{
+			Scheme: fmt.Sprintf("Agile Encryption (Office %s+, AES-%d, %s x %d)", version, k.KeyBits, k.HashAlgorithm, k.SpinCount),
+			Hash: fmt.Sprintf("$office$*%s*%d*%d*%d*%s*%s*%s", version, k.SpinCount, k.KeyBits, len(salt),
+				hex.EncodeToString(salt), hex.EncodeToString(encInput), hex.EncodeToString(encValue[:32])),
+		}
+		enc.verify = func(password string) bool {
+			h := newHash()
+			hv := officeIteratedHash(h, salt, password, k.SpinCount)
+			keyLen := k.KeyBits / 8
+			input := agileDecrypt(h, hv, agileVerifierInputKey, keyLen, salt, encInput)
+			value := agileDecrypt(h, hv, agileVerifierValueKey, keyLen, salt, encValue)
+			if input == nil || value == nil || len(input) < len(salt) {
+				return false
+			}
+			h.Reset()
+			h.Write(input[:len(salt)])
+			// The stored value may be truncated (office2john keeps 32 bytes)
+			n := min(h.Size(), len(value))
+			return bytes.Equal(h.Sum(nil)[:n], value[:n])
+		}
+		return enc, nil
+	}
+	return nil, errors.New("no password key encryptor in agile descriptor")
+}
+
+// officeIteratedHash computes H0 = H(salt + password) followed by spinCount
+// rounds of H(iterator + H), the password hash shared by Standard and Agile
+func officeIteratedHash(h hash.Hash, salt []byte, password string, spinCount int) []byte {
+	h.Reset()
+	h.Write(salt)
+	h.Write(utf16le(password))
+	hv := h.Sum(nil)
+	var it [4]byte
+	for i := 0; i < spinCount; i++ {
+		binary.LittleEndian.PutUint32(it[:], uint32(i))
+		h.Reset()
+		h.Write(it[:])
+		h.Write(hv)
+		hv = h.Sum(hv[:0])
+	}
+	return hv
+}
+
+func agileDecrypt(h hash.Hash, hv, blockKey []byte, keyLen int, iv, ciphertext []byte) []byte {
+	h.Reset()
+	h.Write(hv)
+	h.Write(blockKey)
+	key := h.Sum(nil)
+	for len(key) < keyLen {
+		key = append(key, 0x36)
+	}
+	block, err := aes.NewCipher(key[:keyLen])
+	if err != nil || len(ciphertext)%aes.BlockSize != 0 || len(iv) < aes.BlockSize {
+		return nil
+	}
+	out := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv[:aes.BlockSize]).CryptBlocks(out, ciphertext)
+	return out
+}
+
+func utf16le(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	out := make([]byte, len(units)*2)
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(out[i*2:], u)
+	}
+	return out
+}
+
+// officeSolver emits the hash of a password-protected Office document and
+// runs the wordlist
+type officeSolver struct{}
+
+func (officeSolver) Name() string { return "Office Encryption" }
+
+func (officeSolver) Applicable(l *Layer) bool {
+	return l.Type == "File (OLE2)"
+}
+
+func (officeSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	analyzeOffice(ctx, l.Data, l.Opts)
+	return nil
+}
+
+func analyzeOffice(ctx context.Context, data []byte, opts *Options) {
+	ole, err := ParseOLE(data)
+	if err != nil {
+		fmt.Printf("    %sFailed to parse: %v%s\n", ColorYellow, err, ColorReset)
+		return
+	}
+	enc, err := ParseOfficeEncryption(ole)
+	if enc == nil && err == nil {
+		fmt.Printf("    Not encrypted.\n")
+		return
+	}
+	if err != nil {
+		fmt.Printf("    %sFailed to parse encryption: %v%s\n", ColorYellow, err, ColorReset)
+		return
+	}
+	fmt.Printf("    Scheme: %s\n", enc.Scheme)
+	EmitHash(opts, "office2john", enc.Hash)
+
+	ctx, cancel := attackContext(ctx, opts)
+	defer cancel()
+	if password, ok := enc.Crack(ctx, opts.Wordlist); ok {
+		fmt.Printf("    %sSuccess! Password: %s%s\n", ColorGreen, password, ColorReset)
+	} else if !reportStopped(ctx) {
+		fmt.Printf("    %sPassword not found in wordlist (%d tried).%s\n", ColorYellow, len(opts.Wordlist), ColorReset)
+	}
+}
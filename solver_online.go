@@ -1,19 +1,33 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
+// userAgent identifies cipher-sleuth to the services it contacts
+const userAgent = "Mozilla/5.0 (compatible; CipherSleuth/1.0; +https://github.com/byteoverride/cipher-sleuth)"
+
+// maxFetchSize bounds the body downloaded by -u
+const maxFetchSize = 32 << 20
+
+// fetchTimeout bounds the whole -u download, body included. The lookups
+// keep the client's short timeout; a 32 MiB file needs far longer.
+const fetchTimeout = 2 * time.Minute
+
 // OnlineSolver handles online operations
 type OnlineSolver struct {
 	Client *http.Client
 }
 
-// NewOnlineSolver creates a new online solver with a 2s timeout
+// NewOnlineSolver creates a new online solver with a 5s timeout per request
 func NewOnlineSolver() *OnlineSolver {
 	return &OnlineSolver{
 		Client: &http.Client{
@@ -22,23 +36,94 @@ func NewOnlineSolver() *OnlineSolver {
 	}
 }
 
-// GenerateMagicLinks prints passive fallback links
-func (s *OnlineSolver) GenerateMagicLinks(input string) {
-	// CyberChef
-	// Magic recipe is often used, or we can just pass input
-	// CyberChef URL format: https://gchq.github.io/CyberChef/#recipe=Magic(3,false,false,'')&input=...
-	// We need to base64 encode the input for the URL prompt usually, or URL encode
-	encodedInput := url.QueryEscape(input)
-	fmt.Printf("  - CyberChef (Magic): https://gchq.github.io/CyberChef/#recipe=Magic(3,false,false,'')&input=%s\n", encodedInput)
-
-	// dCode
-	// dCode doesn't have a generic "magic" but has specific tools.
-	// We can link to the identifier or a common one.
-	fmt.Printf("  - dCode (Cipher Identifier): https://www.dcode.fr/cipher-identifier\n")
+// MagicLink is an online tool suited to the input
+type MagicLink struct {
+	Tool string
+	URL  string
+}
+
+// cyberChefRecipes decode an identified encoding directly instead of
+// leaving it to Magic
+var cyberChefRecipes = map[string]string{
+	"Hex":    "From_Hex('Auto')",
+	"Base32": "From_Base32('A-Z2-7%3D',true)",
+	"Base64": "From_Base64('A-Za-z0-9%2B/%3D',true,false)",
+	"Base58": "From_Base58('123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz',true)",
+	"URL":    "URL_Decode()",
+}
+
+// MagicLinks picks the online tools for the identified type, the RSA
+// modulus if one was parsed, and the shape of the text (letters and their
+// IoC, digits, book references), most specific first
+func MagicLinks(input, identifiedType string, ioc float64, n *big.Int) []MagicLink {
+	var links []MagicLink
+	add := func(tool, url string) { links = append(links, MagicLink{tool, url}) }
+	data := []byte(input)
+
+	switch {
+	case identifiedType == "Hash (CRC32)":
+		// Checksums are inverted locally; no lookup site stores them
+	case strings.HasPrefix(identifiedType, "Hash ("):
+		add("CrackStation", "https://crackstation.net/")
+		add("Hashes.com", "https://hashes.com/en/decrypt/hash")
+	case strings.HasPrefix(identifiedType, "Encoded Text ("):
+		name := strings.TrimSuffix(strings.TrimPrefix(identifiedType, "Encoded Text ("), "?)")
+		if recipe, ok := cyberChefRecipes[name]; ok {
+			add("CyberChef (From "+name+")", "https://gchq.github.io/CyberChef/#recipe="+recipe+"&input="+url.QueryEscape(input))
+		}
+	case strings.HasPrefix(identifiedType, "Key ("):
+		add("ASN.1 JavaScript decoder", "https://lapo.it/asn1js/")
+	}
+	switch identifiedType {
+	case "File (PNG)", "File (JPG)", "File (GIF)":
+		add("Aperi'Solve", "https://www.aperisolve.com/")
+		add("StegOnline", "https://georgeom.net/StegOnline/upload")
+	case "File (MP3)", "File (MPEG Audio)", "File (OGG)", "File (FLAC)":
+		add("Spectrum Analyzer", "https://academo.org/demos/spectrum-analyzer/")
+	case "File (ELF)":
+		add("Decompiler Explorer", "https://dogbolt.org/")
+	}
+	if n != nil {
+		add("FactorDB", "https://factordb.com/index.php?query="+n.String())
+		add("dCode (RSA)", "https://www.dcode.fr/rsa-cipher")
+	}
+
+	// Classical ciphers, by what the text is made of
+	switch {
+	case looksMonoalphabetic(data, ioc):
+		add("quipqiup", "https://quipqiup.com/")
+		add("dCode (Monoalphabetic Substitution)", "https://www.dcode.fr/monoalphabetic-substitution")
+	case isLetterText(data):
+		add("dCode (Caesar)", "https://www.dcode.fr/caesar-cipher")
+		add("dCode (Vigenère)", "https://www.dcode.fr/vigenere-cipher")
+	case isDigitText(input):
+		add("dCode (Straddling Checkerboard)", "https://www.dcode.fr/straddling-checkerboard-cipher")
+	}
+	if _, ok := ParseBookRefs(input); ok {
+		add("dCode (Book Cipher)", "https://www.dcode.fr/book-cipher")
+	}
+
+	add("CyberChef (Magic)", "https://gchq.github.io/CyberChef/#recipe=Magic(3,false,false,'')&input="+url.QueryEscape(input))
+	add("dCode (Cipher Identifier)", "https://www.dcode.fr/cipher-identifier")
+	return links
+}
+
+// isLetterText reports text of mostly letters, long enough for a classical
+// cipher solver to work with
+func isLetterText(data []byte) bool {
+	letters, other := letterCounts(data)
+	return letters >= 12 && letters >= 4*other
+}
+
+// GenerateMagicLinks prints passive fallback links for the identified type
+func (s *OnlineSolver) GenerateMagicLinks(input, identifiedType string, ioc float64, n *big.Int) {
+	for _, l := range MagicLinks(input, identifiedType, ioc, n) {
+		fmt.Printf("  - %s: %s\n", l.Tool, l.URL)
+	}
 }
 
 // ActiveLookup attempts to reverse a hash using online APIs
-func (s *OnlineSolver) ActiveLookup(hash string, hashType string) (bool, string) {
+func (s *OnlineSolver) ActiveLookup(ctx context.Context, hash string, hashType string) (bool, string) {
 	// Simple active lookup for MD5/SHA1 using nitrxgen or hashtoolkit
 	// Note: these are examples and might not always work or have rate limits.
 	// We'll try nitrxgen for MD5 as requested in prompt.
@@ -46,7 +131,7 @@ func (s *OnlineSolver) ActiveLookup(hash string, hashType string) (bool, string)
 	if hashType == "MD5" || hashType == "NTLM" {
 		// NTLM and MD5 have the same format (32 hex chars).
 		// We'll try the MD5 lookup service for both.
-		return s.lookupNitrxgen(hash)
+		return s.lookupNitrxgen(ctx, hash)
 	}
 
 	// For other hashes, we could add more APIs, but for this task we'll implement MD5 as the primary example
@@ -55,15 +140,15 @@ func (s *OnlineSolver) ActiveLookup(hash string, hashType string) (bool, string)
 	return false, ""
 }
 
-func (s *OnlineSolver) lookupNitrxgen(hash string) (bool, string) {
+func (s *OnlineSolver) lookupNitrxgen(ctx context.Context, hash string) (bool, string) {
 	url := fmt.Sprintf("https://www.nitrxgen.net/md5db/%s", hash)
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return false, ""
 	}
 
 	// Custom User-Agent
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; CipherSleuth/1.0; +https://github.com/byteoverride/cipher-sleuth)")
+	req.Header.Set("User-Agent", userAgent)
 
 	resp, err := s.Client.Do(req)
 	if err != nil {
@@ -79,3 +164,95 @@ func (s *OnlineSolver) lookupNitrxgen(hash string) (bool, string) {
 	}
 	return false, ""
 }
+
+// FetchURL downloads the body served at rawURL for analysis, refusing
+// non-HTTP schemes, error statuses and bodies over maxFetchSize. The
+// download is bounded by fetchTimeout (and ctx) instead of the client's
+// timeout.
+func (s *OnlineSolver) FetchURL(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q (want http or https)", u.Scheme)
+	}
+	ctx, cancel := context.WithTimeoutCause(ctx, fetchTimeout, fmt.Errorf("download took over %s", fetchTimeout))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	client := *s.Client
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+	if resp.ContentLength > maxFetchSize {
+		return nil, fmt.Errorf("response too large (%d bytes, limit %d)", resp.ContentLength, maxFetchSize)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchSize+1))
+	if err != nil {
+		if cause := context.Cause(ctx); cause != nil {
+			return nil, cause
+		}
+		return nil, err
+	}
+	if len(body) > maxFetchSize {
+		return nil, errors.New("response exceeds the size limit")
+	}
+	return body, nil
+}
+
+// fallbackSolver runs last: with --online it looks hashes up, and it always
+// prints hints for what no local solver covers and links to online tools
+type fallbackSolver struct{}
+
+func (fallbackSolver) Name() string { return "Online Fallback" }
+
+func (fallbackSolver) Applicable(l *Layer) bool { return true }
+
+func (fallbackSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	online := NewOnlineSolver()
+	text := string(l.Data)
+
+	// Active lookup when the layer looks like a hash
+	if _, rest, ok := strings.Cut(l.Type, "Hash ("); l.Opts.Online && ok {
+		hashType := strings.TrimRight(rest, ")")
+		if success, result := online.ActiveLookup(ctx, text, hashType); success {
+			return []Candidate{{Step: "Online Lookup", Data: []byte(result), Final: true}}
+		}
+		fmt.Printf("    %sActive Lookup: Failed or Not Supported.%s\n", ColorRed, ColorReset)
+	}
+
+	// Hostnames may hide data in their TXT records (DNS exfiltration)
+	if !l.Opts.Online && isPrintable(l.Data) {
+		if names := FindHostnames(l.Data); len(names) > 0 {
+			fmt.Printf("    Hostnames: %s (--online fetches their TXT records)\n", strings.Join(names, ", "))
+		}
+	}
+
+	// Base2048 has no local decoder: its repertoire is a fixed table
+	if LooksBase2048(l.Data) {
+		fmt.Printf("    %sLooks like Base2048 (letters of many scripts below U+1100): decode with qntm's base2048 (https://github.com/qntm/base2048).%s\n", ColorYellow, ColorReset)
+	}
+
+	// Number pairs and triplets may index a book the solver was not given
+	if l.Opts.Book == nil {
+		if refs, ok := ParseBookRefs(text); ok && len(refs[0]) > 1 {
+			fmt.Printf("    %sLooks like book cipher references (%d numbers each): supply the key text with -book <file>.%s\n", ColorYellow, len(refs[0]), ColorReset)
+		}
+	}
+
+	// Always show passive links
+	online.GenerateMagicLinks(text, l.Type, l.IoC, l.RSA.N)
+	return nil
+}
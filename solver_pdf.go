@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pdfPadding is the 32-byte password padding string from the PDF spec
+var pdfPadding = []byte{
+	0x28, 0xbf, 0x4e, 0x5e, 0x4e, 0x75, 0x8a, 0x41, 0x64, 0x00, 0x4e, 0x56, 0xff, 0xfa, 0x01, 0x08,
+	0x2e, 0x2e, 0x00, 0xb6, 0xd0, 0x68, 0x3e, 0x80, 0x2f, 0x0c, 0xa9, 0xfe, 0x64, 0x53, 0x69, 0x7a,
+}
+
+var (
+	pdfEncryptRef = regexp.MustCompile(`/Encrypt\s+(\d+)\s+(\d+)\s+R`)
+	pdfEncryptDct = regexp.MustCompile(`/Encrypt\s*<<`)
+	pdfTrailerID  = regexp.MustCompile(`/ID\s*\[\s*(<[0-9A-Fa-f\s]*>|\()`)
+)
+
+// PDFEncryption holds the Standard security handler parameters
+type PDFEncryption struct {
+	V, R, Length    int
+	P               int32
+	EncryptMetadata bool
+	ID, O, U        []byte
+}
+
+// ParsePDFEncryption locates the /Encrypt dictionary (direct or referenced
+// from the trailer) and the first /ID string of an encrypted PDF
+func ParsePDFEncryption(data []byte) (*PDFEncryption, error) {
+	var dict map[string]pdfValue
+	if refs := pdfEncryptRef.FindAllSubmatch(data, -1); refs != nil {
+		last := refs[len(refs)-1] // incremental updates append newer trailers
+		header := regexp.MustCompile(`(?:^|\s)` + string(last[1]) + `\s+` + string(last[2]) + `\s+obj\s*<<`)
+		loc := header.FindIndex(data)
+		if loc == nil {
+			return nil, errors.New("referenced /Encrypt object not found")
+		}
+		dict = parsePDFDict(data[loc[1]:])
+	} else if loc := pdfEncryptDct.FindIndex(data); loc != nil {
+		dict = parsePDFDict(data[loc[1]:])
+	} else {
+		return nil, nil
+	}
+
+	if f := dict["Filter"].name; f != "" && f != "Standard" {
+		return nil, fmt.Errorf("unsupported security handler /%s", f)
+	}
+	enc := &PDFEncryption{
+		V:               dict["V"].num,
+		R:               dict["R"].num,
+		Length:          40,
+		P:               int32(dict["P"].num),
+		EncryptMetadata: dict["EncryptMetadata"].name != "false",
+		O:               dict["O"].str,
+		U:               dict["U"].str,
+	}
+	if l := dict["Length"].num; l > 0 {
+		enc.Length = l
+	}
+	if enc.R >= 5 {
+		enc.Length = 256
+	}
+	if len(enc.O) < 32 || len(enc.U) < 32 {
+		return nil, errors.New("/O or /U entry missing")
+	}
+
+	if ids := pdfTrailerID.FindAllSubmatchIndex(data, -1); ids != nil {
+		// First string of the last /ID array
+		start := ids[len(ids)-1][2]
+		enc.ID, _ = readPDFString(data[start:])
+	}
+	if enc.ID == nil && enc.R < 5 {
+		return nil, errors.New("trailer /ID missing")
+	}
+	return enc, nil
+}
+
+// Hash returns the pdf2john / hashcat representation
+func (e *PDFEncryption) Hash() string {
+	meta := 0
+	if e.EncryptMetadata {
+		meta = 1
+	}
+	return fmt.Sprintf("$pdf$%d*%d*%d*%d*%d*%d*%s*%d*%s*%d*%s", e.V, e.R, e.Length, e.P, meta,
+		len(e.ID), hex.EncodeToString(e.ID), len(e.U), hex.EncodeToString(e.U), len(e.O), hex.EncodeToString(e.O))
+}
+
+// CheckUserPassword validates a user password with Algorithms 6 (R2-4),
+// 11 (R5) or the R6 hash from ISO 32000-2
+func (e *PDFEncryption) CheckUserPassword(password string) bool {
+	switch {
+	case e.R >= 2 && e.R <= 4:
+		key := e.fileKey(password)
+		if e.R == 2 {
+			out := make([]byte, 32)
+			c, _ := rc4.NewCipher(key)
+			c.XORKeyStream(out, pdfPadding)
+			return bytes.Equal(out, e.U[:32])
+		}
+		h := md5.New()
+		h.Write(pdfPadding)
+		h.Write(e.ID)
+		x := h.Sum(nil)
+		for i := 0; i < 20; i++ {
+			k := make([]byte, len(key))
+			for j := range key {
+				k[j] = key[j] ^ byte(i)
+			}
+			c, _ := rc4.NewCipher(k)
+			c.XORKeyStream(x, x)
+		}
+		return bytes.Equal(x, e.U[:16])
+	case e.R == 5 || e.R == 6:
+		if len(e.U) < 48 {
+			return false
+		}
+		pw := []byte(password)
+		if len(pw) > 127 {
+			pw = pw[:127]
+		}
+		validationSalt := e.U[32:40]
+		var digest []byte
+		if e.R == 5 {
+			sum := sha256.Sum256(append(append([]byte{}, pw...), validationSalt...))
+			digest = sum[:]
+		} else {
+			digest = pdfHash2B(pw, validationSalt, nil)
+		}
+		return bytes.Equal(digest, e.U[:32])
+	}
+	return false
+}
+
+// fileKey is Algorithm 2: the RC4/AES-128 file encryption key for R2-4
+func (e *PDFEncryption) fileKey(password string) []byte {
+	padded := append([]byte(password), pdfPadding...)[:32]
+	h := md5.New()
+	h.Write(padded)
+	h.Write(e.O[:32])
+	h.Write([]byte{byte(e.P), byte(e.P >> 8), byte(e.P >> 16), byte(e.P >> 24)})
+	h.Write(e.ID)
+	if e.R >= 4 && !e.EncryptMetadata {
+		h.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	}
+	key := h.Sum(nil)
+
+	n := 5
+	if e.R >= 3 {
+		n = e.Length / 8
+		if n < 5 || n > 16 {
+			n = 16
+		}
+		for i := 0; i < 50; i++ {
+			sum := md5.Sum(key[:n])
+			key = sum[:]
+		}
+	}
+	return key[:n]
+}
+
+// pdfHash2B is the iterated SHA-2/AES hash used by revision 6
+func pdfHash2B(password, salt, udata []byte) []byte {
+	sum := sha256.Sum256(append(append(append([]byte{}, password...), salt...), udata...))
+	k := sum[:]
+	for round := 0; ; round++ {
+		seq := append(append(append([]byte{}, password...), k...), udata...)
+		k1 := bytes.Repeat(seq, 64)
+
+		block, _ := aes.NewCipher(k[:16])
+		e := make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, k[16:32]).CryptBlocks(e, k1)
+
+		mod := 0
+		for _, b := range e[:16] {
+			mod += int(b)
+		}
+		var h hash.Hash
+		switch mod % 3 {
+		case 0:
+			h = sha256.New()
+		case 1:
+			h = sha512.New384()
+		default:
+			h = sha512.New()
+		}
+		h.Write(e)
+		k = h.Sum(nil)
+
+		// At least 64 rounds, then until the last byte of E <= rounds done - 32
+		if round >= 63 && int(e[len(e)-1]) <= round+1-32 {
+			break
+		}
+	}
+	return k[:32]
+}
+
+// pdfValue is a loosely typed PDF object, enough for flat dictionaries
+type pdfValue struct {
+	num  int
+	name string
+	str  []byte
+}
+
+// parsePDFDict reads key/value pairs up to the closing ">>" of a dictionary
+// whose opening "<<" has already been consumed. Nested dictionaries and
+// arrays are skipped.
+func parsePDFDict(b []byte) map[string]pdfValue {
+	dict := make(map[string]pdfValue)
+	var key string
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c == '>' && i+1 < len(b) && b[i+1] == '>':
+			return dict
+		case c == '/':
+			j := i + 1
+			for j < len(b) && !isPDFDelimiter(b[j]) {
+				j++
+			}
+			name := string(b[i+1 : j])
+			if key == "" {
+				key = name
+			} else {
+				dict[key] = pdfValue{name: name}
+				key = ""
+			}
+			i = j
+		case c == '(' || (c == '<' && (i+1 >= len(b) || b[i+1] != '<')):
+			s, n := readPDFString(b[i:])
+			if key != "" {
+				dict[key] = pdfValue{str: s}
+				key = ""
+			}
+			i += n
+		case c == '<' || c == '[':
+			i += skipPDFNested(b[i:])
+			key = ""
+		case c == '-' || c == '+' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(b) && (b[j] >= '0' && b[j] <= '9' || b[j] == '.') {
+				j++
+			}
+			num, _ := strconv.Atoi(strings.SplitN(string(b[i:j]), ".", 2)[0])
+			if key != "" {
+				dict[key] = pdfValue{num: num}
+				key = ""
+			}
+			i = j
+		case c >= 'a' && c <= 'z':
+			j := i
+			for j < len(b) && b[j] >= 'a' && b[j] <= 'z' {
+				j++
+			}
+			if key != "" {
+				// Booleans stored as names, indirect "R" is ignored
+				if word := string(b[i:j]); word == "true" || word == "false" {
+					dict[key] = pdfValue{name: word}
+					key = ""
+				}
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	return dict
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte(" \t\r\n\f\x00()<>[]{}/%", c) >= 0
+}
+
+// readPDFString decodes a literal "(...)" or hex "<...>" string, returning
+// the bytes and how much input was consumed
+func readPDFString(b []byte) ([]byte, int) {
+	if len(b) == 0 {
+		return nil, 0
+	}
+	if b[0] == '<' {
+		end := bytes.IndexByte(b, '>')
+		if end < 0 {
+			return nil, len(b)
+		}
+		digits := strings.Map(func(r rune) rune {
+			if strings.ContainsRune(" \t\r\n\f", r) {
+				return -1
+			}
+			return r
+		}, string(b[1:end]))
+		if len(digits)%2 == 1 {
+			digits += "0"
+		}
+		s, _ := hex.DecodeString(digits)
+		return s, end + 1
+	}
+
+	var out []byte
+	depth := 0
+	for i := 1; i < len(b); i++ {
+		c := b[i]
+		switch c {
+		case '\\':
+			i++
+			if i >= len(b) {
+				return out, i
+			}
+			switch e := b[i]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				if i+1 < len(b) && b[i+1] == '\n' {
+					i++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := 0
+					j := i
+					for ; j < len(b) && j < i+3 && b[j] >= '0' && b[j] <= '7'; j++ {
+						v = v*8 + int(b[j]-'0')
+					}
+					out = append(out, byte(v))
+					i = j - 1
+				} else {
+					out = append(out, e)
+				}
+			}
+		case '(':
+			depth++
+			out = append(out, c)
+		case ')':
+			if depth == 0 {
+				return out, i + 1
+			}
+			depth--
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out, len(b)
+}
+
+// skipPDFNested skips a balanced array or dictionary, returning its length
+func skipPDFNested(b []byte) int {
+	depth := 0
+	for i := 0; i < len(b); i++ {
+		switch {
+		case b[i] == '(':
+			_, n := readPDFString(b[i:])
+			i += n - 1
+		case b[i] == '[' || (b[i] == '<' && i+1 < len(b) && b[i+1] == '<'):
+			depth++
+			if b[i] == '<' {
+				i++
+			}
+		case b[i] == ']' || (b[i] == '>' && i+1 < len(b) && b[i+1] == '>'):
+			depth--
+			if b[i] == '>' {
+				i++
+			}
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(b)
+}
+
+// pdfSolver emits the hash of an encrypted PDF and runs the wordlist
+// against its user password
+type pdfSolver struct{}
+
+func (pdfSolver) Name() string { return "PDF Encryption" }
+
+func (pdfSolver) Applicable(l *Layer) bool {
+	return l.Type == "File (PDF)"
+}
+
+func (pdfSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	analyzePDF(ctx, l.Data, l.Opts)
+	return nil
+}
+
+func analyzePDF(ctx context.Context, data []byte, opts *Options) {
+	enc, err := ParsePDFEncryption(data)
+	if enc == nil && err == nil {
+		fmt.Printf("    Not encrypted.\n")
+		return
+	}
+	if err != nil {
+		fmt.Printf("    %sFailed to parse /Encrypt: %v%s\n", ColorYellow, err, ColorReset)
+		return
+	}
+	fmt.Printf("    Security Handler: Standard V%d R%d (%d-bit), P=%d\n", enc.V, enc.R, enc.Length, enc.P)
+	EmitHash(opts, "pdf2john", enc.Hash())
+
+	if enc.CheckUserPassword("") {
+		fmt.Printf("    %sUser password is empty: the document opens without one (owner restrictions only).%s\n", ColorGreen, ColorReset)
+		return
+	}
+	ctx, cancel := attackContext(ctx, opts)
+	defer cancel()
+	for _, word := range opts.Wordlist {
+		if ctx.Err() != nil {
+			break
+		}
+		if enc.CheckUserPassword(word) {
+			fmt.Printf("    %sSuccess! User password: %s%s\n", ColorGreen, word, ColorReset)
+			return
+		}
+	}
+	if !reportStopped(ctx) {
+		fmt.Printf("    %sUser password not found in wordlist (%d tried).%s\n", ColorYellow, len(opts.Wordlist), ColorReset)
+	}
+}
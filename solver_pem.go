@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	minDERLen       = 8  // shorter "structures" are coincidences
+	maxASN1Lines    = 40 // outline lines printed per structure
+	maxASN1Value    = 48 // string and integer values longer than this are cut
+	maxASN1Nesting  = 16
+	asn1OutlineStep = "  "
+)
+
+var (
+	armorBegin  = regexp.MustCompile(`^-----BEGIN ([A-Z0-9 ]+)-----$`)
+	pgpChecksum = regexp.MustCompile(`^=[A-Za-z0-9+/]{4}$`)
+)
+
+// ArmorBlock is one -----BEGIN X----- block with its Base64 body decoded
+type ArmorBlock struct {
+	Label   string   // e.g. "CERTIFICATE" or "PGP PUBLIC KEY BLOCK"
+	Headers []string // RFC 1421 / OpenPGP armor headers, e.g. "Proc-Type: 4,ENCRYPTED"
+	Body    []byte
+}
+
+// Encrypted reports a legacy PEM encrypted body, which holds no DER
+func (b ArmorBlock) Encrypted() bool {
+	for _, h := range b.Headers {
+		if strings.HasPrefix(h, "Proc-Type:") && strings.Contains(h, "ENCRYPTED") {
+			return true
+		}
+	}
+	return false
+}
+
+// FindArmorBlocks returns every armored block in the input whatever its
+// label, with headers and the OpenPGP =CRC24 line stripped before the body
+// is decoded. Blocks whose body is not Base64 are skipped.
+func FindArmorBlocks(data []byte) []ArmorBlock {
+	if !bytes.Contains(data, []byte("-----BEGIN ")) {
+		return nil
+	}
+	var blocks []ArmorBlock
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		m := armorBegin.FindStringSubmatch(strings.TrimSpace(lines[i]))
+		if m == nil {
+			continue
+		}
+		end := "-----END " + m[1] + "-----"
+		for j := i + 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) != end {
+				continue
+			}
+			body := lines[i+1 : j]
+			if n := len(body); n > 0 && pgpChecksum.MatchString(strings.TrimSpace(body[n-1])) {
+				body = body[:n-1]
+			}
+			block := ArmorBlock{Label: m[1]}
+			for _, line := range body {
+				if !mimeHeader.MatchString(strings.TrimSpace(line)) {
+					break
+				}
+				block.Headers = append(block.Headers, strings.TrimSpace(line))
+			}
+			armored := strings.Join(append(append([]string{lines[i]}, body...), lines[j]), "\n")
+			if b64, ok := UnwrapBase64(armored); ok {
+				block.Body, _ = base64.StdEncoding.DecodeString(b64)
+				blocks = append(blocks, block)
+			}
+			i = j
+			break
+		}
+	}
+	return blocks
+}
+
+// identifyArmor names armored text: the known key labels keep their key
+// types, anything else is reported by its label
+func identifyArmor(s, label string) string {
+	if key := identifyKey(s); key != "Unknown" {
+		return key
+	}
+	return "PEM (" + label + ")"
+}
+
+// isDER reports whether data is exactly one well-formed DER SEQUENCE, every
+// constructed element inside it parsing to its end
+func isDER(data []byte) bool {
+	if len(data) < minDERLen || data[0] != 0x30 {
+		return false
+	}
+	return validDER(data, 0)
+}
+
+func validDER(data []byte, depth int) bool {
+	if depth > maxASN1Nesting {
+		return false
+	}
+	for len(data) > 0 {
+		var raw asn1.RawValue
+		rest, err := asn1.Unmarshal(data, &raw)
+		if err != nil {
+			return false
+		}
+		if raw.IsCompound && !validDER(raw.Bytes, depth+1) {
+			return false
+		}
+		if depth == 0 && len(rest) > 0 {
+			return false
+		}
+		data = rest
+	}
+	return true
+}
+
+// DERKind names the structure a DER blob holds, trying the certificate and
+// key formats before falling back to a plain "Structure". Empty when the
+// data is not DER.
+func DERKind(data []byte) string {
+	if !isDER(data) {
+		return ""
+	}
+	if _, err := x509.ParseCertificate(data); err == nil {
+		return "X.509 Certificate"
+	}
+	if _, err := x509.ParseCertificateRequest(data); err == nil {
+		return "Certificate Request"
+	}
+	if _, err := x509.ParseRevocationList(data); err == nil {
+		return "Certificate Revocation List"
+	}
+	if _, err := x509.ParsePKCS1PrivateKey(data); err == nil {
+		return "PKCS#1 RSA Private Key"
+	}
+	if _, err := x509.ParsePKCS8PrivateKey(data); err == nil {
+		return "PKCS#8 Private Key"
+	}
+	if _, err := x509.ParseECPrivateKey(data); err == nil {
+		return "EC Private Key"
+	}
+	if _, err := x509.ParsePKIXPublicKey(data); err == nil {
+		return "Public Key"
+	}
+	if _, err := x509.ParsePKCS1PublicKey(data); err == nil {
+		return "PKCS#1 RSA Public Key"
+	}
+	return "Structure"
+}
+
+// oidNames covers the object identifiers certificates and keys are made of
+var oidNames = map[string]string{
+	"1.2.840.113549.1.1.1":  "rsaEncryption",
+	"1.2.840.113549.1.1.5":  "sha1WithRSAEncryption",
+	"1.2.840.113549.1.1.11": "sha256WithRSAEncryption",
+	"1.2.840.113549.1.1.12": "sha384WithRSAEncryption",
+	"1.2.840.113549.1.1.13": "sha512WithRSAEncryption",
+	"1.2.840.113549.1.9.1":  "emailAddress",
+	"1.2.840.113549.1.7.1":  "pkcs7-data",
+	"1.2.840.113549.1.7.2":  "pkcs7-signedData",
+	"1.2.840.10045.2.1":     "ecPublicKey",
+	"1.2.840.10045.3.1.7":   "prime256v1",
+	"1.2.840.10045.4.3.2":   "ecdsa-with-SHA256",
+	"1.2.840.10045.4.3.3":   "ecdsa-with-SHA384",
+	"1.3.132.0.34":          "secp384r1",
+	"1.3.132.0.35":          "secp521r1",
+	"1.3.101.112":           "Ed25519",
+	"2.5.4.3":               "commonName",
+	"2.5.4.6":               "countryName",
+	"2.5.4.7":               "localityName",
+	"2.5.4.8":               "stateOrProvinceName",
+	"2.5.4.10":              "organizationName",
+	"2.5.4.11":              "organizationalUnitName",
+	"2.5.29.14":             "subjectKeyIdentifier",
+	"2.5.29.15":             "keyUsage",
+	"2.5.29.17":             "subjectAltName",
+	"2.5.29.19":             "basicConstraints",
+	"2.5.29.35":             "authorityKeyIdentifier",
+	"2.5.29.37":             "extKeyUsage",
+}
+
+var asn1TagNames = map[int]string{
+	asn1.TagBoolean:         "BOOLEAN",
+	asn1.TagInteger:         "INTEGER",
+	asn1.TagBitString:       "BIT STRING",
+	asn1.TagOctetString:     "OCTET STRING",
+	asn1.TagNull:            "NULL",
+	asn1.TagOID:             "OBJECT IDENTIFIER",
+	asn1.TagEnum:            "ENUMERATED",
+	asn1.TagUTF8String:      "UTF8String",
+	asn1.TagSequence:        "SEQUENCE",
+	asn1.TagSet:             "SET",
+	asn1.TagNumericString:   "NumericString",
+	asn1.TagPrintableString: "PrintableString",
+	asn1.TagT61String:       "T61String",
+	asn1.TagIA5String:       "IA5String",
+	asn1.TagUTCTime:         "UTCTime",
+	asn1.TagGeneralizedTime: "GeneralizedTime",
+	asn1.TagGeneralString:   "GeneralString",
+	asn1.TagBMPString:       "BMPString",
+}
+
+// ASN1Outline renders a DER structure one element per line, indented by
+// nesting, like openssl asn1parse. OCTET and BIT STRINGs wrapping DER are
+// opened too, as keys and extensions nest that way.
+func ASN1Outline(der []byte) []string {
+	var lines []string
+	outlineDER(der, 0, &lines)
+	return lines
+}
+
+func outlineDER(data []byte, depth int, lines *[]string) {
+	for len(data) > 0 && depth <= maxASN1Nesting {
+		var raw asn1.RawValue
+		rest, err := asn1.Unmarshal(data, &raw)
+		if err != nil {
+			return
+		}
+		data = rest
+		indent := strings.Repeat(asn1OutlineStep, depth)
+		name := asn1TagNames[raw.Tag]
+		switch {
+		case raw.Class == asn1.ClassContextSpecific:
+			name = fmt.Sprintf("[%d]", raw.Tag)
+		case raw.Class != asn1.ClassUniversal || name == "":
+			name = fmt.Sprintf("[class %d tag %d]", raw.Class, raw.Tag)
+		}
+		*lines = append(*lines, indent+name+asn1Value(raw))
+
+		switch {
+		case raw.IsCompound:
+			outlineDER(raw.Bytes, depth+1, lines)
+		case raw.Class == asn1.ClassUniversal && raw.Tag == asn1.TagOctetString && isDER(raw.Bytes):
+			outlineDER(raw.Bytes, depth+1, lines)
+		case raw.Class == asn1.ClassUniversal && raw.Tag == asn1.TagBitString && len(raw.Bytes) > 1 &&
+			raw.Bytes[0] == 0 && isDER(raw.Bytes[1:]):
+			outlineDER(raw.Bytes[1:], depth+1, lines)
+		}
+	}
+}
+
+// asn1Value formats a primitive element's value for the outline
+func asn1Value(raw asn1.RawValue) string {
+	if raw.Class != asn1.ClassUniversal || raw.IsCompound {
+		if raw.IsCompound {
+			return ""
+		}
+		return fmt.Sprintf(" (%d bytes)", len(raw.Bytes))
+	}
+	switch raw.Tag {
+	case asn1.TagOID:
+		var oid asn1.ObjectIdentifier
+		if _, err := asn1.Unmarshal(raw.FullBytes, &oid); err != nil {
+			return ""
+		}
+		if name, ok := oidNames[oid.String()]; ok {
+			return fmt.Sprintf(" %s (%s)", oid, name)
+		}
+		return " " + oid.String()
+	case asn1.TagInteger, asn1.TagEnum:
+		n := new(big.Int).SetBytes(raw.Bytes)
+		if len(raw.Bytes) > 0 && raw.Bytes[0]&0x80 != 0 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(raw.Bytes)*8)))
+		}
+		if s := n.String(); len(s) <= maxASN1Value {
+			return " " + s
+		}
+		return fmt.Sprintf(" (%d bits)", n.BitLen())
+	case asn1.TagBoolean:
+		return fmt.Sprintf(" %t", len(raw.Bytes) == 1 && raw.Bytes[0] != 0)
+	case asn1.TagUTF8String, asn1.TagNumericString, asn1.TagPrintableString, asn1.TagT61String,
+		asn1.TagIA5String, asn1.TagUTCTime, asn1.TagGeneralizedTime, asn1.TagGeneralString:
+		if !utf8.Valid(raw.Bytes) {
+			break
+		}
+		if r := []rune(string(raw.Bytes)); len(r) > maxASN1Value {
+			return fmt.Sprintf(" %q...", string(r[:maxASN1Value]))
+		}
+		return fmt.Sprintf(" %q", raw.Bytes)
+	case asn1.TagNull:
+		return ""
+	}
+	return fmt.Sprintf(" (%d bytes)", len(raw.Bytes))
+}
+
+// analyzeDER reports what a DER layer holds: the certificate or key fields,
+// the RSA weak-key checks on any RSA public key, and the element outline.
+// A parsed certificate or key is the end of its chain.
+func analyzeDER(ctx context.Context, data []byte, kind string, opts *Options) bool {
+	fmt.Printf("    ASN.1 DER: %s\n", kind)
+	var pub *rsa.PublicKey
+	switch kind {
+	case "X.509 Certificate":
+		cert, _ := x509.ParseCertificate(data)
+		fmt.Printf("    Subject: %s\n", cert.Subject)
+		printCertificate(cert)
+		pub, _ = cert.PublicKey.(*rsa.PublicKey)
+	case "Certificate Request":
+		csr, _ := x509.ParseCertificateRequest(data)
+		fmt.Printf("    Subject: %s\n", csr.Subject)
+		if len(csr.DNSNames) > 0 {
+			fmt.Printf("    Names: %s\n", strings.Join(csr.DNSNames, ", "))
+		}
+		keyType, bits := describePublicKey(csr.PublicKey)
+		fmt.Printf("    Key: %s (%d bits)\n", keyType, bits)
+		pub, _ = csr.PublicKey.(*rsa.PublicKey)
+	case "Certificate Revocation List":
+		crl, _ := x509.ParseRevocationList(data)
+		fmt.Printf("    Issuer: %s\n", crl.Issuer)
+		fmt.Printf("    Revoked: %d certificates\n", len(crl.RevokedCertificateEntries))
+	case "PKCS#1 RSA Private Key", "PKCS#8 Private Key", "EC Private Key":
+		var key any
+		switch kind {
+		case "PKCS#1 RSA Private Key":
+			key, _ = x509.ParsePKCS1PrivateKey(data)
+		case "PKCS#8 Private Key":
+			key, _ = x509.ParsePKCS8PrivateKey(data)
+		default:
+			key, _ = x509.ParseECPrivateKey(data)
+		}
+		printKeyInfo(fillKeyInfo(&KeyInfo{Label: kind}, key))
+	case "Public Key", "PKCS#1 RSA Public Key":
+		var key any
+		if kind == "Public Key" {
+			key, _ = x509.ParsePKIXPublicKey(data)
+		} else {
+			key, _ = x509.ParsePKCS1PublicKey(data)
+		}
+		keyType, bits := describePublicKey(key)
+		fmt.Printf("    Key: %s (%d bits)\n", keyType, bits)
+		pub, _ = key.(*rsa.PublicKey)
+	}
+	recovered := pub != nil && reportRSAPublicKey(ctx, pub, opts)
+
+	outline := ASN1Outline(data)
+	fmt.Printf("    Outline (%d elements):\n", len(outline))
+	for i, line := range outline {
+		if i == maxASN1Lines {
+			fmt.Printf("      ... %d more\n", len(outline)-i)
+			break
+		}
+		fmt.Printf("      %s\n", line)
+	}
+	return recovered || kind != "Structure"
+}
+
+// pemSolver strips the armor off every -----BEGIN X----- block and hands
+// the decoded body on as the next layer, whatever the label, then analyses
+// that body once it is a DER layer. Private keys and the armored formats
+// with their own analysers are left to those.
+type pemSolver struct{}
+
+func (pemSolver) Name() string { return "PEM Armor & DER" }
+
+func (pemSolver) Applicable(l *Layer) bool {
+	if strings.HasPrefix(l.Type, "ASN.1 DER (") {
+		return true
+	}
+	if strings.HasPrefix(l.Type, "File") || l.Type == "OpenPGP Symmetric Encrypted Data" {
+		return false
+	}
+	return len(FindArmorBlocks(l.Data)) > 0
+}
+
+func (pemSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	// A parsed certificate or key ends the chain; a bare structure is left
+	// to the other solvers
+	if kind, ok := strings.CutPrefix(l.Type, "ASN.1 DER ("); ok {
+		kind = strings.TrimSuffix(kind, ")")
+		if !analyzeDER(ctx, l.Data, kind, l.Opts) {
+			return nil
+		}
+		return []Candidate{{Step: "ASN.1 Parse (" + kind + ")", Data: l.Data, Final: true}}
+	}
+
+	blocks := FindArmorBlocks(l.Data)
+	var candidates []Candidate
+	for i, b := range blocks {
+		fmt.Printf("    Block %d: %s (%d bytes)\n", i+1, b.Label, len(b.Body))
+		for _, h := range b.Headers {
+			fmt.Printf("      %s\n", h)
+		}
+		switch {
+		case strings.HasSuffix(b.Label, "PRIVATE KEY"):
+			fmt.Printf("      %sPrivate key: see the key analysis above.%s\n", ColorYellow, ColorReset)
+			continue
+		case b.Encrypted():
+			fmt.Printf("      %sEncrypted body: nothing to decode without the passphrase.%s\n", ColorYellow, ColorReset)
+			continue
+		}
+		candidates = append(candidates, Candidate{
+			Step:  fmt.Sprintf("PEM Decode (%s)", b.Label),
+			Data:  b.Body,
+			Score: float64(len(blocks) - i), // in input order
+		})
+	}
+	return candidates
+}
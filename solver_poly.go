@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"math"
 	"strings"
 	"unicode"
 )
@@ -15,6 +18,19 @@ var englishFreq = map[byte]float64{
 
 // SolveSingleByteXOR attempts to break single-byte XOR
 func SolveSingleByteXOR(input []byte) (string, byte, float64) {
+	return bestByteKey(input, func(b, key byte) byte { return b ^ key })
+}
+
+// SolveByteShift attempts to break an additive shift of every byte mod 256
+// ("add 1 to every byte"), which the letter-only Caesar solver misses. The
+// key is the amount added to decode.
+func SolveByteShift(input []byte) (string, byte, float64) {
+	return bestByteKey(input, func(b, key byte) byte { return b + key })
+}
+
+// bestByteKey tries all 256 keys of a byte-wise cipher and returns the
+// most English-looking result, or a flag-bearing one outright
+func bestByteKey(input []byte, apply func(b, key byte) byte) (string, byte, float64) {
 	bestScore := 0.0
 	bestRes := ""
 	bestKey := byte(0)
@@ -24,9 +40,9 @@ func SolveSingleByteXOR(input []byte) (string, byte, float64) {
 		decoded := make([]byte, len(input))
 		score := 0.0
 
-		// XOR and Score
+		// Decode and Score
 		for i, b := range input {
-			dec := b ^ key
+			dec := apply(b, key)
 			decoded[i] = dec
 
 			// Scoring
@@ -43,8 +59,8 @@ func SolveSingleByteXOR(input []byte) (string, byte, float64) {
 
 		resStr := string(decoded)
 
-		// Magic Check: Instant Win
-		if strings.Contains(resStr, "picoCTF{") || strings.Contains(resStr, "HTB{") {
+		// Magic Check: Instant Win (key 0 is the unchanged input)
+		if key != 0 && hasFlag(decoded) {
 			return resStr, key, 1000.0 // Max confidence
 		}
 
@@ -58,6 +74,120 @@ func SolveSingleByteXOR(input []byte) (string, byte, float64) {
 	return bestRes, bestKey, bestScore
 }
 
+// CrackRepeatingXOR recovers an XOR key of known length column by column.
+// Each column is a single-byte XOR, scored as English first; if that reads
+// as nothing, each column's most common byte is taken as zero, as in most
+// binary formats. Text must score as English (or hold a flag), binary must
+// start with a known file signature. It returns the key and plaintext, or
+// nil when neither reading holds. Text already reading as English, whose
+// repeated words also peak, is left alone.
+func CrackRepeatingXOR(data []byte, keyLen int) ([]byte, []byte) {
+	if keyLen < 2 || len(data) < 2*keyLen || LooksSolved(data) {
+		return nil, nil
+	}
+	text, binary := make([]byte, keyLen), make([]byte, keyLen)
+	for col := range keyLen {
+		var column []byte
+		var counts [256]int
+		for i := col; i < len(data); i += keyLen {
+			column = append(column, data[i])
+			counts[data[i]]++
+		}
+		_, text[col], _ = SolveSingleByteXOR(column)
+		for b, n := range counts {
+			if n > counts[binary[col]] {
+				binary[col] = byte(b)
+			}
+		}
+	}
+	if plain := xorKey(data, text); !allZero(text) && (hasFlag(plain) || LooksSolved(plain) && englishLetterScore(plain) >= vigenereMinScore) {
+		return text, plain
+	}
+	if plain := xorKey(data, binary); !allZero(binary) {
+		if kind, _ := IdentifyType(plain); strings.HasPrefix(kind, "File") {
+			return binary, plain
+		}
+	}
+	return nil, nil
+}
+
+// printAutocorrelation reports the autocorrelation peaks and the key length
+// they point to
+func printAutocorrelation(corr []float64, peaks []int) {
+	base := AutocorrelationBaseline(corr)
+	if len(peaks) == 0 {
+		fmt.Printf("    Autocorrelation (shifts 1-%d): no peaks over the %.1f%% baseline\n", len(corr), 100*base)
+		return
+	}
+	var shown []string
+	for _, p := range peaks[:min(len(peaks), 8)] {
+		shown = append(shown, fmt.Sprintf("%d (%.1f%%)", p, 100*corr[p-1]))
+	}
+	if len(peaks) > 8 {
+		shown = append(shown, "...")
+	}
+	fmt.Printf("    Autocorrelation (shifts 1-%d): peaks at %s over a %.1f%% baseline\n", len(corr), strings.Join(shown, ", "), 100*base)
+	divisible := ""
+	for n := 2; n*n <= peaks[0]; n++ {
+		if peaks[0]%n == 0 {
+			divisible = " or a divisor of it"
+			break
+		}
+	}
+	fmt.Printf("    Likely repeating key length: %d%s\n", peaks[0], divisible)
+}
+
+// byteAffineLimit bounds the inputs tried with every byte affine key: there
+// are 32768 of them
+const byteAffineLimit = 4096
+
+// SolveByteAffine attempts to break a byte affine transform y = a*x + b
+// mod 256 (a odd, so invertible). Every inverse map c*y + d is tried, keys
+// are dropped at the first unprintable byte, and only a flag counts as a
+// win. Odd multipliers other than 1 are covered: c = 1 is a byte shift.
+func SolveByteAffine(input []byte) (decoded string, c, d byte, ok bool) {
+	if len(input) == 0 || len(input) > byteAffineLimit {
+		return "", 0, 0, false
+	}
+	out := make([]byte, len(input))
+	for ci := 3; ci < 256; ci += 2 {
+		for di := 0; di < 256; di++ {
+			printable := true
+			for i, y := range input {
+				x := byte(ci)*y + byte(di)
+				if (x < 32 || x > 126) && x != '\n' && x != '\r' && x != '\t' {
+					printable = false
+					break
+				}
+				out[i] = x
+			}
+			if printable && hasFlag(out) {
+				return string(out), byte(ci), byte(di), true
+			}
+		}
+	}
+	return "", 0, 0, false
+}
+
+// byteInverse is the multiplicative inverse of an odd byte mod 256, by
+// Newton's iteration (each round doubles the correct low bits)
+func byteInverse(a byte) byte {
+	inv := a // correct to 3 bits for any odd a
+	for range 3 {
+		inv *= 2 - a*inv
+	}
+	return inv
+}
+
+// byteAffine maps every byte x to a*x + b mod 256
+func byteAffine(data []byte, a, b byte) []byte {
+	out := make([]byte, len(data))
+	for i, x := range data {
+		out[i] = a*x + b
+	}
+	return out
+}
+
 // SolveVigenere attempts a dictionary attack on Vigenère cipher
 func SolveVigenere(input string) (string, string) {
 	// Embedded dictionary
@@ -67,7 +197,7 @@ func SolveVigenere(input string) (string, string) {
 		decoded := vigenereDecrypt(input, key)
 
 		// Check for flag prefix
-		if strings.Contains(decoded, "picoCTF{") || strings.Contains(decoded, "HTB{") {
+		if hasFlag([]byte(decoded)) {
 			return decoded, key
 		}
 	}
@@ -75,6 +205,87 @@ func SolveVigenere(input string) (string, string) {
 	return "", ""
 }
 
+// vigenereMinScore is the English score (letters only) a cracked Vigenère
+// or repeating-key XOR plaintext needs; a single wrong key letter drops the
+// right one below it
+const vigenereMinScore = 0.15
+
+// CrackVigenere recovers a Vigenère key of known length column by column:
+// each column is a Caesar shift, taken as the one whose letter frequencies
+// are closest to English. The decryption must score as English or hold a
+// flag.
+func CrackVigenere(input string, period int) (string, string) {
+	var letters []byte
+	for i := 0; i < len(input); i++ {
+		if c := input[i] | 0x20; c >= 'a' && c <= 'z' {
+			letters = append(letters, c)
+		}
+	}
+	if period < 1 || len(letters) < 2*period {
+		return "", ""
+	}
+	key := make([]byte, period)
+	column := make([]byte, 0, len(letters)/period+1)
+	for col := range period {
+		bestChi := math.Inf(1)
+		for shift := range 26 {
+			column = column[:0]
+			for i := col; i < len(letters); i += period {
+				column = append(column, 'a'+(letters[i]-'a'+26-byte(shift))%26)
+			}
+			if chi := englishChiSquare(string(column)); chi < bestChi {
+				bestChi, key[col] = chi, byte('A'+shift)
+			}
+		}
+	}
+	if strings.Trim(string(key), "A") == "" {
+		return "", "" // already plaintext
+	}
+	decoded := vigenereDecrypt(input, string(key))
+	if hasFlag([]byte(decoded)) || englishLetterScore([]byte(decoded)) >= vigenereMinScore {
+		return decoded, string(key)
+	}
+	return "", ""
+}
+
+// looksPolyalphabetic reports letter text whose IoC has been flattened
+// below English, as a Vigenère-style cipher does
+func looksPolyalphabetic(data []byte, ioc float64) bool {
+	letters, other := letterCounts(data)
+	return letters >= 40 && letters >= 4*other && ioc < 1.5
+}
+
+// printPeriodicIoC prints the periodic IoC five periods a row, peaks
+// starred, and the key length they point to
+func printPeriodicIoC(iocs []float64, peaks []int) {
+	fmt.Printf("    Periodic IoC (English ~1.73, Random ~1.0):\n")
+	isPeak := make(map[int]bool)
+	for _, p := range peaks {
+		isPeak[p] = true
+	}
+	for i, v := range iocs {
+		if i%5 == 0 {
+			fmt.Printf("     ")
+		}
+		if isPeak[i+1] {
+			fmt.Printf(" %s%3d: %.2f*%s", ColorGreen, i+1, v, ColorReset)
+		} else {
+			fmt.Printf(" %3d: %.2f ", i+1, v)
+		}
+		if i%5 == 4 || i == len(iocs)-1 {
+			fmt.Println()
+		}
+	}
+	switch {
+	case len(peaks) == 0:
+		fmt.Printf("    %sNo period stands out: not a short periodic key.%s\n", ColorYellow, ColorReset)
+	case peaks[0] == 1:
+		fmt.Printf("    Likely key length: 1 (a single alphabet)\n")
+	default:
+		fmt.Printf("    Likely key length: %d (peaks at %s)\n", peaks[0], strings.Trim(strings.Join(strings.Fields(fmt.Sprint(peaks)), ", "), "[]"))
+	}
+}
+
 func vigenereDecrypt(input, key string) string {
 	var result strings.Builder
 	keyIndex := 0
@@ -100,3 +311,133 @@ func vigenereDecrypt(input, key string) string {
 	}
 	return result.String()
 }
+
+func vigenereEncrypt(input, key string) string {
+	var result strings.Builder
+	keyIndex := 0
+	keyRunes := []rune(strings.ToUpper(key))
+
+	for _, r := range input {
+		if !unicode.IsLetter(r) || r > unicode.MaxASCII {
+			result.WriteRune(r)
+			continue
+		}
+
+		shift := keyRunes[keyIndex%len(keyRunes)] - 'A'
+
+		if unicode.IsUpper(r) {
+			result.WriteRune('A' + (r-'A'+shift)%26)
+		} else {
+			result.WriteRune('a' + (r-'a'+shift)%26)
+		}
+		keyIndex++
+	}
+	return result.String()
+}
+
+// polySolver tries the keyed byte and letter ciphers: single-byte XOR, byte
+// shifts and affine maps, Vigenère, and with a crib Vigenère key recovery
+// and transpositions
+type polySolver struct{}
+
+func (polySolver) Name() string { return "Poly Solver" }
+
+func (polySolver) Applicable(l *Layer) bool {
+	return l.Randomness.Verdict == VerdictEncrypted || l.Type == "Unknown" || l.Entropy > 3.0
+}
+
+func (polySolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	// Classical ciphers and short-key XOR keep the plaintext's bias
+	if l.Randomness.Verdict == VerdictEncrypted {
+		fmt.Printf("    %sSkipped: uniformly random bytes are not XOR or Vigenère output; look for a key, IV or container format.%s\n", ColorYellow, ColorReset)
+		return nil
+	}
+	data, text, crib := l.Data, string(l.Data), l.Opts.Crib
+	found := func(step, plain string, details ...string) []Candidate {
+		return []Candidate{{Step: step, Data: []byte(plain), Details: details, Final: true}}
+	}
+
+	// 1. XOR: only a flag counts as a win. Scores add up per byte, so long
+	// text passes 1000 unchanged (key 0) without one.
+	xorRes, xorKey, xorScore := SolveSingleByteXOR(data)
+	step := fmt.Sprintf("Single Byte XOR (Key: 0x%02X)", xorKey)
+	l.Opts.progress.Candidate(withStep(l.Chain, step), []byte(xorRes))
+	if xorScore >= 1000.0 && xorKey != 0 && hasFlag([]byte(xorRes)) {
+		return found(step, xorRes)
+	}
+
+	// 1b. Byte-wise shift ("add N to every byte", not just letters)
+	if shiftRes, shift, shiftScore := SolveByteShift(data); shiftScore >= 1000.0 && shift != 0 && hasFlag([]byte(shiftRes)) {
+		return found(fmt.Sprintf("Byte Shift (+%d)", shift), shiftRes)
+	}
+
+	// 1c. Byte affine (a*x+b mod 256), undone as c*y+d
+	if affineRes, c, d, ok := SolveByteAffine(data); ok {
+		a := byteInverse(c)
+		return found(fmt.Sprintf("Byte Affine (%d*x+%d)", c, d), affineRes, fmt.Sprintf("Encoded with a=%d b=%d", a, -a*d))
+	}
+
+	// 1d. Repeating-key XOR: autocorrelation peaks give the key length,
+	// for binary plaintexts too
+	if corr := Autocorrelation(data, maxAutocorrShift); len(corr) > 0 {
+		peaks := AutocorrelationPeaks(corr)
+		printAutocorrelation(corr, peaks)
+		// A short text may peak at a multiple first: try the divisors
+		for n := 2; len(peaks) > 0 && n <= peaks[0]; n++ {
+			if peaks[0]%n != 0 {
+				continue
+			}
+			if key, plain := CrackRepeatingXOR(data, n); key != nil {
+				details := []string{fmt.Sprintf("Key length %d from the autocorrelation", len(key)), fmt.Sprintf("Recipe: xor:0x%x", key)}
+				step := fmt.Sprintf("Repeating-Key XOR (Key: 0x%x)", key)
+				if isPrintable(key) {
+					step = fmt.Sprintf("Repeating-Key XOR (Key: %q)", key)
+				}
+				if kind, _ := IdentifyType(plain); strings.HasPrefix(kind, "File") {
+					return []Candidate{{Step: step, Data: plain, Details: details}}
+				}
+				return found(step, string(plain), details...)
+			}
+		}
+	}
+
+	// 2. Vigenère (Only if text-like): common keys, then a key of the
+	// length the periodic IoC points to
+	if l.Entropy < 6.0 {
+		if vigRes, vigKey := SolveVigenere(text); vigRes != "" {
+			return found("Vigenère (Key: "+vigKey+")", vigRes)
+		}
+		if looksPolyalphabetic(data, l.IoC) {
+			iocs := PeriodicIoC(data, maxIoCPeriod)
+			peaks := IoCPeaks(iocs)
+			printPeriodicIoC(iocs, peaks)
+			if len(peaks) > 0 && peaks[0] > 1 {
+				if vigRes, vigKey := CrackVigenere(text, peaks[0]); vigRes != "" {
+					return found("Vigenère (Key: "+vigKey+")", vigRes, fmt.Sprintf("Key length %d from the periodic IoC", peaks[0]))
+				}
+			}
+		}
+	}
+
+	// 3. Crib-constrained attacks: key recovery and transposition
+	if crib != "" && l.Entropy < 6.0 {
+		if vigRes, vigKey := SolveVigenereCrib(text, crib); vigRes != "" {
+			return found("Vigenère (Key: "+vigKey+")", vigRes, fmt.Sprintf("Key from crib %q", crib))
+		}
+		if res, alg := SolveTranspositionCrib(text, crib); res != "" {
+			return found(alg, res, fmt.Sprintf("Crib: %q", crib))
+		}
+	}
+
+	// Only wins are printed; near misses would just be noise
+	fmt.Printf("    %sNo Poly-Alphabetic, XOR, or weak RSA matches found.%s\n", ColorYellow, ColorReset)
+	if looksMonoalphabetic(data, l.IoC) && !LooksSolved(data) {
+		fmt.Printf("    IoC %.2f is English-like: possibly a substitution cipher. Solve it interactively with:\n", l.IoC)
+		if crib != "" {
+			fmt.Printf("      ./cipher-sleuth workbench -crib %q -t %q\n", crib, text)
+		} else {
+			fmt.Printf("      ./cipher-sleuth workbench -t %q\n", text)
+		}
+	}
+	return nil
+}
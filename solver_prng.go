@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxPRNGText      = 256 * 1024 // larger layers are not parsed for outputs
+	prngSeedWindow   = time.Hour  // default -seed-window
+	prngShownOutputs = 5          // predicted outputs printed
+	prngTimeLo       = 946684800  // 2000-01-01: smaller numbers are not seed times
+	prngTimeHi       = 4102444800 // 2100-01-01
+)
+
+var (
+	prngDecimal = regexp.MustCompile(`^\d+$`)
+	prngHex     = regexp.MustCompile(`^(?:0[xX])?[0-9a-fA-F]+$`)
+	prngBase64  = regexp.MustCompile(`^[A-Za-z0-9+/]+={0,2}$`)
+)
+
+// PRNGInput is what a weak-randomness challenge gives away: consecutive
+// generator outputs, times the generator may have been seeded with, and
+// data encrypted with its keystream
+type PRNGInput struct {
+	Outputs    []*big.Int
+	Timestamps []int64
+	Ciphertext []byte
+}
+
+// ParsePRNGInput reads a text layer line by line. A line holding only
+// numbers (after an optional "label:" or "label =") adds them to the
+// outputs; a single labelled number in the Unix-time range of this century
+// is a timestamp instead. The longest hex or Base64 blob is the ciphertext.
+func ParsePRNGInput(text string) PRNGInput {
+	var in PRNGInput
+	for _, line := range strings.Split(text, "\n") {
+		labelled := false
+		if i := prngLabelEnd(line); i >= 0 {
+			line, labelled = line[i+1:], true
+		}
+		var numbers []*big.Int
+		words := 0
+		for _, tok := range strings.FieldsFunc(line, func(r rune) bool { return strings.ContainsRune(" \t\r,;[](){}", r) }) {
+			if blob := prngBlob(tok); len(blob) > len(in.Ciphertext) {
+				in.Ciphertext = blob
+				continue
+			} else if blob != nil {
+				continue
+			}
+			n, ok := new(big.Int), false
+			switch {
+			case prngDecimal.MatchString(tok):
+				n, ok = n.SetString(tok, 10)
+			case prngHex.MatchString(tok) && len(tok) > 2 && (tok[1] == 'x' || tok[1] == 'X'):
+				n, ok = n.SetString(tok[2:], 16)
+			}
+			if ok {
+				numbers = append(numbers, n)
+			} else {
+				words++
+			}
+		}
+		switch {
+		case len(numbers) == 1 && (labelled || words > 0) && numbers[0].IsInt64() &&
+			numbers[0].Int64() >= prngTimeLo && numbers[0].Int64() < prngTimeHi:
+			in.Timestamps = append(in.Timestamps, numbers[0].Int64())
+		case words == 0:
+			in.Outputs = append(in.Outputs, numbers...)
+		}
+	}
+	return in
+}
+
+// prngLabelEnd finds the ':' or '=' ending a "label:" or "label =" prefix,
+// passing over Base64 padding; -1 when there is none
+func prngLabelEnd(line string) int {
+	for i := 0; i < len(line); i++ {
+		if line[i] == ':' || line[i] == '=' && i+1 < len(line) && line[i+1] != '=' {
+			return i
+		}
+	}
+	return -1
+}
+
+// prngBlob decodes a token long enough to be ciphertext: hex with at least
+// one letter (or 0x and more than 64 bits), or Base64 that is not a word
+func prngBlob(tok string) []byte {
+	if len(tok) < 16 {
+		return nil
+	}
+	if prngHex.MatchString(tok) {
+		digits := strings.TrimPrefix(strings.TrimPrefix(tok, "0x"), "0X")
+		if len(digits) == len(tok) && !strings.ContainsAny(strings.ToLower(tok), "abcdef") || len(digits) < len(tok) && len(digits) <= 16 {
+			return nil
+		}
+		b, _ := hex.DecodeString(digits)
+		return b
+	}
+	if prngBase64.MatchString(tok) && len(tok)%4 == 0 && strings.ContainsAny(tok, "0123456789+/=") {
+		b, _ := base64.StdEncoding.DecodeString(tok)
+		return b
+	}
+	return nil
+}
+
+// ParseSeedTime reads -seed-time: Unix seconds, RFC 3339, or
+// "2006-01-02 15:04:05" in UTC
+func ParseSeedTime(s string) (time.Time, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateTime, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither Unix seconds, RFC 3339 nor %q", s, time.DateTime)
+	}
+	return t, nil
+}
+
+// prngByteMap turns generator outputs of a given width into n keystream
+// bytes the way challenge code usually does
+type prngByteMap struct {
+	Name     string
+	Bytes    func(next func() uint64, bits, n int) []byte
+	Variable bool // consumes a data-dependent number of outputs
+	MinBits  int  // narrower generators can't feed it (the low byte is all they have)
+}
+
+var prngByteMaps = []prngByteMap{
+	{Name: "low byte", Bytes: func(next func() uint64, bits, n int) []byte {
+		out := make([]byte, n)
+		for i := range out {
+			out[i] = byte(next())
+		}
+		return out
+	}},
+	{Name: "top byte", MinBits: 9, Bytes: func(next func() uint64, bits, n int) []byte {
+		out := make([]byte, n)
+		for i := range out {
+			out[i] = byte(next() >> (bits - 8))
+		}
+		return out
+	}},
+	// Python's randbytes: whole words little-endian, the last one's top bits
+	{Name: "randbytes", MinBits: 9, Bytes: func(next func() uint64, bits, n int) []byte {
+		width := (bits + 7) / 8
+		out := make([]byte, 0, n+width)
+		for len(out) < n {
+			w, take := next(), min(width, n-len(out))
+			if take < width {
+				w >>= bits - 8*take
+			}
+			for k := range take {
+				out = append(out, byte(w>>(8*k)))
+			}
+		}
+		return out
+	}},
+	// Python's randint(0, 255): 9 top bits, redrawn until below 256
+	{Name: "randint(0, 255)", Variable: true, MinBits: 9, Bytes: func(next func() uint64, bits, n int) []byte {
+		out := make([]byte, n)
+		for i := range out {
+			r := next() >> (bits - 9)
+			for r >= 256 {
+				r = next() >> (bits - 9)
+			}
+			out[i] = byte(r)
+		}
+		return out
+	}},
+}
+
+// outputsUsed counts the outputs a fixed-rate map consumes for n bytes
+func (m prngByteMap) outputsUsed(bits, n int) int {
+	count := 0
+	m.Bytes(func() uint64 { count++; return 0 }, bits, n)
+	return count
+}
+
+// prngSeeders are the generators seeded from time(NULL) in the search
+var prngSeeders = []struct {
+	Name string // the seeding call
+	Bits int
+	New  func(seed uint32) func() uint64
+}{
+	{"srand", 31, func(seed uint32) func() uint64 {
+		g := NewGlibcRand(seed)
+		return func() uint64 { return uint64(g.Rand()) }
+	}},
+	{"std::mt19937", 32, func(seed uint32) func() uint64 {
+		m := NewMT19937(seed)
+		return func() uint64 { return uint64(m.Uint32()) }
+	}},
+	{"random.seed", 32, func(seed uint32) func() uint64 {
+		m := NewPythonRandom(uint64(seed))
+		return func() uint64 { return uint64(m.Uint32()) }
+	}},
+}
+
+// prngPlausible decides whether a keystream decrypted the ciphertext: the
+// crib when one is given, otherwise a flag or readable text
+func prngPlausible(plain []byte, crib string) bool {
+	if crib != "" {
+		return containsCrib(string(plain), crib)
+	}
+	return hasFlag(plain) || len(plain) >= 8 && looksLikeText([][]byte{plain})
+}
+
+func formatSeedTime(seed int64) string {
+	return time.Unix(seed, 0).UTC().Format(time.DateTime) + " UTC"
+}
+
+// prngSolver attacks weak random number use: it recovers LCG parameters
+// from consecutive outputs, clones a Mersenne Twister from 624 of them and
+// searches time(NULL) seeds around timestamps in the input or -seed-time,
+// then regenerates the keystream to decrypt the accompanying data
+type prngSolver struct{}
+
+func (prngSolver) Name() string { return "PRNG Seeds" }
+
+func (prngSolver) Applicable(l *Layer) bool {
+	if l.Type == "RSA Challenge Data" || len(l.Data) > maxPRNGText {
+		return false
+	}
+	in := prngInput(l)
+	if len(in.Ciphertext) > 0 {
+		return len(in.Outputs) >= 4 || len(in.Timestamps) > 0 || !l.Opts.SeedTime.IsZero()
+	}
+	// Without ciphertext, only predicting outputs is left: want enough of
+	// them, and wider than the byte and small-number lists other decoders
+	// take
+	wide := false
+	for _, x := range in.Outputs {
+		wide = wide || x.BitLen() > 16
+	}
+	return len(in.Outputs) >= 6 && wide || len(in.Timestamps) > 0 && len(in.Outputs) > 0
+}
+
+// prngInput parses a text layer; any other layer is all ciphertext
+func prngInput(l *Layer) PRNGInput {
+	if !isPrintable(l.Data) {
+		return PRNGInput{Ciphertext: l.Data}
+	}
+	return ParsePRNGInput(string(l.Data))
+}
+
+func (prngSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	in := prngInput(l)
+	var given []string
+	if len(in.Outputs) > 0 {
+		given = append(given, fmt.Sprintf("%d outputs", len(in.Outputs)))
+	}
+	if len(in.Timestamps) > 0 {
+		given = append(given, fmt.Sprintf("timestamps %v", in.Timestamps))
+	}
+	if len(in.Ciphertext) > 0 {
+		given = append(given, fmt.Sprintf("%d bytes of ciphertext", len(in.Ciphertext)))
+	}
+	fmt.Printf("    Given: %s\n", strings.Join(given, ", "))
+
+	var candidates []Candidate
+	recovered := false
+	// decrypt XORs the ciphertext with each mapping of a generator's
+	// outputs, keeping the plaintexts that read as solved. A file signature
+	// also counts once the generator is known; searched seeds would hit the
+	// short ones by chance.
+	decrypt := func(step string, next func() uint64, bits int, details ...string) {
+		if len(in.Ciphertext) == 0 {
+			return
+		}
+		// Every mapping reads the same outputs from the start
+		var outputs []uint64
+		for _, m := range prngByteMaps {
+			if bits < m.MinBits {
+				continue
+			}
+			i := 0
+			replay := func() uint64 {
+				if i == len(outputs) {
+					outputs = append(outputs, next())
+				}
+				i++
+				return outputs[i-1]
+			}
+			plain := xorRepeating(in.Ciphertext, m.Bytes(replay, bits, len(in.Ciphertext)))
+			final := prngPlausible(plain, l.Opts.Crib)
+			if !final {
+				if !recovered {
+					continue
+				}
+				if kind, _ := IdentifyType(plain); !strings.HasPrefix(kind, "File") {
+					continue
+				}
+			}
+			candidates = append(candidates, Candidate{
+				Step:    fmt.Sprintf("%s (%s)", step, m.Name),
+				Data:    plain,
+				Score:   magicScore(plain),
+				Details: details,
+				Final:   final,
+			})
+		}
+	}
+
+	if mt := prngCloneMT(in.Outputs); mt != nil {
+		recovered = true
+		fmt.Printf("    %sMersenne Twister state cloned from 624 outputs%s\n", ColorGreen, ColorReset)
+		if extra := len(in.Outputs) - 624; extra > 0 {
+			fmt.Printf("    The clone predicts the %d outputs after them\n", extra)
+		}
+		state := *mt
+		next := make([]string, prngShownOutputs)
+		for i := range next {
+			next[i] = strconv.FormatUint(uint64(state.Uint32()), 10)
+		}
+		fmt.Printf("    Next outputs: %s\n", strings.Join(next, ", "))
+		state = *mt
+		decrypt("MT19937 Keystream", func() uint64 { return uint64(state.Uint32()) }, 32, "Generator state untempered from 624 consecutive outputs")
+	}
+
+	var lcg *LCG
+	if !recovered {
+		lcg = RecoverLCG(in.Outputs)
+	}
+	if lcg != nil {
+		recovered = true
+		params := fmt.Sprintf("x' = (%s*x + %s) mod %s", lcg.A, lcg.C, lcg.M)
+		fmt.Printf("    %sLCG: %s predicts all %d outputs%s\n", ColorGreen, params, len(in.Outputs), ColorReset)
+		back := &LCG{A: lcg.A, C: lcg.C, M: lcg.M, State: in.Outputs[0]}
+		if seed, ok := back.Prev(); ok {
+			fmt.Printf("    Seed (the state before the first output): %s\n", seed)
+		}
+		ahead := *lcg
+		next := make([]string, prngShownOutputs)
+		for i := range next {
+			next[i] = ahead.Next().String()
+		}
+		fmt.Printf("    Next outputs: %s\n", strings.Join(next, ", "))
+
+		bits := lcg.M.BitLen()
+		if lcg.M.Cmp(new(big.Int).Lsh(big.NewInt(1), uint(bits-1))) == 0 {
+			bits-- // a power-of-two modulus 2^k gives k-bit outputs
+		}
+		decrypt("LCG Keystream after the outputs", func() uint64 { return lcg.Next().Uint64() }, bits, params)
+
+		// The ciphertext may have been made before the outputs were shown
+		for _, m := range prngByteMaps {
+			if m.Variable || bits < m.MinBits || len(in.Ciphertext) == 0 {
+				continue
+			}
+			g := &LCG{A: lcg.A, C: lcg.C, M: lcg.M, State: in.Outputs[0]}
+			ok := true
+			for range m.outputsUsed(bits, len(in.Ciphertext)) {
+				if _, ok = g.Prev(); !ok {
+					break
+				}
+			}
+			if !ok {
+				break
+			}
+			first := true
+			source := func() uint64 {
+				if first {
+					first = false
+					return g.State.Uint64()
+				}
+				return g.Next().Uint64()
+			}
+			plain := xorRepeating(in.Ciphertext, m.Bytes(source, bits, len(in.Ciphertext)))
+			if prngPlausible(plain, l.Opts.Crib) {
+				candidates = append(candidates, Candidate{
+					Step:    fmt.Sprintf("LCG Keystream before the outputs (%s)", m.Name),
+					Data:    plain,
+					Score:   magicScore(plain),
+					Details: []string{params},
+					Final:   true,
+				})
+			}
+		}
+	}
+	if !recovered && len(in.Outputs) >= 4 {
+		fmt.Printf("    %sThe outputs fit neither an LCG nor a Mersenne Twister (which needs 624).%s\n", ColorYellow, ColorReset)
+	}
+
+	// time(NULL) seeds around every timestamp given
+	centres := in.Timestamps
+	if !l.Opts.SeedTime.IsZero() {
+		centres = append([]int64{l.Opts.SeedTime.Unix()}, centres...)
+	}
+	if len(centres) > 0 && !recovered && (len(in.Ciphertext) > 0 || len(in.Outputs) > 0) {
+		window := l.Opts.SeedWindow
+		if window <= 0 {
+			window = prngSeedWindow
+		}
+		attackCtx, cancel := attackContext(ctx, l.Opts)
+		defer cancel()
+		for _, centre := range centres {
+			found := false
+			fmt.Printf("    Seed search: %s ± %s (glibc srand, std::mt19937, Python random.seed)\n", formatSeedTime(centre), window)
+			for d := int64(0); d <= int64(window/time.Second) && !found && attackCtx.Err() == nil; d++ {
+				for _, seed := range []int64{centre + d, centre - d} {
+					if d == 0 && seed != centre || seed < 0 || seed > 1<<32-1 {
+						continue
+					}
+					for _, s := range prngSeeders {
+						if len(in.Outputs) > 0 && !prngSeedMatches(s.New(uint32(seed)), in.Outputs) {
+							continue
+						}
+						before := len(candidates)
+						detail := fmt.Sprintf("%s(%d): seeded at %s", s.Name, seed, formatSeedTime(seed))
+						if len(in.Outputs) > 0 {
+							fmt.Printf("    %s%s reproduces the outputs%s\n", ColorGreen, detail, ColorReset)
+						}
+						next := s.New(uint32(seed))
+						for range in.Outputs {
+							next()
+						}
+						decrypt(fmt.Sprintf("%s(%d) Keystream", s.Name, seed), next, s.Bits, detail)
+						found = found || len(candidates) > before || len(in.Outputs) > 0
+					}
+				}
+			}
+			if !found && !reportStopped(attackCtx) {
+				fmt.Printf("    %sNo seed in the window fits.%s\n", ColorYellow, ColorReset)
+			}
+		}
+	}
+
+	if len(candidates) == 0 && len(in.Ciphertext) > 0 && recovered {
+		fmt.Printf("    %sNo keystream mapping (low byte, top byte, randbytes, randint) gives readable plaintext.%s\n", ColorYellow, ColorReset)
+	}
+	return candidates
+}
+
+// prngCloneMT clones a Mersenne Twister from 32-bit outputs, checking any
+// beyond the first 624 against its predictions
+func prngCloneMT(outputs []*big.Int) *MT19937 {
+	if len(outputs) < 624 {
+		return nil
+	}
+	words := make([]uint32, len(outputs))
+	for i, x := range outputs {
+		if x.BitLen() > 32 {
+			return nil
+		}
+		words[i] = uint32(x.Uint64())
+	}
+	mt := CloneMT19937(words)
+	check := *mt
+	for _, w := range words[624:] {
+		if check.Uint32() != w {
+			return nil
+		}
+	}
+	return &check
+}
+
+// prngSeedMatches reports whether a freshly seeded generator produces the
+// outputs given (at most the first three are compared)
+func prngSeedMatches(next func() uint64, outputs []*big.Int) bool {
+	for _, x := range outputs[:min(3, len(outputs))] {
+		if !x.IsUint64() || next() != x.Uint64() {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -16,11 +17,13 @@ type RSAParams struct {
 	N *big.Int
 	E *big.Int
 	C *big.Int
+	D *big.Int // Private exponent, when a private key was supplied
 }
 
 // ParseRSA extracts N, e, c from input string (Decimal or Hex)
 func ParseRSA(input string) *RSAParams {
 	params := &RSAParams{}
+	input = limitRegexInput(input)
 
 	// Regex to find numbers assigned to n, e, c (case insensitive)
 	// Supports: "n = 123", "Modulus: 0xabc", "c=..."
@@ -61,7 +64,7 @@ func ParseRSA(input string) *RSAParams {
 // SolveResult from main package (assumed shared or we redefine if needed, but since it's same package main, it's fine)
 
 // SolveRSA attempts to solve the parameters
-func SolveRSA(params *RSAParams, online bool) *SolveResult {
+func SolveRSA(ctx context.Context, params *RSAParams, online bool) *SolveResult {
 	if params.N == nil || params.E == nil || params.C == nil {
 		return &SolveResult{Success: false}
 	}
@@ -70,8 +73,18 @@ func SolveRSA(params *RSAParams, online bool) *SolveResult {
 	fmt.Printf("    N: %d bits\n", params.N.BitLen())
 	fmt.Printf("    e: %s\n", params.E.String())
 
+	// Attack 0: Private exponent already known (e.g. from a private key file)
+	if params.D != nil {
+		m := new(big.Int).Exp(params.C, params.D, params.N)
+		return &SolveResult{
+			Success:     true,
+			Algorithm:   "RSA Decryption (Private Key Supplied)",
+			DecodedData: bigIntToString(m),
+		}
+	}
+
 	// Attack 1: Small Exponent Attack (m^e < N)
-	if params.E.Cmp(big.NewInt(100000)) < 0 { // Check if e is reasonably small
+	if params.E.Sign() > 0 && params.E.Cmp(big.NewInt(100000)) < 0 { // Check if e is reasonably small
 		m := iroot(params.C, params.E)
 
 		// Check m and m+1 to handle potential off-by-one or floor issues in iroot
@@ -92,7 +105,7 @@ func SolveRSA(params *RSAParams, online bool) *SolveResult {
 
 	// Attack 2: FactorDB (Online)
 	if online {
-		p, q := queryFactorDB(params.N)
+		p, q := queryFactorDB(ctx, params.N)
 		if p != nil && q != nil {
 			fmt.Printf("    %s[+] Attack: FactorDB Lookup (Success)%s\n", ColorGreen, ColorReset)
 			one := big.NewInt(1)
@@ -127,9 +140,18 @@ func iroot(base *big.Int, root *big.Int) *big.Int {
 	if root.Cmp(big.NewInt(1)) == 0 {
 		return new(big.Int).Set(base)
 	}
+	if root.Sign() <= 0 || base.Sign() <= 0 {
+		return big.NewInt(0)
+	}
 
+	// The root has at most bitlen/root+1 bits; bounding the search there
+	// keeps mid^root near the size of base instead of bitlen*root bits
 	low := big.NewInt(0)
-	high := new(big.Int).Set(base)
+	bits := uint(1)
+	if root.IsInt64() {
+		bits += uint(base.BitLen()) / uint(root.Int64())
+	}
+	high := new(big.Int).Lsh(big.NewInt(1), bits)
 	one := big.NewInt(1)
 	ans := big.NewInt(0)
 
@@ -163,11 +185,15 @@ type FactorDBResponse struct {
 	Factors [][]interface{} `json:"factors"`
 }
 
-func queryFactorDB(N *big.Int) (*big.Int, *big.Int) {
+func queryFactorDB(ctx context.Context, N *big.Int) (*big.Int, *big.Int) {
 	client := &http.Client{Timeout: 5 * time.Second}
 	url := fmt.Sprintf("http://factordb.com/api?query=%s", N.String())
 
-	resp, err := client.Get(url)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, nil
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, nil
 	}
@@ -231,3 +257,22 @@ func queryFactorDB(N *big.Int) (*big.Int, *big.Int) {
 
 	return nil, nil
 }
+
+// rsaSolver attacks layers holding N, e and c
+type rsaSolver struct{}
+
+func (rsaSolver) Name() string { return "RSA Solver" }
+
+func (rsaSolver) Applicable(l *Layer) bool {
+	return l.RSA.N != nil && l.RSA.E != nil && l.RSA.C != nil
+}
+
+func (rsaSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	result := SolveRSA(ctx, l.RSA, l.Opts.Online)
+	if !result.Success {
+		fmt.Printf("    %sFailed to solve RSA (Small E or FactorDB failed).%s\n", ColorYellow, ColorReset)
+		return nil
+	}
+	// The plaintext is usually the final flag
+	return []Candidate{{Step: result.Algorithm, Data: []byte(result.DecodedData), Final: true}}
+}
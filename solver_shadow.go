@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/md4"
+)
+
+var (
+	credUserPattern = regexp.MustCompile(`^[A-Za-z0-9_.@$-]+$`)
+	hexPattern      = regexp.MustCompile(`^[a-fA-F0-9]+$`)
+	desCryptPattern = regexp.MustCompile(`^[./0-9A-Za-z]{13}$`)
+)
+
+// CredentialEntry is one user:hash record from a credential dump
+type CredentialEntry struct {
+	User     string
+	Hash     string
+	Format   string
+	Password string
+	Cracked  bool
+	Note     string // why an entry was not attacked, or how it was solved
+}
+
+// ParseCredentialDump recognises /etc/shadow, pwdump, htpasswd and plain
+// user:hash listings. Every non-comment line must split into a user and a
+// hash field, and at least one hash must have a known format. Returns the
+// dump kind and its entries, or "" and nil.
+func ParseCredentialDump(input string) (string, []CredentialEntry) {
+	var entries []CredentialEntry
+	kinds := make(map[string]bool)
+	known := false
+	if len(input) > maxRegexInput {
+		return "", nil // dumps are small; don't split megabytes into lines
+	}
+	for _, line := range strings.Split(input, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) < 2 || !credUserPattern.MatchString(fields[0]) {
+			return "", nil
+		}
+
+		e := CredentialEntry{User: fields[0], Hash: fields[1]}
+		switch {
+		case len(fields) == 9:
+			kinds["shadow"] = true
+		case len(fields) >= 4 && len(fields[3]) == 32 && hexPattern.MatchString(fields[3]):
+			// pwdump: user:rid:LM:NT:::
+			e.Hash = fields[3]
+			kinds["pwdump"] = true
+		case len(fields) == 2:
+			kinds["user:hash"] = true
+		default:
+			return "", nil
+		}
+		e.Format = IdentifyHashFormat(e.Hash)
+		if e.Format == "Unknown" && e.Hash != "" {
+			if len(fields) != 2 {
+				return "", nil
+			}
+		} else if e.Format != "Empty" && e.Format != "Locked" && e.Format != "DES crypt" {
+			known = true // DES crypt alone is too ambiguous to trust
+		}
+		entries = append(entries, e)
+	}
+	if !known || len(kinds) != 1 {
+		return "", nil
+	}
+
+	for kind := range kinds {
+		if kind != "user:hash" {
+			return kind, entries
+		}
+	}
+	// Two-field dumps are htpasswd when every hash uses an Apache format
+	for _, e := range entries {
+		switch e.Format {
+		case "apr1", "bcrypt", "SHA1 (Base64)", "DES crypt":
+		default:
+			return "user:hash", entries
+		}
+	}
+	return "htpasswd", entries
+}
+
+// IdentifyHashFormat names the crypt(3)/htpasswd/raw digest format of a hash
+func IdentifyHashFormat(h string) string {
+	switch {
+	case h == "":
+		return "Empty"
+	case strings.HasPrefix(h, "!") || strings.HasPrefix(h, "*") || h == "x":
+		return "Locked"
+	case strings.HasPrefix(h, "$1$"):
+		return "md5crypt"
+	case strings.HasPrefix(h, "$apr1$"):
+		return "apr1"
+	case strings.HasPrefix(h, "$5$"):
+		return "sha256crypt"
+	case strings.HasPrefix(h, "$6$"):
+		return "sha512crypt"
+	case strings.HasPrefix(h, "$2a$") || strings.HasPrefix(h, "$2b$") || strings.HasPrefix(h, "$2y$"):
+		return "bcrypt"
+	case strings.HasPrefix(h, "$y$"):
+		return "yescrypt"
+	case strings.HasPrefix(h, "$7$"):
+		return "scrypt"
+	case strings.HasPrefix(h, "$argon2"):
+		return "Argon2"
+	case strings.HasPrefix(h, "{SHA}"):
+		return "SHA1 (Base64)"
+	case strings.HasPrefix(h, "{SSHA}"):
+		return "SSHA"
+	case hexPattern.MatchString(h):
+		switch len(h) {
+		case 32:
+			return "MD5/NTLM"
+		case 40:
+			return "SHA1"
+		case 64:
+			return "SHA256"
+		case 128:
+			return "SHA512"
+		}
+	case desCryptPattern.MatchString(h):
+		return "DES crypt"
+	}
+	return "Unknown"
+}
+
+// CheckHashPassword tests a candidate against a hash of the given format.
+// The returned name refines ambiguous formats (MD5 vs NTLM); ok is false
+// when the format cannot be checked locally.
+func CheckHashPassword(format, h, password string) (match bool, name string, ok bool) {
+	equal := func(a, b string) bool { return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1 }
+	switch format {
+	case "md5crypt":
+		return equal(MD5Crypt(password, h[3:], "$1$"), h), format, true
+	case "apr1":
+		return equal(MD5Crypt(password, h[6:], "$apr1$"), h), format, true
+	case "sha256crypt":
+		return equal(SHACrypt(password, h[3:], false), h), format, true
+	case "sha512crypt":
+		return equal(SHACrypt(password, h[3:], true), h), format, true
+	case "bcrypt":
+		return bcrypt.CompareHashAndPassword([]byte(h), []byte(password)) == nil, format, true
+	case "SHA1 (Base64)":
+		sum := sha1.Sum([]byte(password))
+		return equal(base64.StdEncoding.EncodeToString(sum[:]), h[5:]), format, true
+	case "SSHA":
+		raw, err := base64.StdEncoding.DecodeString(h[6:])
+		if err != nil || len(raw) <= sha1.Size {
+			return false, format, true
+		}
+		sum := sha1.Sum(append([]byte(password), raw[sha1.Size:]...))
+		return subtle.ConstantTimeCompare(sum[:], raw[:sha1.Size]) == 1, format, true
+	case "MD5/NTLM":
+		sum := md5.Sum([]byte(password))
+		if strings.EqualFold(hex.EncodeToString(sum[:]), h) {
+			return true, "MD5", true
+		}
+		nt := md4.New()
+		nt.Write(utf16le(password))
+		if strings.EqualFold(hex.EncodeToString(nt.Sum(nil)), h) {
+			return true, "NTLM", true
+		}
+		return false, format, true
+	case "SHA1":
+		sum := sha1.Sum([]byte(password))
+		return strings.EqualFold(hex.EncodeToString(sum[:]), h), format, true
+	case "SHA256":
+		sum := sha256.Sum256([]byte(password))
+		return strings.EqualFold(hex.EncodeToString(sum[:]), h), format, true
+	case "SHA512":
+		sum := sha512.Sum512([]byte(password))
+		return strings.EqualFold(hex.EncodeToString(sum[:]), h), format, true
+	}
+	return false, format, false
+}
+
+// crackCredential runs the wordlist, then the online lookup for raw MD5
+func crackCredential(ctx context.Context, e *CredentialEntry, opts *Options) {
+	switch e.Format {
+	case "Empty":
+		e.Cracked, e.Note = true, "empty password"
+		return
+	case "Locked":
+		e.Note = "locked / no password login"
+		return
+	}
+	if note := credentialCostTooHigh(e); note != "" {
+		e.Note = note
+		return
+	}
+
+	attackCtx, cancel := attackContext(ctx, opts)
+	defer cancel()
+	for _, word := range opts.Wordlist {
+		if attackCtx.Err() != nil {
+			e.Note = fmt.Sprintf("stopped: %v", context.Cause(attackCtx))
+			break
+		}
+		match, name, ok := CheckHashPassword(e.Format, e.Hash, word)
+		if !ok {
+			e.Note = "not supported locally"
+			break
+		}
+		if match {
+			e.Format, e.Password, e.Cracked = name, word, true
+			return
+		}
+	}
+
+	if opts.Online && e.Format == "MD5/NTLM" {
+		if success, result := NewOnlineSolver().ActiveLookup(ctx, e.Hash, "MD5"); success {
+			e.Password, e.Cracked, e.Note = strings.TrimSpace(result), true, "online lookup"
+			return
+		}
+	}
+	if e.Note == "" {
+		e.Note = "not in wordlist"
+	}
+}
+
+// Work factors above these make a wordlist attack impractical; a crafted
+// dump could otherwise stall the tool for hours on one entry
+const (
+	maxCrackBcryptCost     = 14
+	maxCrackSHACryptRounds = 1000000
+)
+
+func credentialCostTooHigh(e *CredentialEntry) string {
+	switch e.Format {
+	case "bcrypt":
+		if cost, err := bcrypt.Cost([]byte(e.Hash)); err == nil && cost > maxCrackBcryptCost {
+			return fmt.Sprintf("bcrypt cost %d too high to attack", cost)
+		}
+	case "sha256crypt", "sha512crypt":
+		if rest, ok := strings.CutPrefix(e.Hash[3:], "rounds="); ok {
+			digits, _, _ := strings.Cut(rest, "$")
+			if n, err := strconv.Atoi(digits); err != nil || n > maxCrackSHACryptRounds {
+				return fmt.Sprintf("rounds=%s too high to attack", digits)
+			}
+		}
+	}
+	return ""
+}
+
+func analyzeCredentials(ctx context.Context, kind string, entries []CredentialEntry, opts *Options) {
+	fmt.Printf("%s[+] Credential Dump (%s, %d entries):%s\n", ColorBlue, kind, len(entries), ColorReset)
+
+	userWidth, formatWidth := len("USER"), len("FORMAT")
+	for i := range entries {
+		crackCredential(ctx, &entries[i], opts)
+		userWidth = max(userWidth, len(entries[i].User))
+		formatWidth = max(formatWidth, len(entries[i].Format))
+	}
+
+	cracked := 0
+	fmt.Printf("    %-*s  %-*s  %s\n", userWidth, "USER", formatWidth, "FORMAT", "RESULT")
+	for _, e := range entries {
+		result := fmt.Sprintf("%s%s%s", ColorYellow, e.Note, ColorReset)
+		if e.Cracked {
+			cracked++
+			result = fmt.Sprintf("%s%s%s", ColorGreen, e.Password, ColorReset)
+			if e.Note != "" {
+				result += " (" + e.Note + ")"
+			}
+		}
+		fmt.Printf("    %-*s  %-*s  %s\n", userWidth, e.User, formatWidth, e.Format, result)
+	}
+	fmt.Printf("    Cracked %d/%d with %d candidate passwords.\n", cracked, len(entries), len(opts.Wordlist))
+}
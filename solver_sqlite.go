@@ -0,0 +1,542 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+// SQLite file format constants
+const (
+	sqliteHeaderSize = 100
+	maxSQLiteRows    = 10000 // rows read per table
+	maxSQLiteLayers  = 64    // cells analysed as layers per database
+)
+
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+// sqliteTableSQL matches a CREATE TABLE statement up to its column list
+var sqliteTableSQL = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:TEMP\w*\s+)?TABLE\b`)
+
+// SQLiteDB is a minimal read-only SQLite reader: it walks the table
+// b-trees and decodes their records, without an SQL engine
+type SQLiteDB struct {
+	data     []byte
+	PageSize int
+	usable   int
+	Pages    int
+	Encoding string // "UTF-8", "UTF-16le" or "UTF-16be"
+	Tables   []SQLiteTable
+}
+
+// SQLiteTable is a table with its rows in rowid (or primary key) order
+type SQLiteTable struct {
+	Name         string
+	SQL          string
+	Columns      []string // in the order records store them
+	WithoutRowID bool     // rows are keyed by primary key alone, RowID is 0
+	Rows         []SQLiteRow
+	Err          error // set when the table's b-tree is damaged or truncated
+}
+
+// SQLiteRow is one record; values are nil, int64, float64, string or []byte
+type SQLiteRow struct {
+	RowID  int64
+	Values []any
+}
+
+// Column names column i, falling back to its position when the schema
+// didn't say
+func (t *SQLiteTable) Column(i int) string {
+	if i < len(t.Columns) {
+		return t.Columns[i]
+	}
+	return fmt.Sprintf("col%d", i+1)
+}
+
+// ParseSQLite reads the schema of a database and every row of its tables
+func ParseSQLite(data []byte) (*SQLiteDB, error) {
+	if len(data) < sqliteHeaderSize || string(data[:16]) != string(sqliteMagic) {
+		return nil, errors.New("not an SQLite database")
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("bad page size %d", pageSize)
+	}
+	db := &SQLiteDB{
+		data:     data,
+		PageSize: pageSize,
+		usable:   pageSize - int(data[20]),
+		Pages:    (len(data) + pageSize - 1) / pageSize,
+		Encoding: "UTF-8",
+	}
+	if db.usable < 480 {
+		return nil, errors.New("bad reserved space size")
+	}
+	switch binary.BigEndian.Uint32(data[56:60]) {
+	case 2:
+		db.Encoding = "UTF-16le"
+	case 3:
+		db.Encoding = "UTF-16be"
+	}
+
+	// Page 1 is the root of sqlite_schema: type, name, tbl_name, rootpage, sql
+	var schema []SQLiteRow
+	if err := db.walk(1, func(payload []byte, rowid int64) bool {
+		schema = append(schema, SQLiteRow{RowID: rowid, Values: db.record(payload)})
+		return len(schema) < maxSQLiteRows
+	}); err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	for _, entry := range schema {
+		if len(entry.Values) < 5 {
+			continue
+		}
+		kind, _ := entry.Values[0].(string)
+		name, _ := entry.Values[1].(string)
+		root, _ := entry.Values[3].(int64)
+		sql, _ := entry.Values[4].(string)
+		// Virtual tables have no b-tree of their own
+		if kind != "table" || root <= 0 {
+			continue
+		}
+		t := SQLiteTable{Name: name, SQL: sql, WithoutRowID: sqliteWithoutRowID(sql)}
+		columns, alias := sqliteColumns(sql)
+		t.Columns = columns
+		t.Err = db.walk(int(root), func(payload []byte, rowid int64) bool {
+			values := db.record(payload)
+			// An INTEGER PRIMARY KEY is stored as NULL: its value is the rowid
+			if alias >= 0 && alias < len(values) && values[alias] == nil {
+				values[alias] = rowid
+			}
+			t.Rows = append(t.Rows, SQLiteRow{RowID: rowid, Values: values})
+			return len(t.Rows) < maxSQLiteRows
+		})
+		db.Tables = append(db.Tables, t)
+	}
+	return db, nil
+}
+
+// page returns page n, numbered from 1, or nil past the end of the file
+func (db *SQLiteDB) page(n int) []byte {
+	start := (n - 1) * db.PageSize
+	if n < 1 || start+db.PageSize > len(db.data) {
+		return nil
+	}
+	return db.data[start : start+db.PageSize]
+}
+
+// walk calls fn with the payload of every leaf cell of the b-tree rooted at
+// a page, in key order, until fn returns false. Index b-trees (WITHOUT
+// ROWID tables) have no rowids and pass 0.
+func (db *SQLiteDB) walk(root int, fn func(payload []byte, rowid int64) bool) error {
+	visited := make(map[int]bool)
+	var visit func(n int) (bool, error)
+	visit = func(n int) (bool, error) {
+		if visited[n] {
+			return false, fmt.Errorf("page %d linked twice", n)
+		}
+		visited[n] = true
+		page := db.page(n)
+		if page == nil {
+			return false, fmt.Errorf("page %d is past the end of the file", n)
+		}
+		hdr := 0
+		if n == 1 {
+			hdr = sqliteHeaderSize
+		}
+		if hdr+8 > len(page) {
+			return false, fmt.Errorf("page %d truncated", n)
+		}
+		kind := page[hdr]
+		cells := int(binary.BigEndian.Uint16(page[hdr+3:]))
+		ptrs := hdr + 8
+		interior := kind == 0x05 || kind == 0x02
+		if interior {
+			ptrs += 4
+		}
+		if kind != 0x0d && kind != 0x05 && kind != 0x0a && kind != 0x02 {
+			return false, fmt.Errorf("page %d is not a b-tree page (type %#x)", n, kind)
+		}
+		if ptrs+2*cells > len(page) {
+			return false, fmt.Errorf("page %d has a bad cell count", n)
+		}
+
+		for i := range cells {
+			off := int(binary.BigEndian.Uint16(page[ptrs+2*i:]))
+			if off < ptrs || off >= db.usable {
+				return false, fmt.Errorf("page %d cell %d out of bounds", n, i)
+			}
+			cell := page[off:db.usable]
+			if interior {
+				if len(cell) < 4 {
+					return false, fmt.Errorf("page %d cell %d truncated", n, i)
+				}
+				if more, err := visit(int(binary.BigEndian.Uint32(cell))); !more || err != nil {
+					return more, err
+				}
+				// Table interior cells hold only a key; index interior
+				// cells carry a record of their own between the subtrees
+				if kind == 0x05 {
+					continue
+				}
+				cell = cell[4:]
+			}
+			size, k := sqliteVarint(cell)
+			cell = cell[k:]
+			var rowid int64
+			if kind == 0x0d {
+				id, k := sqliteVarint(cell)
+				rowid, cell = int64(id), cell[k:]
+			}
+			payload, err := db.payload(cell, int(min(size, uint64(len(db.data)))), kind == 0x0d)
+			if err != nil {
+				return false, fmt.Errorf("page %d cell %d: %w", n, i, err)
+			}
+			if !fn(payload, rowid) {
+				return false, nil
+			}
+		}
+		if interior {
+			return visit(int(binary.BigEndian.Uint32(page[hdr+8:])))
+		}
+		return true, nil
+	}
+	_, err := visit(root)
+	return err
+}
+
+// payload gathers a cell's payload from the page and, when it spills, the
+// chain of overflow pages
+func (db *SQLiteDB) payload(cell []byte, size int, tableLeaf bool) ([]byte, error) {
+	u := db.usable
+	maxLocal := (u-12)*64/255 - 23
+	if tableLeaf {
+		maxLocal = u - 35
+	}
+	local := size
+	if size > maxLocal {
+		minLocal := (u-12)*32/255 - 23
+		if local = minLocal + (size-minLocal)%(u-4); local > maxLocal {
+			local = minLocal
+		}
+	}
+	if local > len(cell) || (local < size && local+4 > len(cell)) {
+		return nil, errors.New("payload runs off the page")
+	}
+	out := append([]byte(nil), cell[:local]...)
+	if local == size {
+		return out, nil
+	}
+	visited := make(map[int]bool)
+	for next := int(binary.BigEndian.Uint32(cell[local:])); len(out) < size; {
+		page := db.page(next)
+		if page == nil || visited[next] {
+			return out, errors.New("broken overflow chain")
+		}
+		visited[next] = true
+		chunk := page[4:u]
+		out = append(out, chunk[:min(len(chunk), size-len(out))]...)
+		next = int(binary.BigEndian.Uint32(page))
+	}
+	return out, nil
+}
+
+// record decodes a record into its column values; a truncated record
+// yields the columns that were complete
+func (db *SQLiteDB) record(payload []byte) []any {
+	hdrSize, k := sqliteVarint(payload)
+	if hdrSize > uint64(len(payload)) || int(hdrSize) < k {
+		return nil
+	}
+	header, body := payload[k:hdrSize], payload[hdrSize:]
+	var values []any
+	for len(header) > 0 {
+		serial, k := sqliteVarint(header)
+		header = header[k:]
+		size := sqliteSerialSize(serial)
+		if size > uint64(len(body)) {
+			break
+		}
+		v := body[:size]
+		body = body[size:]
+		switch {
+		case serial == 0:
+			values = append(values, nil)
+		case serial <= 6:
+			// Big-endian two's complement of 1 to 8 bytes
+			n := int64(int8(v[0]))
+			for _, b := range v[1:] {
+				n = n<<8 | int64(b)
+			}
+			values = append(values, n)
+		case serial == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(v)))
+		case serial == 8 || serial == 9:
+			values = append(values, int64(serial-8))
+		case serial >= 12 && serial%2 == 0:
+			values = append(values, append([]byte(nil), v...))
+		case serial >= 13:
+			values = append(values, db.text(v))
+		default:
+			// 10 and 11 are reserved
+			values = append(values, nil)
+		}
+	}
+	return values
+}
+
+// text decodes a TEXT value in the database encoding
+func (db *SQLiteDB) text(b []byte) string {
+	if db.Encoding == "UTF-8" {
+		return string(b)
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if db.Encoding == "UTF-16be" {
+		order = binary.BigEndian
+	}
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = order.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// sqliteSerialSize is the body size of a record value of a serial type
+func sqliteSerialSize(serial uint64) uint64 {
+	switch {
+	case serial >= 12:
+		return (serial - 12) / 2
+	case serial <= 4:
+		return serial
+	case serial == 5:
+		return 6
+	case serial == 6 || serial == 7:
+		return 8
+	}
+	return 0
+}
+
+// sqliteVarint reads a big-endian varint of up to nine bytes, the last
+// contributing all eight of its bits
+func sqliteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(b) && i < 9; i++ {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return v, len(b)
+}
+
+// sqliteColumns reads the column names from a CREATE TABLE statement in
+// record order (primary key first for WITHOUT ROWID tables), with the
+// index of an INTEGER PRIMARY KEY column, which stores the rowid, or -1
+func sqliteColumns(sql string) ([]string, int) {
+	open, end := strings.Index(sql, "("), strings.LastIndex(sql, ")")
+	if !sqliteTableSQL.MatchString(sql) || open < 0 || end < open {
+		return nil, -1
+	}
+	var defs []string
+	depth, start := 0, open+1
+	var quote byte
+	for i := open + 1; i < end; i++ {
+		switch c := sql[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			defs = append(defs, sql[start:i])
+			start = i + 1
+		}
+	}
+	defs = append(defs, sql[start:end])
+
+	var columns, key []string
+	alias := -1
+	for _, def := range defs {
+		def = strings.TrimSpace(def)
+		name, rest := sqliteIdent(def)
+		upper := strings.ToUpper(def)
+		switch strings.ToUpper(name) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			if i := strings.Index(upper, "PRIMARY KEY"); i >= 0 {
+				list := def[i+len("PRIMARY KEY"):]
+				if l, r := strings.Index(list, "("), strings.Index(list, ")"); l >= 0 && r > l {
+					for _, col := range strings.Split(list[l+1:r], ",") {
+						col, _ = sqliteIdent(strings.TrimSpace(col))
+						key = append(key, col)
+					}
+				}
+			}
+			continue
+		}
+		if strings.Contains(strings.ToUpper(rest), "PRIMARY KEY") {
+			key = append(key, name)
+			if fields := strings.Fields(strings.ToUpper(rest)); len(fields) > 0 && fields[0] == "INTEGER" {
+				alias = len(columns)
+			}
+		}
+		columns = append(columns, name)
+	}
+	if len(key) != 1 {
+		alias = -1
+	}
+
+	if !sqliteWithoutRowID(sql) {
+		return columns, alias
+	}
+	// WITHOUT ROWID records lead with the primary key columns
+	ordered := append([]string(nil), key...)
+	for _, col := range columns {
+		inKey := false
+		for _, k := range key {
+			inKey = inKey || strings.EqualFold(k, col)
+		}
+		if !inKey {
+			ordered = append(ordered, col)
+		}
+	}
+	return ordered, -1
+}
+
+// sqliteWithoutRowID reports whether a CREATE TABLE statement ends with
+// the WITHOUT ROWID option
+func sqliteWithoutRowID(sql string) bool {
+	tail := sql[strings.LastIndex(sql, ")")+1:]
+	return strings.Contains(strings.Join(strings.Fields(strings.ToUpper(tail)), " "), "WITHOUT ROWID")
+}
+
+// sqliteIdent splits a leading identifier, quoted or bare, from the rest
+func sqliteIdent(s string) (string, string) {
+	if s == "" {
+		return "", ""
+	}
+	closing := map[byte]byte{'"': '"', '`': '`', '[': ']', '\'': '\''}[s[0]]
+	if closing != 0 {
+		if i := strings.IndexByte(s[1:], closing); i >= 0 {
+			return s[1 : i+1], s[i+2:]
+		}
+	}
+	if i := strings.IndexAny(s, " \t\r\n("); i >= 0 {
+		return s[:i], s[i:]
+	}
+	return s, ""
+}
+
+// analyzeSQLite lists the tables of an SQLite database and scans every text
+// and blob cell for flags. Long text cells and those identifying as an
+// encoding, hash or file, and every blob, are analysed as layers. It
+// reports whether a flag was found.
+func analyzeSQLite(ctx context.Context, data []byte, opts *Options, chain []string) bool {
+	flagsBefore := len(opts.flags)
+	db, err := ParseSQLite(data)
+	if err != nil {
+		fmt.Printf("    %sFailed to parse: %v%s\n", ColorYellow, err, ColorReset)
+		return false
+	}
+	fmt.Printf("    Page size: %d, %d pages, %s, %d tables\n", db.PageSize, db.Pages, db.Encoding, len(db.Tables))
+
+	type cell struct {
+		name string
+		data []byte
+		blob bool
+	}
+	var layers []cell
+	seen := make(map[string]bool)
+	for _, t := range db.Tables {
+		fmt.Printf("    %s (%s): %d rows\n", t.Name, strings.Join(t.Columns, ", "), len(t.Rows))
+		if t.Err != nil {
+			fmt.Printf("    %s%s: %v%s\n", ColorYellow, t.Name, t.Err, ColorReset)
+		}
+		if len(t.Rows) == maxSQLiteRows {
+			fmt.Printf("    %sOnly the first %d rows of %s were read%s\n", ColorYellow, maxSQLiteRows, t.Name, ColorReset)
+		}
+		for n, row := range t.Rows {
+			// Without rowids, rows are numbered by position
+			id := row.RowID
+			if t.WithoutRowID {
+				id = int64(n + 1)
+			}
+			for i, v := range row.Values {
+				c := cell{name: fmt.Sprintf("%s.%s (row %d)", t.Name, t.Column(i), id)}
+				switch v := v.(type) {
+				case string:
+					c.data = []byte(strings.TrimSpace(v))
+				case []byte:
+					c.data, c.blob = v, true
+				default:
+					continue
+				}
+				reportFlags(opts, c.data, withStep(chain, "SQLite "+c.name))
+				if len(c.data) == 0 || seen[string(c.data)] {
+					continue
+				}
+				seen[string(c.data)] = true
+				if !c.blob {
+					if len(c.data) < minFieldLayer {
+						continue
+					}
+					if kind, _ := IdentifyType(c.data); kind == "Unknown" && len(c.data) < 2*minFieldLayer {
+						continue
+					}
+				}
+				layers = append(layers, c)
+			}
+		}
+	}
+
+	if len(layers) > maxSQLiteLayers {
+		fmt.Printf("    %sAnalysing the first %d of %d cells%s\n", ColorYellow, maxSQLiteLayers, len(layers), ColorReset)
+		layers = layers[:maxSQLiteLayers]
+	}
+	for _, c := range layers {
+		if ctx.Err() != nil {
+			break
+		}
+		fmt.Printf("%s[+] SQLite %s (%d bytes):%s\n", ColorBlue, c.name, len(c.data), ColorReset)
+		if c.blob {
+			name := strings.NewReplacer(" ", "_", "(", "", ")", "", "/", "_").Replace(c.name)
+			saveArtifact(opts, "sqlite_"+name+".bin", c.data)
+		}
+		orchestrate(ctx, c.data, opts, withStep(chain, "SQLite "+c.name))
+	}
+	return len(opts.flags) > flagsBefore
+}
+
+// sqliteSolver scans SQLite databases. Flags found in the cells, or in the
+// layers under them, end the chain.
+type sqliteSolver struct{}
+
+func (sqliteSolver) Name() string { return "SQLite Database" }
+
+func (sqliteSolver) Applicable(l *Layer) bool {
+	return l.Type == "File (SQLite)"
+}
+
+func (sqliteSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	flagsBefore := len(l.Opts.flags)
+	analyzeSQLite(ctx, l.Data, l.Opts, l.Chain)
+	return flagCandidate(l, flagsBefore, "SQLite Cells")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+)
+
+const base64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// Base64Lines splits input into lines and returns them only if there are at
+// least two and every one is a well-formed, padded Base64 block.
+func Base64Lines(input string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(input, "\r", ""), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if len(line)%4 != 0 || !EncodingChecks["Base64"].MatchString(line) {
+			return nil
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) < 2 {
+		return nil
+	}
+	return lines
+}
+
+// ExtractBase64Steg recovers data hidden in the unused low bits of the last
+// character before "=" padding. A "==" line carries 4 hidden bits, a "=" line
+// carries 2. The decoder throws these bits away, so the visible decoded text
+// is unchanged while the bits across all lines spell out a hidden message.
+func ExtractBase64Steg(lines []string) []byte {
+	var bits []byte
+	for _, line := range lines {
+		switch {
+		case strings.HasSuffix(line, "=="):
+			idx := strings.IndexByte(base64Alphabet, line[len(line)-3])
+			for i := 3; i >= 0; i-- {
+				bits = append(bits, byte(idx>>i)&1)
+			}
+		case strings.HasSuffix(line, "="):
+			idx := strings.IndexByte(base64Alphabet, line[len(line)-2])
+			for i := 1; i >= 0; i-- {
+				bits = append(bits, byte(idx>>i)&1)
+			}
+		}
+	}
+
+	hidden := make([]byte, 0, len(bits)/8)
+	for i := 0; i+8 <= len(bits); i += 8 {
+		var b byte
+		for _, bit := range bits[i : i+8] {
+			b = b<<1 | bit
+		}
+		hidden = append(hidden, b)
+	}
+	return hidden
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// tlsDialTimeout bounds the connection and handshake of --tls
+const tlsDialTimeout = 10 * time.Second
+
+// fermatRounds bounds the Fermat search for primes that are close together
+const fermatRounds = 100000
+
+// rocaPrimes are the small primes of the ROCA fingerprint (CVE-2017-15361).
+// Infineon RSALib primes are k*M + (65537^a mod M) with M their product.
+var rocaPrimes = []int64{3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53, 59, 61, 67, 71, 73,
+	79, 83, 89, 97, 101, 103, 107, 109, 113, 127, 131, 137, 139, 149, 151, 157, 163, 167}
+
+// FetchCertificates connects to a TLS server and returns the certificate
+// chain it presents, leaf first. Port 443 is assumed when none is given.
+// The chain is kept even when the handshake then fails, as it does for
+// keys too weak for crypto/tls to verify a signature with.
+func FetchCertificates(ctx context.Context, addr string) ([]*x509.Certificate, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, addr = addr, net.JoinHostPort(addr, "443")
+	}
+	var certs []*x509.Certificate
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: tlsDialTimeout},
+		// The chain is analysed, not trusted: self-signed and expired
+		// certificates are the interesting ones
+		Config: &tls.Config{ServerName: host, InsecureSkipVerify: true,
+			VerifyPeerCertificate: func(raw [][]byte, _ [][]*x509.Certificate) error {
+				for _, der := range raw {
+					cert, err := x509.ParseCertificate(der)
+					if err != nil {
+						return err
+					}
+					certs = append(certs, cert)
+				}
+				return nil
+			}},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err == nil {
+		conn.Close()
+	}
+	if len(certs) > 0 {
+		return certs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%s presented no certificates", addr)
+}
+
+// RSAWeakness is what the public key checks found out about one key
+type RSAWeakness struct {
+	Findings []string
+	P, Q     *big.Int // the factors, when a check recovered them
+}
+
+// CheckRSAPublicKey looks for the weaknesses a single RSA public key can
+// show: a tiny exponent, a short modulus, the ROCA fingerprint and primes
+// close enough for Fermat's method. FactorDB is asked when online.
+func CheckRSAPublicKey(ctx context.Context, pub *rsa.PublicKey, online bool) *RSAWeakness {
+	w := &RSAWeakness{}
+	switch {
+	case pub.E == 1:
+		w.Findings = append(w.Findings, "e = 1: ciphertexts are the plaintext")
+	case pub.E < 65537:
+		w.Findings = append(w.Findings, fmt.Sprintf("small exponent e = %d: short unpadded messages fall to an e-th root, and %d ciphertexts of one message to Håstad's attack", pub.E, pub.E))
+	}
+	switch bits := pub.N.BitLen(); {
+	case bits <= 512:
+		w.Findings = append(w.Findings, fmt.Sprintf("%d-bit modulus: factorable in hours with CADO-NFS or msieve", bits))
+	case bits < 2048:
+		w.Findings = append(w.Findings, fmt.Sprintf("%d-bit modulus: below the 2048-bit minimum", bits))
+	}
+	if IsROCAKey(pub.N) {
+		w.Findings = append(w.Findings, "ROCA fingerprint (Infineon RSALib, CVE-2017-15361): factorable with Coppersmith's method (e.g. neca)")
+	}
+	if p, q := fermatFactor(pub.N, fermatRounds); p != nil {
+		w.Findings = append(w.Findings, "primes are close together: factored with Fermat's method")
+		w.P, w.Q = p, q
+	} else if online {
+		if p, q := queryFactorDB(ctx, pub.N); p != nil && q != nil {
+			w.Findings = append(w.Findings, "modulus is factored in FactorDB")
+			w.P, w.Q = p, q
+		}
+	}
+	return w
+}
+
+// IsROCAKey reports whether N has the ROCA fingerprint: N mod r lies in the
+// subgroup generated by 65537 for every prime r of the fingerprint. Other
+// keys pass this by chance with negligible probability.
+func IsROCAKey(n *big.Int) bool {
+	rem := new(big.Int)
+	for _, r := range rocaPrimes {
+		x := rem.Mod(n, big.NewInt(r)).Int64()
+		g, found := int64(1), false
+		for {
+			if g == x {
+				found = true
+				break
+			}
+			if g = g * (65537 % r) % r; g == 1 {
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// fermatFactor factors n = p*q when p and q are within a few rounds of
+// sqrt(n), or returns nil
+func fermatFactor(n *big.Int, rounds int) (*big.Int, *big.Int) {
+	if n.Sign() <= 0 || n.Bit(0) == 0 {
+		return nil, nil
+	}
+	a := new(big.Int).Sqrt(n)
+	if new(big.Int).Mul(a, a).Cmp(n) < 0 {
+		a.Add(a, big.NewInt(1))
+	}
+	b2 := new(big.Int).Sub(new(big.Int).Mul(a, a), n)
+	b := new(big.Int)
+	for range rounds {
+		b.Sqrt(b2)
+		if new(big.Int).Mul(b, b).Cmp(b2) == 0 {
+			p := new(big.Int).Sub(a, b)
+			if p.Cmp(big.NewInt(1)) <= 0 {
+				return nil, nil
+			}
+			return p, new(big.Int).Add(a, b)
+		}
+		// (a+1)^2 - n = b2 + 2a + 1
+		b2.Add(b2, a).Add(b2, a).Add(b2, big.NewInt(1))
+		a.Add(a, big.NewInt(1))
+	}
+	return nil, nil
+}
+
+// recoverPrivateKey rebuilds the private key of pub from its factors.
+// rsa.PrivateKey.Validate is not used: it rejects the close primes
+// Fermat's method finds.
+func recoverPrivateKey(pub *rsa.PublicKey, p, q *big.Int) (*rsa.PrivateKey, error) {
+	if new(big.Int).Mul(p, q).Cmp(pub.N) != 0 {
+		return nil, errors.New("the factors do not multiply to N")
+	}
+	one := big.NewInt(1)
+	pm1, qm1 := new(big.Int).Sub(p, one), new(big.Int).Sub(q, one)
+	d := new(big.Int).ModInverse(big.NewInt(int64(pub.E)), new(big.Int).Mul(pm1, qm1))
+	if d == nil {
+		return nil, fmt.Errorf("e = %d is not invertible modulo phi(N)", pub.E)
+	}
+	key := &rsa.PrivateKey{PublicKey: *pub, D: d, Primes: []*big.Int{p, q}}
+	key.Precomputed.Dp = new(big.Int).Mod(d, pm1)
+	key.Precomputed.Dq = new(big.Int).Mod(d, qm1)
+	key.Precomputed.Qinv = new(big.Int).ModInverse(q, p)
+	return key, nil
+}
+
+// analyzeCertificates prints a certificate chain and runs the RSA weak-key
+// checks on every RSA key in it, including shared factors across the chain.
+// It reports whether a private key was recovered.
+func analyzeCertificates(ctx context.Context, source string, certs []*x509.Certificate, opts *Options) bool {
+	fmt.Printf("%s[+] TLS Certificate Chain (%s, %d certificates):%s\n", ColorBlue, source, len(certs), ColorReset)
+	var keys []*rsa.PublicKey
+	var owners []int
+	recovered := false
+	for i, cert := range certs {
+		chain := []string{fmt.Sprintf("TLS Certificate %d", i+1)}
+		fmt.Printf("%s[+] Certificate %d: %s%s\n", ColorBlue, i+1, cert.Subject, ColorReset)
+		printCertificate(cert)
+		// Flags hide in subjects, SANs and extensions
+		reportFlags(opts, cert.Raw, chain)
+
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			continue
+		}
+		recovered = reportRSAPublicKey(ctx, pub, opts) || recovered
+		keys = append(keys, pub)
+		owners = append(owners, i)
+	}
+
+	// Moduli sharing a prime (bad randomness at key generation) factor each other
+	if len(keys) > 1 {
+		moduli := make([]*big.Int, len(keys))
+		for i, k := range keys {
+			moduli[i] = k.N
+		}
+		shared := false
+		for i, g := range BatchGCD(moduli) {
+			if g.Cmp(big.NewInt(1)) == 0 || g.Cmp(keys[i].N) == 0 {
+				continue
+			}
+			if !shared {
+				fmt.Printf("%s[+] Shared Factors:%s\n", ColorBlue, ColorReset)
+				shared = true
+			}
+			fmt.Printf("    %sCertificate %d shares a prime with another key in the chain%s\n", ColorYellow, owners[i]+1, ColorReset)
+			recovered = printRecoveredKey(keys[i], g, new(big.Int).Div(keys[i].N, g)) || recovered
+		}
+	}
+	return recovered
+}
+
+// printRecoveredKey prints the factors and private key rebuilt from them
+// printCertificate prints the fields of a certificate worth a look
+func printCertificate(cert *x509.Certificate) {
+	fmt.Printf("    Issuer: %s\n", cert.Issuer)
+	validity := fmt.Sprintf("%s to %s", cert.NotBefore.Format(time.DateOnly), cert.NotAfter.Format(time.DateOnly))
+	if now := time.Now(); now.After(cert.NotAfter) || now.Before(cert.NotBefore) {
+		fmt.Printf("    Valid: %s%s (not valid now)%s\n", ColorYellow, validity, ColorReset)
+	} else {
+		fmt.Printf("    Valid: %s\n", validity)
+	}
+	if names := slices.Concat(cert.DNSNames, ipStrings(cert.IPAddresses)); len(names) > 0 {
+		fmt.Printf("    Names: %s\n", strings.Join(names, ", "))
+	}
+	keyType, bits := describePublicKey(cert.PublicKey)
+	fmt.Printf("    Key: %s (%d bits)\n", keyType, bits)
+	fmt.Printf("    Signature: %s\n", cert.SignatureAlgorithm)
+	if cert.Issuer.String() == cert.Subject.String() {
+		fmt.Printf("    Self-signed: yes\n")
+	}
+}
+
+// reportRSAPublicKey runs the weak-key checks on one RSA public key and
+// prints the private key when they factor it
+func reportRSAPublicKey(ctx context.Context, pub *rsa.PublicKey, opts *Options) bool {
+	fmt.Printf("    e: %d\n", pub.E)
+	w := CheckRSAPublicKey(ctx, pub, opts.Online)
+	if len(w.Findings) == 0 {
+		fmt.Printf("    Weak key checks: %snone found%s\n", ColorGreen, ColorReset)
+	}
+	for _, f := range w.Findings {
+		fmt.Printf("    %sWeak key: %s%s\n", ColorYellow, f, ColorReset)
+	}
+	return w.P != nil && printRecoveredKey(pub, w.P, w.Q)
+}
+
+func printRecoveredKey(pub *rsa.PublicKey, p, q *big.Int) bool {
+	key, err := recoverPrivateKey(pub, p, q)
+	if err != nil {
+		fmt.Printf("    %sFactors found but no private key: %v%s\n", ColorRed, err, ColorReset)
+		return false
+	}
+	fmt.Printf("    %sSuccess! Private key recovered%s\n", ColorGreen, ColorReset)
+	fmt.Printf("    p: %s\n    q: %s\n    d: %s\n", p, q, key.D)
+	block := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	for _, line := range strings.Split(strings.TrimSpace(string(block)), "\n") {
+		fmt.Printf("    %s\n", line)
+	}
+	return true
+}
+
+func ipStrings(ips []net.IP) []string {
+	out := make([]string, len(ips))
+	for i, ip := range ips {
+		out[i] = ip.String()
+	}
+	return out
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	veraMinSize      = 64 * 1024 // smaller files are rarely containers
+	veraBlockSize    = 4096
+	veraBlockEntropy = 7.9 // uniform random 4 KiB blocks sit around 7.95
+	veraCryptType    = "Possible VeraCrypt/TrueCrypt Container"
+)
+
+// DetectVeraCrypt applies the only test available for VeraCrypt/TrueCrypt
+// containers, which have no magic bytes: the size is a multiple of 512 and
+// every block looks like uniform random data. Returns a reason when the
+// heuristic rejects the input.
+func DetectVeraCrypt(data []byte) (bool, string) {
+	if len(data) < veraMinSize {
+		return false, "too small"
+	}
+	if len(data)%512 != 0 {
+		return false, "size is not a multiple of 512"
+	}
+	for off := 0; off+veraBlockSize <= len(data); off += veraBlockSize {
+		if e := CalculateShannonEntropy(data[off : off+veraBlockSize]); e < veraBlockEntropy {
+			return false, fmt.Sprintf("low-entropy region at offset 0x%X (%.2f)", off, e)
+		}
+	}
+	return true, ""
+}
+
+// veraCryptSolver explains how to attack a suspected VeraCrypt/TrueCrypt
+// container, which has no header to parse
+type veraCryptSolver struct{}
+
+func (veraCryptSolver) Name() string { return "VeraCrypt/TrueCrypt Heuristics" }
+
+func (veraCryptSolver) Applicable(l *Layer) bool {
+	return l.Type == veraCryptType
+}
+
+func (veraCryptSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	reportVeraCrypt(len(l.Data))
+	return nil
+}
+
+func reportVeraCrypt(size int) {
+	fmt.Printf("    %sSuspected encrypted container%s: %d bytes (%d sectors), uniformly random, no known signature.\n", ColorYellow, ColorReset, size, size/512)
+	fmt.Printf("    john: truecrypt2john <file> > tc.hash && john --wordlist=<list> tc.hash\n")
+	fmt.Printf("    hashcat: dd if=<file> of=header.bin bs=512 count=1, then -m 137xx (VeraCrypt) or -m 62xx (TrueCrypt)\n")
+	fmt.Printf("    Hidden volumes keep their header at offset 65536: dd ... skip=128 bs=512 count=1\n")
+}
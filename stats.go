@@ -1,7 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
 	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
 )
 
 // CalculateShannonEntropy returns a float (0-8) representing data entropy
@@ -110,3 +117,360 @@ func CalculateIoC(data []byte) float64 {
 
 	return rawIoC * 26.0
 }
+
+// maxIoCPeriod is the longest key length the periodic IoC table covers
+const maxIoCPeriod = 20
+
+// minIoCColumn is the fewest letters per column a period needs to be
+// measured: shorter columns give noisy IoCs, and long periods fitted to
+// them beat the true key length
+const minIoCColumn = 10
+
+// iocPeakMin is the highest periodic IoC needed before any period counts as
+// a peak: random letters stay near 1.0
+const iocPeakMin = 1.4
+
+// PeriodicIoC splits the letters of data into p interleaved columns, as a
+// Vigenère key of length p would, and averages the columns' normalized IoC
+// for p = 1 to maxPeriod. The key length and its multiples come out near
+// English (~1.73) while other periods stay near random (~1.0).
+func PeriodicIoC(data []byte, maxPeriod int) []float64 {
+	var letters []byte
+	for _, b := range data {
+		if c := b | 0x20; c >= 'a' && c <= 'z' {
+			letters = append(letters, c-'a')
+		}
+	}
+	var iocs []float64
+	for p := 1; p <= maxPeriod && len(letters)/p >= minIoCColumn; p++ {
+		sum := 0.0
+		for col := 0; col < p; col++ {
+			var counts [26]int
+			n := 0
+			for i := col; i < len(letters); i += p {
+				counts[letters[i]]++
+				n++
+			}
+			pairs := 0
+			for _, c := range counts {
+				pairs += c * (c - 1)
+			}
+			sum += 26 * float64(pairs) / float64(n*(n-1))
+		}
+		iocs = append(iocs, sum/float64(p))
+	}
+	return iocs
+}
+
+// IoCPeaks are the periods (1-based) whose IoC comes within 20% of the
+// highest, if that stands clearly above random; the first is the likely key
+// length and the rest mostly its multiples
+func IoCPeaks(iocs []float64) []int {
+	best := 0.0
+	for _, v := range iocs {
+		best = max(best, v)
+	}
+	if best < iocPeakMin {
+		return nil
+	}
+	var peaks []int
+	for i, v := range iocs {
+		if v >= 0.8*best {
+			peaks = append(peaks, i+1)
+		}
+	}
+	return peaks
+}
+
+// maxAutocorrShift is the longest repeating key the autocorrelation covers
+const maxAutocorrShift = 64
+
+// minAutocorrOverlap is the fewest byte pairs a shift needs to be measured
+const minAutocorrOverlap = 32
+
+// Autocorrelation is the share of positions where data matches itself
+// shifted by s, for s = 1 to maxShift. Under a repeating XOR key, bytes a
+// multiple of the key length apart match as often as the plaintext's bytes
+// do, and others about as rarely as random bytes, whatever the plaintext's
+// alphabet.
+func Autocorrelation(data []byte, maxShift int) []float64 {
+	var corr []float64
+	for s := 1; s <= maxShift && len(data)-s >= minAutocorrOverlap; s++ {
+		same := 0
+		for i := 0; i+s < len(data); i++ {
+			if data[i] == data[i+s] {
+				same++
+			}
+		}
+		corr = append(corr, float64(same)/float64(len(data)-s))
+	}
+	return corr
+}
+
+// AutocorrelationBaseline is the lower quartile of the shares: the level of
+// shifts that are no multiple of the key length, even for a 2-byte key
+func AutocorrelationBaseline(corr []float64) float64 {
+	if len(corr) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), corr...)
+	sort.Float64s(sorted)
+	return sorted[len(sorted)/4]
+}
+
+// AutocorrelationPeaks are the shifts (1-based) matching at least twice as
+// often as the baseline and 3 points above it; the first is the likely key
+// length and the rest mostly its multiples
+func AutocorrelationPeaks(corr []float64) []int {
+	if len(corr) < 4 {
+		return nil
+	}
+	base := AutocorrelationBaseline(corr)
+	var peaks []int
+	for i, v := range corr {
+		if v >= 2*base && v >= base+0.03 {
+			peaks = append(peaks, i+1)
+		}
+	}
+	return peaks
+}
+
+// Verdicts of the secondary test run on high-entropy data
+const (
+	VerdictEncrypted  = "Likely Encrypted"
+	VerdictCompressed = "Compressed"
+	VerdictStructured = "Structured Binary"
+	VerdictUnclear    = "Encrypted/Compressed"
+)
+
+const (
+	randomnessSample   = 1 << 20  // bytes examined
+	randomnessMinSize  = 1 << 10  // below this the chi-square says nothing
+	randomnessConclude = 32 << 10 // deflate output shows its bias from here on
+)
+
+// compressionOps are the Magic decompressors tried on high-entropy data
+var compressionOps = []string{"Gunzip", "Zlib Inflate", "Raw Inflate", "Bunzip2", "XZ Decompress"}
+
+// Randomness tells already-compressed data from likely ciphertext. Both have
+// near-maximal entropy, but compressor output (deflate, bzip2, zstd, image
+// codecs) keeps a measurable byte bias, while a good cipher's output passes
+// a uniformity test and cannot be compressed at all.
+type Randomness struct {
+	ChiSquare float64 // byte histogram vs uniform; ~255 for random data
+	Z         float64 // normal deviate of ChiSquare at 255 degrees of freedom
+	Deflate   float64 // deflated size / size; ~1.0 when incompressible
+	Verdict   string
+	Reason    string
+}
+
+// AnalyzeRandomness runs the compressed-vs-encrypted test
+func AnalyzeRandomness(data []byte) Randomness {
+	sample := data[:min(len(data), randomnessSample)]
+	var r Randomness
+	r.ChiSquare = ChiSquareUniform(sample)
+	const df = 255.0
+	// Wilson-Hilferty: (X/k)^(1/3) is close to normal
+	r.Z = (math.Cbrt(r.ChiSquare/df) - (1 - 2/(9*df))) / math.Sqrt(2/(9*df))
+	r.Deflate = DeflateRatio(sample)
+
+	for _, name := range compressionOps {
+		if _, err := OperationByName(name).Apply(data); err == nil || errors.Is(err, errDecompressLimit) {
+			r.Verdict, r.Reason = VerdictCompressed, "decodes as "+name
+			return r
+		}
+	}
+	switch {
+	case r.Deflate < 0.97:
+		r.Verdict, r.Reason = VerdictStructured, "deflate still shrinks it"
+	case len(sample) < randomnessMinSize:
+		r.Verdict, r.Reason = VerdictUnclear, "too short to test"
+	case r.Z > 4:
+		r.Verdict, r.Reason = VerdictCompressed, "byte frequencies are not uniform"
+	case len(sample) >= randomnessConclude:
+		r.Verdict, r.Reason = VerdictEncrypted, "uniform bytes, incompressible"
+	default:
+		r.Verdict, r.Reason = VerdictUnclear, "uniform, but too short to rule out compression"
+	}
+	return r
+}
+
+// ChiSquareUniform returns the chi-square statistic of the byte histogram
+// against a uniform distribution
+func ChiSquareUniform(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	var counts [256]float64
+	for _, b := range data {
+		counts[b]++
+	}
+	expected := float64(len(data)) / 256
+	chi := 0.0
+	for _, c := range counts {
+		chi += (c - expected) * (c - expected) / expected
+	}
+	return chi
+}
+
+// DeflateRatio returns the deflated size over the original size
+func DeflateRatio(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	w.Write(data)
+	w.Close()
+	return float64(buf.Len()) / float64(len(data))
+}
+
+// charsetClasses name the alphabets layers are usually written in,
+// narrowest first
+var charsetClasses = []struct {
+	name    string
+	matches func(b byte) bool
+}{
+	{"binary digits", func(b byte) bool { return b == '0' || b == '1' }},
+	{"decimal digits", func(b byte) bool { return b >= '0' && b <= '9' }},
+	{"hex digits", func(b byte) bool { return strings.IndexByte("0123456789abcdefABCDEF", b) >= 0 }},
+	{"Base32 alphabet", func(b byte) bool { return (b >= 'A' && b <= 'Z') || (b >= '2' && b <= '7') || b == '=' }},
+	{"letters", func(b byte) bool { return (b|0x20) >= 'a' && (b|0x20) <= 'z' }},
+	{"Base64 alphabet", func(b byte) bool {
+		return (b|0x20) >= 'a' && (b|0x20) <= 'z' || (b >= '0' && b <= '9') || strings.IndexByte("+/=-_", b) >= 0
+	}},
+	{"printable ASCII", func(b byte) bool { return b >= 32 && b <= 126 }},
+}
+
+// Charset names the narrowest alphabet data is written in; whitespace is
+// ignored
+func Charset(data []byte) string {
+	var seen [256]bool
+	n := 0
+	for _, b := range data {
+		if b == ' ' || b == '\n' || b == '\r' || b == '\t' {
+			continue
+		}
+		seen[b] = true
+		n++
+	}
+	if n == 0 {
+		return "whitespace"
+	}
+	for _, class := range charsetClasses {
+		all := true
+		for b, ok := range seen {
+			if ok && !class.matches(byte(b)) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return class.name
+		}
+	}
+	if utf8.Valid(data) {
+		return "UTF-8 text"
+	}
+	return "binary"
+}
+
+// distinctBytes counts the different byte values in data
+func distinctBytes(data []byte) int {
+	var seen [256]bool
+	n := 0
+	for _, b := range data {
+		if !seen[b] {
+			seen[b] = true
+			n++
+		}
+	}
+	return n
+}
+
+// LayerDiff summarises what a transform did: length, entropy, distinct
+// bytes and charset from the parent layer to the child
+func LayerDiff(parent, child []byte) string {
+	change := "n/a"
+	if len(parent) > 0 {
+		change = fmt.Sprintf("%+.0f%%", 100*float64(len(child)-len(parent))/float64(len(parent)))
+	}
+	charset := fmt.Sprintf("charset %s -> %s", Charset(parent), Charset(child))
+	if c := Charset(child); c == Charset(parent) {
+		charset = "charset unchanged (" + c + ")"
+	}
+	return fmt.Sprintf("%d -> %d bytes (%s), entropy %.2f -> %.2f, %d -> %d distinct bytes, %s",
+		len(parent), len(child), change, CalculateShannonEntropy(parent), CalculateShannonEntropy(child),
+		distinctBytes(parent), distinctBytes(child), charset)
+}
+
+// Common English bigrams and trigrams, for scoring plaintexts
+var (
+	bigramSet  = ngramSet(englishBigrams)
+	trigramSet = ngramSet(englishTrigrams)
+)
+
+// englishScore rates how English an unspaced plaintext is: common bigrams
+// and trigrams count for it, letter frequencies far from English
+// (chi-square) against it. Letter frequencies alone favour keys that put
+// frequent letters in place; n-grams alone favour repeats.
+func englishScore(s string) float64 {
+	lower := strings.ToLower(s)
+	return ngramShare(lower, bigramSet, 2) + 2*ngramShare(lower, trigramSet, 3) - englishChiSquare(lower)/200
+}
+
+// englishLetterScore is the englishScore of the letters of data alone, for
+// plaintexts with spaces and punctuation
+func englishLetterScore(data []byte) float64 {
+	var letters []byte
+	for _, b := range data {
+		if c := b | 0x20; c >= 'a' && c <= 'z' {
+			letters = append(letters, c)
+		}
+	}
+	return englishScore(string(letters))
+}
+
+func ngramSet(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, g := range strings.Fields(list) {
+		set[g] = true
+	}
+	return set
+}
+
+// ngramShare is the share of positions in s starting an n-gram of the set
+func ngramShare(s string, set map[string]bool, n int) float64 {
+	if len(s) < n {
+		return 0
+	}
+	hits := 0
+	for i := 0; i+n <= len(s); i++ {
+		if set[s[i:i+n]] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(s)-n+1)
+}
+
+// englishChiSquare measures how far the letter frequencies of s are from
+// English; lower is closer
+func englishChiSquare(s string) float64 {
+	var counts [26]float64
+	n := 0.0
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; c >= 'a' && c <= 'z' {
+			counts[c-'a']++
+			n++
+		}
+	}
+	if n == 0 {
+		return math.Inf(1)
+	}
+	chi := 0.0
+	for i, f := range letterFreq {
+		expected := n * f / 100
+		chi += (counts[i] - expected) * (counts[i] - expected) / expected
+	}
+	return chi
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// statsColumns heads the -stats-csv report
+var statsColumns = []string{"file", "size", "entropy", "ioc", "type", "layers", "solved", "flags"}
+
+// StatsRow is one analysed input in the -stats-csv report
+type StatsRow struct {
+	Name    string
+	Size    int
+	Entropy float64
+	IoC     float64
+	Type    string // identified type of the input itself
+	Layers  int    // layers the analysis entered, the input included
+	Solved  bool
+	Flags   []string // flags first found in this input
+}
+
+// StatsCSV writes the -stats-csv report: one row per input, flushed as
+// soon as it is written so an interrupted batch keeps the rows so far. A
+// .tsv file name switches to tab separation.
+type StatsCSV struct {
+	f *os.File
+	w *csv.Writer
+}
+
+// CreateStatsCSV creates (or truncates) the report and writes its header
+func CreateStatsCSV(path string) (*StatsCSV, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &StatsCSV{f: f, w: csv.NewWriter(f)}
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		s.w.Comma = '\t'
+	}
+	if err := s.write(statsColumns); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write appends one row. A nil *StatsCSV writes nothing.
+func (s *StatsCSV) Write(row StatsRow) error {
+	if s == nil {
+		return nil
+	}
+	solved := "no"
+	if row.Solved {
+		solved = "yes"
+	}
+	return s.write([]string{
+		row.Name,
+		strconv.Itoa(row.Size),
+		strconv.FormatFloat(row.Entropy, 'f', 2, 64),
+		strconv.FormatFloat(row.IoC, 'f', 2, 64),
+		row.Type,
+		strconv.Itoa(row.Layers),
+		solved,
+		strings.Join(row.Flags, " "),
+	})
+}
+
+func (s *StatsCSV) write(record []string) error {
+	if err := s.w.Write(record); err != nil {
+		return err
+	}
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// Close closes the report file
+func (s *StatsCSV) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.f.Close()
+}
+
+// analyzeInput runs one input through the orchestrator and, with
+// -stats-csv, adds its row to the report
+func analyzeInput(ctx context.Context, in Input, opts *Options) (string, bool) {
+	if opts.Stats == nil {
+		return orchestrate(ctx, in.Data, opts, nil)
+	}
+	// The layers this input reaches are read back from the progress record
+	if opts.progress == nil {
+		opts.progress = &Progress{}
+	}
+	layers, flags := len(opts.progress.layers), len(opts.flags)
+	output, solved := orchestrate(ctx, in.Data, opts, nil)
+
+	row := StatsRow{
+		Name:    in.Name,
+		Size:    len(in.Data),
+		Entropy: CalculateShannonEntropy(in.Data),
+		IoC:     CalculateIoC(in.Data),
+		Type:    "Unknown",
+		Solved:  solved,
+		Flags:   opts.flags[flags:],
+	}
+	if reached := opts.progress.layers[layers:]; len(reached) > 0 {
+		row.Type, row.Layers = reached[0].kind, len(reached)
+	}
+	if err := opts.Stats.Write(row); err != nil {
+		fmt.Printf("%sError writing stats: %v%s\n", ColorRed, err, ColorReset)
+	}
+	return output, solved
+}
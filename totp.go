@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// otpSecretText is a bare Base32 secret as authenticator apps show it:
+// either case, optionally grouped by spaces, optionally padded
+var otpSecretText = regexp.MustCompile(`^[A-Za-z2-7]{4}(?: ?[A-Za-z2-7]{2,4})+=*$`)
+
+// OTPParams are the parameters of an otpauth:// URI (or the defaults for
+// a bare secret)
+type OTPParams struct {
+	Type      string // "totp" or "hotp"
+	Account   string
+	Issuer    string
+	Secret    []byte
+	Algorithm string // "SHA1", "SHA256" or "SHA512"
+	Digits    int
+	Period    int    // seconds per TOTP code
+	Counter   uint64 // HOTP counter
+}
+
+// decodeOTPSecret decodes a Base32 secret, ignoring case, spaces and padding
+func decodeOTPSecret(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.ToUpper(strings.ReplaceAll(s, " ", "")), "=")
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+}
+
+// ParseOTPAuthURI reads an otpauth://totp/ or otpauth://hotp/ URI, the
+// format behind 2FA enrolment QR codes
+func ParseOTPAuthURI(s string) (*OTPParams, error) {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "otpauth" {
+		return nil, errors.New("not an otpauth URI")
+	}
+	p := &OTPParams{Type: strings.ToLower(u.Host), Algorithm: "SHA1", Digits: 6, Period: 30}
+	if p.Type != "totp" && p.Type != "hotp" {
+		return nil, fmt.Errorf("unknown OTP type %q", u.Host)
+	}
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		p.Issuer, p.Account = issuer, strings.TrimSpace(account)
+	} else {
+		p.Account = label
+	}
+
+	q := u.Query()
+	if p.Secret, err = decodeOTPSecret(q.Get("secret")); err != nil || len(p.Secret) == 0 {
+		return nil, errors.New("missing or invalid Base32 secret")
+	}
+	if issuer := q.Get("issuer"); issuer != "" {
+		p.Issuer = issuer
+	}
+	if alg := strings.ToUpper(q.Get("algorithm")); alg != "" {
+		if otpHash(alg) == nil {
+			return nil, fmt.Errorf("unknown algorithm %q", alg)
+		}
+		p.Algorithm = alg
+	}
+	if d := q.Get("digits"); d != "" {
+		if p.Digits, err = strconv.Atoi(d); err != nil || p.Digits < 6 || p.Digits > 10 {
+			return nil, fmt.Errorf("invalid digits %q", d)
+		}
+	}
+	if period := q.Get("period"); period != "" {
+		if p.Period, err = strconv.Atoi(period); err != nil || p.Period <= 0 {
+			return nil, fmt.Errorf("invalid period %q", period)
+		}
+	}
+	if c := q.Get("counter"); c != "" {
+		if p.Counter, err = strconv.ParseUint(c, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid counter %q", c)
+		}
+	}
+	return p, nil
+}
+
+// OTPSecret reads a bare Base32 TOTP secret. Base32 of readable text is
+// an encoding rather than a key, so only secrets decoding to binary of a
+// key's length (10 to 64 bytes) count.
+func OTPSecret(s string) (*OTPParams, bool) {
+	s = strings.TrimSpace(s)
+	if !otpSecretText.MatchString(s) || !strings.ContainsAny(s, "234567") {
+		return nil, false
+	}
+	secret, err := decodeOTPSecret(s)
+	if err != nil || len(secret) < 10 || len(secret) > 64 || isPrintable(secret) {
+		return nil, false
+	}
+	return &OTPParams{Type: "totp", Secret: secret, Algorithm: "SHA1", Digits: 6, Period: 30}, true
+}
+
+func otpHash(alg string) func() hash.Hash {
+	switch alg {
+	case "SHA1":
+		return sha1.New
+	case "SHA256":
+		return sha256.New
+	case "SHA512":
+		return sha512.New
+	}
+	return nil
+}
+
+// HOTP computes the RFC 4226 code for a counter
+func (p *OTPParams) HOTP(counter uint64) string {
+	mac := hmac.New(otpHash(p.Algorithm), p.Secret)
+	binary.Write(mac, binary.BigEndian, counter)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	code := uint64(binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff)
+	mod := uint64(1)
+	for range p.Digits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", p.Digits, code%mod)
+}
+
+// TOTP computes the RFC 6238 code for a moment, returning the time step
+// it belongs to
+func (p *OTPParams) TOTP(t time.Time) (string, uint64) {
+	step := uint64(t.Unix()) / uint64(p.Period)
+	return p.HOTP(step), step
+}
+
+// analyzeOTP prints the OTP parameters and the codes they give now
+func analyzeOTP(p *OTPParams, bare bool) {
+	if bare {
+		fmt.Printf("    Read as a bare TOTP secret with the usual defaults\n")
+	} else {
+		fmt.Printf("    otpauth URI (%s)\n", strings.ToUpper(p.Type))
+	}
+	if p.Issuer != "" {
+		fmt.Printf("    Issuer: %s\n", p.Issuer)
+	}
+	if p.Account != "" {
+		fmt.Printf("    Account: %s\n", p.Account)
+	}
+	fmt.Printf("    Secret: %s (%d bytes, hex %x)\n", base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(p.Secret), len(p.Secret), p.Secret)
+	if isPrintable(p.Secret) {
+		fmt.Printf("    %sSecret as text: %s%s\n", ColorGreen, p.Secret, ColorReset)
+	}
+	if p.Type == "hotp" {
+		fmt.Printf("    Algorithm: %s, Digits: %d, Counter: %d\n", p.Algorithm, p.Digits, p.Counter)
+		fmt.Printf("    %sCode at counter %d: %s%s\n", ColorGreen, p.Counter, p.HOTP(p.Counter), ColorReset)
+		fmt.Printf("    Code at counter %d: %s\n", p.Counter+1, p.HOTP(p.Counter+1))
+		return
+	}
+	now := time.Now()
+	code, step := p.TOTP(now)
+	left := p.Period - int(now.Unix()%int64(p.Period))
+	fmt.Printf("    Algorithm: %s, Digits: %d, Period: %ds\n", p.Algorithm, p.Digits, p.Period)
+	fmt.Printf("    %sCurrent code: %s (step %d, %ds left)%s\n", ColorGreen, code, step, left, ColorReset)
+	fmt.Printf("    Next code: %s\n", p.HOTP(step+1))
+}
+
+// otpSolver reads 2FA seeds: otpauth:// URIs, whose secret ends the chain,
+// and bare Base32 secrets, which are only a guess and leave the layer to
+// the later solvers
+type otpSolver struct{}
+
+func (otpSolver) Name() string { return "One-Time Passwords" }
+
+func (otpSolver) Applicable(l *Layer) bool {
+	if strings.HasPrefix(l.Type, "OTP Auth URI") {
+		return true
+	}
+	_, ok := OTPSecret(string(l.Data))
+	return ok && !strings.HasPrefix(l.Type, "File")
+}
+
+func (otpSolver) Solve(ctx context.Context, l *Layer) []Candidate {
+	if !strings.HasPrefix(l.Type, "OTP Auth URI") {
+		if p, ok := OTPSecret(string(l.Data)); ok {
+			analyzeOTP(p, true)
+		}
+		return nil
+	}
+	p, err := ParseOTPAuthURI(string(l.Data))
+	if err != nil {
+		fmt.Printf("    %sInvalid otpauth URI: %v%s\n", ColorYellow, err, ColorReset)
+		return nil
+	}
+	analyzeOTP(p, false)
+	return []Candidate{{Step: "OTP Secret (Base32)", Data: p.Secret, Final: true}}
+}
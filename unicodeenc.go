@@ -0,0 +1,173 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// rot8000Bounds toggles the ROT8000 rotation set on and off: starting at
+// each key, code points are rotated (true) or left alone (false). Controls,
+// spaces and surrogates stay put, everything else in the BMP rotates.
+var rot8000Bounds = []struct {
+	start rune
+	on    bool
+}{
+	{33, true}, {127, false}, {161, true}, {5760, false}, {5761, true}, {8192, false}, {8203, true},
+	{8232, false}, {8234, true}, {8239, false}, {8240, true}, {8287, false}, {8288, true},
+	{12288, false}, {12289, true}, {55296, false}, {57344, true},
+}
+
+// rot8000Runes lists the rotated code points in order; a code point moves
+// half the list along
+var rot8000Runes, rot8000Index = buildROT8000()
+
+func buildROT8000() ([]rune, map[rune]int) {
+	var runes []rune
+	on, next := false, 0
+	for r := rune(0); r < 0x10000; r++ {
+		if next < len(rot8000Bounds) && rot8000Bounds[next].start == r {
+			on = rot8000Bounds[next].on
+			next++
+		}
+		if on {
+			runes = append(runes, r)
+		}
+	}
+	index := make(map[rune]int, len(runes))
+	for i, r := range runes {
+		index[r] = i
+	}
+	return runes, index
+}
+
+// ROT8000 rotates every BMP character by half the rotatable set. It is its
+// own inverse: ASCII text becomes CJK-looking text and back.
+func ROT8000(s string) string {
+	half := len(rot8000Runes) / 2
+	return strings.Map(func(r rune) rune {
+		if i, ok := rot8000Index[r]; ok {
+			return rot8000Runes[(i+half)%len(rot8000Runes)]
+		}
+		return r
+	}, s)
+}
+
+// decodeROT8000 undoes ROT8000 on text that has been through it: nothing
+// but whitespace is left in ASCII, and something rotates back into it
+func decodeROT8000(data []byte) ([]byte, error) {
+	if !utf8.Valid(data) {
+		return nil, errNoChange
+	}
+	s := string(data)
+	half, rotated := len(rot8000Runes)/2, false
+	for _, r := range s {
+		switch {
+		case r < utf8.RuneSelf && !unicode.IsSpace(r):
+			return nil, errNoChange
+		case r >= utf8.RuneSelf:
+			// The second half of the set rotates back, its first 94 into ASCII
+			if i, ok := rot8000Index[r]; ok && i >= half && i < half+94 {
+				rotated = true
+			}
+		}
+	}
+	if !rotated {
+		return nil, errNoChange
+	}
+	return []byte(ROT8000(s)), nil
+}
+
+// base65536Blocks are the 256-code-point blocks of Base65536, in order: a
+// byte pair (a, b) is written as base65536Blocks[b] + a
+var base65536Blocks = buildBase65536Blocks()
+
+// base65536Pad is the block writing a final odd byte
+const base65536Pad = 0x1500
+
+func buildBase65536Blocks() []rune {
+	ranges := [][2]rune{
+		{0x3400, 0x4CFF}, {0x4E00, 0x9EFF}, {0xA100, 0xA3FF}, {0xA500, 0xA5FF}, {0x10600, 0x106FF},
+		{0x12000, 0x122FF}, {0x13000, 0x133FF}, {0x14400, 0x145FF}, {0x16800, 0x169FF}, {0x20000, 0x285FF},
+	}
+	var blocks []rune
+	for _, r := range ranges {
+		for b := r[0]; b < r[1]; b += 256 {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks
+}
+
+// EncodeBase65536 writes two bytes per character, as in qntm's base65536
+func EncodeBase65536(data []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(data); i += 2 {
+		if i+1 == len(data) {
+			sb.WriteRune(base65536Pad + rune(data[i]))
+			break
+		}
+		sb.WriteRune(base65536Blocks[data[i+1]] + rune(data[i]))
+	}
+	return sb.String()
+}
+
+// DecodeBase65536 reverses EncodeBase65536, ignoring whitespace. Any other
+// character outside the repertoire fails, as does a pad character before
+// the end.
+func DecodeBase65536(s string) ([]byte, error) {
+	block := make(map[rune]byte, len(base65536Blocks))
+	for i, b := range base65536Blocks {
+		block[b] = byte(i)
+	}
+	var out []byte
+	done := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		if done {
+			return nil, errNoChange
+		}
+		if r&^0xFF == base65536Pad {
+			out = append(out, byte(r))
+			done = true
+			continue
+		}
+		b, ok := block[r&^0xFF]
+		if !ok {
+			return nil, errNoChange
+		}
+		out = append(out, byte(r), b)
+	}
+	if len(out) == 0 {
+		return nil, errNoChange
+	}
+	return out, nil
+}
+
+// LooksBase2048 reports text made only of the non-ASCII letters below
+// U+1100 (Latin extensions, Greek, Cyrillic, Armenian, Hebrew, Arabic,
+// Indic, Thai, Georgian...) that Base2048 draws on, mixing several scripts
+// the way no natural text does
+func LooksBase2048(data []byte) bool {
+	if !utf8.Valid(data) || utf8.RuneCount(data) < 8 {
+		return false
+	}
+	scripts := make(map[string]bool)
+	for _, r := range strings.TrimSpace(string(data)) {
+		switch {
+		case r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z':
+		case r < utf8.RuneSelf || r >= 0x1100 || !unicode.IsLetter(r):
+			return false
+		default:
+			for name, table := range unicode.Scripts {
+				if unicode.Is(table, r) {
+					scripts[name] = true
+					break
+				}
+			}
+		}
+	}
+	return len(scripts) >= 3
+}
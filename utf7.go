@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+const utf7Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// utf7Direct are the characters UTF-7 writes as themselves (RFC 2152 set D
+// and whitespace); everything else goes into a shifted Base64 run
+const utf7Direct = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789'(),-./:? \t\r\n"
+
+// DecodeUTF7 decodes RFC 2152 UTF-7: "+" opens a run of modified Base64
+// holding UTF-16, closed by "-" (dropped) or any other non-Base64
+// character, and "+-" is a literal plus. Text without a single shifted run
+// does not apply.
+func DecodeUTF7(s string) ([]byte, error) {
+	var sb strings.Builder
+	shifted := false
+	for i := 0; i < len(s); i++ {
+		if s[i] != '+' {
+			sb.WriteByte(s[i])
+			continue
+		}
+		j := i + 1
+		for j < len(s) && strings.IndexByte(utf7Alphabet, s[j]) >= 0 {
+			j++
+		}
+		run := s[i+1 : j]
+		if run == "" {
+			if j < len(s) && s[j] == '-' {
+				sb.WriteByte('+')
+				i = j
+				continue
+			}
+			return nil, errNoChange // a bare "+" is not UTF-7
+		}
+		units, ok := decodeUTF7Run(run)
+		if !ok {
+			return nil, errNoChange
+		}
+		sb.WriteString(string(utf16.Decode(units)))
+		shifted = true
+		i = j - 1
+		if j < len(s) && s[j] == '-' {
+			i = j
+		}
+	}
+	if !shifted {
+		return nil, errNoChange
+	}
+	return []byte(sb.String()), nil
+}
+
+// decodeUTF7Run turns modified Base64 into UTF-16 units; the bits left over
+// must be fewer than six and zero
+func decodeUTF7Run(run string) ([]uint16, bool) {
+	var units []uint16
+	var acc uint32
+	bits := 0
+	for i := 0; i < len(run); i++ {
+		acc = acc<<6 | uint32(strings.IndexByte(utf7Alphabet, run[i]))
+		bits += 6
+		if bits >= 16 {
+			bits -= 16
+			units = append(units, uint16(acc>>bits))
+			acc &= 1<<bits - 1
+		}
+	}
+	return units, len(units) > 0 && bits < 6 && acc == 0
+}
+
+// EncodeUTF7 writes text as UTF-7, shifting every character outside the
+// direct set (so <script> becomes +ADw-script+AD4-)
+func EncodeUTF7(data []byte) []byte {
+	var sb strings.Builder
+	runes := []rune(string(data))
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		if r == '+' {
+			sb.WriteString("+-")
+			i++
+			continue
+		}
+		if strings.ContainsRune(utf7Direct, r) {
+			sb.WriteRune(r)
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && runes[j] != '+' && !strings.ContainsRune(utf7Direct, runes[j]) {
+			j++
+		}
+		var acc uint32
+		bits := 0
+		sb.WriteByte('+')
+		for _, u := range utf16.Encode(runes[i:j]) {
+			acc = acc<<16 | uint32(u)
+			bits += 16
+			for bits >= 6 {
+				bits -= 6
+				sb.WriteByte(utf7Alphabet[acc>>bits&63])
+			}
+		}
+		if bits > 0 {
+			sb.WriteByte(utf7Alphabet[acc<<(6-bits)&63])
+		}
+		sb.WriteByte('-')
+		i = j
+	}
+	return []byte(sb.String())
+}
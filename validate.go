@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// flagCommandTimeout bounds one run of a cmd: validator
+const flagCommandTimeout = 10 * time.Second
+
+// FlagValidator is a check every candidate flag must pass on top of the
+// flag format, so coincidental flag-like strings are not reported as
+// solutions. Validators come from -flag-validator:
+//
+//	re:REGEX      the candidate must match REGEX
+//	expr:EXPR     EXPR must hold, e.g. expr:len(inner) == 32 or
+//	              expr:crc32(inner[:-9]) == inner[-8:]
+//	cmd:COMMAND   COMMAND (run by sh, the candidate on stdin and in $FLAG)
+//	              must exit 0
+type FlagValidator struct {
+	Spec  string
+	check func(flag string) error
+}
+
+// ParseFlagValidator builds a validator from a -flag-validator spec
+func ParseFlagValidator(spec string) (FlagValidator, error) {
+	kind, arg, ok := strings.Cut(spec, ":")
+	if !ok || strings.TrimSpace(arg) == "" {
+		return FlagValidator{}, fmt.Errorf("want re:REGEX, expr:EXPR or cmd:COMMAND, got %q", spec)
+	}
+	v := FlagValidator{Spec: spec}
+	switch kind {
+	case "re":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return v, err
+		}
+		v.check = func(flag string) error {
+			if !re.MatchString(flag) {
+				return fmt.Errorf("does not match %s", arg)
+			}
+			return nil
+		}
+	case "expr":
+		expr, err := parseFlagExpr(arg)
+		if err != nil {
+			return v, err
+		}
+		v.check = func(flag string) error {
+			if !expr.eval(flag) {
+				return fmt.Errorf("fails %s", strings.TrimSpace(arg))
+			}
+			return nil
+		}
+	case "cmd":
+		v.check = commandCheck(arg)
+	default:
+		return v, fmt.Errorf("unknown validator kind %q (want re, expr or cmd)", kind)
+	}
+	return v, nil
+}
+
+// commandCheck runs command once per distinct candidate, since the same
+// candidate is checked again by every scorer that sees it
+func commandCheck(command string) func(string) error {
+	var mu sync.Mutex
+	verdicts := make(map[string]error)
+	return func(flag string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if err, ok := verdicts[flag]; ok {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), flagCommandTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Stdin = strings.NewReader(flag)
+		cmd.Env = append(os.Environ(), "FLAG="+flag)
+		err := cmd.Run()
+		if err != nil {
+			err = fmt.Errorf("rejected by %q (%v)", command, err)
+		}
+		verdicts[flag] = err
+		return err
+	}
+}
+
+// flagValidators is the flag.Value behind the repeatable -flag-validator
+type flagValidators []FlagValidator
+
+func (v *flagValidators) String() string { return "" }
+
+func (v *flagValidators) Set(spec string) error {
+	validator, err := ParseFlagValidator(spec)
+	if err != nil {
+		return err
+	}
+	*v = append(*v, validator)
+	return nil
+}
+
+// validateFlag runs a candidate through Config.FlagValidators, returning
+// the first failure
+func validateFlag(flag string) error {
+	for _, v := range Config.FlagValidators {
+		if err := v.check(flag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findFlags returns the flags in data that pass the validators
+func findFlags(data []byte) [][]byte {
+	var flags [][]byte
+	for _, match := range Config.FlagPattern.FindAll(data, -1) {
+		if validateFlag(string(match)) == nil {
+			flags = append(flags, match)
+		}
+	}
+	return flags
+}
+
+// hasFlag reports whether data holds a flag that passes the validators
+func hasFlag(data []byte) bool {
+	if len(Config.FlagValidators) == 0 {
+		return Config.FlagPattern.Match(data)
+	}
+	return len(findFlags(data)) > 0
+}
+
+// flagExpr is a parsed expr: validator, one comparison of two terms
+type flagExpr struct {
+	left, right flagTerm
+	op          string
+}
+
+// flagTerm evaluates to a string, or to an int for len() and literals
+type flagTerm struct {
+	isInt bool
+	eval  func(flag string) (string, int)
+}
+
+func (e flagExpr) eval(flag string) bool {
+	ls, li := e.left.eval(flag)
+	rs, ri := e.right.eval(flag)
+	if e.left.isInt {
+		switch e.op {
+		case "==":
+			return li == ri
+		case "!=":
+			return li != ri
+		case "<":
+			return li < ri
+		case "<=":
+			return li <= ri
+		case ">":
+			return li > ri
+		default:
+			return li >= ri
+		}
+	}
+	if e.op == "==" {
+		return strings.EqualFold(ls, rs)
+	}
+	return !strings.EqualFold(ls, rs)
+}
+
+// flagExprFuncs are the functions an expr: validator may call. Digests are
+// lower-case hex.
+var flagExprFuncs = map[string]func(string) string{
+	"md5":    func(s string) string { sum := md5.Sum([]byte(s)); return hex.EncodeToString(sum[:]) },
+	"sha1":   func(s string) string { sum := sha1.Sum([]byte(s)); return hex.EncodeToString(sum[:]) },
+	"sha256": func(s string) string { sum := sha256.Sum256([]byte(s)); return hex.EncodeToString(sum[:]) },
+	"crc32":  func(s string) string { return fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(s))) },
+	"lower":  strings.ToLower,
+	"upper":  strings.ToUpper,
+}
+
+// exprParser is a recursive-descent parser over the tokens of an expr:
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+var exprToken = regexp.MustCompile(`\s*("(?:[^"\\]|\\.)*"|-?\d+|[A-Za-z_][A-Za-z0-9_]*|==|!=|<=|>=|[<>()\[\]:])`)
+
+var exprName = regexp.MustCompile(`^[A-Za-z_]`)
+
+func parseFlagExpr(src string) (flagExpr, error) {
+	p := &exprParser{}
+	rest := src
+	for strings.TrimSpace(rest) != "" {
+		m := exprToken.FindStringSubmatchIndex(rest)
+		if m == nil || m[0] != 0 {
+			return flagExpr{}, fmt.Errorf("unexpected %q in expression", strings.TrimSpace(rest))
+		}
+		p.tokens = append(p.tokens, rest[m[2]:m[3]])
+		rest = rest[m[1]:]
+	}
+
+	left, err := p.term()
+	if err != nil {
+		return flagExpr{}, err
+	}
+	op := p.next()
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return flagExpr{}, fmt.Errorf("want a comparison (==, !=, <, <=, >, >=), got %q", op)
+	}
+	right, err := p.term()
+	if err != nil {
+		return flagExpr{}, err
+	}
+	if p.pos != len(p.tokens) {
+		return flagExpr{}, fmt.Errorf("unexpected %q after the comparison", p.tokens[p.pos])
+	}
+	if left.isInt != right.isInt {
+		return flagExpr{}, errors.New("cannot compare a number with a string")
+	}
+	if !left.isInt && op != "==" && op != "!=" {
+		return flagExpr{}, fmt.Errorf("strings only compare with == or !=")
+	}
+	return flagExpr{left: left, right: right, op: op}, nil
+}
+
+func (p *exprParser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	p.pos++
+	return p.tokens[p.pos-1]
+}
+
+func (p *exprParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+// term := primary [ "[" [int] ":" [int] "]" ]
+func (p *exprParser) term() (flagTerm, error) {
+	t, err := p.primary()
+	if err != nil || p.peek() != "[" {
+		return t, err
+	}
+	if t.isInt {
+		return t, errors.New("cannot slice a number")
+	}
+	p.next()
+	from, to, hasTo := 0, 0, false
+	if tok := p.peek(); tok != ":" {
+		if from, err = strconv.Atoi(p.next()); err != nil {
+			return t, fmt.Errorf("bad slice start %q", tok)
+		}
+	}
+	if p.next() != ":" {
+		return t, errors.New("want [from:to] slices")
+	}
+	if tok := p.peek(); tok != "]" {
+		if to, err = strconv.Atoi(p.next()); err != nil {
+			return t, fmt.Errorf("bad slice end %q", tok)
+		}
+		hasTo = true
+	}
+	if p.next() != "]" {
+		return t, errors.New("unclosed slice")
+	}
+	inner := t.eval
+	return flagTerm{eval: func(flag string) (string, int) {
+		s, _ := inner(flag)
+		lo, hi := sliceBound(from, len(s)), len(s)
+		if hasTo {
+			hi = sliceBound(to, len(s))
+		}
+		if lo > hi {
+			return "", 0
+		}
+		return s[lo:hi], 0
+	}}, nil
+}
+
+// sliceBound resolves a Python-style index, negative from the end, clamped
+func sliceBound(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	return max(0, min(i, n))
+}
+
+// primary := "flag" | "inner" | string | int | func "(" term ")"
+func (p *exprParser) primary() (flagTerm, error) {
+	tok := p.next()
+	switch {
+	case tok == "":
+		return flagTerm{}, errors.New("expression ends early")
+	case tok == "flag":
+		return flagTerm{eval: func(flag string) (string, int) { return flag, 0 }}, nil
+	case tok == "inner":
+		return flagTerm{eval: func(flag string) (string, int) { return flagInner(flag), 0 }}, nil
+	case tok[0] == '"':
+		s, err := strconv.Unquote(tok)
+		if err != nil {
+			return flagTerm{}, err
+		}
+		return flagTerm{eval: func(string) (string, int) { return s, 0 }}, nil
+	case tok[0] == '-' || (tok[0] >= '0' && tok[0] <= '9'):
+		n, _ := strconv.Atoi(tok)
+		return flagTerm{isInt: true, eval: func(string) (string, int) { return "", n }}, nil
+	}
+
+	fn, known := flagExprFuncs[tok]
+	if !exprName.MatchString(tok) {
+		return flagTerm{}, fmt.Errorf("unexpected %q in expression", tok)
+	}
+	if !known && tok != "len" {
+		return flagTerm{}, fmt.Errorf("unknown name %q (want flag, inner, len, md5, sha1, sha256, crc32, lower or upper)", tok)
+	}
+	if p.next() != "(" {
+		return flagTerm{}, fmt.Errorf("%s needs (", tok)
+	}
+	arg, err := p.term()
+	if err != nil {
+		return flagTerm{}, err
+	}
+	if p.next() != ")" {
+		return flagTerm{}, fmt.Errorf("%s( is not closed", tok)
+	}
+	if arg.isInt {
+		return flagTerm{}, fmt.Errorf("%s takes a string", tok)
+	}
+	if tok == "len" {
+		return flagTerm{isInt: true, eval: func(flag string) (string, int) { s, _ := arg.eval(flag); return "", len(s) }}, nil
+	}
+	return flagTerm{eval: func(flag string) (string, int) { s, _ := arg.eval(flag); return fn(s), 0 }}, nil
+}
+
+// flagInner is the text between a flag's outer braces
+func flagInner(flag string) string {
+	if i, j := strings.IndexByte(flag, '{'), strings.LastIndexByte(flag, '}'); i >= 0 && j > i {
+		return flag[i+1 : j]
+	}
+	return flag
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	watchInterval = time.Second
+	watchMaxSize  = 64 << 20 // larger files are reported but not analysed
+)
+
+// fileState is what a poll remembers about a file
+type fileState struct {
+	size int64
+	mod  time.Time
+}
+
+// Watcher polls a file or directory tree for new and changed files. A file
+// is only reported once it has looked the same on two consecutive polls, so
+// downloads still being written are not analysed half-finished.
+type Watcher struct {
+	root    string
+	seen    map[string]fileState // state last analysed (or present at start)
+	pending map[string]fileState // changed, waiting to settle
+}
+
+// NewWatcher snapshots root so only files that appear or change afterwards
+// are reported
+func NewWatcher(root string) (*Watcher, error) {
+	if _, err := os.Stat(root); err != nil {
+		return nil, err
+	}
+	w := &Watcher{root: root, pending: make(map[string]fileState)}
+	snapshot, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+	w.seen = snapshot
+	return w, nil
+}
+
+// Poll returns the files that changed and have since settled, in name order
+func (w *Watcher) Poll() ([]string, error) {
+	current, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+	var ready []string
+	for path, state := range current {
+		if old, ok := w.seen[path]; ok && old == state {
+			delete(w.pending, path)
+			continue
+		}
+		if prev, ok := w.pending[path]; ok && prev == state {
+			ready = append(ready, path)
+			w.seen[path] = state
+			delete(w.pending, path)
+			continue
+		}
+		w.pending[path] = state
+	}
+	for path := range w.seen {
+		if _, ok := current[path]; !ok {
+			delete(w.seen, path)
+		}
+	}
+	sort.Strings(ready)
+	return ready, nil
+}
+
+func (w *Watcher) scan() (map[string]fileState, error) {
+	states := make(map[string]fileState)
+	err := filepath.WalkDir(w.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // vanished or unreadable entries are skipped
+		}
+		if path != w.root && skipWatched(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		states[path] = fileState{size: info.Size(), mod: info.ModTime()}
+		return nil
+	})
+	return states, err
+}
+
+// skipWatched ignores hidden entries and in-progress browser downloads
+func skipWatched(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return true
+	}
+	for _, ext := range []string{".crdownload", ".part", ".partial", ".download", ".tmp"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch analyses every file that appears or changes under root until the
+// process is interrupted
+func Watch(root string, opts *Options) error {
+	w, err := NewWatcher(root)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s[+] Watching %s for new or changed files (Ctrl+C to stop)...%s\n", ColorBlue, root, ColorReset)
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ready, err := w.Poll()
+		if err != nil {
+			return err
+		}
+		for _, path := range ready {
+			analyzeWatched(path, opts)
+		}
+	}
+	return nil
+}
+
+func analyzeWatched(path string, opts *Options) {
+	fmt.Printf("\n%s[+] %s: %s%s\n", ColorBlue, time.Now().Format("15:04:05"), path, ColorReset)
+	info, err := os.Stat(path)
+	if err != nil {
+		fmt.Printf("    %sError reading file: %v%s\n", ColorRed, err, ColorReset)
+		return
+	}
+	if info.Size() > watchMaxSize {
+		fmt.Printf("    %sSkipped: %d bytes exceeds the %d byte watch limit.%s\n", ColorYellow, info.Size(), watchMaxSize, ColorReset)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("    %sError reading file: %v%s\n", ColorRed, err, ColorReset)
+		return
+	}
+	if skipKnownFile(opts, filepath.Base(path), data) {
+		return
+	}
+
+	// Flags are reported per file, not once per session
+	fileOpts := *opts
+	fileOpts.flagsSeen, fileOpts.flags = nil, nil
+	ctx, cancel := runContext(context.Background(), &fileOpts)
+	defer cancel()
+	if opts.Online {
+		reportFileReputation(ctx, path, data)
+	}
+	output, solved := analyzeInput(ctx, Input{Name: path, Data: trimInput(data)}, &fileOpts)
+	if ctx.Err() != nil {
+		fmt.Printf("%s[!] %s: analysis stopped early: %v%s\n", ColorYellow, filepath.Base(path), context.Cause(ctx), ColorReset)
+	}
+	if solved {
+		fmt.Printf("%s[=] %s solved: %s%s\n", ColorGreen, filepath.Base(path), displayData([]byte(output)), ColorReset)
+	} else {
+		fmt.Printf("%s[=] %s: no solution.%s\n", ColorYellow, filepath.Base(path), ColorReset)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// DefaultWordlist is a small embedded list of passwords that show up again
+// and again in CTF challenges. Use -w for a real wordlist (e.g. rockyou.txt).
+var DefaultWordlist = []string{
+	"password", "123456", "12345678", "qwerty", "letmein", "secret",
+	"admin", "root", "toor", "hunter2", "iloveyou", "princess",
+	"abc123", "password1", "passw0rd", "trustno1", "dragon", "monkey",
+	"welcome", "changeme", "test", "guest", "flag", "ctf", "pico",
+	"picoctf", "cylab", "hacker", "security", "sunshine",
+}
+
+// LoadWordlist reads one candidate password per line, skipping blank lines
+func LoadWordlist(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		word := strings.TrimRight(scanner.Text(), "\r")
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+	return words, scanner.Err()
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// English letters, bigrams and trigrams, most frequent first
+const (
+	englishLetterOrder = "etaoinshrdlcumwfgypbvkjxqz"
+	englishBigrams     = "th he in er an re nd at on nt ha es st en ed to it ou ea hi"
+	englishTrigrams    = "the and ing ent ion her for tha nth int ere tio ter est ers ati hat ate all eth hes ver his oft ith fth sth oth res ont"
+)
+
+// Workbench display bounds
+const (
+	workbenchWidth   = 64 // characters per preview line
+	workbenchRows    = 12 // preview line pairs shown
+	workbenchBigrams = 15 // bigrams listed
+	workbenchBar     = 20 // width of the longest frequency bar
+)
+
+// Workbench is an interactive assistant for monoalphabetic substitution:
+// it shows frequencies next to English and previews the plaintext under the
+// letter mappings pinned so far
+type Workbench struct {
+	ciphertext string
+	key        [26]byte // cipher letter -> plain letter, 0 when unknown
+}
+
+// NewWorkbench starts with no letters mapped
+func NewWorkbench(ciphertext string) *Workbench {
+	return &Workbench{ciphertext: ciphertext}
+}
+
+// Pin maps each cipher letter to the plain letter at the same position. A
+// plain letter already taken by another cipher letter is moved; the
+// displaced cipher letters are returned.
+func (w *Workbench) Pin(cipher, plain string) ([]byte, error) {
+	if len(cipher) != len(plain) {
+		return nil, fmt.Errorf("%q and %q differ in length", cipher, plain)
+	}
+	var displaced []byte
+	for i := 0; i < len(cipher); i++ {
+		c, p := letterIndex(cipher[i]), letterIndex(plain[i])
+		if c < 0 || p < 0 {
+			return displaced, fmt.Errorf("%q=%q: letters only", cipher[i], plain[i])
+		}
+		for other := range w.key {
+			if other != c && w.key[other] == byte('a'+p) {
+				w.key[other] = 0
+				displaced = append(displaced, byte('A'+other))
+			}
+		}
+		w.key[c] = byte('a' + p)
+	}
+	return displaced, nil
+}
+
+// Unpin forgets the mappings of the given cipher letters
+func (w *Workbench) Unpin(cipher string) {
+	for i := 0; i < len(cipher); i++ {
+		if c := letterIndex(cipher[i]); c >= 0 {
+			w.key[c] = 0
+		}
+	}
+}
+
+// Guess maps every unmapped cipher letter by frequency rank to the most
+// frequent English letter still free, as a starting point to correct
+func (w *Workbench) Guess() {
+	used := make(map[byte]bool)
+	for _, p := range w.key {
+		used[p] = true
+	}
+	free := []byte{}
+	for i := 0; i < len(englishLetterOrder); i++ {
+		if !used[englishLetterOrder[i]] {
+			free = append(free, englishLetterOrder[i])
+		}
+	}
+	for _, lc := range w.letterCounts() {
+		if len(free) == 0 {
+			break
+		}
+		if c := lc.letter - 'A'; w.key[c] == 0 {
+			w.key[c], free = free[0], free[1:]
+		}
+	}
+}
+
+// Plaintext applies the mappings, keeping case; unmapped letters become '_'
+func (w *Workbench) Plaintext() string {
+	return strings.Map(func(r rune) rune {
+		if r > 'z' {
+			return r
+		}
+		c := letterIndex(byte(r))
+		switch {
+		case c < 0:
+			return r
+		case w.key[c] == 0:
+			return '_'
+		case r >= 'a':
+			return rune(w.key[c])
+		default:
+			return rune(w.key[c] - 'a' + 'A')
+		}
+	}, w.ciphertext)
+}
+
+type letterCount struct {
+	letter byte // upper case
+	count  int
+}
+
+type bigramCount struct {
+	pair  string // upper case
+	count int
+}
+
+// letterCounts counts cipher letters case-insensitively, most frequent first
+func (w *Workbench) letterCounts() []letterCount {
+	var counts [26]int
+	for i := 0; i < len(w.ciphertext); i++ {
+		if c := letterIndex(w.ciphertext[i]); c >= 0 {
+			counts[c]++
+		}
+	}
+	var out []letterCount
+	for i, n := range counts {
+		if n > 0 {
+			out = append(out, letterCount{byte('A' + i), n})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].count > out[j].count })
+	return out
+}
+
+// bigramCounts counts adjacent cipher letter pairs within words
+func (w *Workbench) bigramCounts() []bigramCount {
+	counts := make(map[string]int)
+	upper := strings.ToUpper(w.ciphertext)
+	for i := 0; i+1 < len(upper); i++ {
+		if letterIndex(upper[i]) >= 0 && letterIndex(upper[i+1]) >= 0 {
+			counts[upper[i:i+2]]++
+		}
+	}
+	out := make([]bigramCount, 0, len(counts))
+	for pair, n := range counts {
+		out = append(out, bigramCount{pair, n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].count != out[j].count {
+			return out[i].count > out[j].count
+		}
+		return out[i].pair < out[j].pair
+	})
+	return out
+}
+
+// PrintFrequencies shows letter and bigram frequencies next to English
+func (w *Workbench) PrintFrequencies(out io.Writer) {
+	letters := w.letterCounts()
+	total := 0
+	for _, lc := range letters {
+		total += lc.count
+	}
+	fmt.Fprintf(out, "%s[+] Letter Frequencies (%d letters):%s\n", ColorBlue, total, ColorReset)
+	for i, lc := range letters {
+		mapped := ""
+		if p := w.key[lc.letter-'A']; p != 0 {
+			mapped = fmt.Sprintf("%s-> %c%s", ColorGreen, p, ColorReset)
+		}
+		english := ""
+		if i < len(englishLetterOrder) {
+			english = fmt.Sprintf("(English #%d: %c)", i+1, englishLetterOrder[i])
+		}
+		bar := strings.Repeat("#", max(1, lc.count*workbenchBar/letters[0].count))
+		fmt.Fprintf(out, "    %c %5.1f%% %-*s %-18s %s\n", lc.letter, 100*float64(lc.count)/float64(total), workbenchBar, bar, english, mapped)
+	}
+
+	var pairs []string
+	for i, b := range w.bigramCounts() {
+		if i == workbenchBigrams {
+			break
+		}
+		pairs = append(pairs, fmt.Sprintf("%s %d", b.pair, b.count))
+	}
+	fmt.Fprintf(out, "%s[+] Bigrams:%s %s\n", ColorBlue, ColorReset, strings.Join(pairs, ", "))
+	fmt.Fprintf(out, "    English: %s\n", englishBigrams)
+}
+
+// PrintKey shows the mapping table, cipher letters above plain letters
+func (w *Workbench) PrintKey(out io.Writer) {
+	var plain strings.Builder
+	for _, p := range w.key {
+		if p == 0 {
+			p = '.'
+		}
+		plain.WriteByte(p)
+	}
+	fmt.Fprintf(out, "    Cipher: ABCDEFGHIJKLMNOPQRSTUVWXYZ\n")
+	fmt.Fprintf(out, "    Plain:  %s\n", plain.String())
+}
+
+// PrintPreview shows ciphertext lines with the plaintext so far beneath
+func (w *Workbench) PrintPreview(out io.Writer) {
+	cipherLines := wrapRunes(w.ciphertext, workbenchWidth)
+	plainLines := wrapRunes(w.Plaintext(), workbenchWidth)
+	fmt.Fprintf(out, "%s[+] Preview:%s\n", ColorBlue, ColorReset)
+	for i := range cipherLines {
+		if i == workbenchRows {
+			fmt.Fprintf(out, "    ... %d more lines\n", len(cipherLines)-i)
+			break
+		}
+		fmt.Fprintf(out, "    %s\n    %s%s%s\n", cipherLines[i], ColorGreen, plainLines[i], ColorReset)
+	}
+	// Plaintext keeps byte offsets, so a flag is complete once every cipher
+	// letter under it is mapped
+	plain := w.Plaintext()
+	for _, loc := range Config.FlagPattern.FindAllStringIndex(plain, -1) {
+		if flag := plain[loc[0]:loc[1]]; w.mapped(loc[0], loc[1]) && validateFlag(flag) == nil {
+			fmt.Fprintf(out, "%s[!] Flag found: %s%s\n", ColorGreen, flag, ColorReset)
+		}
+	}
+}
+
+// mapped reports whether every cipher letter in ciphertext[lo:hi] is mapped
+func (w *Workbench) mapped(lo, hi int) bool {
+	for i := lo; i < hi; i++ {
+		if c := letterIndex(w.ciphertext[i]); c >= 0 && w.key[c] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// wrapRunes splits s on newlines, then into chunks of at most width runes
+func wrapRunes(s string, width int) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		r := []rune(line)
+		for len(r) > width {
+			lines = append(lines, string(r[:width]))
+			r = r[width:]
+		}
+		lines = append(lines, string(r))
+	}
+	return lines
+}
+
+const workbenchHelp = `    XYZ=the     map cipher letters X,Y,Z to plain t,h,e (several pairs may follow)
+    -XYZ        unmap cipher letters X,Y,Z
+    guess       fill unmapped letters by English frequency rank
+    crib WORD   pin WORD where it fits the ciphertext (lists the places if several do)
+    reset       unmap everything
+    freq        letter and bigram frequencies
+    key         the mapping table
+    (empty)     the preview again
+    quit        print the result and leave
+`
+
+// Run reads commands from in until "quit" or EOF, writing to out
+func (w *Workbench) Run(in io.Reader, out io.Writer) {
+	w.PrintFrequencies(out)
+	w.PrintPreview(out)
+	fmt.Fprintf(out, "Type \"help\" for commands.\n")
+
+	scanner := bufio.NewScanner(in)
+	for fmt.Fprint(out, "workbench> "); scanner.Scan(); fmt.Fprint(out, "workbench> ") {
+		cmd := strings.TrimSpace(scanner.Text())
+		switch {
+		case cmd == "":
+			w.PrintPreview(out)
+		case cmd == "help" || cmd == "?":
+			fmt.Fprint(out, workbenchHelp)
+		case cmd == "quit" || cmd == "q" || cmd == "exit":
+			w.finish(out)
+			return
+		case cmd == "freq":
+			w.PrintFrequencies(out)
+		case cmd == "key":
+			w.PrintKey(out)
+		case cmd == "guess":
+			w.Guess()
+			w.PrintPreview(out)
+		case strings.HasPrefix(cmd, "crib "):
+			w.PlaceCrib(strings.TrimSpace(cmd[len("crib "):]), out)
+			w.PrintPreview(out)
+		case cmd == "reset":
+			w.key = [26]byte{}
+			w.PrintPreview(out)
+		case strings.HasPrefix(cmd, "-"):
+			w.Unpin(cmd[1:])
+			w.PrintPreview(out)
+		case strings.Contains(cmd, "="):
+			for _, pair := range strings.Fields(cmd) {
+				cipher, plain, _ := strings.Cut(pair, "=")
+				displaced, err := w.Pin(cipher, plain)
+				if err != nil {
+					fmt.Fprintf(out, "    %sError: %v%s\n", ColorRed, err, ColorReset)
+					break
+				}
+				if len(displaced) > 0 {
+					fmt.Fprintf(out, "    %sUnmapped %s (letter reused).%s\n", ColorYellow, displaced, ColorReset)
+				}
+			}
+			w.PrintPreview(out)
+		default:
+			fmt.Fprintf(out, "    %sUnknown command %q; type \"help\".%s\n", ColorYellow, cmd, ColorReset)
+		}
+	}
+	fmt.Fprintln(out)
+	w.finish(out)
+}
+
+func (w *Workbench) finish(out io.Writer) {
+	fmt.Fprintf(out, "%s[+] Result:%s\n", ColorBlue, ColorReset)
+	w.PrintKey(out)
+	fmt.Fprintf(out, "    Plaintext: %s\n", w.Plaintext())
+}
+
+// CribFits lists the distinct cipher strings the crib could be the
+// plaintext of: letter runs with the crib's pattern of repeated letters
+// that agree with the mappings already pinned
+func (w *Workbench) CribFits(crib string) []string {
+	want := cribLetters(crib)
+	if want == nil {
+		return nil
+	}
+	var fits []string
+	seen := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(w.ciphertext, func(r rune) bool { return r > unicode.MaxASCII || letterIndex(byte(r)) < 0 }) {
+		word = strings.ToUpper(word)
+		for p := 0; p+len(want) <= len(word); p++ {
+			cand := word[p : p+len(want)]
+			if !seen[cand] && w.cribFits(cand, want) {
+				fits = append(fits, cand)
+			}
+			seen[cand] = true
+		}
+	}
+	return fits
+}
+
+// PlaceCrib pins the crib when exactly one place in the ciphertext fits it,
+// and otherwise says where it might go
+func (w *Workbench) PlaceCrib(crib string, out io.Writer) {
+	fits := w.CribFits(crib)
+	plain := strings.ToLower(string(cribLetters(crib)))
+	switch len(fits) {
+	case 0:
+		fmt.Fprintf(out, "    %sCrib %q fits nowhere under the current mappings.%s\n", ColorYellow, crib, ColorReset)
+	case 1:
+		w.Pin(fits[0], plain)
+		fmt.Fprintf(out, "    %sPinned %s=%s.%s\n", ColorGreen, fits[0], plain, ColorReset)
+	default:
+		fmt.Fprintf(out, "    Crib %q fits %d places: %s (pin one with XYZ=%s)\n", crib, len(fits), strings.Join(fits, ", "), plain)
+	}
+}
+
+// cribFits reports whether cipher letters cand can decrypt to want, with
+// each cipher letter standing for one plain letter and vice versa
+func (w *Workbench) cribFits(cand string, want []byte) bool {
+	key := w.key
+	for i := range want {
+		c, plain := cand[i]-'A', want[i]-'A'+'a'
+		switch key[c] {
+		case plain:
+			continue
+		case 0:
+			for other := range key {
+				if key[other] == plain {
+					return false
+				}
+			}
+			key[c] = plain
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// letterIndex returns 0-25 for an ASCII letter, -1 otherwise
+func letterIndex(b byte) int {
+	switch {
+	case b >= 'a' && b <= 'z':
+		return int(b - 'a')
+	case b >= 'A' && b <= 'Z':
+		return int(b - 'A')
+	}
+	return -1
+}
+
+// looksMonoalphabetic reports letter text whose IoC is still English-like,
+// which a substitution cipher preserves but polyalphabetic ciphers flatten
+func looksMonoalphabetic(data []byte, ioc float64) bool {
+	letters, other := letterCounts(data)
+	return letters >= 40 && letters >= 4*other && ioc >= 1.5
+}
+
+// letterCounts counts the letters of data and the other symbols, spaces
+// and line breaks aside
+func letterCounts(data []byte) (letters, other int) {
+	for _, b := range data {
+		switch {
+		case letterIndex(b) >= 0:
+			letters++
+		case b != ' ' && b != '\n' && b != '\r':
+			other++
+		}
+	}
+	return letters, other
+}
+
+// runWorkbench implements "cipher-sleuth workbench": the ciphertext comes
+// from -t or -f, since stdin carries the commands
+func runWorkbench(args []string) {
+	fs := flag.NewFlagSet("workbench", flag.ExitOnError)
+	textInput := fs.String("t", "", "Ciphertext to work on")
+	fileInput := fs.String("f", "", "File holding the ciphertext")
+	crib := fs.String("crib", "", "Word known to be in the plaintext, pinned where it fits")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: ./cipher-sleuth workbench [-crib WORD] -t <ciphertext> | -f <file>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var ciphertext string
+	switch {
+	case *textInput != "":
+		ciphertext = *textInput
+	case *fileInput != "":
+		data, err := os.ReadFile(*fileInput)
+		if err != nil {
+			fmt.Printf("%sError reading file: %v%s\n", ColorRed, err, ColorReset)
+			os.Exit(1)
+		}
+		ciphertext = strings.TrimSpace(string(data))
+	default:
+		fs.Usage()
+		os.Exit(1)
+	}
+	w := NewWorkbench(ciphertext)
+	if *crib != "" {
+		w.PlaceCrib(*crib, os.Stdout)
+	}
+	w.Run(os.Stdin, os.Stdout)
+}